@@ -2,17 +2,22 @@ package main
 
 import (
 	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
 	"VPS-control/internal/auth"
 	"VPS-control/internal/config"
 	"VPS-control/internal/database/postgresql"
 	"VPS-control/internal/database/sqlite3_local"
 	"VPS-control/internal/middleware"
 	"VPS-control/internal/nats"
+	"VPS-control/internal/password"
+	"VPS-control/internal/users"
 	"VPS-control/internal/vps"
 	"VPS-control/internal/vps/fail2ban"
 	"VPS-control/internal/vps/pm2"
+	"context"
 	_ "embed"
 	"net/http"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -31,10 +36,21 @@ type application struct {
 	authHdl    auth.Handler
 	pm2Hdl     pm2.Handler
 	f2bHdl     fail2ban.Handler
+	usersHdl   users.Handler
 	authJwt    auth.JwtProvider
 	authCookie auth.SetAuthCookie
 	tokenRepo  sqlite3_local.TokenStore
+	apiKeys    auth.APIKeyAuthenticator
+	permCache  auth.AccessResolver
+	jwtKeys    *auth.KeySet
 	sanitizer  middleware.Sanitizer
+	banWatcher *fail2ban.BanWatcher
+	escalator  *fail2ban.Escalator
+	blocklist  *fail2ban.BlocklistService
+	selfUnban  *fail2ban.SelfUnbanService
+	loginMon   *auth.LoginMonitorService
+	grants     *users.GrantService
+	jobs       sync.WaitGroup
 }
 
 func initApp(
@@ -52,24 +68,95 @@ func initApp(
 		logger.Fatal("Failed to connect to NATS", zap.Error(err))
 	}
 
-	userRepo := postgresql.NewUserRepository(pgDB.Pool, logger)
+	passwordPolicy, err := password.NewPolicy(cfg.Auth.Password)
+	if err != nil {
+		logger.Fatal("Failed to initialize password policy", zap.Error(err))
+	}
+	userRepo := postgresql.NewUserRepository(pgDB.Pool, password.NewHasher(cfg.Auth.Password.Hash), logger)
 	permRepo := postgresql.NewPermissionRepository(pgDB.Pool, logger)
+	twoFactorRepo := postgresql.NewTwoFactorRepository(pgDB.Pool, logger)
+	identityRepo := postgresql.NewIdentityRepository(pgDB.Pool, logger)
+	webAuthnRepo := postgresql.NewWebAuthnRepository(pgDB.Pool, logger)
+	grantRepo := postgresql.NewGrantRepository(pgDB.Pool, logger)
 	tokenRepo := sqlite3_local.NewTokenRepository(s3DB, logger)
+	refreshRepo := sqlite3_local.NewRefreshTokenRepository(s3DB, logger)
+	apiKeyRepo := sqlite3_local.NewAPIKeyRepository(s3DB, logger)
+	auditRepo := sqlite3_local.NewAuditRepository(s3DB, logger)
+	auditSvc := audit.NewService(auditRepo, logger)
+	blockRepo := sqlite3_local.NewBlockRepository(s3DB, logger)
+	banEventRepo := sqlite3_local.NewBanEventRepository(s3DB, logger)
+	importedBanRepo := sqlite3_local.NewImportedBanRepository(s3DB, logger)
+	tempIgnoreRepo := sqlite3_local.NewTempIgnoreRepository(s3DB, logger)
+	loginAttemptRepo := sqlite3_local.NewLoginAttemptRepository(s3DB, logger)
+	lockoutRepo := sqlite3_local.NewAccountLockoutRepository(s3DB, logger)
 	baseVpsSvc := vps.NewBaseVpsService()
-	sanitizer := middleware.NewInputSanitizer(logger)
 
 	broker := nats.NewNatsBroker(natsConn)
 	authJwt := auth.NewAuthJwtService(cfg, logger)
 	authCookie := auth.NewAuthCookieService(cfg)
-	authMgr := auth.NewAuthManagerService(userRepo, permRepo)
-	authHdl := auth.NewHandler(authMgr, authJwt, authCookie, tokenRepo, logger)
+	permCache := auth.NewPermissionCache(cfg.Auth.PermissionCache, permRepo, broker, logger)
+	if err := permCache.Listen(broker); err != nil {
+		logger.Fatal("Failed to subscribe to permission invalidations", zap.Error(err))
+	}
+	authMgr := auth.NewAuthManagerService(cfg.Auth.Sessions, userRepo, permCache, passwordPolicy)
+	authFailureLog := auth.NewFailureLogService(cfg.Fail2Ban.AuthJail, logger)
+	authTwoFactor := auth.NewTwoFactorService(cfg.Auth.TwoFactor, twoFactorRepo, permCache, logger)
+	authChallenges := auth.NewChallengeService(cfg.Auth.TwoFactor.ChallengeTTL, cfg.Auth.TwoFactor.MaxAttempts)
+	authRefresh := auth.NewRefreshService(cfg.JWT.RefreshTTL, refreshRepo, logger)
+	authAPIKeys := auth.NewAPIKeyService(cfg.Auth.APIKeys, apiKeyRepo, authMgr, logger)
+	var authOIDC auth.OIDCAuthenticator
+	if cfg.Auth.OIDC.Enabled {
+		authOIDC = auth.NewOIDCService(cfg.Auth.OIDC, userRepo, identityRepo, permCache, logger)
+	}
+	var authWebAuthn auth.WebAuthnManager
+	if cfg.Auth.WebAuthn.Enabled {
+		authWebAuthn = auth.NewWebAuthnService(cfg.Auth.WebAuthn, webAuthnRepo, logger)
+	}
+	var loginAlerts auth.LoginAlertNotifier
+	if alerts := auth.NewLoginAlertService(cfg.Auth.LoginAlerts, logger); alerts.Enabled() {
+		loginAlerts = alerts
+	}
+	loginMonitor := auth.NewLoginMonitorService(cfg.Auth.Lockout, loginAttemptRepo, lockoutRepo, broker, loginAlerts, logger)
+	var authIntrospection auth.Introspector
+	if cfg.Auth.Introspection.Enabled {
+		introspection := auth.NewIntrospectionService(
+			cfg.Auth.Introspection, authJwt, tokenRepo, authAPIKeys, permCache, logger,
+		)
+		err := introspection.Listen(broker, cfg.Auth.Introspection.Subject, cfg.Auth.Introspection.Queue)
+		if err != nil {
+			logger.Fatal("Failed to subscribe to token introspection requests", zap.Error(err))
+		}
+		authIntrospection = introspection
+	}
+	authHdl := auth.NewHandler(
+		authMgr, authJwt, authCookie, tokenRepo, authRefresh, authFailureLog, authTwoFactor, authChallenges,
+		authAPIKeys, authOIDC, authWebAuthn, loginMonitor, authIntrospection, auditSvc, logger,
+	)
+
+	usersSvc := users.NewManagementService(userRepo, tokenRepo, passwordPolicy, logger)
+	rolesSvc := users.NewRoleService(permCache, userRepo, logger)
+	grantsSvc := users.NewGrantService(cfg.Auth.Grants, grantRepo, permCache, auditSvc, logger)
+	usersHdl := users.NewHandler(usersSvc, rolesSvc, grantsSvc, auditSvc, logger)
 
 	pm2ListSvc := pm2.NewListService(baseVpsSvc)
 	pm2ControlSvc := pm2.NewControlService(pm2ListSvc)
 	pm2Hdl := pm2.NewHandler(pm2ListSvc, pm2ControlSvc, logger)
 
 	f2bControlSvc := fail2ban.NewControlService(baseVpsSvc, logger)
-	f2bHdl := fail2ban.NewHandler(f2bControlSvc, logger)
+	f2bConfigWriter := fail2ban.NewConfigWriter(cfg.Fail2Ban, tempIgnoreRepo, logger)
+	f2bRegexTester := fail2ban.NewRegexTester(cfg.Fail2Ban, logger)
+	f2bEscalator := fail2ban.NewEscalator(cfg.Sanitizer.Escalation, f2bControlSvc, blockRepo, broker, auditSvc, logger)
+	f2bBanWatcher := fail2ban.NewBanWatcher(cfg.Fail2Ban.BanWatcher, f2bControlSvc, banEventRepo, broker, logger)
+	f2bBlocklist := fail2ban.NewBlocklistService(
+		cfg.Fail2Ban.Blocklist.Import, f2bControlSvc, f2bControlSvc, importedBanRepo, logger,
+	)
+	f2bSelfUnban := fail2ban.NewSelfUnbanService(cfg.Fail2Ban.SelfUnban, f2bControlSvc, tempIgnoreRepo, auditSvc, logger)
+	f2bHdl := fail2ban.NewHandler(
+		f2bControlSvc, f2bConfigWriter, f2bRegexTester, f2bEscalator, f2bBanWatcher, f2bBlocklist, f2bSelfUnban,
+		auditSvc, logger,
+	)
+
+	sanitizer := middleware.NewInputSanitizer(logger, f2bEscalator)
 
 	return &application{
 		cfg:        cfg,
@@ -81,10 +168,41 @@ func initApp(
 		authHdl:    authHdl,
 		pm2Hdl:     pm2Hdl,
 		f2bHdl:     f2bHdl,
+		usersHdl:   usersHdl,
 		authJwt:    authJwt,
 		authCookie: authCookie,
 		tokenRepo:  tokenRepo,
+		apiKeys:    authAPIKeys,
+		permCache:  permCache,
+		jwtKeys:    authJwt.Keys(),
 		sanitizer:  sanitizer,
+		banWatcher: f2bBanWatcher,
+		escalator:  f2bEscalator,
+		blocklist:  f2bBlocklist,
+		selfUnban:  f2bSelfUnban,
+		loginMon:   loginMonitor,
+		grants:     grantsSvc,
+	}
+}
+
+// startBackgroundJobs launches periodic workers; they stop when ctx is cancelled.
+func (app *application) startBackgroundJobs(ctx context.Context) {
+	if app.cfg.Fail2Ban.BanWatcher.Enabled {
+		app.jobs.Go(func() { app.banWatcher.Run(ctx) })
+	}
+	if app.cfg.Sanitizer.Escalation.Enabled {
+		app.jobs.Go(func() { app.escalator.Run(ctx) })
+	}
+	app.jobs.Go(func() { app.selfUnban.Run(ctx) })
+	app.jobs.Go(func() { app.loginMon.Run(ctx) })
+	app.jobs.Go(func() { app.grants.Run(ctx) })
+	if app.cfg.Fail2Ban.Blocklist.Import.Enabled {
+		app.jobs.Go(func() { app.blocklist.Run(ctx) })
+	}
+	if app.jwtKeys != nil && app.cfg.JWT.RotationInterval > 0 {
+		// Retired keys stay published until the last token they signed expires.
+		retain := app.cfg.JWT.TTL + time.Minute
+		app.jobs.Go(func() { app.jwtKeys.Run(ctx, app.cfg.JWT.RotationInterval, retain) })
 	}
 }
 
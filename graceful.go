@@ -13,11 +13,15 @@ import (
 )
 
 func (app *application) Run() error {
-	router := setupRouter(app.logger)
+	router := setupRouter(app.cfg.Server, app.logger)
 	app.registerRoutes(router)
 
 	srv := app.newServer(router)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	app.startBackgroundJobs(jobsCtx)
+
 	serverErrors := make(chan error, 1)
 
 	go func() {
@@ -47,6 +51,8 @@ func (app *application) Run() error {
 		}
 	}
 
+	stopJobs()
+	app.jobs.Wait()
 	app.closeResources()
 
 	if err := app.logger.Sync(); err != nil {
@@ -9,39 +9,147 @@ import (
 )
 
 type errorRegistry struct {
-	INTERNAL_ERROR           *AppError
-	INVALID_REQUEST          *AppError
-	DATABASE_ERROR           *AppError
-	INVALID_CREDENTIALS      *AppError
-	TOKEN_EXPIRED            *AppError
-	PERMISSION_DENIED        *AppError
-	RATE_LIMIT_EXCEEDED      *AppError
-	PM2_PROCESS_NOT_FOUND    *AppError
-	ACTION_NOT_ALLOWED       *AppError
-	PROCESS_ALREADY_RUNNING  *AppError
-	PROCESS_ALREADY_STOPPED  *AppError
-	MALICIOUS_INPUT_DETECTED *AppError
-	FAIL2BAN_JAIL_NOT_FOUND  *AppError
-	FAIL2BAN_IP_NOT_BANNED   *AppError
-	FAIL2BAN_EXECUTION_ERROR *AppError
+	INTERNAL_ERROR                 *AppError
+	INVALID_REQUEST                *AppError
+	DATABASE_ERROR                 *AppError
+	INVALID_CREDENTIALS            *AppError
+	TOKEN_EXPIRED                  *AppError
+	PERMISSION_DENIED              *AppError
+	RATE_LIMIT_EXCEEDED            *AppError
+	PM2_PROCESS_NOT_FOUND          *AppError
+	ACTION_NOT_ALLOWED             *AppError
+	PROCESS_ALREADY_RUNNING        *AppError
+	PROCESS_ALREADY_STOPPED        *AppError
+	MALICIOUS_INPUT_DETECTED       *AppError
+	FAIL2BAN_JAIL_NOT_FOUND        *AppError
+	FAIL2BAN_IP_NOT_BANNED         *AppError
+	FAIL2BAN_EXECUTION_ERROR       *AppError
+	FAIL2BAN_CONFIG_WRITE_ERROR    *AppError
+	FAIL2BAN_SERVER_UNAVAILABLE    *AppError
+	FAIL2BAN_RELOAD_FAILED         *AppError
+	FAIL2BAN_JAIL_ALREADY_RUNNING  *AppError
+	FAIL2BAN_FILTER_NOT_FOUND      *AppError
+	FAIL2BAN_INVALID_REGEX         *AppError
+	FAIL2BAN_LOG_NOT_ALLOWED       *AppError
+	FAIL2BAN_BLOCK_NOT_FOUND       *AppError
+	IP_BLOCKED                     *AppError
+	FAIL2BAN_IMPORT_NOT_CONFIGURED *AppError
+	FAIL2BAN_IMPORT_IN_PROGRESS    *AppError
+	USER_NOT_FOUND                 *AppError
+	USER_ALREADY_EXISTS            *AppError
+	USER_SELF_MODIFICATION         *AppError
+	ROLE_NOT_FOUND                 *AppError
+	ROLE_ALREADY_EXISTS            *AppError
+	PERMISSION_NOT_FOUND           *AppError
+	TWO_FACTOR_CHALLENGE_INVALID   *AppError
+	INVALID_TWO_FACTOR_CODE        *AppError
+	TWO_FACTOR_ALREADY_ENABLED     *AppError
+	TWO_FACTOR_NOT_ENABLED         *AppError
+	TWO_FACTOR_REQUIRED            *AppError
+	REFRESH_TOKEN_INVALID          *AppError
+	REFRESH_TOKEN_REUSED           *AppError
+	API_KEY_INVALID                *AppError
+	API_KEY_SCOPE_EXCEEDED         *AppError
+	API_KEY_LIMIT_REACHED          *AppError
+	API_KEY_NOT_FOUND              *AppError
+	API_KEY_NOT_ALLOWED            *AppError
+	OIDC_DISABLED                  *AppError
+	OIDC_STATE_INVALID             *AppError
+	OIDC_LOGIN_FAILED              *AppError
+	OIDC_PROVIDER_UNAVAILABLE      *AppError
+	OIDC_USER_NOT_PROVISIONED      *AppError
+	OIDC_ACCOUNT_CONFLICT          *AppError
+	OIDC_USERNAME_INVALID          *AppError
+	WEBAUTHN_DISABLED              *AppError
+	WEBAUTHN_CHALLENGE_INVALID     *AppError
+	WEBAUTHN_VERIFICATION_FAILED   *AppError
+	WEBAUTHN_CREDENTIAL_EXISTS     *AppError
+	WEBAUTHN_CREDENTIAL_NOT_FOUND  *AppError
+	WEBAUTHN_LIMIT_REACHED         *AppError
+	REAUTH_REQUIRED                *AppError
+	ACCOUNT_LOCKED                 *AppError
+	CSRF_TOKEN_INVALID             *AppError
+	PASSWORD_POLICY_VIOLATION      *AppError
+	INVALID_CLIENT                 *AppError
+	INTROSPECTION_DISABLED         *AppError
+	GRANT_NOT_FOUND                *AppError
+	GRANT_EXPIRY_INVALID           *AppError
+	ACCESS_REQUEST_NOT_FOUND       *AppError
+	ACCESS_REQUEST_ALREADY_DECIDED *AppError
+	ACCESS_REQUEST_DUPLICATE       *AppError
+	ACCESS_REQUEST_SELF_APPROVAL   *AppError
 }
 
 var Errors = &errorRegistry{
-	INTERNAL_ERROR:           &AppError{Code: "INTERNAL_ERROR", Status: 500},
-	INVALID_REQUEST:          &AppError{Code: "INVALID_REQUEST", Status: 400},
-	DATABASE_ERROR:           &AppError{Code: "DATABASE_ERROR", Status: 500},
-	INVALID_CREDENTIALS:      &AppError{Code: "INVALID_CREDENTIALS", Status: 401},
-	TOKEN_EXPIRED:            &AppError{Code: "TOKEN_EXPIRED", Status: 401},
-	PERMISSION_DENIED:        &AppError{Code: "PERMISSION_DENIED", Status: 403},
-	RATE_LIMIT_EXCEEDED:      &AppError{Code: "RATE_LIMIT_EXCEEDED", Status: 429},
-	PM2_PROCESS_NOT_FOUND:    &AppError{Code: "PM2_PROCESS_NOT_FOUND", Status: 404},
-	ACTION_NOT_ALLOWED:       &AppError{Code: "ACTION_NOT_ALLOWED", Status: 403},
-	PROCESS_ALREADY_RUNNING:  &AppError{Code: "PROCESS_ALREADY_RUNNING", Status: 409},
-	PROCESS_ALREADY_STOPPED:  &AppError{Code: "PROCESS_ALREADY_STOPPED", Status: 409},
-	MALICIOUS_INPUT_DETECTED: &AppError{Code: "MALICIOUS_INPUT_DETECTED", Status: 400},
-	FAIL2BAN_JAIL_NOT_FOUND:  &AppError{Code: "FAIL2BAN_JAIL_NOT_FOUND", Status: 404},
-	FAIL2BAN_IP_NOT_BANNED:   &AppError{Code: "FAIL2BAN_IP_NOT_BANNED", Status: 404},
-	FAIL2BAN_EXECUTION_ERROR: &AppError{Code: "FAIL2BAN_EXECUTION_ERROR", Status: 500},
+	INTERNAL_ERROR:                 &AppError{Code: "INTERNAL_ERROR", Status: 500},
+	INVALID_REQUEST:                &AppError{Code: "INVALID_REQUEST", Status: 400},
+	DATABASE_ERROR:                 &AppError{Code: "DATABASE_ERROR", Status: 500},
+	INVALID_CREDENTIALS:            &AppError{Code: "INVALID_CREDENTIALS", Status: 401},
+	TOKEN_EXPIRED:                  &AppError{Code: "TOKEN_EXPIRED", Status: 401},
+	PERMISSION_DENIED:              &AppError{Code: "PERMISSION_DENIED", Status: 403},
+	RATE_LIMIT_EXCEEDED:            &AppError{Code: "RATE_LIMIT_EXCEEDED", Status: 429},
+	PM2_PROCESS_NOT_FOUND:          &AppError{Code: "PM2_PROCESS_NOT_FOUND", Status: 404},
+	ACTION_NOT_ALLOWED:             &AppError{Code: "ACTION_NOT_ALLOWED", Status: 403},
+	PROCESS_ALREADY_RUNNING:        &AppError{Code: "PROCESS_ALREADY_RUNNING", Status: 409},
+	PROCESS_ALREADY_STOPPED:        &AppError{Code: "PROCESS_ALREADY_STOPPED", Status: 409},
+	MALICIOUS_INPUT_DETECTED:       &AppError{Code: "MALICIOUS_INPUT_DETECTED", Status: 400},
+	FAIL2BAN_JAIL_NOT_FOUND:        &AppError{Code: "FAIL2BAN_JAIL_NOT_FOUND", Status: 404},
+	FAIL2BAN_IP_NOT_BANNED:         &AppError{Code: "FAIL2BAN_IP_NOT_BANNED", Status: 404},
+	FAIL2BAN_EXECUTION_ERROR:       &AppError{Code: "FAIL2BAN_EXECUTION_ERROR", Status: 500},
+	FAIL2BAN_CONFIG_WRITE_ERROR:    &AppError{Code: "FAIL2BAN_CONFIG_WRITE_ERROR", Status: 500},
+	FAIL2BAN_SERVER_UNAVAILABLE:    &AppError{Code: "FAIL2BAN_SERVER_UNAVAILABLE", Status: 503},
+	FAIL2BAN_RELOAD_FAILED:         &AppError{Code: "FAIL2BAN_RELOAD_FAILED", Status: 500},
+	FAIL2BAN_JAIL_ALREADY_RUNNING:  &AppError{Code: "FAIL2BAN_JAIL_ALREADY_RUNNING", Status: 409},
+	FAIL2BAN_FILTER_NOT_FOUND:      &AppError{Code: "FAIL2BAN_FILTER_NOT_FOUND", Status: 404},
+	FAIL2BAN_INVALID_REGEX:         &AppError{Code: "FAIL2BAN_INVALID_REGEX", Status: 400},
+	FAIL2BAN_LOG_NOT_ALLOWED:       &AppError{Code: "FAIL2BAN_LOG_NOT_ALLOWED", Status: 403},
+	FAIL2BAN_BLOCK_NOT_FOUND:       &AppError{Code: "FAIL2BAN_BLOCK_NOT_FOUND", Status: 404},
+	IP_BLOCKED:                     &AppError{Code: "IP_BLOCKED", Status: 403},
+	FAIL2BAN_IMPORT_NOT_CONFIGURED: &AppError{Code: "FAIL2BAN_IMPORT_NOT_CONFIGURED", Status: 409},
+	FAIL2BAN_IMPORT_IN_PROGRESS:    &AppError{Code: "FAIL2BAN_IMPORT_IN_PROGRESS", Status: 409},
+	USER_NOT_FOUND:                 &AppError{Code: "USER_NOT_FOUND", Status: 404},
+	USER_ALREADY_EXISTS:            &AppError{Code: "USER_ALREADY_EXISTS", Status: 409},
+	USER_SELF_MODIFICATION:         &AppError{Code: "USER_SELF_MODIFICATION", Status: 403},
+	ROLE_NOT_FOUND:                 &AppError{Code: "ROLE_NOT_FOUND", Status: 404},
+	ROLE_ALREADY_EXISTS:            &AppError{Code: "ROLE_ALREADY_EXISTS", Status: 409},
+	PERMISSION_NOT_FOUND:           &AppError{Code: "PERMISSION_NOT_FOUND", Status: 400},
+	TWO_FACTOR_CHALLENGE_INVALID:   &AppError{Code: "TWO_FACTOR_CHALLENGE_INVALID", Status: 401},
+	INVALID_TWO_FACTOR_CODE:        &AppError{Code: "INVALID_TWO_FACTOR_CODE", Status: 401},
+	TWO_FACTOR_ALREADY_ENABLED:     &AppError{Code: "TWO_FACTOR_ALREADY_ENABLED", Status: 409},
+	TWO_FACTOR_NOT_ENABLED:         &AppError{Code: "TWO_FACTOR_NOT_ENABLED", Status: 409},
+	TWO_FACTOR_REQUIRED:            &AppError{Code: "TWO_FACTOR_REQUIRED", Status: 403},
+	REFRESH_TOKEN_INVALID:          &AppError{Code: "REFRESH_TOKEN_INVALID", Status: 401},
+	REFRESH_TOKEN_REUSED:           &AppError{Code: "REFRESH_TOKEN_REUSED", Status: 401},
+	API_KEY_INVALID:                &AppError{Code: "API_KEY_INVALID", Status: 401},
+	API_KEY_SCOPE_EXCEEDED:         &AppError{Code: "API_KEY_SCOPE_EXCEEDED", Status: 403},
+	API_KEY_LIMIT_REACHED:          &AppError{Code: "API_KEY_LIMIT_REACHED", Status: 409},
+	API_KEY_NOT_FOUND:              &AppError{Code: "API_KEY_NOT_FOUND", Status: 404},
+	API_KEY_NOT_ALLOWED:            &AppError{Code: "API_KEY_NOT_ALLOWED", Status: 403},
+	OIDC_DISABLED:                  &AppError{Code: "OIDC_DISABLED", Status: 404},
+	OIDC_STATE_INVALID:             &AppError{Code: "OIDC_STATE_INVALID", Status: 400},
+	OIDC_LOGIN_FAILED:              &AppError{Code: "OIDC_LOGIN_FAILED", Status: 401},
+	OIDC_PROVIDER_UNAVAILABLE:      &AppError{Code: "OIDC_PROVIDER_UNAVAILABLE", Status: 502},
+	OIDC_USER_NOT_PROVISIONED:      &AppError{Code: "OIDC_USER_NOT_PROVISIONED", Status: 403},
+	OIDC_ACCOUNT_CONFLICT:          &AppError{Code: "OIDC_ACCOUNT_CONFLICT", Status: 409},
+	OIDC_USERNAME_INVALID:          &AppError{Code: "OIDC_USERNAME_INVALID", Status: 403},
+	WEBAUTHN_DISABLED:              &AppError{Code: "WEBAUTHN_DISABLED", Status: 404},
+	WEBAUTHN_CHALLENGE_INVALID:     &AppError{Code: "WEBAUTHN_CHALLENGE_INVALID", Status: 400},
+	WEBAUTHN_VERIFICATION_FAILED:   &AppError{Code: "WEBAUTHN_VERIFICATION_FAILED", Status: 401},
+	WEBAUTHN_CREDENTIAL_EXISTS:     &AppError{Code: "WEBAUTHN_CREDENTIAL_EXISTS", Status: 409},
+	WEBAUTHN_CREDENTIAL_NOT_FOUND:  &AppError{Code: "WEBAUTHN_CREDENTIAL_NOT_FOUND", Status: 404},
+	WEBAUTHN_LIMIT_REACHED:         &AppError{Code: "WEBAUTHN_LIMIT_REACHED", Status: 409},
+	REAUTH_REQUIRED:                &AppError{Code: "REAUTH_REQUIRED", Status: 403},
+	ACCOUNT_LOCKED:                 &AppError{Code: "ACCOUNT_LOCKED", Status: 429},
+	CSRF_TOKEN_INVALID:             &AppError{Code: "CSRF_TOKEN_INVALID", Status: 403},
+	PASSWORD_POLICY_VIOLATION:      &AppError{Code: "PASSWORD_POLICY_VIOLATION", Status: 400},
+	INVALID_CLIENT:                 &AppError{Code: "INVALID_CLIENT", Status: 401},
+	INTROSPECTION_DISABLED:         &AppError{Code: "INTROSPECTION_DISABLED", Status: 404},
+	GRANT_NOT_FOUND:                &AppError{Code: "GRANT_NOT_FOUND", Status: 404},
+	GRANT_EXPIRY_INVALID:           &AppError{Code: "GRANT_EXPIRY_INVALID", Status: 400},
+	ACCESS_REQUEST_NOT_FOUND:       &AppError{Code: "ACCESS_REQUEST_NOT_FOUND", Status: 404},
+	ACCESS_REQUEST_ALREADY_DECIDED: &AppError{Code: "ACCESS_REQUEST_ALREADY_DECIDED", Status: 409},
+	ACCESS_REQUEST_DUPLICATE:       &AppError{Code: "ACCESS_REQUEST_DUPLICATE", Status: 409},
+	ACCESS_REQUEST_SELF_APPROVAL:   &AppError{Code: "ACCESS_REQUEST_SELF_APPROVAL", Status: 403},
 }
 
 var log *zap.Logger
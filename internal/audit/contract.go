@@ -0,0 +1,15 @@
+package audit
+
+type Recorder interface {
+	Record(entry Entry)
+}
+
+type Entry struct {
+	ActorID int
+	Actor   string
+	Action  string
+	Target  string
+	Details string
+	IP      string
+	Success bool
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+var _ Recorder = (*Service)(nil)
+
+// Service persists audit entries to the local database. Recording never fails
+// the calling request: storage errors are logged and the entry is dropped.
+type Service struct {
+	store  sqlite3_local.AuditStore
+	logger *zap.Logger
+}
+
+func NewService(
+	store sqlite3_local.AuditStore,
+	logger *zap.Logger,
+) *Service {
+	return &Service{
+		store:  store,
+		logger: logger.Named("audit"),
+	}
+}
+
+func (s *Service) Record(entry Entry) {
+	fields := []zap.Field{
+		zap.String("action", entry.Action),
+		zap.String("target", entry.Target),
+		zap.String("details", entry.Details),
+		zap.String("actor", entry.Actor),
+		zap.Int("actor_id", entry.ActorID),
+		zap.String("ip", entry.IP),
+		zap.Bool("success", entry.Success),
+	}
+	s.logger.Info("Audit event", fields...)
+
+	err := s.store.RecordAudit(
+		sqlite3_local.AuditEntity{
+			ActorID:       int64(entry.ActorID),
+			ActorUsername: entry.Actor,
+			Action:        entry.Action,
+			Target:        entry.Target,
+			Details:       entry.Details,
+			IP:            entry.IP,
+			Success:       entry.Success,
+		},
+	)
+	if err != nil {
+		s.logger.Error("Failed to persist audit event", append(fields, zap.Error(err))...)
+	}
+}
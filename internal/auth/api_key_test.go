@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+type fakeAPIKeyStore struct {
+	keys []sqlite3_local.APIKeyEntity
+}
+
+func (f *fakeAPIKeyStore) SaveAPIKey(key sqlite3_local.APIKeyEntity) (int64, error) {
+	key.ID = int64(len(f.keys) + 1)
+	f.keys = append(f.keys, key)
+	return key.ID, nil
+}
+
+func (f *fakeAPIKeyStore) GetAPIKeyByPrefix(prefix string) (*sqlite3_local.APIKeyEntity, error) {
+	for i := range f.keys {
+		if f.keys[i].Prefix == prefix {
+			cp := f.keys[i]
+			return &cp, nil
+		}
+	}
+	return nil, sqlite3_local.ErrAPIKeyNotFound
+}
+
+func (f *fakeAPIKeyStore) GetUserAPIKeys(userID int64) ([]sqlite3_local.APIKeyEntity, error) {
+	var res []sqlite3_local.APIKeyEntity
+	for _, k := range f.keys {
+		if k.UserID == userID {
+			res = append(res, k)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeAPIKeyStore) TouchAPIKey(id, usedAt int64, ip string) error {
+	f.keys[id-1].LastUsedAt = usedAt
+	f.keys[id-1].LastUsedIP = ip
+	return nil
+}
+
+func (f *fakeAPIKeyStore) RevokeAPIKey(id, userID int64) error {
+	if id < 1 || int(id) > len(f.keys) || f.keys[id-1].UserID != userID || f.keys[id-1].Revoked {
+		return sqlite3_local.ErrAPIKeyNotFound
+	}
+	f.keys[id-1].Revoked = true
+	return nil
+}
+
+type fakeAuthManager struct {
+	AuthManager
+	active      bool
+	permissions []string
+}
+
+func (f *fakeAuthManager) GetUserPermissions(context.Context, int) ([]string, error) {
+	return f.permissions, nil
+}
+
+func (f *fakeAuthManager) Reload(_ context.Context, userID int) (*AuthResult, error) {
+	if !f.active {
+		return nil, postgresql.ErrUserInactive
+	}
+	return &AuthResult{
+		User:        &postgresql.UserResponseDTO{ID: userID, Username: "alice", Active: true},
+		Permissions: f.permissions,
+	}, nil
+}
+
+func newTestAPIKeyService() (*APIKeyService, *fakeAPIKeyStore, *fakeAuthManager) {
+	store := &fakeAPIKeyStore{}
+	am := &fakeAuthManager{active: true, permissions: []string{PermPM2ViewBasic, PermPM2ControlRestart}}
+	cfg := config.APIKeysConfig{DefaultTTL: 24 * time.Hour, MaxTTL: 48 * time.Hour, MaxPerUser: 2}
+	return NewAPIKeyService(cfg, store, am, zap.NewNop()), store, am
+}
+
+func TestAPIKeyCreateAndAuthenticate(t *testing.T) {
+	svc, store, am := newTestAPIKeyService()
+
+	created, err := svc.Create(
+		context.Background(), 1, "alice",
+		CreateAPIKeyRequest{Name: "ci", Permissions: []string{PermPM2ControlRestart, PermPM2ControlRestart}},
+	)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") {
+		t.Errorf("key %q does not start with prefix %q", created.Key, created.Prefix)
+	}
+	if strings.Contains(store.keys[0].SecretHash, strings.TrimPrefix(created.Key, created.Prefix+"_")) {
+		t.Error("secret must not be stored in clear")
+	}
+	if len(created.Permissions) != 1 {
+		t.Errorf("permissions = %v, want deduplicated scope", created.Permissions)
+	}
+
+	claims, err := svc.Authenticate(context.Background(), created.Key, "203.0.113.7")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !claims.HasPermission(PermPM2ControlRestart) || claims.HasPermission(PermPM2ViewBasic) {
+		t.Errorf("permissions = %v, want only the key scope", claims.Permissions)
+	}
+	if store.keys[0].LastUsedIP != "203.0.113.7" {
+		t.Errorf("last used ip = %q, want 203.0.113.7", store.keys[0].LastUsedIP)
+	}
+
+	t.Run(
+		"owner lost permission", func(t *testing.T) {
+			am.permissions = []string{PermPM2ViewBasic}
+			defer func() { am.permissions = []string{PermPM2ViewBasic, PermPM2ControlRestart} }()
+
+			claims, err := svc.Authenticate(context.Background(), created.Key, "")
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if len(claims.Permissions) != 0 {
+				t.Errorf("permissions = %v, want none", claims.Permissions)
+			}
+		},
+	)
+
+	t.Run(
+		"owner inactive", func(t *testing.T) {
+			am.active = false
+			defer func() { am.active = true }()
+
+			if _, err := svc.Authenticate(context.Background(), created.Key, ""); !errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+				t.Errorf("err = %v, want API_KEY_INVALID", err)
+			}
+		},
+	)
+
+	t.Run(
+		"wrong secret", func(t *testing.T) {
+			if _, err := svc.Authenticate(context.Background(), created.Prefix+"_WRONG", ""); !errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+				t.Errorf("err = %v, want API_KEY_INVALID", err)
+			}
+		},
+	)
+
+	t.Run(
+		"expired", func(t *testing.T) {
+			svc.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+			defer func() { svc.now = time.Now }()
+
+			if _, err := svc.Authenticate(context.Background(), created.Key, ""); !errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+				t.Errorf("err = %v, want API_KEY_INVALID", err)
+			}
+		},
+	)
+
+	t.Run(
+		"revoked", func(t *testing.T) {
+			if err := svc.Revoke(2, created.ID); !errors.Is(err, apierror.Errors.API_KEY_NOT_FOUND) {
+				t.Errorf("foreign revoke err = %v, want API_KEY_NOT_FOUND", err)
+			}
+			if err := svc.Revoke(1, created.ID); err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+			if _, err := svc.Authenticate(context.Background(), created.Key, ""); !errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+				t.Errorf("err = %v, want API_KEY_INVALID", err)
+			}
+		},
+	)
+}
+
+func TestAPIKeyWildcardScope(t *testing.T) {
+	svc, _, am := newTestAPIKeyService()
+	am.permissions = []string{"*", "!user.*"}
+
+	created, err := svc.Create(
+		context.Background(), 1, "alice",
+		CreateAPIKeyRequest{Name: "ops", Permissions: []string{"*", "!pm2.control.stop"}},
+	)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	claims, err := svc.Authenticate(context.Background(), created.Key, "")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	for perm, want := range map[string]bool{
+		PermPM2ControlRestart: true,
+		PermPM2ControlStop:    false,
+		PermUserDelete:        false,
+	} {
+		if got := claims.HasPermission(perm); got != want {
+			t.Errorf("HasPermission(%q) = %v, want %v (permissions %v)", perm, got, want, claims.Permissions)
+		}
+	}
+}
+
+func TestAPIKeyInspect(t *testing.T) {
+	svc, store, _ := newTestAPIKeyService()
+	created, err := svc.Create(context.Background(), 1, "alice", CreateAPIKeyRequest{Name: "ci"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.Authenticate(context.Background(), created.Key, "203.0.113.7"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	used := store.keys[0]
+
+	// Проверка ключа сторонним сервисом не считается его использованием
+	svc.now = func() time.Time { return time.Now().Add(time.Hour) }
+	claims, err := svc.Inspect(context.Background(), created.Key)
+	if err != nil || claims.Username != "alice" {
+		t.Fatalf("Inspect() = %+v, %v", claims, err)
+	}
+	if store.keys[0].LastUsedAt != used.LastUsedAt || store.keys[0].LastUsedIP != "203.0.113.7" {
+		t.Errorf("Inspect touched the key: %+v", store.keys[0])
+	}
+
+	if _, err := svc.Inspect(context.Background(), created.Key+"x"); !errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+		t.Errorf("Inspect(wrong secret) error = %v, want API_KEY_INVALID", err)
+	}
+}
+
+func TestAPIKeyCreateRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		req  CreateAPIKeyRequest
+		want *apierror.AppError
+	}{
+		{
+			"permission not held",
+			CreateAPIKeyRequest{Name: "bot", Permissions: []string{PermUserDelete}},
+			apierror.Errors.API_KEY_SCOPE_EXCEEDED,
+		},
+		{
+			"lifetime above max",
+			CreateAPIKeyRequest{Name: "bot", Permissions: []string{PermPM2ViewBasic}, ExpiresInDays: 3},
+			apierror.Errors.INVALID_REQUEST,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				svc, _, _ := newTestAPIKeyService()
+				_, err := svc.Create(context.Background(), 1, "alice", tt.req)
+				var appErr *apierror.AppError
+				if !errors.As(err, &appErr) || appErr.Code != tt.want.Code {
+					t.Errorf("err = %v, want %v", err, tt.want.Code)
+				}
+			},
+		)
+	}
+
+	t.Run(
+		"limit reached", func(t *testing.T) {
+			svc, _, _ := newTestAPIKeyService()
+			req := CreateAPIKeyRequest{Name: "bot", Permissions: []string{PermPM2ViewBasic}}
+			for range 2 {
+				if _, err := svc.Create(context.Background(), 1, "alice", req); err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+			}
+			_, err := svc.Create(context.Background(), 1, "alice", req)
+			var appErr *apierror.AppError
+			if !errors.As(err, &appErr) || appErr.Code != "API_KEY_LIMIT_REACHED" {
+				t.Errorf("err = %v, want API_KEY_LIMIT_REACHED", err)
+			}
+		},
+	)
+}
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		raw string
+		ok  bool
+	}{
+		{"vpsk_abcdefgh_SECRET", true},
+		{"vpsk_abc_SECRET", false},
+		{"vpsk_abcdefgh_", false},
+		{"abcdefgh_SECRET", false},
+		{"eyJhbGciOi.x.y", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.raw, func(t *testing.T) {
+				if _, _, ok := ParseAPIKey(tt.raw); ok != tt.ok {
+					t.Errorf("ParseAPIKey(%q) ok = %v, want %v", tt.raw, ok, tt.ok)
+				}
+			},
+		)
+	}
+}
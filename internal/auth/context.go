@@ -11,6 +11,7 @@ const (
 	CtxRoles       = "roles"
 	CtxPermissions = "permissions"
 	CtxClaims      = "claims"
+	CtxAPIKey      = "api_key"
 )
 
 func GetClaims(c *gin.Context) (*CustomClaims, bool) {
@@ -31,6 +32,26 @@ func GetUserID(c *gin.Context) (int, bool) {
 	return userID, ok
 }
 
+func GetUsername(c *gin.Context) (string, bool) {
+	name, exists := c.Get(CtxUsername)
+	if !exists {
+		return "", false
+	}
+	val, ok := name.(string)
+	return val, ok
+}
+
+// GetActor returns the authenticated user for audit purposes.
+// Missing values fall back to zero ID and "UNKNOWN".
+func GetActor(c *gin.Context) (int, string) {
+	userID, _ := GetUserID(c)
+	username, ok := GetUsername(c)
+	if !ok || username == "" {
+		username = "UNKNOWN"
+	}
+	return userID, username
+}
+
 func GetJTI(c *gin.Context) (string, bool) {
 	jti, exists := c.Get(CtxJTI)
 	if !exists {
@@ -39,3 +60,9 @@ func GetJTI(c *gin.Context) (string, bool) {
 	val, ok := jti.(string)
 	return val, ok
 }
+
+// IsAPIKeyRequest reports whether the request was authenticated with an API
+// key instead of a login session.
+func IsAPIKeyRequest(c *gin.Context) bool {
+	return c.GetBool(CtxAPIKey)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"VPS-control/internal/database/sqlite3_local"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,8 +13,34 @@ type Handler interface {
 	Login(c *gin.Context)
 	Verify(c *gin.Context)
 	Logout(c *gin.Context)
+	Refresh(c *gin.Context)
+	JWKS(c *gin.Context)
 	GetSessions(c *gin.Context)
 	RevokeSession(c *gin.Context)
+	GetMySessions(c *gin.Context)
+	RevokeMySession(c *gin.Context)
+	VerifyTwoFactor(c *gin.Context)
+	GetTwoFactorStatus(c *gin.Context)
+	EnrollTwoFactor(c *gin.Context)
+	ConfirmTwoFactor(c *gin.Context)
+	DisableTwoFactor(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+	ListAPIKeys(c *gin.Context)
+	CreateAPIKey(c *gin.Context)
+	RevokeAPIKey(c *gin.Context)
+	OIDCLogin(c *gin.Context)
+	OIDCCallback(c *gin.Context)
+	BeginPasskeyRegistration(c *gin.Context)
+	FinishPasskeyRegistration(c *gin.Context)
+	ListPasskeys(c *gin.Context)
+	DeletePasskey(c *gin.Context)
+	BeginPasskeyLogin(c *gin.Context)
+	FinishPasskeyLogin(c *gin.Context)
+	BeginReauthPasskey(c *gin.Context)
+	Reauthenticate(c *gin.Context)
+	GetLoginAttempts(c *gin.Context)
+	ChangePassword(c *gin.Context)
+	Introspect(c *gin.Context)
 }
 
 type JwtProvider interface {
@@ -20,6 +48,7 @@ type JwtProvider interface {
 	ValidateToken(tokenString string) (*CustomClaims, error)
 	GetIssuer() string
 	GetTTL() time.Duration
+	JWKS() JWKSResponse
 }
 
 type SetAuthCookie interface {
@@ -29,6 +58,29 @@ type SetAuthCookie interface {
 	)
 	GetAuthCookie(c *gin.Context) (string, error)
 	ClearAuthCookie(c *gin.Context)
+	SetRefreshCookie(
+		c *gin.Context,
+		token string,
+	)
+	GetRefreshCookie(c *gin.Context) (string, error)
+	ClearRefreshCookie(c *gin.Context)
+	SetOIDCStateCookie(
+		c *gin.Context,
+		state string,
+	)
+	GetOIDCStateCookie(c *gin.Context) (string, error)
+	ClearOIDCStateCookie(c *gin.Context)
+	SetCSRFCookie(
+		c *gin.Context,
+		token string,
+	)
+	ClearCSRFCookie(c *gin.Context)
+}
+
+type FailureLogger interface {
+	LogFailure(
+		ip, username, reason string,
+	)
 }
 
 type AuthManager interface {
@@ -45,4 +97,192 @@ type AuthManager interface {
 		userID int,
 		permission string,
 	) (bool, error)
+	Reload(
+		ctx context.Context,
+		userID int,
+	) (*AuthResult, error)
+	ChangePassword(
+		ctx context.Context,
+		userID int,
+		username, current, next string,
+	) error
+}
+
+type PasswordPolicy interface {
+	Validate(
+		username, raw string,
+	) []string
+}
+
+type TwoFactorManager interface {
+	LoginRequirement(
+		ctx context.Context,
+		userID int,
+	) (enabled, required bool, err error)
+	Status(
+		ctx context.Context,
+		userID int,
+	) (*TwoFactorStatusResponse, error)
+	BeginEnrollment(
+		ctx context.Context,
+		userID int,
+		username string,
+	) (*TOTPEnrollmentResponse, error)
+	ConfirmEnrollment(
+		ctx context.Context,
+		userID int,
+		code string,
+	) ([]string, error)
+	Verify(
+		ctx context.Context,
+		userID int,
+		code, recoveryCode string,
+	) error
+	Disable(
+		ctx context.Context,
+		userID int,
+		code, recoveryCode string,
+	) error
+	RegenerateRecoveryCodes(
+		ctx context.Context,
+		userID int,
+		code string,
+	) ([]string, error)
+}
+
+type ChallengeStore interface {
+	Create(
+		result *AuthResult,
+		enrollment bool,
+		device string,
+	) *LoginChallenge
+	Get(id string) (*LoginChallenge, bool)
+	Fail(id string) int
+	Delete(id string)
+}
+
+type RefreshManager interface {
+	Issue(
+		userID int,
+		username, accessJTI string,
+		amr []string,
+	) (string, error)
+	Lookup(raw string) (*sqlite3_local.RefreshTokenEntity, error)
+	Rotate(
+		raw string,
+		current *sqlite3_local.RefreshTokenEntity,
+		accessJTI string,
+	) (string, error)
+	RevokeFamily(familyID string) error
+	RevokeByAccessJTI(jti string) error
+}
+
+type APIKeyAuthenticator interface {
+	Authenticate(
+		ctx context.Context,
+		raw, ip string,
+	) (*CustomClaims, error)
+}
+
+type APIKeyInspector interface {
+	Inspect(
+		ctx context.Context,
+		raw string,
+	) (*CustomClaims, error)
+}
+
+type APIKeyManager interface {
+	APIKeyAuthenticator
+	Create(
+		ctx context.Context,
+		userID int,
+		username string,
+		req CreateAPIKeyRequest,
+	) (*APIKeyCreatedResponse, error)
+	List(userID int) ([]APIKeyResponse, error)
+	Revoke(
+		userID int,
+		id int64,
+	) error
+}
+
+type WebAuthnManager interface {
+	BeginRegistration(
+		ctx context.Context,
+		userID int,
+		username string,
+	) (*WebAuthnCreationOptions, error)
+	FinishRegistration(
+		ctx context.Context,
+		userID int,
+		req WebAuthnRegisterRequest,
+	) (*PasskeyResponse, error)
+	BeginLogin(
+		ctx context.Context,
+		userID int,
+	) (*WebAuthnRequestOptions, error)
+	FinishLogin(
+		ctx context.Context,
+		userID int,
+		assertion WebAuthnAssertion,
+	) (*WebAuthnLogin, error)
+	HasPasskeys(
+		ctx context.Context,
+		userID int,
+	) (bool, error)
+	List(
+		ctx context.Context,
+		userID int,
+	) ([]PasskeyResponse, error)
+	Delete(
+		ctx context.Context,
+		userID, id int,
+	) error
+}
+
+type OIDCAuthenticator interface {
+	AuthCodeURL(ctx context.Context) (redirectURL, state string, err error)
+	Authenticate(
+		ctx context.Context,
+		state, code string,
+	) (*OIDCLogin, error)
+	SuccessURL() string
+}
+
+type LoginMonitor interface {
+	Check(attempt LoginAttempt) (time.Duration, error)
+	Failed(attempt LoginAttempt)
+	Succeeded(attempt LoginAttempt)
+	Attempts(filter sqlite3_local.LoginAttemptFilter) ([]LoginAttemptResponse, error)
+}
+
+type Introspector interface {
+	AuthenticateClient(
+		id, secret string,
+	) bool
+	Introspect(
+		ctx context.Context,
+		token string,
+	) (*IntrospectionResponse, error)
+}
+
+type LoginAlertNotifier interface {
+	Notify(
+		ctx context.Context,
+		event LoginAnomalyEvent,
+	) error
+}
+
+type AccessResolver interface {
+	Access(
+		ctx context.Context,
+		userID int,
+	) (*UserAccess, error)
+}
+
+type eventPublisher interface {
+	Publish(
+		subject string,
+		data any,
+	) error
 }
@@ -15,13 +15,20 @@ import (
 func newTestCookieService() *AuthCookieService {
 	cfg := &config.Config{
 		Cookie: config.CookieConfig{
-			Name:     "test_token",
-			Secure:   false,
-			HttpOnly: true,
-			SameSite: "strict",
+			Name:        "test_token",
+			RefreshName: "test_refresh",
+			RefreshPath: "/api/auth",
+			CSRFName:    "test_csrf",
+			Secure:      false,
+			HttpOnly:    true,
+			SameSite:    "strict",
 		},
 		JWT: config.JWTConfig{
-			TTL: time.Hour,
+			TTL:        time.Hour,
+			RefreshTTL: 24 * time.Hour,
+		},
+		Auth: config.AuthConfig{
+			OIDC: config.OIDCConfig{StateTTL: 10 * time.Minute},
 		},
 	}
 	return NewAuthCookieService(cfg)
@@ -71,6 +78,33 @@ func TestSetAuthCookie(t *testing.T) {
 	}
 }
 
+func TestSetCSRFCookie(t *testing.T) {
+	svc := newTestCookieService()
+	c, w := setupTestContext()
+
+	svc.SetCSRFCookie(c, "csrf-value")
+
+	var found *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "test_csrf" {
+			found = cookie
+			break
+		}
+	}
+	if found == nil {
+		t.Fatal("cookie 'test_csrf' not found")
+	}
+	if found.Value != "csrf-value" {
+		t.Errorf("cookie value = %q, want %q", found.Value, "csrf-value")
+	}
+	if found.HttpOnly {
+		t.Error("CSRF cookie must be readable by scripts")
+	}
+	if found.Path != "/" {
+		t.Errorf("cookie path = %q, want /", found.Path)
+	}
+}
+
 func TestGetAuthCookie(t *testing.T) {
 	svc := newTestCookieService()
 
@@ -135,6 +169,83 @@ func TestClearAuthCookie(t *testing.T) {
 	}
 }
 
+func TestRefreshCookie(t *testing.T) {
+	svc := newTestCookieService()
+	c, w := setupTestContext()
+
+	svc.SetRefreshCookie(c, "refresh-value")
+
+	var found *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "test_refresh" {
+			found = cookie
+			break
+		}
+	}
+
+	if found == nil {
+		t.Fatal("cookie 'test_refresh' not found")
+	}
+
+	if found.Value != "refresh-value" {
+		t.Errorf("cookie value = %q, want %q", found.Value, "refresh-value")
+	}
+
+	if found.Path != "/api/auth" {
+		t.Errorf("cookie Path = %q, want %q", found.Path, "/api/auth")
+	}
+
+	if !found.HttpOnly {
+		t.Error("refresh cookie should be HttpOnly")
+	}
+
+	if found.MaxAge != 86400 {
+		t.Errorf("cookie MaxAge = %d, want %d", found.MaxAge, 86400)
+	}
+
+	c, _ = setupTestContext()
+	c.Request.AddCookie(&http.Cookie{Name: "test_refresh", Value: "from-request"})
+	token, err := svc.GetRefreshCookie(c)
+	if err != nil || token != "from-request" {
+		t.Errorf("GetRefreshCookie = %q, %v", token, err)
+	}
+}
+
+func TestOIDCStateCookie(t *testing.T) {
+	svc := newTestCookieService()
+	c, w := setupTestContext()
+
+	svc.SetOIDCStateCookie(c, "state-value")
+
+	var found *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "test_token_oidc_state" {
+			found = cookie
+			break
+		}
+	}
+
+	if found == nil {
+		t.Fatal("cookie 'test_token_oidc_state' not found")
+	}
+
+	// The provider redirects back cross-site, so Strict would drop the cookie.
+	if found.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie SameSite = %v, want Lax", found.SameSite)
+	}
+
+	if found.Path != "/api/auth" || !found.HttpOnly || found.MaxAge != 600 {
+		t.Errorf("cookie = %+v", found)
+	}
+
+	c, _ = setupTestContext()
+	c.Request.AddCookie(&http.Cookie{Name: "test_token_oidc_state", Value: "from-request"})
+	state, err := svc.GetOIDCStateCookie(c)
+	if err != nil || state != "from-request" {
+		t.Errorf("GetOIDCStateCookie = %q, %v", state, err)
+	}
+}
+
 func TestSameSiteModes(t *testing.T) {
 	tests := []struct {
 		configValue string
@@ -0,0 +1,65 @@
+package auth
+
+import "strings"
+
+const (
+	maxUserAgentLen = 255
+	unknownDevice   = "Unknown device"
+)
+
+// Order matters: Edge and Opera also announce Chrome, Chrome announces Safari,
+// and Android user agents contain "Linux".
+var (
+	uaBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+	}
+	uaSystems = []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DeviceLabel derives a human-readable label such as "Firefox on Linux" from
+// a User-Agent header. It is only a hint for the session list.
+func DeviceLabel(userAgent string) string {
+	var browser, system string
+	for _, b := range uaBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range uaSystems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return unknownDevice
+}
+
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) > maxUserAgentLen {
+		return userAgent[:maxUserAgentLen]
+	}
+	return userAgent
+}
@@ -0,0 +1,49 @@
+package auth
+
+import "testing"
+
+func TestDeviceLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{
+			"chrome on windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			"Chrome on Windows",
+		},
+		{
+			"edge on windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0",
+			"Edge on Windows",
+		},
+		{
+			"firefox on linux",
+			"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0",
+			"Firefox on Linux",
+		},
+		{
+			"chrome on android",
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+			"Chrome on Android",
+		},
+		{
+			"safari on iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			"Safari on iOS",
+		},
+		{"curl", "curl/8.5.0", "curl"},
+		{"empty", "", unknownDevice},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := DeviceLabel(tt.ua); got != tt.want {
+					t.Errorf("DeviceLabel() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
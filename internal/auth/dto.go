@@ -3,11 +3,21 @@ package auth
 type LoginRequest struct {
 	Username string `json:"username" example:"admin" binding:"required,min=3,max=32,alphanum"`
 	Password string `json:"password" example:"secret_pass" binding:"required,min=8,max=128,excludes= "`
+	Device   string `json:"device,omitempty" example:"Work laptop" binding:"omitempty,max=64"`
 }
 
+// LoginResponse either confirms the session or, when TwoFactorRequired is set,
+// carries the challenge to complete via /auth/2fa/verify or, for passkeys,
+// /auth/passkeys/login. TwoFactorMethods lists the factors the user has set up.
 type LoginResponse struct {
-	Success bool   `json:"success" example:"true"`
-	Message string `json:"message" example:"Logged in successfully"`
+	Success            bool                    `json:"success" example:"true"`
+	Message            string                  `json:"message" example:"Logged in successfully"`
+	TwoFactorRequired  bool                    `json:"two_factor_required,omitempty" example:"false"`
+	TwoFactorMethods   []string                `json:"two_factor_methods,omitempty" example:"totp,webauthn"`
+	Challenge          string                  `json:"challenge,omitempty"`
+	ChallengeExpiresAt int64                   `json:"challenge_expires_at,omitempty"`
+	Enrollment         *TOTPEnrollmentResponse `json:"enrollment,omitempty"`
+	RecoveryCodes      []string                `json:"recovery_codes,omitempty"`
 }
 
 type AuthStatusResponse struct {
@@ -25,6 +35,11 @@ type SessionResponse struct {
 	RevokedByUsername string `json:"revoked_by_username,omitempty"`
 	ExpiresAt         int64  `json:"expires_at"`
 	CreatedAt         int64  `json:"created_at"`
+	IP                string `json:"ip,omitempty" example:"203.0.113.7"`
+	UserAgent         string `json:"user_agent,omitempty"`
+	Device            string `json:"device,omitempty" example:"Firefox on Linux"`
+	LastSeenAt        int64  `json:"last_seen_at,omitempty"`
+	Current           bool   `json:"current,omitempty"`
 }
 
 type SessionListResponse struct {
@@ -35,3 +50,426 @@ type SessionListResponse struct {
 type RevokeSessionRequest struct {
 	JTI string `json:"jti" binding:"required"`
 }
+
+// Двухфакторная аутентификация (TOTP)
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+
+	TwoFactorMethodTOTP     = "totp"
+	TwoFactorMethodWebAuthn = "webauthn"
+
+	AuditActionTwoFactorEnable    = "auth.2fa.enable"
+	AuditActionTwoFactorDisable   = "auth.2fa.disable"
+	AuditActionRecoveryRegenerate = "auth.2fa.recovery.regenerate"
+	AuditActionRecoveryCodeUsed   = "auth.2fa.recovery.used"
+)
+
+type TwoFactorVerifyRequest struct {
+	Challenge    string `json:"challenge" binding:"required,alphanum,max=64"`
+	Code         string `json:"code,omitempty" example:"123456" binding:"omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"abcde-fghij" binding:"omitempty,max=16"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" example:"123456" binding:"required,numeric,len=6"`
+}
+
+type TwoFactorDisableRequest struct {
+	Code         string `json:"code,omitempty" example:"123456" binding:"omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"abcde-fghij" binding:"omitempty,max=16"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"otpauth_uri" example:"otpauth://totp/VPS_API:admin?secret=JBSWY3DPEHPK3PXP&issuer=VPS_API"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool `json:"enabled" example:"true"`
+	Pending           bool `json:"pending" example:"false"`
+	Required          bool `json:"required" example:"true"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left" example:"8"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// API-ключи для автоматизации
+const (
+	AMRAPIKey = "apikey"
+
+	// APIKeyPrefix starts every key so it can be told apart from a JWT in the
+	// Authorization header.
+	APIKeyPrefix = "vpsk_"
+	HeaderAPIKey = "X-API-Key"
+
+	// HeaderCSRFToken carries the session's CSRF token: in login and refresh
+	// responses, and back from the client on mutating cookie-authenticated
+	// requests.
+	HeaderCSRFToken = "X-CSRF-Token"
+
+	AuditActionAPIKeyCreate = "auth.apikey.create"
+	AuditActionAPIKeyRevoke = "auth.apikey.revoke"
+)
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" example:"ci-deploy" binding:"required,min=1,max=64"`
+	Permissions   []string `json:"permissions" example:"pm2.control.restart" binding:"required,min=1,dive,required,max=64"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty" example:"30" binding:"omitempty,min=1"`
+}
+
+type RevokeAPIKeyRequest struct {
+	ID int64 `json:"id" example:"3" binding:"required,min=1"`
+}
+
+type APIKeyResponse struct {
+	ID          int64    `json:"id" example:"3"`
+	Name        string   `json:"name" example:"ci-deploy"`
+	Prefix      string   `json:"prefix" example:"vpsk_k3j9x2ab"`
+	Permissions []string `json:"permissions"`
+	ExpiresAt   int64    `json:"expires_at" example:"1767225600"`
+	LastUsedAt  int64    `json:"last_used_at,omitempty" example:"1764547200"`
+	LastUsedIP  string   `json:"last_used_ip,omitempty" example:"203.0.113.7"`
+	Revoked     bool     `json:"revoked" example:"false"`
+	CreatedAt   int64    `json:"created_at" example:"1764547200"`
+}
+
+// APIKeyCreatedResponse carries the full key. It is returned only once.
+type APIKeyCreatedResponse struct {
+	APIKeyResponse
+	Key string `json:"key" example:"vpsk_k3j9x2ab_JBSWY3DPEHPK3PXPJBSWY3DPEH"`
+}
+
+type APIKeyListResponse struct {
+	Keys  []APIKeyResponse `json:"keys"`
+	Total int              `json:"total" example:"1"`
+}
+
+// JWKS
+type JWK struct {
+	KeyType   string `json:"kty" example:"OKP"`
+	KeyID     string `json:"kid" example:"20260101-120000-abcdef"`
+	Use       string `json:"use" example:"sig"`
+	Algorithm string `json:"alg" example:"EdDSA"`
+	Curve     string `json:"crv,omitempty" example:"Ed25519"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Кэш прав доступа
+const SubjectPermissionsInvalidate = "auth.permissions.invalidate"
+
+// UserAccess is what the middleware authorizes against: the user's current
+// roles and permissions, independent of the token.
+type UserAccess struct {
+	Roles       []string
+	Permissions []string
+}
+
+// PermissionInvalidation is broadcast to the other instances. All drops every
+// entry, otherwise only UserIDs. Origin lets an instance skip its own message.
+type PermissionInvalidation struct {
+	Origin  string `json:"origin"`
+	UserIDs []int  `json:"user_ids,omitempty"`
+	All     bool   `json:"all,omitempty"`
+}
+
+// Вход через OpenID Connect
+const (
+	AMROIDC = "oidc"
+
+	QueryParamOIDCCode  = "code"
+	QueryParamOIDCState = "state"
+	QueryParamOIDCError = "error"
+
+	QueryParamTwoFactorChallenge = "two_factor_challenge"
+)
+
+// OIDCLogin is a user authenticated by the identity provider and mapped to a
+// local account.
+type OIDCLogin struct {
+	UserID   int
+	Username string
+	AMR      []string
+}
+
+// Ключи доступа (WebAuthn)
+const (
+	// AMRHardwareKey is "proof-of-possession of a hardware-secured key" (RFC 8176).
+	AMRHardwareKey = "hwk"
+
+	CeremonyWebAuthnCreate = "webauthn.create"
+	CeremonyWebAuthnGet    = "webauthn.get"
+
+	AuditActionPasskeyRegister = "auth.passkey.register"
+	AuditActionPasskeyDelete   = "auth.passkey.delete"
+)
+
+// WebAuthnCreationOptions wraps PublicKeyCredentialCreationOptionsJSON; pass
+// publicKey to PublicKeyCredential.parseCreationOptionsFromJSON.
+type WebAuthnCreationOptions struct {
+	PublicKey WebAuthnCreationOptionsJSON `json:"publicKey"`
+}
+
+type WebAuthnCreationOptionsJSON struct {
+	RP                     WebAuthnRP                     `json:"rp"`
+	User                   WebAuthnUser                   `json:"user"`
+	Challenge              string                         `json:"challenge"`
+	PubKeyCredParams       []WebAuthnCredentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions wraps PublicKeyCredentialRequestOptionsJSON; pass
+// publicKey to PublicKeyCredential.parseRequestOptionsFromJSON.
+type WebAuthnRequestOptions struct {
+	PublicKey WebAuthnRequestOptionsJSON `json:"publicKey"`
+}
+
+type WebAuthnRequestOptionsJSON struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+type WebAuthnRP struct {
+	ID   string `json:"id" example:"vps.example.com"`
+	Name string `json:"name" example:"VPS Control"`
+}
+
+type WebAuthnUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name" example:"admin"`
+	DisplayName string `json:"displayName" example:"admin"`
+}
+
+type WebAuthnCredentialParam struct {
+	Type string `json:"type" example:"public-key"`
+	Alg  int    `json:"alg" example:"-7"`
+}
+
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type" example:"public-key"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey" example:"preferred"`
+	UserVerification string `json:"userVerification" example:"preferred"`
+}
+
+// WebAuthnAttestation is RegistrationResponseJSON as produced by
+// PublicKeyCredential.toJSON(); binary fields are base64url.
+type WebAuthnAttestation struct {
+	ID       string `json:"id" binding:"required,max=1366"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type" binding:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports,omitempty" binding:"max=8,dive,max=32"`
+	} `json:"response" binding:"required"`
+}
+
+// WebAuthnAssertion is AuthenticationResponseJSON as produced by
+// PublicKeyCredential.toJSON(); binary fields are base64url.
+type WebAuthnAssertion struct {
+	ID       string `json:"id" binding:"required,max=1366"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type" binding:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response" binding:"required"`
+}
+
+type WebAuthnRegisterRequest struct {
+	Name       string              `json:"name" example:"YubiKey 5" binding:"required,min=1,max=64"`
+	Credential WebAuthnAttestation `json:"credential" binding:"required"`
+}
+
+// WebAuthnLoginBeginRequest starts a passwordless login when Challenge is
+// empty, or the second step of a password login with its challenge.
+type WebAuthnLoginBeginRequest struct {
+	Challenge string `json:"challenge,omitempty" binding:"omitempty,alphanum,max=64"`
+}
+
+type WebAuthnLoginFinishRequest struct {
+	Challenge  string            `json:"challenge,omitempty" binding:"omitempty,alphanum,max=64"`
+	Device     string            `json:"device,omitempty" example:"Work laptop" binding:"omitempty,max=64"`
+	Credential WebAuthnAssertion `json:"credential" binding:"required"`
+}
+
+type DeletePasskeyRequest struct {
+	ID int `json:"id" example:"2" binding:"required,min=1"`
+}
+
+type PasskeyResponse struct {
+	ID         int      `json:"id" example:"2"`
+	Name       string   `json:"name" example:"YubiKey 5"`
+	Transports []string `json:"transports,omitempty"`
+	CreatedAt  int64    `json:"created_at" example:"1764547200"`
+	LastUsedAt int64    `json:"last_used_at,omitempty" example:"1764547200"`
+}
+
+type PasskeyListResponse struct {
+	Passkeys []PasskeyResponse `json:"passkeys"`
+	Total    int               `json:"total" example:"1"`
+}
+
+// WebAuthnLogin is a verified assertion. UserVerified means the authenticator
+// checked a PIN or biometric, so the key alone is a multi-factor login.
+type WebAuthnLogin struct {
+	UserID       int
+	UserVerified bool
+}
+
+// Повторная аутентификация (step-up)
+const (
+	ReauthMethodPassword = "password"
+
+	AuditActionReauth = "auth.reauth"
+)
+
+// ReauthRequest confirms the identity of a logged-in user with exactly one of
+// the password, a TOTP code or a passkey assertion for the options returned
+// by /auth/reauth/passkey.
+type ReauthRequest struct {
+	Password   string             `json:"password,omitempty" example:"secret_pass" binding:"omitempty,max=128"`
+	Code       string             `json:"code,omitempty" example:"123456" binding:"omitempty,numeric,len=6"`
+	Credential *WebAuthnAssertion `json:"credential,omitempty"`
+}
+
+type ReauthResponse struct {
+	Success         bool   `json:"success" example:"true"`
+	Message         string `json:"message" example:"Identity confirmed"`
+	Method          string `json:"method" example:"totp"`
+	AuthenticatedAt int64  `json:"authenticated_at" example:"1764547200"`
+}
+
+// Журнал входов, блокировка учётных записей и оповещения о новых входах
+const (
+	SubjectLoginAnomaly = "auth.login.anomaly"
+
+	LoginAttemptsDefaultLimit = 100
+	LoginAttemptsMaxLimit     = 1000
+	QueryParamUsername        = "username"
+	QueryParamIP              = "ip"
+	QueryParamSince           = "since"
+	QueryParamLimit           = "limit"
+)
+
+// LoginAttempt describes one authentication attempt for the login monitor.
+// Reason is set for failures only.
+type LoginAttempt struct {
+	UserID    int
+	Username  string
+	IP        string
+	UserAgent string
+	Reason    string
+	AMR       []string
+}
+
+// LoginAnomalyEvent is published when a user logs in from an IP address or a
+// device that none of their earlier successful logins used.
+type LoginAnomalyEvent struct {
+	UserID    int      `json:"user_id" example:"1"`
+	Username  string   `json:"username" example:"admin"`
+	IP        string   `json:"ip" example:"203.0.113.7"`
+	UserAgent string   `json:"user_agent,omitempty"`
+	Device    string   `json:"device,omitempty" example:"Firefox on Linux"`
+	NewIP     bool     `json:"new_ip"`
+	NewDevice bool     `json:"new_device"`
+	AMR       []string `json:"amr,omitempty"`
+	Timestamp int64    `json:"timestamp" example:"1764547200"`
+}
+
+type LoginAttemptResponse struct {
+	ID        int64  `json:"id" example:"42"`
+	Username  string `json:"username" example:"admin"`
+	IP        string `json:"ip" example:"203.0.113.7"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Device    string `json:"device,omitempty" example:"Firefox on Linux"`
+	Result    string `json:"result" example:"failure"`
+	Reason    string `json:"reason,omitempty" example:"bad_password"`
+	Timestamp int64  `json:"timestamp" example:"1764547200"`
+}
+
+type LoginAttemptListResponse struct {
+	Attempts []LoginAttemptResponse `json:"attempts"`
+	Total    int                    `json:"total" example:"1"`
+}
+
+// Смена пароля пользователем
+const (
+	AuditActionPasswordChange = "auth.password.change"
+)
+
+// ChangePasswordRequest lengths are only sanity limits; the configured
+// password policy decides what a valid new password is.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"old_secret_pass" binding:"required,max=256"`
+	NewPassword     string `json:"new_password" example:"correct horse battery staple" binding:"required,max=256"`
+}
+
+type ChangePasswordResponse struct {
+	Success         bool   `json:"success" example:"true"`
+	Message         string `json:"message" example:"Password changed"`
+	RevokedSessions int64  `json:"revoked_sessions" example:"2"`
+}
+
+// Проверка токенов другими сервисами (RFC 7662)
+const (
+	TokenTypeAccess = "access_token"
+	TokenTypeAPIKey = "api_key"
+
+	IntrospectErrInvalidClient = "invalid_client"
+	IntrospectErrServer        = "server_error"
+)
+
+// IntrospectionRequest is the body of a NATS introspection request. Over
+// HTTP the client authenticates with Basic auth and sends the token as a
+// form field instead.
+type IntrospectionRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Token        string `json:"token"`
+}
+
+// IntrospectionResponse follows RFC 7662: an unusable token yields only
+// "active": false. Scope lists the permissions separated by spaces; the same
+// permissions and the roles are also given as arrays. Error is set in NATS
+// replies only, when the request itself was refused.
+type IntrospectionResponse struct {
+	Active      bool     `json:"active" example:"true"`
+	TokenType   string   `json:"token_type,omitempty" example:"access_token"`
+	Scope       string   `json:"scope,omitempty" example:"pm2.view.basic pm2.control.restart"`
+	Username    string   `json:"username,omitempty" example:"admin"`
+	Subject     string   `json:"sub,omitempty" example:"admin"`
+	UserID      int      `json:"uid,omitempty" example:"1"`
+	Issuer      string   `json:"iss,omitempty" example:"VPS_API"`
+	JTI         string   `json:"jti,omitempty"`
+	ExpiresAt   int64    `json:"exp,omitempty" example:"1764548100"`
+	IssuedAt    int64    `json:"iat,omitempty" example:"1764547200"`
+	NotBefore   int64    `json:"nbf,omitempty" example:"1764547200"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	AMR         []string `json:"amr,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
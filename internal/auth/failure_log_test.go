@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+func newTestFailureLog(t *testing.T, enabled bool) (*FailureLogService, string) {
+	path := filepath.Join(t.TempDir(), "log", "auth-failures.log")
+	svc := NewFailureLogService(
+		config.AuthJailConfig{Enabled: enabled, LogPath: path},
+		zap.NewNop(),
+	)
+	svc.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	return svc, path
+}
+
+func TestFailureLogService_LogFailure(t *testing.T) {
+	svc, path := newTestFailureLog(t, true)
+
+	svc.LogFailure("203.0.113.7", "admin", FailureReasonBadPassword)
+	svc.LogFailure("2001:db8::1", "ghost", FailureReasonUnknownUser)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []string{
+		`2026-10-18T12:00:00Z vps-control[auth]: authentication failure user="admin" reason=bad_password from 203.0.113.7`,
+		`2026-10-18T12:00:00Z vps-control[auth]: authentication failure user="ghost" reason=unknown_user from 2001:db8::1`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %q", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestFailureLogService_Disabled(t *testing.T) {
+	svc, path := newTestFailureLog(t, false)
+
+	svc.LogFailure("203.0.113.7", "admin", FailureReasonBadPassword)
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("log file should not be created when disabled, stat err = %v", err)
+	}
+}
+
+func TestSanitizeLogUsername(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "admin", "admin"},
+		{"quote injection", `x" reason=a from 1.1.1.1`, `x__reason=a_from_1.1.1.1`},
+		{"newline injection", "x\n2026-01-01T00:00:00Z vps-control[auth]", "x_2026-01-01T00:00:00Z_vps-control[auth]"},
+		{"backslash", `a\b`, "a_b"},
+		{"truncated", strings.Repeat("a", 100), strings.Repeat("a", failureLogMaxUser)},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := sanitizeLogUsername(tt.input); got != tt.want {
+					t.Errorf("sanitizeLogUsername(%q) = %q, want %q", tt.input, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{postgresql.ErrUserNotFound, FailureReasonUnknownUser},
+		{fmt.Errorf("login: %w", postgresql.ErrInvalidCredentials), FailureReasonBadPassword},
+		{postgresql.ErrUserInactive, FailureReasonInactiveUser},
+		{errors.New("connection refused"), ""},
+	}
+
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("failureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,21 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
 	"VPS-control/internal/database/postgresql"
 	"VPS-control/internal/database/sqlite3_local"
 
@@ -18,6 +28,16 @@ type handler struct {
 	jwtService    JwtProvider
 	cookieService SetAuthCookie
 	tokenRepo     sqlite3_local.TokenStore
+	refresh       RefreshManager
+	failureLog    FailureLogger
+	twoFactor     TwoFactorManager
+	challenges    ChallengeStore
+	apiKeys       APIKeyManager
+	oidc          OIDCAuthenticator
+	webauthn      WebAuthnManager
+	monitor       LoginMonitor
+	introspection Introspector
+	audit         audit.Recorder
 	logger        *zap.Logger
 }
 
@@ -26,6 +46,16 @@ func NewHandler(
 	aj JwtProvider,
 	ac SetAuthCookie,
 	tr sqlite3_local.TokenStore,
+	rm RefreshManager,
+	fl FailureLogger,
+	tf TwoFactorManager,
+	cs ChallengeStore,
+	ak APIKeyManager,
+	oa OIDCAuthenticator,
+	wa WebAuthnManager,
+	lm LoginMonitor,
+	in Introspector,
+	ar audit.Recorder,
 	l *zap.Logger,
 ) Handler {
 	return &handler{
@@ -33,20 +63,33 @@ func NewHandler(
 		jwtService:    aj,
 		cookieService: ac,
 		tokenRepo:     tr,
+		refresh:       rm,
+		failureLog:    fl,
+		twoFactor:     tf,
+		challenges:    cs,
+		apiKeys:       ak,
+		oidc:          oa,
+		webauthn:      wa,
+		monitor:       lm,
+		introspection: in,
+		audit:         ar,
 		logger:        l,
 	}
 }
 
 // Login godoc
 // @Summary      User login
-// @Description  Authenticate user and set HTTP-only cookie with JWT token containing permissions
+// @Description  Authenticate user and set HTTP-only cookie with JWT token containing permissions.
+// @Description  When 2FA is enabled or required by role, returns a challenge to complete via /auth/2fa/verify instead.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body LoginRequest true "Login Credentials"
 // @Success      200 {object} LoginResponse
+// @Header       200 {string} X-CSRF-Token "CSRF token to send back on mutating requests"
 // @Failure      400 {object} apierror.AppError
 // @Failure      401 {object} apierror.AppError
+// @Failure      429 {object} apierror.AppError
 // @Failure      500 {object} apierror.AppError
 // @Router       /auth/login [post]
 func (h *handler) Login(c *gin.Context) {
@@ -56,11 +99,15 @@ func (h *handler) Login(c *gin.Context) {
 		return
 	}
 
+	if err := h.lockout(c, req.Username); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
 	result, err := h.authManager.Login(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
-		if errors.Is(err, postgresql.ErrUserNotFound) ||
-			errors.Is(err, postgresql.ErrInvalidCredentials) ||
-			errors.Is(err, postgresql.ErrUserInactive) {
+		if reason := failureReason(err); reason != "" {
+			h.loginFailed(c, req.Username, reason)
 			apierror.Abort(c, apierror.Errors.INVALID_CREDENTIALS)
 			return
 		}
@@ -68,39 +115,387 @@ func (h *handler) Login(c *gin.Context) {
 		return
 	}
 
+	methods, required, err := h.secondFactors(c, result.User.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+	if len(methods) == 0 && !required {
+		h.issueSession(c, result, []string{AMRPassword}, nil, req.Device)
+		return
+	}
+
+	resp := LoginResponse{
+		Success:           true,
+		Message:           MsgTwoFactorRequired,
+		TwoFactorRequired: true,
+		TwoFactorMethods:  methods,
+	}
+	enrollment := len(methods) == 0
+	if enrollment {
+		resp.Message = MsgTwoFactorEnroll
+		resp.Enrollment, err = h.twoFactor.BeginEnrollment(c.Request.Context(), result.User.ID, result.User.Username)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+	}
+
+	challenge := h.challenges.Create(result, enrollment, req.Device)
+	resp.Challenge = challenge.ID
+	resp.ChallengeExpiresAt = challenge.ExpiresAt.Unix()
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// VerifyTwoFactor godoc
+// @Summary      Complete two-factor login
+// @Description  Completes a login challenge with a TOTP code or a recovery code and sets the session cookie.
+// @Description  For enrollment challenges the code confirms the new secret and recovery codes are returned once.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body TwoFactorVerifyRequest true "Challenge and code"
+// @Success      200 {object} LoginResponse
+// @Header       200 {string} X-CSRF-Token "CSRF token to send back on mutating requests"
+// @Failure      400 {object} apierror.AppError
+// @Failure      401 {object} apierror.AppError
+// @Router       /auth/2fa/verify [post]
+func (h *handler) VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "") == (req.RecoveryCode == "") {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("exactly one of 'code' or 'recovery_code' is required"))
+		return
+	}
+
+	challenge, ok := h.challenges.Get(req.Challenge)
+	if !ok {
+		apierror.Abort(c, apierror.Errors.TWO_FACTOR_CHALLENGE_INVALID)
+		return
+	}
+	user := challenge.Result.User
+
+	var (
+		recoveryCodes []string
+		err           error
+	)
+	if challenge.Enrollment {
+		if req.Code == "" {
+			apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("enrollment must be confirmed with 'code'"))
+			return
+		}
+		recoveryCodes, err = h.twoFactor.ConfirmEnrollment(c.Request.Context(), user.ID, req.Code)
+	} else {
+		err = h.twoFactor.Verify(c.Request.Context(), user.ID, req.Code, req.RecoveryCode)
+	}
+
+	if err != nil {
+		if errors.Is(err, apierror.Errors.INVALID_TWO_FACTOR_CODE) {
+			left := h.challenges.Fail(req.Challenge)
+			h.loginFailed(c, user.Username, FailureReasonBadOTP)
+			apierror.Abort(c, apierror.Errors.INVALID_TWO_FACTOR_CODE.WithMeta(gin.H{"attempts_left": left}))
+			return
+		}
+		apierror.Abort(c, err)
+		return
+	}
+	h.challenges.Delete(req.Challenge)
+
+	switch {
+	case challenge.Enrollment:
+		h.recordUserAudit(c, user.ID, user.Username, AuditActionTwoFactorEnable, nil)
+	case req.RecoveryCode != "":
+		h.recordUserAudit(c, user.ID, user.Username, AuditActionRecoveryCodeUsed, nil)
+	}
+
+	firstFactor := challenge.FirstFactor
+	if firstFactor == "" {
+		firstFactor = AMRPassword
+	}
+	h.issueSession(c, challenge.Result, []string{firstFactor, AMROTP, AMRMFA}, recoveryCodes, challenge.Device)
+}
+
+// issueSession starts a session for a fully authenticated user and reports
+// it in the JSON response.
+func (h *handler) issueSession(
+	c *gin.Context,
+	result *AuthResult,
+	amr []string,
+	recoveryCodes []string,
+	device string,
+) {
+	if !h.startSession(c, result, amr, device) {
+		return
+	}
+	c.JSON(http.StatusOK, LoginResponse{Success: true, Message: MsgLoginSuccess, RecoveryCodes: recoveryCodes})
+}
+
+// startSession generates the access token, stores it, starts a refresh token
+// family and sets both cookies. Other sessions are revoked only under the
+// single-session policy. On failure the request is aborted and false returned.
+func (h *handler) startSession(
+	c *gin.Context,
+	result *AuthResult,
+	amr []string,
+	device string,
+) bool {
 	jti := h.tokenRepo.GenerateJTI(result.User.Username)
 	expiresAt := time.Now().Add(h.jwtService.GetTTL()).Unix()
+	csrf := rand.Text()
 
 	token, err := h.jwtService.GenerateToken(
 		TokenData{
-			UserID:      result.User.ID,
-			Username:    result.User.Username,
-			JTI:         jti,
-			Roles:       result.Roles,
-			Permissions: result.Permissions,
+			UserID:   result.User.ID,
+			Username: result.User.Username,
+			JTI:      jti,
+			AMR:      amr,
+			CSRF:     csrf,
 		},
 	)
 	if err != nil {
 		apierror.Abort(c, apierror.Errors.INTERNAL_ERROR.Wrap(err))
-		return
+		return false
 	}
 
-	revokedCount, err := h.tokenRepo.SaveTokenExclusive(jti, result.User.Username, expiresAt)
+	revokedCount, err := h.tokenRepo.SaveSession(
+		newSession(c, jti, result.User.Username, expiresAt, device), result.SingleSession,
+	)
 	if err != nil {
 		apierror.Abort(c, apierror.Errors.INTERNAL_ERROR.Wrap(err))
-		return
+		return false
+	}
+
+	refreshToken, err := h.refresh.Issue(result.User.ID, result.User.Username, jti, amr)
+	if err != nil {
+		apierror.Abort(c, err)
+		return false
 	}
 
 	h.cookieService.SetAuthCookie(c, token)
+	h.cookieService.SetRefreshCookie(c, refreshToken)
+	h.setCSRFToken(c, csrf)
+
+	attempt := newLoginAttempt(c, result.User.Username, "")
+	attempt.UserID = result.User.ID
+	attempt.AMR = amr
+	h.monitor.Succeeded(attempt)
 
 	h.logger.Info(
 		LogUserLoggedIn,
 		zap.String("username", result.User.Username),
 		zap.Int("user_id", result.User.ID),
+		zap.Strings("amr", amr),
 		zap.Int64("revoked_sessions", revokedCount),
 	)
+	return true
+}
+
+// OIDCLogin godoc
+// @Summary      Start OpenID Connect login
+// @Description  Redirects the browser to the identity provider (authorization code flow with PKCE).
+// @Description  A short-lived cookie binds the login to this browser until the provider redirects back.
+// @Tags         auth
+// @Success      302
+// @Failure      404 {object} apierror.AppError
+// @Failure      502 {object} apierror.AppError
+// @Router       /auth/oidc/login [get]
+func (h *handler) OIDCLogin(c *gin.Context) {
+	if h.oidc == nil {
+		apierror.Abort(c, apierror.Errors.OIDC_DISABLED)
+		return
+	}
+
+	redirectURL, state, err := h.oidc.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	h.cookieService.SetOIDCStateCookie(c, state)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// OIDCCallback godoc
+// @Summary      Complete OpenID Connect login
+// @Description  Redirect target of the identity provider. Verifies the ID token, creates or links the local user,
+// @Description  synchronizes the roles mapped from provider groups, sets the session cookies and redirects to the app.
+// @Description  If the user has local 2FA and the provider did not report MFA, redirects with a "two_factor_challenge"
+// @Description  query parameter instead; the challenge is completed via /auth/2fa/verify.
+// @Tags         auth
+// @Param        code   query string false "Authorization code"
+// @Param        state  query string true  "State from the login redirect"
+// @Param        error  query string false "Error reported by the provider"
+// @Success      302
+// @Failure      400 {object} apierror.AppError
+// @Failure      401 {object} apierror.AppError
+// @Failure      403 {object} apierror.AppError
+// @Failure      404 {object} apierror.AppError
+// @Failure      409 {object} apierror.AppError
+// @Failure      502 {object} apierror.AppError
+// @Router       /auth/oidc/callback [get]
+func (h *handler) OIDCCallback(c *gin.Context) {
+	if h.oidc == nil {
+		apierror.Abort(c, apierror.Errors.OIDC_DISABLED)
+		return
+	}
+
+	state := c.Query(QueryParamOIDCState)
+	expected, err := h.cookieService.GetOIDCStateCookie(c)
+	h.cookieService.ClearOIDCStateCookie(c)
+	if err != nil || expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(state)) != 1 {
+		apierror.Abort(c, apierror.Errors.OIDC_STATE_INVALID)
+		return
+	}
+	if providerErr := c.Query(QueryParamOIDCError); providerErr != "" {
+		apierror.Abort(c, apierror.Errors.OIDC_LOGIN_FAILED.WithMeta(providerErr))
+		return
+	}
+
+	login, err := h.oidc.Authenticate(c.Request.Context(), state, c.Query(QueryParamOIDCCode))
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	result, err := h.authManager.Reload(c.Request.Context(), login.UserID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrUserNotFound) || errors.Is(err, postgresql.ErrUserInactive) {
+			h.loginFailed(c, login.Username, failureReason(err))
+			apierror.Abort(c, apierror.Errors.INVALID_CREDENTIALS)
+			return
+		}
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+
+	// Local 2FA still applies unless the provider already verified a second factor.
+	if !slices.Contains(login.AMR, AMRMFA) {
+		methods, required, err := h.secondFactors(c, result.User.ID)
+		if err != nil {
+			apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+			return
+		}
+		if len(methods) > 0 {
+			// The challenge ID is not known to anyone before the redirect.
+			challenge := h.challenges.Create(result, false, "")
+			challenge.FirstFactor = AMROIDC
+			c.Redirect(http.StatusFound, withQuery(h.oidc.SuccessURL(), QueryParamTwoFactorChallenge, challenge.ID))
+			return
+		}
+		if required {
+			apierror.Abort(c, apierror.Errors.TWO_FACTOR_REQUIRED.WithMeta("enroll 2FA through password login first"))
+			return
+		}
+	}
+
+	if !h.startSession(c, result, login.AMR, "") {
+		return
+	}
+	c.Redirect(http.StatusFound, h.oidc.SuccessURL())
+}
+
+// Refresh godoc
+// @Summary      Refresh access token
+// @Description  Exchanges the refresh cookie for a new access token and a new refresh token.
+// @Description  Every refresh token is single-use: presenting a used one revokes the whole login.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} LoginResponse
+// @Header       200 {string} X-CSRF-Token "CSRF token to send back on mutating requests"
+// @Failure      401 {object} apierror.AppError
+// @Failure      500 {object} apierror.AppError
+// @Router       /auth/refresh [post]
+func (h *handler) Refresh(c *gin.Context) {
+	raw, err := h.cookieService.GetRefreshCookie(c)
+	if err != nil || raw == "" {
+		apierror.Abort(c, apierror.Errors.REFRESH_TOKEN_INVALID)
+		return
+	}
+
+	current, err := h.refresh.Lookup(raw)
+	if err != nil {
+		h.clearSessionCookies(c)
+		apierror.Abort(c, err)
+		return
+	}
+
+	result, err := h.authManager.Reload(c.Request.Context(), int(current.UserID))
+	if err != nil {
+		if errors.Is(err, postgresql.ErrUserNotFound) || errors.Is(err, postgresql.ErrUserInactive) {
+			if rErr := h.refresh.RevokeFamily(current.FamilyID); rErr != nil {
+				h.logger.Warn("Failed to revoke refresh tokens", zap.String("username", current.Username), zap.Error(rErr))
+			}
+			h.clearSessionCookies(c)
+			apierror.Abort(c, apierror.Errors.REFRESH_TOKEN_INVALID)
+			return
+		}
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+
+	jti := h.tokenRepo.GenerateJTI(result.User.Username)
+	expiresAt := time.Now().Add(h.jwtService.GetTTL()).Unix()
+
+	next, err := h.refresh.Rotate(raw, current, jti)
+	if err != nil {
+		h.clearSessionCookies(c)
+		apierror.Abort(c, err)
+		return
+	}
+
+	csrf := rand.Text()
+	token, err := h.jwtService.GenerateToken(
+		TokenData{
+			UserID:   result.User.ID,
+			Username: result.User.Username,
+			JTI:      jti,
+			AMR:      SplitAMR(current.AMR),
+			CSRF:     csrf,
+		},
+	)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.INTERNAL_ERROR.Wrap(err))
+		return
+	}
+
+	// The session keeps its device label and authentication time across
+	// refreshes; a refresh is not a re-authentication.
+	session := newSession(c, jti, result.User.Username, expiresAt, "")
+	session.AuthAt = 0
+	if prev, pErr := h.tokenRepo.GetToken(current.AccessJTI); pErr == nil {
+		session.Device = prev.Device
+		session.AuthAt = prev.AuthAt
+	}
+	if _, err = h.tokenRepo.SaveSession(session, false); err != nil {
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
 
-	c.JSON(http.StatusOK, LoginResponse{Success: true, Message: MsgLoginSuccess})
+	// The previous access token may still be valid; it must not outlive its refresh token.
+	err = h.tokenRepo.RevokeToken(current.AccessJTI, result.User.ID, result.User.Username)
+	if err != nil && !errors.Is(err, sqlite3_local.ErrTokenNotFound) {
+		h.logger.Warn("Failed to revoke previous access token", zap.String("jti", current.AccessJTI), zap.Error(err))
+	}
+
+	h.cookieService.SetAuthCookie(c, token)
+	h.cookieService.SetRefreshCookie(c, next)
+	h.setCSRFToken(c, csrf)
+
+	h.logger.Debug(LogSessionRefreshed, zap.String("username", result.User.Username), zap.String("jti", jti))
+	c.JSON(http.StatusOK, LoginResponse{Success: true, Message: MsgRefreshSuccess})
+}
+
+// JWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  Public keys for verifying access tokens signed with RS256 or EdDSA, selected by the "kid" header.
+// @Description  The set is empty when tokens are signed with HS256.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} JWKSResponse
+// @Router       /.well-known/jwks.json [get]
+func (h *handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
 }
 
 // Verify godoc
@@ -146,8 +541,9 @@ func (h *handler) Logout(c *gin.Context) {
 	if err := h.tokenRepo.RevokeToken(claims.JTI, claims.UserID, claims.Username); err != nil {
 		h.logger.Warn("Failed to revoke token", zap.String("jti", claims.JTI), zap.Error(err))
 	}
+	h.revokeRefreshFamily(claims.JTI)
 
-	h.cookieService.ClearAuthCookie(c)
+	h.clearSessionCookies(c)
 	c.JSON(http.StatusOK, AuthStatusResponse{Success: true, Message: MsgLogoutSuccess})
 }
 
@@ -170,28 +566,13 @@ func (h *handler) GetSessions(c *gin.Context) {
 		return
 	}
 
+	currentJTI, _ := GetJTI(c)
 	sessions := make([]SessionResponse, 0)
 	for _, e := range entities {
 		if filterUser != "" && e.Username != filterUser {
 			continue
 		}
-
-		resp := SessionResponse{
-			ID:        e.ID,
-			JTI:       e.JTI,
-			Username:  e.Username,
-			Revoked:   e.Revoked,
-			ExpiresAt: e.ExpiresAt,
-			CreatedAt: e.CreatedAt,
-		}
-		if e.RevokedByID.Valid {
-			val := e.RevokedByID.Int64
-			resp.RevokedByID = &val
-		}
-		if e.RevokedByUsername.Valid {
-			resp.RevokedByUsername = e.RevokedByUsername.String
-		}
-		sessions = append(sessions, resp)
+		sessions = append(sessions, toSessionResponse(e, currentJTI))
 	}
 
 	c.JSON(http.StatusOK, SessionListResponse{Sessions: sessions, Total: len(sessions)})
@@ -234,6 +615,1049 @@ func (h *handler) RevokeSession(c *gin.Context) {
 		return
 	}
 
+	h.revokeRefreshFamily(req.JTI)
+
 	h.logger.Info("Session revoked", zap.String("jti", req.JTI), zap.String("by", usernameStr))
 	c.JSON(http.StatusOK, gin.H{"message": "session revoked successfully"})
 }
+
+// GetMySessions godoc
+// @Summary      List own sessions
+// @Description  Returns the caller's active sessions with device metadata. The session making the request is marked as current.
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {object}  SessionListResponse
+// @Failure      500  {object}  apierror.AppError
+// @Router       /auth/sessions/me [get]
+func (h *handler) GetMySessions(c *gin.Context) {
+	_, username := GetActor(c)
+	currentJTI, _ := GetJTI(c)
+
+	entities, err := h.tokenRepo.GetUserTokens(username, time.Now().Unix())
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+
+	sessions := make([]SessionResponse, 0, len(entities))
+	for _, e := range entities {
+		sessions = append(sessions, toSessionResponse(e, currentJTI))
+	}
+
+	c.JSON(http.StatusOK, SessionListResponse{Sessions: sessions, Total: len(sessions)})
+}
+
+// RevokeMySession godoc
+// @Summary      Revoke own session
+// @Description  Signs out one of the caller's sessions, e.g. a lost phone. Sessions of other users are reported as not found.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body RevokeSessionRequest true "Session JTI"
+// @Success      200  {object}  AuthStatusResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /auth/sessions/me/revoke [post]
+func (h *handler) RevokeMySession(c *gin.Context) {
+	var req RevokeSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	userID, username := GetActor(c)
+
+	err := h.tokenRepo.RevokeUserToken(req.JTI, username, userID, username)
+	if err != nil {
+		if errors.Is(err, sqlite3_local.ErrTokenNotFound) {
+			apierror.Abort(c, apierror.Errors.INVALID_REQUEST.Wrap(err))
+			return
+		}
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+	h.revokeRefreshFamily(req.JTI)
+
+	if currentJTI, _ := GetJTI(c); currentJTI == req.JTI {
+		h.clearSessionCookies(c)
+	}
+
+	h.logger.Info("Session revoked by owner", zap.String("jti", req.JTI), zap.String("username", username))
+	c.JSON(http.StatusOK, AuthStatusResponse{Success: true, Message: MsgSessionRevoked, Username: username})
+}
+
+// GetTwoFactorStatus godoc
+// @Summary      Get own two-factor status
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200 {object} TwoFactorStatusResponse
+// @Failure      401 {object} apierror.AppError
+// @Router       /auth/2fa [get]
+func (h *handler) GetTwoFactorStatus(c *gin.Context) {
+	userID, _ := GetActor(c)
+
+	res, err := h.twoFactor.Status(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// EnrollTwoFactor godoc
+// @Summary      Start TOTP enrollment
+// @Description  Generates a new pending secret and otpauth URI. 2FA is enabled only after /auth/2fa/confirm.
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200 {object} TOTPEnrollmentResponse
+// @Failure      409 {object} apierror.AppError
+// @Router       /auth/2fa/enroll [post]
+func (h *handler) EnrollTwoFactor(c *gin.Context) {
+	userID, username := GetActor(c)
+
+	res, err := h.twoFactor.BeginEnrollment(c.Request.Context(), userID, username)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// ConfirmTwoFactor godoc
+// @Summary      Confirm TOTP enrollment
+// @Description  Enables 2FA with the first valid code and returns recovery codes. They are shown only once.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body TwoFactorCodeRequest true "TOTP code"
+// @Success      200 {object} RecoveryCodesResponse
+// @Failure      401 {object} apierror.AppError
+// @Failure      409 {object} apierror.AppError
+// @Router       /auth/2fa/confirm [post]
+func (h *handler) ConfirmTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	userID, username := GetActor(c)
+
+	codes, err := h.twoFactor.ConfirmEnrollment(c.Request.Context(), userID, req.Code)
+	h.recordUserAudit(c, userID, username, AuditActionTwoFactorEnable, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary      Disable two-factor authentication
+// @Description  Requires a current TOTP code or a recovery code. Not allowed when a role requires 2FA.
+// @Description  A wrong code counts as a failed login.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body TwoFactorDisableRequest true "TOTP or recovery code"
+// @Success      200 {object} AuthStatusResponse
+// @Failure      401 {object} apierror.AppError
+// @Failure      403 {object} apierror.AppError
+// @Failure      429 {object} apierror.AppError
+// @Router       /auth/2fa/disable [post]
+func (h *handler) DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "") == (req.RecoveryCode == "") {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("exactly one of 'code' or 'recovery_code' is required"))
+		return
+	}
+	userID, username := GetActor(c)
+
+	if err := h.lockout(c, username); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	err := h.twoFactor.Disable(c.Request.Context(), userID, req.Code, req.RecoveryCode)
+	h.recordUserAudit(c, userID, username, AuditActionTwoFactorDisable, err)
+	if err != nil {
+		h.twoFactorFailed(c, username, err)
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, AuthStatusResponse{Success: true, Message: MsgTwoFactorDisabled, Username: username})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Regenerate recovery codes
+// @Description  Replaces all recovery codes. Requires a current TOTP code.
+// @Description  A wrong code counts as a failed login.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body TwoFactorCodeRequest true "TOTP code"
+// @Success      200 {object} RecoveryCodesResponse
+// @Failure      401 {object} apierror.AppError
+// @Failure      429 {object} apierror.AppError
+// @Router       /auth/2fa/recovery/regenerate [post]
+func (h *handler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	userID, username := GetActor(c)
+
+	if err := h.lockout(c, username); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	h.recordUserAudit(c, userID, username, AuditActionRecoveryRegenerate, err)
+	if err != nil {
+		h.twoFactorFailed(c, username, err)
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// BeginPasskeyRegistration godoc
+// @Summary      Start passkey registration
+// @Description  Returns PublicKeyCredentialCreationOptions for navigator.credentials.create().
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200 {object} WebAuthnCreationOptions
+// @Failure      404 {object} apierror.AppError
+// @Failure      409 {object} apierror.AppError
+// @Router       /auth/passkeys/register/begin [post]
+func (h *handler) BeginPasskeyRegistration(c *gin.Context) {
+	if !h.passkeysAvailable(c) {
+		return
+	}
+	userID, username := GetActor(c)
+
+	options, err := h.webauthn.BeginRegistration(c.Request.Context(), userID, username)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishPasskeyRegistration godoc
+// @Summary      Complete passkey registration
+// @Description  Verifies the new credential (PublicKeyCredential.toJSON()) against the started ceremony and stores it.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body WebAuthnRegisterRequest true "Passkey name and credential"
+// @Success      201 {object} PasskeyResponse
+// @Failure      400 {object} apierror.AppError
+// @Failure      401 {object} apierror.AppError
+// @Failure      409 {object} apierror.AppError
+// @Router       /auth/passkeys/register/finish [post]
+func (h *handler) FinishPasskeyRegistration(c *gin.Context) {
+	if !h.passkeysAvailable(c) {
+		return
+	}
+
+	var req WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.Wrap(err))
+		return
+	}
+	userID, username := GetActor(c)
+
+	res, err := h.webauthn.FinishRegistration(c.Request.Context(), userID, req)
+	h.recordUserAudit(c, userID, username, AuditActionPasskeyRegister, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// ListPasskeys godoc
+// @Summary      List own passkeys
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200 {object} PasskeyListResponse
+// @Failure      404 {object} apierror.AppError
+// @Router       /auth/passkeys [get]
+func (h *handler) ListPasskeys(c *gin.Context) {
+	if !h.passkeysAvailable(c) {
+		return
+	}
+	userID, _ := GetActor(c)
+
+	passkeys, err := h.webauthn.List(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, PasskeyListResponse{Passkeys: passkeys, Total: len(passkeys)})
+}
+
+// DeletePasskey godoc
+// @Summary      Delete a passkey
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body DeletePasskeyRequest true "Passkey ID"
+// @Success      200 {object} AuthStatusResponse
+// @Failure      404 {object} apierror.AppError
+// @Router       /auth/passkeys/delete [post]
+func (h *handler) DeletePasskey(c *gin.Context) {
+	if !h.passkeysAvailable(c) {
+		return
+	}
+
+	var req DeletePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	userID, username := GetActor(c)
+
+	err := h.webauthn.Delete(c.Request.Context(), userID, req.ID)
+	h.recordUserAudit(c, userID, username, AuditActionPasskeyDelete, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, AuthStatusResponse{Success: true, Message: MsgPasskeyDeleted, Username: username})
+}
+
+// BeginPasskeyLogin godoc
+// @Summary      Start passkey login
+// @Description  Returns PublicKeyCredentialRequestOptions for navigator.credentials.get().
+// @Description  Without "challenge" any discoverable passkey with user verification logs in without a password.
+// @Description  With the challenge of a password login only that user's passkeys are offered, as a second factor.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body WebAuthnLoginBeginRequest false "Login challenge for second-factor use"
+// @Success      200 {object} WebAuthnRequestOptions
+// @Failure      401 {object} apierror.AppError
+// @Failure      404 {object} apierror.AppError
+// @Router       /auth/passkeys/login/begin [post]
+func (h *handler) BeginPasskeyLogin(c *gin.Context) {
+	if h.webauthn == nil {
+		apierror.Abort(c, apierror.Errors.WEBAUTHN_DISABLED)
+		return
+	}
+
+	var req WebAuthnLoginBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	var userID int
+	if req.Challenge != "" {
+		challenge, ok := h.challenges.Get(req.Challenge)
+		if !ok {
+			apierror.Abort(c, apierror.Errors.TWO_FACTOR_CHALLENGE_INVALID)
+			return
+		}
+		userID = challenge.Result.User.ID
+	}
+
+	options, err := h.webauthn.BeginLogin(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishPasskeyLogin godoc
+// @Summary      Complete passkey login
+// @Description  Verifies the assertion (PublicKeyCredential.toJSON()) and sets the session cookies.
+// @Description  Pass the same "challenge" as to /auth/passkeys/login/begin when completing a password login.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body WebAuthnLoginFinishRequest true "Assertion"
+// @Success      200 {object} LoginResponse
+// @Header       200 {string} X-CSRF-Token "CSRF token to send back on mutating requests"
+// @Failure      400 {object} apierror.AppError
+// @Failure      401 {object} apierror.AppError
+// @Failure      404 {object} apierror.AppError
+// @Router       /auth/passkeys/login/finish [post]
+func (h *handler) FinishPasskeyLogin(c *gin.Context) {
+	if h.webauthn == nil {
+		apierror.Abort(c, apierror.Errors.WEBAUTHN_DISABLED)
+		return
+	}
+
+	var req WebAuthnLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.Wrap(err))
+		return
+	}
+
+	if req.Challenge == "" {
+		h.finishPasswordlessLogin(c, req)
+		return
+	}
+
+	challenge, ok := h.challenges.Get(req.Challenge)
+	if !ok {
+		apierror.Abort(c, apierror.Errors.TWO_FACTOR_CHALLENGE_INVALID)
+		return
+	}
+	user := challenge.Result.User
+
+	if _, err := h.webauthn.FinishLogin(c.Request.Context(), user.ID, req.Credential); err != nil {
+		if errors.Is(err, apierror.Errors.WEBAUTHN_VERIFICATION_FAILED) {
+			left := h.challenges.Fail(req.Challenge)
+			h.loginFailed(c, user.Username, FailureReasonBadPasskey)
+			apierror.Abort(c, apierror.Errors.WEBAUTHN_VERIFICATION_FAILED.WithMeta(gin.H{"attempts_left": left}))
+			return
+		}
+		apierror.Abort(c, err)
+		return
+	}
+	h.challenges.Delete(req.Challenge)
+
+	firstFactor := challenge.FirstFactor
+	if firstFactor == "" {
+		firstFactor = AMRPassword
+	}
+	h.issueSession(c, challenge.Result, []string{firstFactor, AMRHardwareKey, AMRMFA}, nil, challenge.Device)
+}
+
+// finishPasswordlessLogin logs in with a passkey alone. The ceremony required
+// user verification, so the login counts as multi-factor.
+func (h *handler) finishPasswordlessLogin(
+	c *gin.Context,
+	req WebAuthnLoginFinishRequest,
+) {
+	login, err := h.webauthn.FinishLogin(c.Request.Context(), 0, req.Credential)
+	if err != nil {
+		if errors.Is(err, apierror.Errors.WEBAUTHN_VERIFICATION_FAILED) {
+			h.loginFailed(c, "", FailureReasonBadPasskey)
+		}
+		apierror.Abort(c, err)
+		return
+	}
+
+	result, err := h.authManager.Reload(c.Request.Context(), login.UserID)
+	if err != nil {
+		if reason := failureReason(err); reason != "" {
+			h.loginFailed(c, "", reason)
+			apierror.Abort(c, apierror.Errors.INVALID_CREDENTIALS)
+			return
+		}
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+
+	h.issueSession(c, result, []string{AMRHardwareKey, AMRMFA}, nil, req.Device)
+}
+
+// passkeysAvailable rejects passkey management when WebAuthn is disabled or
+// the caller authenticated with an API key.
+func (h *handler) passkeysAvailable(c *gin.Context) bool {
+	if h.webauthn == nil {
+		apierror.Abort(c, apierror.Errors.WEBAUTHN_DISABLED)
+		return false
+	}
+	if IsAPIKeyRequest(c) {
+		apierror.Abort(c, apierror.Errors.API_KEY_NOT_ALLOWED)
+		return false
+	}
+	return true
+}
+
+// BeginReauthPasskey godoc
+// @Summary      Start passkey re-authentication
+// @Description  Returns PublicKeyCredentialRequestOptions limited to the caller's passkeys.
+// @Description  Complete the ceremony with the assertion in "credential" of /auth/reauth.
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200 {object} WebAuthnRequestOptions
+// @Failure      403 {object} apierror.AppError
+// @Failure      404 {object} apierror.AppError
+// @Router       /auth/reauth/passkey [post]
+func (h *handler) BeginReauthPasskey(c *gin.Context) {
+	if !h.passkeysAvailable(c) {
+		return
+	}
+	userID, _ := GetActor(c)
+
+	options, err := h.webauthn.BeginLogin(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// Reauthenticate godoc
+// @Summary      Confirm identity for sensitive operations
+// @Description  Proves the caller is still present with the password, a TOTP code or a passkey and marks the
+// @Description  current session as freshly authenticated. Sensitive routes answer REAUTH_REQUIRED until then.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body ReauthRequest true "Exactly one of password, code or credential"
+// @Success      200 {object} ReauthResponse
+// @Failure      400 {object} apierror.AppError
+// @Failure      401 {object} apierror.AppError
+// @Failure      403 {object} apierror.AppError
+// @Failure      429 {object} apierror.AppError
+// @Router       /auth/reauth [post]
+func (h *handler) Reauthenticate(c *gin.Context) {
+	if IsAPIKeyRequest(c) {
+		apierror.Abort(c, apierror.Errors.API_KEY_NOT_ALLOWED)
+		return
+	}
+
+	var req ReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.Wrap(err))
+		return
+	}
+	provided := 0
+	for _, set := range []bool{req.Password != "", req.Code != "", req.Credential != nil} {
+		if set {
+			provided++
+		}
+	}
+	if provided != 1 {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("exactly one of 'password', 'code' or 'credential' is required"))
+		return
+	}
+	userID, username := GetActor(c)
+
+	method, err := h.verifyIdentity(c, userID, username, req)
+	h.recordUserAudit(c, userID, username, AuditActionReauth, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	jti, _ := GetJTI(c)
+	now := time.Now().Unix()
+	if err := h.tokenRepo.SetAuthTime(jti, now); err != nil {
+		if errors.Is(err, sqlite3_local.ErrTokenNotFound) {
+			apierror.Abort(c, apierror.Errors.TOKEN_EXPIRED)
+			return
+		}
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+
+	h.logger.Info("Session re-authenticated", zap.String("username", username), zap.String("method", method))
+	c.JSON(
+		http.StatusOK, ReauthResponse{
+			Success:         true,
+			Message:         MsgReauthSuccess,
+			Method:          method,
+			AuthenticatedAt: now,
+		},
+	)
+}
+
+// verifyIdentity checks the single factor given in req and returns its
+// method name. A locked account is refused before any factor is checked;
+// failures go to the failure log like failed logins.
+func (h *handler) verifyIdentity(
+	c *gin.Context,
+	userID int,
+	username string,
+	req ReauthRequest,
+) (string, error) {
+	ctx := c.Request.Context()
+	if err := h.lockout(c, username); err != nil {
+		return "", err
+	}
+
+	switch {
+	case req.Password != "":
+		if _, err := h.authManager.Login(ctx, username, req.Password); err != nil {
+			if reason := failureReason(err); reason != "" {
+				h.loginFailed(c, username, reason)
+				return "", apierror.Errors.INVALID_CREDENTIALS
+			}
+			return "", apierror.Errors.DATABASE_ERROR.Wrap(err)
+		}
+		return ReauthMethodPassword, nil
+	case req.Code != "":
+		if err := h.twoFactor.Verify(ctx, userID, req.Code, ""); err != nil {
+			h.twoFactorFailed(c, username, err)
+			return "", err
+		}
+		return TwoFactorMethodTOTP, nil
+	default:
+		if h.webauthn == nil {
+			return "", apierror.Errors.WEBAUTHN_DISABLED
+		}
+		if _, err := h.webauthn.FinishLogin(ctx, userID, *req.Credential); err != nil {
+			if errors.Is(err, apierror.Errors.WEBAUTHN_VERIFICATION_FAILED) {
+				h.loginFailed(c, username, FailureReasonBadPasskey)
+			}
+			return "", err
+		}
+		return TwoFactorMethodWebAuthn, nil
+	}
+}
+
+// ChangePassword godoc
+// @Summary      Change own password
+// @Description  Verifies the current password, checks the new one against the password policy and stores it.
+// @Description  Every other session of the user is revoked; the current one stays logged in.
+// @Description  A wrong current password counts as a failed login.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body ChangePasswordRequest true "Current and new password"
+// @Success      200 {object} ChangePasswordResponse
+// @Failure      400 {object} apierror.AppError
+// @Failure      401 {object} apierror.AppError
+// @Failure      403 {object} apierror.AppError
+// @Failure      429 {object} apierror.AppError
+// @Router       /auth/password [post]
+func (h *handler) ChangePassword(c *gin.Context) {
+	if IsAPIKeyRequest(c) {
+		apierror.Abort(c, apierror.Errors.API_KEY_NOT_ALLOWED)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.Wrap(err))
+		return
+	}
+	userID, username := GetActor(c)
+
+	if err := h.lockout(c, username); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	err := h.authManager.ChangePassword(c.Request.Context(), userID, username, req.CurrentPassword, req.NewPassword)
+	h.recordUserAudit(c, userID, username, AuditActionPasswordChange, err)
+	if err != nil {
+		if reason := failureReason(err); reason != "" {
+			h.loginFailed(c, username, reason)
+			apierror.Abort(c, apierror.Errors.INVALID_CREDENTIALS)
+			return
+		}
+		apierror.Abort(c, err)
+		return
+	}
+
+	jti, _ := GetJTI(c)
+	revoked, err := h.tokenRepo.RevokeOtherUserTokens(username, jti, userID, username)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+		return
+	}
+
+	h.logger.Info("Password changed", zap.String("username", username), zap.Int64("revoked_sessions", revoked))
+	c.JSON(
+		http.StatusOK, ChangePasswordResponse{
+			Success:         true,
+			Message:         MsgPasswordChanged,
+			RevokedSessions: revoked,
+		},
+	)
+}
+
+// GetLoginAttempts godoc
+// @Summary      Login attempt history
+// @Description  Returns recorded login attempts with IP, user agent and result, newest first.
+// @Description  Result is "success", "failure" or "locked" (refused while the account was locked).
+// @Tags         auth
+// @Security     CookieAuth
+// @Param        username  query  string  false  "Username"
+// @Param        ip        query  string  false  "IP address"
+// @Param        since     query  int     false  "Unix timestamp, only attempts at or after it"
+// @Param        limit     query  int     false  "Max attempts (default 100, max 1000)"
+// @Produce      json
+// @Success      200  {object}  LoginAttemptListResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /auth/login-attempts [get]
+func (h *handler) GetLoginAttempts(c *gin.Context) {
+	filter, err := parseLoginAttemptFilter(c)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta(err.Error()))
+		return
+	}
+
+	attempts, err := h.monitor.Attempts(filter)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, LoginAttemptListResponse{Attempts: attempts, Total: len(attempts)})
+}
+
+// Introspect godoc
+// @Summary      Token introspection
+// @Description  RFC 7662 introspection for other services: tells whether an access token or API key is usable
+// @Description  and returns its owner with the current roles and permissions. Unusable tokens yield only
+// @Description  "active": false. The caller authenticates with its client ID and secret over HTTP Basic auth.
+// @Tags         auth
+// @Security     IntrospectionClient
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token            formData  string  true   "Access token or API key"
+// @Param        token_type_hint  formData  string  false  "Ignored; the token type is detected"
+// @Success      200  {object}  IntrospectionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      401  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /auth/introspect [post]
+func (h *handler) Introspect(c *gin.Context) {
+	if h.introspection == nil {
+		apierror.Abort(c, apierror.Errors.INTROSPECTION_DISABLED)
+		return
+	}
+
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok || !h.introspection.AuthenticateClient(id, secret) {
+		h.logger.Warn("Introspection client rejected", zap.String("client_id", id), zap.String("ip", c.ClientIP()))
+		c.Header("WWW-Authenticate", `Basic realm="introspection"`)
+		apierror.Abort(c, apierror.Errors.INVALID_CLIENT)
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("form field 'token' is required"))
+		return
+	}
+
+	resp, err := h.introspection.Introspect(c.Request.Context(), token)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListAPIKeys godoc
+// @Summary      List own API keys
+// @Description  Returns the caller's API keys. Secrets are never returned.
+// @Tags         auth
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200 {object} APIKeyListResponse
+// @Failure      403 {object} apierror.AppError
+// @Router       /auth/api-keys [get]
+func (h *handler) ListAPIKeys(c *gin.Context) {
+	userID, _ := GetActor(c)
+
+	keys, err := h.apiKeys.List(userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, APIKeyListResponse{Keys: keys, Total: len(keys)})
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Issues a key limited to a subset of the caller's permissions. Use it as
+// @Description  "Authorization: Bearer <key>" or "X-API-Key: <key>". The key is shown only once.
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body CreateAPIKeyRequest true "Key name, permissions and lifetime"
+// @Success      201 {object} APIKeyCreatedResponse
+// @Failure      400 {object} apierror.AppError
+// @Failure      403 {object} apierror.AppError
+// @Failure      409 {object} apierror.AppError
+// @Router       /auth/api-keys/create [post]
+func (h *handler) CreateAPIKey(c *gin.Context) {
+	if IsAPIKeyRequest(c) {
+		apierror.Abort(c, apierror.Errors.API_KEY_NOT_ALLOWED)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.Wrap(err))
+		return
+	}
+	userID, username := GetActor(c)
+
+	res, err := h.apiKeys.Create(c.Request.Context(), userID, username, req)
+	h.recordUserAudit(c, userID, username, AuditActionAPIKeyCreate, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Tags         auth
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request body RevokeAPIKeyRequest true "Key ID"
+// @Success      200 {object} AuthStatusResponse
+// @Failure      404 {object} apierror.AppError
+// @Router       /auth/api-keys/revoke [post]
+func (h *handler) RevokeAPIKey(c *gin.Context) {
+	if IsAPIKeyRequest(c) {
+		apierror.Abort(c, apierror.Errors.API_KEY_NOT_ALLOWED)
+		return
+	}
+
+	var req RevokeAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	userID, username := GetActor(c)
+
+	err := h.apiKeys.Revoke(userID, req.ID)
+	h.recordUserAudit(c, userID, username, AuditActionAPIKeyRevoke, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, AuthStatusResponse{Success: true, Message: MsgAPIKeyRevoked, Username: username})
+}
+
+// secondFactors lists the second factors the user can complete a login with
+// and reports whether a role requires one.
+func (h *handler) secondFactors(
+	c *gin.Context,
+	userID int,
+) ([]string, bool, error) {
+	enabled, required, err := h.twoFactor.LoginRequirement(c.Request.Context(), userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var methods []string
+	if enabled {
+		methods = append(methods, TwoFactorMethodTOTP)
+	}
+	if h.webauthn != nil {
+		hasPasskeys, err := h.webauthn.HasPasskeys(c.Request.Context(), userID)
+		if err != nil {
+			return nil, false, err
+		}
+		if hasPasskeys {
+			methods = append(methods, TwoFactorMethodWebAuthn)
+		}
+	}
+	return methods, required, nil
+}
+
+// lockout refuses a credential check while the username is locked after
+// repeated failures. The refusal is logged for fail2ban like a failure.
+func (h *handler) lockout(
+	c *gin.Context,
+	username string,
+) error {
+	retryAfter, err := h.monitor.Check(newLoginAttempt(c, username, ""))
+	if err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if retryAfter > 0 {
+		h.failureLog.LogFailure(c.ClientIP(), username, FailureReasonLocked)
+		return apierror.Errors.ACCOUNT_LOCKED.WithMeta(gin.H{"retry_after": int(retryAfter.Seconds())})
+	}
+	return nil
+}
+
+// loginFailed reports a failed authentication to the fail2ban log and to the
+// login monitor, which counts it against the username.
+func (h *handler) loginFailed(
+	c *gin.Context,
+	username, reason string,
+) {
+	h.failureLog.LogFailure(c.ClientIP(), username, reason)
+	h.monitor.Failed(newLoginAttempt(c, username, reason))
+}
+
+// twoFactorFailed counts a wrong TOTP or recovery code like a failed login.
+func (h *handler) twoFactorFailed(
+	c *gin.Context,
+	username string,
+	err error,
+) {
+	if errors.Is(err, apierror.Errors.INVALID_TWO_FACTOR_CODE) {
+		h.loginFailed(c, username, FailureReasonBadOTP)
+	}
+}
+
+// revokeRefreshFamily stops a revoked session from being refreshed back.
+func (h *handler) revokeRefreshFamily(jti string) {
+	if err := h.refresh.RevokeByAccessJTI(jti); err != nil {
+		h.logger.Warn("Failed to revoke refresh tokens", zap.String("jti", jti), zap.Error(err))
+	}
+}
+
+func (h *handler) clearSessionCookies(c *gin.Context) {
+	h.cookieService.ClearAuthCookie(c)
+	h.cookieService.ClearRefreshCookie(c)
+	h.cookieService.ClearCSRFCookie(c)
+}
+
+// setCSRFToken hands the session's CSRF token to the client both as a
+// readable cookie and as a response header, for frontends on another origin
+// that cannot read the API's cookies.
+func (h *handler) setCSRFToken(
+	c *gin.Context,
+	csrf string,
+) {
+	h.cookieService.SetCSRFCookie(c, csrf)
+	c.Header(HeaderCSRFToken, csrf)
+}
+
+// recordUserAudit records an action a user performed on their own account.
+// Login steps run before the auth middleware, so the actor is passed in.
+func (h *handler) recordUserAudit(
+	c *gin.Context,
+	userID int,
+	username, action string,
+	err error,
+) {
+	h.audit.Record(
+		audit.Entry{
+			ActorID: userID,
+			Actor:   username,
+			Action:  action,
+			Target:  username,
+			IP:      c.ClientIP(),
+			Success: err == nil,
+		},
+	)
+}
+
+func newLoginAttempt(
+	c *gin.Context,
+	username, reason string,
+) LoginAttempt {
+	return LoginAttempt{
+		Username:  username,
+		IP:        c.ClientIP(),
+		UserAgent: truncateUserAgent(c.Request.UserAgent()),
+		Reason:    reason,
+	}
+}
+
+func newSession(
+	c *gin.Context,
+	jti, username string,
+	expiresAt int64,
+	device string,
+) sqlite3_local.TokenEntity {
+	now := time.Now().Unix()
+	userAgent := truncateUserAgent(c.Request.UserAgent())
+	if device == "" {
+		device = DeviceLabel(userAgent)
+	}
+	return sqlite3_local.TokenEntity{
+		JTI:        jti,
+		Username:   username,
+		ExpiresAt:  expiresAt,
+		IP:         c.ClientIP(),
+		UserAgent:  userAgent,
+		Device:     device,
+		LastSeenAt: now,
+		AuthAt:     now,
+	}
+}
+
+func toSessionResponse(
+	e sqlite3_local.TokenEntity,
+	currentJTI string,
+) SessionResponse {
+	resp := SessionResponse{
+		ID:         e.ID,
+		JTI:        e.JTI,
+		Username:   e.Username,
+		Revoked:    e.Revoked,
+		ExpiresAt:  e.ExpiresAt,
+		CreatedAt:  e.CreatedAt,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		Device:     e.Device,
+		LastSeenAt: e.LastSeenAt,
+		Current:    e.JTI == currentJTI,
+	}
+	if e.RevokedByID.Valid {
+		val := e.RevokedByID.Int64
+		resp.RevokedByID = &val
+	}
+	if e.RevokedByUsername.Valid {
+		resp.RevokedByUsername = e.RevokedByUsername.String
+	}
+	return resp
+}
+
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, postgresql.ErrUserNotFound):
+		return FailureReasonUnknownUser
+	case errors.Is(err, postgresql.ErrInvalidCredentials):
+		return FailureReasonBadPassword
+	case errors.Is(err, postgresql.ErrUserInactive):
+		return FailureReasonInactiveUser
+	}
+	return ""
+}
+
+func withQuery(target, key, value string) string {
+	sep := "?"
+	if strings.Contains(target, "?") {
+		sep = "&"
+	}
+	return target + sep + url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
+func parseLoginAttemptFilter(c *gin.Context) (sqlite3_local.LoginAttemptFilter, error) {
+	filter := sqlite3_local.LoginAttemptFilter{
+		Username: c.Query(QueryParamUsername),
+		IP:       c.Query(QueryParamIP),
+		Limit:    LoginAttemptsDefaultLimit,
+	}
+	if filter.IP != "" && net.ParseIP(filter.IP) == nil {
+		return filter, errors.New("query parameter 'ip' must be a valid IP address")
+	}
+	if raw := c.Query(QueryParamSince); raw != "" {
+		since, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || since < 0 {
+			return filter, errors.New("query parameter 'since' must be a unix timestamp")
+		}
+		filter.Since = since
+	}
+	if raw := c.Query(QueryParamLimit); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > LoginAttemptsMaxLimit {
+			return filter, fmt.Errorf("query parameter 'limit' must be between 1 and %d", LoginAttemptsMaxLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const testTOTPCode = "123456"
+
+// stubTwoFactor accepts testTOTPCode and counts how often a code was checked.
+type stubTwoFactor struct {
+	TwoFactorManager
+	checks int
+}
+
+func (s *stubTwoFactor) check(code string) error {
+	s.checks++
+	if code != testTOTPCode {
+		return apierror.Errors.INVALID_TWO_FACTOR_CODE
+	}
+	return nil
+}
+
+func (s *stubTwoFactor) Verify(_ context.Context, _ int, code, _ string) error {
+	return s.check(code)
+}
+
+func (s *stubTwoFactor) Disable(_ context.Context, _ int, code, _ string) error {
+	return s.check(code)
+}
+
+func (s *stubTwoFactor) RegenerateRecoveryCodes(_ context.Context, _ int, code string) ([]string, error) {
+	if err := s.check(code); err != nil {
+		return nil, err
+	}
+	return []string{"aaaa-bbbb"}, nil
+}
+
+type stubRecorder struct{}
+
+func (stubRecorder) Record(audit.Entry) {}
+
+func newTestHandler(t *testing.T) (*handler, *stubTwoFactor, *monitorFixture) {
+	t.Helper()
+	f := newMonitorFixture(t)
+	failureLog, _ := newTestFailureLog(t, false)
+	twoFactor := &stubTwoFactor{}
+	h := &handler{
+		failureLog: failureLog,
+		twoFactor:  twoFactor,
+		monitor:    f.monitor,
+		audit:      stubRecorder{},
+		logger:     zap.NewNop(),
+	}
+	return h, twoFactor, f
+}
+
+// serve runs a handler method for the logged in user "admin".
+func serve(handle gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(CtxUserID, 1)
+	c.Set(CtxUsername, "admin")
+	handle(c)
+	return w
+}
+
+func TestHandler_TwoFactorCodeLockout(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(h *handler) gin.HandlerFunc
+	}{
+		{"disable", func(h *handler) gin.HandlerFunc { return h.DisableTwoFactor }},
+		{"regenerate", func(h *handler) gin.HandlerFunc { return h.RegenerateRecoveryCodes }},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				h, twoFactor, _ := newTestHandler(t)
+				body := func(code string) string { return `{"code":"` + code + `"}` }
+
+				// Третий неверный код блокирует учётную запись
+				for i := 0; i < 3; i++ {
+					if w := serve(tt.handle(h), body("000000")); w.Code != http.StatusUnauthorized {
+						t.Fatalf("attempt %d: status = %d, body = %s", i+1, w.Code, w.Body)
+					}
+				}
+
+				w := serve(tt.handle(h), body(testTOTPCode))
+				if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "ACCOUNT_LOCKED") {
+					t.Errorf("locked account: status = %d, body = %s", w.Code, w.Body)
+				}
+				if twoFactor.checks != 3 {
+					t.Errorf("code checked %d times, want 3: a locked account must not reach the check", twoFactor.checks)
+				}
+			},
+		)
+	}
+}
+
+func TestHandler_ReauthenticateLockedAccount(t *testing.T) {
+	h, twoFactor, f := newTestHandler(t)
+	for i := 0; i < 3; i++ {
+		f.fail("admin", "203.0.113.1")
+	}
+
+	w := serve(h.Reauthenticate, `{"code":"`+testTOTPCode+`"}`)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "ACCOUNT_LOCKED") {
+		t.Errorf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if twoFactor.checks != 0 {
+		t.Error("a locked account must not reach the code check")
+	}
+}
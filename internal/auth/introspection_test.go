@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+const testIntrospectionKey = "vpsk_abcdefgh_SECRET"
+
+type stubAccess struct{}
+
+func (stubAccess) Access(context.Context, int) (*UserAccess, error) {
+	return &UserAccess{Roles: []string{"operator"}, Permissions: []string{PermPM2ViewBasic, PermPM2ControlRestart}}, nil
+}
+
+type stubAPIKeys struct{}
+
+func (stubAPIKeys) Inspect(_ context.Context, raw string) (*CustomClaims, error) {
+	if raw != testIntrospectionKey {
+		return nil, apierror.Errors.API_KEY_INVALID
+	}
+	return &CustomClaims{
+		Username:    "ci",
+		UserID:      7,
+		JTI:         "vpsk_abcdefgh",
+		Permissions: []string{PermPM2ViewBasic},
+		AMR:         []string{AMRAPIKey},
+	}, nil
+}
+
+func newTestIntrospection(t *testing.T) (*IntrospectionService, *AuthJwtService, *sqlite3_local.TokenRepository) {
+	localDB, err := sqlite3_local.NewLocalDB(filepath.Join(t.TempDir(), "local.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLocalDB: %v", err)
+	}
+	t.Cleanup(localDB.Close)
+
+	tokens := sqlite3_local.NewTokenRepository(localDB, zap.NewNop())
+	jwtSvc := newTestJwtService(time.Hour)
+	cfg := config.IntrospectionConfig{Clients: []config.IntrospectionClient{{ID: "bots", Secret: "s3cret"}}}
+
+	return NewIntrospectionService(cfg, jwtSvc, tokens, stubAPIKeys{}, stubAccess{}, zap.NewNop()), jwtSvc, tokens
+}
+
+func TestIntrospection_AuthenticateClient(t *testing.T) {
+	svc, _, _ := newTestIntrospection(t)
+
+	tests := []struct {
+		id, secret string
+		want       bool
+	}{
+		{"bots", "s3cret", true},
+		{"bots", "wrong", false},
+		{"bots", "", false},
+		{"other", "s3cret", false},
+	}
+	for _, tt := range tests {
+		if got := svc.AuthenticateClient(tt.id, tt.secret); got != tt.want {
+			t.Errorf("AuthenticateClient(%q, %q) = %v, want %v", tt.id, tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestIntrospection_Introspect(t *testing.T) {
+	svc, jwtSvc, tokens := newTestIntrospection(t)
+	ctx := context.Background()
+
+	data := testTokenData("admin")
+	data.AMR = []string{AMRPassword}
+	token, _ := jwtSvc.GenerateToken(data)
+	_ = tokens.SaveToken(data.JTI, data.Username, time.Now().Add(time.Hour).Unix())
+
+	resp, err := svc.Introspect(ctx, token)
+	if err != nil {
+		t.Fatalf("Introspect() error: %v", err)
+	}
+	if !resp.Active || resp.TokenType != TokenTypeAccess || resp.Username != "admin" || resp.UserID != 1 {
+		t.Errorf("resp = %+v, want active access token of admin", resp)
+	}
+	if resp.Scope != PermPM2ViewBasic+" "+PermPM2ControlRestart || len(resp.Roles) != 1 || resp.ExpiresAt == 0 {
+		t.Errorf("scope = %q, roles = %v, exp = %d", resp.Scope, resp.Roles, resp.ExpiresAt)
+	}
+
+	if err := tokens.RevokeToken(data.JTI, 0, "test"); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if resp, _ := svc.Introspect(ctx, token); resp.Active || resp.Username != "" {
+		t.Errorf("revoked token: resp = %+v, want inactive only", resp)
+	}
+
+	key, err := svc.Introspect(ctx, testIntrospectionKey)
+	if err != nil || !key.Active || key.TokenType != TokenTypeAPIKey || key.Username != "ci" {
+		t.Errorf("API key: resp = %+v, err = %v", key, err)
+	}
+
+	for _, raw := range []string{"", "not.a.jwt", "vpsk_abcdefgh_WRONG"} {
+		if resp, err := svc.Introspect(ctx, raw); err != nil || resp.Active {
+			t.Errorf("Introspect(%q) = %+v, %v; want inactive", raw, resp, err)
+		}
+	}
+}
+
+func TestIntrospection_HandleRequest(t *testing.T) {
+	svc, jwtSvc, tokens := newTestIntrospection(t)
+
+	data := testTokenData("admin")
+	token, _ := jwtSvc.GenerateToken(data)
+	_ = tokens.SaveToken(data.JTI, data.Username, time.Now().Add(time.Hour).Unix())
+
+	resp := svc.handleRequest(IntrospectionRequest{ClientID: "bots", ClientSecret: "wrong", Token: token})
+	if resp.Error != IntrospectErrInvalidClient || resp.Active {
+		t.Errorf("bad client: resp = %+v", resp)
+	}
+
+	resp = svc.handleRequest(IntrospectionRequest{ClientID: "bots", ClientSecret: "s3cret", Token: token})
+	if resp.Error != "" || !resp.Active || resp.Username != "admin" {
+		t.Errorf("good client: resp = %+v", resp)
+	}
+}
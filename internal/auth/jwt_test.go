@@ -24,11 +24,9 @@ func newTestJwtService(ttl time.Duration) *AuthJwtService {
 
 func testTokenData(username string) TokenData {
 	return TokenData{
-		UserID:      1,
-		Username:    username,
-		JTI:         "test-jti-12345",
-		Roles:       []string{"user"},
-		Permissions: []string{"pm2.view.basic"},
+		UserID:   1,
+		Username: username,
+		JTI:      "test-jti-12345",
 	}
 }
 
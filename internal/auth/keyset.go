@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	rsaKeyBits    = 3072
+	keyFileSuffix = ".pem"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported private key type")
+
+// SigningKey is one private key of the keyset. The key ID is the file name
+// without the .pem suffix and is sent as the JWT "kid" header.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// KeySet holds every key that may have signed a live token. Only the current
+// key signs; the others are kept for verification until their tokens expire.
+type KeySet struct {
+	mu        sync.RWMutex
+	dir       string
+	algorithm string
+	keys      map[string]*SigningKey
+	current   *SigningKey
+	now       func() time.Time
+	logger    *zap.Logger
+}
+
+// LoadKeySet reads all PEM private keys (PKCS#8) from dir. The signing key is
+// signingKID when set, otherwise the newest key of the configured algorithm;
+// a new key is generated and persisted when none exists.
+func LoadKeySet(
+	dir, algorithm, signingKID string,
+	logger *zap.Logger,
+) (*KeySet, error) {
+	if algorithm != AlgRS256 && algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		dir:       dir,
+		algorithm: algorithm,
+		keys:      make(map[string]*SigningKey),
+		now:       time.Now,
+		logger:    logger.Named("jwt_keys"),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), keyFileSuffix) {
+			continue
+		}
+		key, err := readKeyFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", e.Name(), err)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	if signingKID != "" {
+		key, ok := ks.keys[signingKID]
+		if !ok {
+			return nil, fmt.Errorf("signing key %q not found in %s", signingKID, dir)
+		}
+		ks.current = key
+	} else {
+		ks.current = ks.newest(algorithm)
+	}
+
+	if ks.current == nil {
+		if _, err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	ks.logger.Info(
+		"JWT keyset loaded",
+		zap.String("signing_kid", ks.current.ID),
+		zap.String("algorithm", ks.current.Algorithm),
+		zap.Int("keys", len(ks.keys)),
+	)
+	return ks, nil
+}
+
+func (ks *KeySet) Signing() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.current
+}
+
+func (ks *KeySet) Get(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Algorithms lists the JWT algorithms of all loaded keys.
+func (ks *KeySet) Algorithms() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	algs := make([]string, 0, 2)
+	for _, k := range ks.keys {
+		if !slices.Contains(algs, k.Algorithm) {
+			algs = append(algs, k.Algorithm)
+		}
+	}
+	return algs
+}
+
+// Rotate generates a new key, persists it and makes it the signing key.
+func (ks *KeySet) Rotate() (*SigningKey, error) {
+	key, err := generateKey(ks.algorithm, ks.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyFile(filepath.Join(ks.dir, key.ID+keyFileSuffix), key.Private); err != nil {
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	ks.keys[key.ID] = key
+	ks.current = key
+	ks.mu.Unlock()
+
+	ks.logger.Info("JWT signing key generated", zap.String("kid", key.ID), zap.String("algorithm", key.Algorithm))
+	return key, nil
+}
+
+// RotateIfDue rotates when the signing key is older than interval and then
+// removes keys that stopped signing more than retain ago: no token signed by
+// them can still be valid.
+func (ks *KeySet) RotateIfDue(interval, retain time.Duration) error {
+	now := ks.now()
+	if now.Sub(ks.Signing().CreatedAt) >= interval {
+		if _, err := ks.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// A key stopped signing when its successor was created.
+	ordered := make([]*SigningKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		ordered = append(ordered, k)
+	}
+	slices.SortFunc(ordered, func(a, b *SigningKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for i, k := range ordered[:len(ordered)-1] {
+		if k == ks.current || now.Sub(ordered[i+1].CreatedAt) < retain {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ks.dir, k.ID+keyFileSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		delete(ks.keys, k.ID)
+		ks.logger.Info("JWT key retired", zap.String("kid", k.ID))
+	}
+	return nil
+}
+
+// Run rotates keys on schedule until ctx is cancelled.
+func (ks *KeySet) Run(
+	ctx context.Context,
+	interval, retain time.Duration,
+) {
+	ticker := time.NewTicker(min(interval, time.Hour))
+	defer ticker.Stop()
+	for {
+		if err := ks.RotateIfDue(interval, retain); err != nil {
+			ks.logger.Error("JWT key rotation failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// JWKS returns the public keys in JSON Web Key Set format (RFC 7517).
+func (ks *KeySet) JWKS() JWKSResponse {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	res := JWKSResponse{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		res.Keys = append(res.Keys, publicJWK(k))
+	}
+	slices.SortFunc(res.Keys, func(a, b JWK) int { return strings.Compare(a.KeyID, b.KeyID) })
+	return res
+}
+
+func (ks *KeySet) newest(algorithm string) *SigningKey {
+	var newest *SigningKey
+	for _, k := range ks.keys {
+		if k.Algorithm == algorithm && (newest == nil || k.CreatedAt.After(newest.CreatedAt)) {
+			newest = k
+		}
+	}
+	return newest
+}
+
+func publicJWK(k *SigningKey) JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	enc := base64.RawURLEncoding
+	switch pub := k.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = enc.EncodeToString(pub.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = enc.EncodeToString(pub)
+	}
+	return jwk
+}
+
+func generateKey(
+	algorithm string,
+	now time.Time,
+) (*SigningKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:        now.UTC().Format("20060102-150405") + "-" + strings.ToLower(rand.Text()[:6]),
+		Algorithm: algorithm,
+		Private:   private,
+		CreatedAt: now,
+	}, nil
+}
+
+func readKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from the configured keys directory
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		ID:        strings.TrimSuffix(filepath.Base(path), keyFileSuffix),
+		CreatedAt: info.ModTime(),
+	}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.Private = AlgRS256, k
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Private = AlgEdDSA, k
+	default:
+		return nil, ErrUnsupportedKey
+	}
+	return key, nil
+}
+
+func writeKeyFile(
+	path string,
+	private crypto.Signer,
+) error {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
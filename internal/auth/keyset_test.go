@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"VPS-control/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func newTestKeyJwtService(t *testing.T, algorithm, secret string) *AuthJwtService {
+	t.Helper()
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:    secret,
+			Issuer:    "test-issuer",
+			TTL:       time.Hour,
+			Algorithm: algorithm,
+			KeysDir:   t.TempDir(),
+		},
+	}
+	return NewAuthJwtService(cfg, zap.NewNop())
+}
+
+func TestKeySetPersistsGeneratedKey(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := LoadKeySet(dir, AlgEdDSA, "", zap.NewNop())
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	kid := first.Signing().ID
+
+	info, err := os.Stat(filepath.Join(dir, kid+keyFileSuffix))
+	if err != nil {
+		t.Fatalf("key file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	second, err := LoadKeySet(dir, AlgEdDSA, "", zap.NewNop())
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if second.Signing().ID != kid {
+		t.Errorf("signing kid after reload = %q, want %q", second.Signing().ID, kid)
+	}
+
+	if _, err := LoadKeySet(dir, AlgEdDSA, "missing", zap.NewNop()); err == nil {
+		t.Error("expected error for unknown signing_kid")
+	}
+}
+
+func TestJwtSignsWithKeyID(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgRS256} {
+		t.Run(
+			alg, func(t *testing.T) {
+				svc := newTestKeyJwtService(t, alg, "")
+
+				token, err := svc.GenerateToken(testTokenData("keyuser"))
+				if err != nil {
+					t.Fatalf("GenerateToken: %v", err)
+				}
+				claims, err := svc.ValidateToken(token)
+				if err != nil {
+					t.Fatalf("ValidateToken: %v", err)
+				}
+				if claims.Username != "keyuser" {
+					t.Errorf("username = %q, want keyuser", claims.Username)
+				}
+
+				jwks := svc.JWKS()
+				if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != svc.Keys().Signing().ID || jwks.Keys[0].Algorithm != alg {
+					t.Errorf("jwks = %+v, want the signing key", jwks)
+				}
+			},
+		)
+	}
+}
+
+func TestJwtRotationKeepsOldTokensValid(t *testing.T) {
+	svc := newTestKeyJwtService(t, AlgEdDSA, "")
+	keys := svc.Keys()
+
+	before, _ := svc.GenerateToken(testTokenData("rotuser"))
+	oldKID := keys.Signing().ID
+
+	keys.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	if err := keys.RotateIfDue(24*time.Hour, time.Hour); err != nil {
+		t.Fatalf("RotateIfDue: %v", err)
+	}
+	if keys.Signing().ID == oldKID {
+		t.Fatal("signing key was not rotated")
+	}
+	if _, err := svc.ValidateToken(before); err != nil {
+		t.Errorf("token signed by previous key rejected: %v", err)
+	}
+	if len(svc.JWKS().Keys) != 2 {
+		t.Errorf("jwks keys = %d, want 2", len(svc.JWKS().Keys))
+	}
+
+	// Once the retain window after rotation has passed the old key is removed.
+	keys.now = func() time.Time { return time.Now().Add(50 * time.Hour) }
+	if err := keys.RotateIfDue(24*time.Hour, time.Hour); err != nil {
+		t.Fatalf("RotateIfDue: %v", err)
+	}
+	if _, ok := keys.Get(oldKID); ok {
+		t.Error("retired key should be removed")
+	}
+	if _, err := svc.ValidateToken(before); err == nil {
+		t.Error("token signed by retired key should be rejected")
+	}
+}
+
+func TestJwtAcceptsLegacyHS256(t *testing.T) {
+	legacy := newTestJwtService(time.Hour)
+	token, _ := legacy.GenerateToken(testTokenData("legacy"))
+
+	t.Run(
+		"secret configured", func(t *testing.T) {
+			svc := newTestKeyJwtService(t, AlgEdDSA, "test-secret-key-minimum-32-chars!")
+			if _, err := svc.ValidateToken(token); err != nil {
+				t.Errorf("legacy token rejected: %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"secret removed", func(t *testing.T) {
+			svc := newTestKeyJwtService(t, AlgEdDSA, "")
+			if _, err := svc.ValidateToken(token); err == nil {
+				t.Error("legacy token must be rejected without a secret")
+			}
+		},
+	)
+}
@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+const (
+	uaFirefoxLinux = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	uaChromeMac    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+)
+
+type anomalyPublisher struct {
+	events []LoginAnomalyEvent
+}
+
+func (p *anomalyPublisher) Publish(subject string, data any) error {
+	if subject == SubjectLoginAnomaly {
+		p.events = append(p.events, data.(LoginAnomalyEvent))
+	}
+	return nil
+}
+
+type chanNotifier chan LoginAnomalyEvent
+
+func (n chanNotifier) Notify(_ context.Context, event LoginAnomalyEvent) error {
+	n <- event
+	return nil
+}
+
+type monitorFixture struct {
+	monitor   *LoginMonitorService
+	attempts  *sqlite3_local.LoginAttemptRepository
+	publisher *anomalyPublisher
+	notified  chanNotifier
+	now       time.Time
+}
+
+func newMonitorFixture(t *testing.T) *monitorFixture {
+	localDB, err := sqlite3_local.NewLocalDB(filepath.Join(t.TempDir(), "local.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLocalDB: %v", err)
+	}
+	t.Cleanup(localDB.Close)
+
+	f := &monitorFixture{
+		attempts:  sqlite3_local.NewLoginAttemptRepository(localDB, zap.NewNop()),
+		publisher: &anomalyPublisher{},
+		notified:  make(chanNotifier, 8),
+		now:       time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+	f.monitor = NewLoginMonitorService(
+		config.LockoutConfig{
+			FreeAttempts: 3,
+			BaseDelay:    30 * time.Second,
+			MaxDelay:     2 * time.Minute,
+			ResetAfter:   time.Hour,
+			Retention:    24 * time.Hour,
+		},
+		f.attempts,
+		sqlite3_local.NewAccountLockoutRepository(localDB, zap.NewNop()),
+		f.publisher,
+		f.notified,
+		zap.NewNop(),
+	)
+	f.monitor.now = func() time.Time { return f.now }
+	return f
+}
+
+func (f *monitorFixture) check(t *testing.T, username string) time.Duration {
+	t.Helper()
+	remaining, err := f.monitor.Check(LoginAttempt{Username: username, IP: "198.51.100.1"})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	return remaining
+}
+
+func (f *monitorFixture) fail(username, ip string) {
+	f.monitor.Failed(LoginAttempt{Username: username, IP: ip, Reason: FailureReasonBadPassword})
+}
+
+func (f *monitorFixture) succeed(username, ip, userAgent string) {
+	f.monitor.Succeeded(LoginAttempt{UserID: 1, Username: username, IP: ip, UserAgent: userAgent, AMR: []string{AMRPassword}})
+}
+
+func TestLoginMonitor_ProgressiveLockout(t *testing.T) {
+	f := newMonitorFixture(t)
+
+	// Every guess comes from a different address; the username is what counts.
+	want := []time.Duration{0, 0, 30 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute}
+	for i, delay := range want {
+		f.fail("admin", "203.0.113."+string(rune('1'+i)))
+		if got := f.check(t, "admin"); got != delay {
+			t.Fatalf("after %d failures locked for %v, want %v", i+1, got, delay)
+		}
+	}
+
+	if got := f.check(t, "operator"); got != 0 {
+		t.Errorf("other username locked for %v, want 0", got)
+	}
+
+	f.now = f.now.Add(2 * time.Minute)
+	if got := f.check(t, "admin"); got != 0 {
+		t.Errorf("lock should expire, still %v", got)
+	}
+	f.fail("admin", "203.0.113.9")
+	if got := f.check(t, "admin"); got != 2*time.Minute {
+		t.Errorf("next failure should keep the maximum delay, got %v", got)
+	}
+}
+
+func TestLoginMonitor_CounterReset(t *testing.T) {
+	t.Run(
+		"successful login", func(t *testing.T) {
+			f := newMonitorFixture(t)
+			f.fail("admin", "203.0.113.1")
+			f.fail("admin", "203.0.113.1")
+			f.succeed("admin", "203.0.113.1", uaFirefoxLinux)
+			f.fail("admin", "203.0.113.1")
+			if got := f.check(t, "admin"); got != 0 {
+				t.Errorf("counter should start over after a login, locked for %v", got)
+			}
+		},
+	)
+
+	t.Run(
+		"quiet period", func(t *testing.T) {
+			f := newMonitorFixture(t)
+			f.fail("admin", "203.0.113.1")
+			f.fail("admin", "203.0.113.1")
+			f.now = f.now.Add(2 * time.Hour)
+			f.fail("admin", "203.0.113.1")
+			if got := f.check(t, "admin"); got != 0 {
+				t.Errorf("old failures should be forgotten, locked for %v", got)
+			}
+		},
+	)
+}
+
+func TestLoginMonitor_RecordsAttempts(t *testing.T) {
+	f := newMonitorFixture(t)
+
+	f.fail("admin", "203.0.113.1")
+	f.fail("admin", "203.0.113.2")
+	f.fail("admin", "203.0.113.3")
+	first := f.check(t, "admin")
+	f.now = f.now.Add(10 * time.Second)
+	if got := f.check(t, "admin"); got != first-10*time.Second {
+		t.Errorf("refused attempt must not extend the lock: %v, want %v", got, first-10*time.Second)
+	}
+	f.now = f.now.Add(time.Minute)
+	f.succeed("admin", "203.0.113.3", uaFirefoxLinux)
+
+	attempts, err := f.monitor.Attempts(sqlite3_local.LoginAttemptFilter{Username: "admin", Limit: 10})
+	if err != nil {
+		t.Fatalf("Attempts: %v", err)
+	}
+	var results []string
+	for _, a := range attempts {
+		results = append(results, a.Result)
+	}
+	want := "success,locked,locked,failure,failure,failure"
+	if got := strings.Join(results, ","); got != want {
+		t.Errorf("results = %s, want %s", got, want)
+	}
+	if attempts[0].Device != "Firefox on Linux" || attempts[0].UserAgent != uaFirefoxLinux {
+		t.Errorf("success attempt = %+v, want user agent and device", attempts[0])
+	}
+	if attempts[1].Reason != FailureReasonLocked || attempts[3].Reason != FailureReasonBadPassword {
+		t.Errorf("reasons = %q, %q", attempts[1].Reason, attempts[3].Reason)
+	}
+
+	f.now = f.now.Add(25 * time.Hour)
+	f.monitor.purge()
+	if attempts, _ := f.monitor.Attempts(sqlite3_local.LoginAttemptFilter{Limit: 10}); len(attempts) != 0 {
+		t.Errorf("%d attempts left after retention", len(attempts))
+	}
+}
+
+func TestLoginMonitor_NewSourceAlerts(t *testing.T) {
+	tests := []struct {
+		name          string
+		ip            string
+		userAgent     string
+		wantAlert     bool
+		wantNewIP     bool
+		wantNewDevice bool
+	}{
+		{"known ip and device", "203.0.113.1", uaFirefoxLinux, false, false, false},
+		{"new ip", "198.51.100.7", uaFirefoxLinux, true, true, false},
+		{"new device", "203.0.113.1", uaChromeMac, true, false, true},
+		{"new ip and device", "198.51.100.7", uaChromeMac, true, true, true},
+		{"ip only seen in failures", "192.0.2.66", uaFirefoxLinux, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				f := newMonitorFixture(t)
+				f.succeed("admin", "203.0.113.1", uaFirefoxLinux)
+				f.fail("admin", "192.0.2.66")
+				if len(f.publisher.events) != 0 {
+					t.Fatal("the first login has nothing to compare with and must not alert")
+				}
+
+				f.succeed("admin", tt.ip, tt.userAgent)
+				f.monitor.alerts.Wait()
+
+				if !tt.wantAlert {
+					if len(f.publisher.events) != 0 || len(f.notified) != 0 {
+						t.Errorf("unexpected alert %+v", f.publisher.events)
+					}
+					return
+				}
+				if len(f.publisher.events) != 1 {
+					t.Fatalf("published %d events, want 1", len(f.publisher.events))
+				}
+				event := f.publisher.events[0]
+				if event.NewIP != tt.wantNewIP || event.NewDevice != tt.wantNewDevice {
+					t.Errorf("new_ip=%v new_device=%v, want %v %v", event.NewIP, event.NewDevice, tt.wantNewIP, tt.wantNewDevice)
+				}
+				if event.Username != "admin" || event.IP != tt.ip || event.Timestamp != f.now.Unix() {
+					t.Errorf("event = %+v", event)
+				}
+				if notified := <-f.notified; notified.IP != tt.ip {
+					t.Errorf("notified %+v, want the published event", notified)
+				}
+			},
+		)
+	}
+}
+
+func TestLoginAlertService_Notify(t *testing.T) {
+	event := LoginAnomalyEvent{
+		UserID:    1,
+		Username:  "admin",
+		IP:        "198.51.100.7",
+		Device:    "Chrome on macOS",
+		NewIP:     true,
+		NewDevice: true,
+		AMR:       []string{AMRPassword, AMROTP, AMRMFA},
+		Timestamp: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC).Unix(),
+	}
+
+	t.Run(
+		"webhook and email", func(t *testing.T) {
+			var posted LoginAnomalyEvent
+			server := httptest.NewServer(
+				http.HandlerFunc(
+					func(w http.ResponseWriter, r *http.Request) {
+						if r.Header.Get("Content-Type") != "application/json" {
+							t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+						}
+						_ = json.NewDecoder(r.Body).Decode(&posted)
+						w.WriteHeader(http.StatusNoContent)
+					},
+				),
+			)
+			defer server.Close()
+
+			svc := NewLoginAlertService(
+				config.LoginAlertsConfig{
+					Webhook: config.LoginAlertWebhookConfig{URL: server.URL, Timeout: time.Second},
+					Email: config.LoginAlertEmailConfig{
+						Host: "smtp.example.com", Port: 587, From: "vps@example.com", To: []string{"ops@example.com"},
+					},
+				},
+				zap.NewNop(),
+			)
+			var (
+				addr string
+				mail string
+			)
+			svc.sendMail = func(a string, _ smtp.Auth, _ string, to []string, msg []byte) error {
+				addr, mail = a, string(msg)
+				return nil
+			}
+
+			if !svc.Enabled() {
+				t.Fatal("service should be enabled")
+			}
+			if err := svc.Notify(context.Background(), event); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			if posted.Username != "admin" || !posted.NewIP {
+				t.Errorf("webhook got %+v", posted)
+			}
+			if addr != "smtp.example.com:587" {
+				t.Errorf("smtp addr = %q", addr)
+			}
+			for _, want := range []string{
+				"Subject: New login for admin\r\n",
+				"To: ops@example.com\r\n",
+				"from a new IP address and device.",
+				"IP address: 198.51.100.7",
+			} {
+				if !strings.Contains(mail, want) {
+					t.Errorf("mail does not contain %q:\n%s", want, mail)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"failing channel does not skip the other", func(t *testing.T) {
+			server := httptest.NewServer(
+				http.HandlerFunc(
+					func(w http.ResponseWriter, _ *http.Request) {
+						w.WriteHeader(http.StatusBadGateway)
+					},
+				),
+			)
+			defer server.Close()
+
+			svc := NewLoginAlertService(
+				config.LoginAlertsConfig{
+					Webhook: config.LoginAlertWebhookConfig{URL: server.URL, Timeout: time.Second},
+					Email:   config.LoginAlertEmailConfig{Host: "smtp.example.com", Port: 25, To: []string{"ops@example.com"}},
+				},
+				zap.NewNop(),
+			)
+			mailed := false
+			svc.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+				mailed = true
+				return errors.New("connection refused")
+			}
+
+			err := svc.Notify(context.Background(), event)
+			if err == nil || !strings.Contains(err.Error(), "webhook") || !strings.Contains(err.Error(), "email") {
+				t.Errorf("err = %v, want both channels reported", err)
+			}
+			if !mailed {
+				t.Error("email should be tried after the webhook failed")
+			}
+		},
+	)
+
+	t.Run(
+		"nothing configured", func(t *testing.T) {
+			svc := NewLoginAlertService(config.LoginAlertsConfig{Email: config.LoginAlertEmailConfig{Host: "smtp.example.com"}}, zap.NewNop())
+			if svc.Enabled() {
+				t.Error("email without recipients should not enable alerts")
+			}
+		},
+	)
+}
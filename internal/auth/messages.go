@@ -4,11 +4,28 @@ package auth
 const (
 	LogUserLoggedIn  = "User logged in"
 	LogUserLoggedOut = "User logged out"
+
+	LogSessionRefreshed = "Session refreshed"
 )
 
 // Response messages
 const (
-	MsgLoginSuccess  = "Logged in successfully"
-	MsgLogoutSuccess = "Logged out successfully"
-	MsgSessionOK     = "ok"
+	MsgLoginSuccess   = "Logged in successfully"
+	MsgLogoutSuccess  = "Logged out successfully"
+	MsgSessionOK      = "ok"
+	MsgRefreshSuccess = "Session refreshed"
+	MsgSessionRevoked = "Session revoked"
+
+	MsgTwoFactorRequired = "Two-factor code required"
+	MsgTwoFactorEnroll   = "Two-factor enrollment required by role"
+	MsgTwoFactorEnabled  = "Two-factor authentication enabled"
+	MsgTwoFactorDisabled = "Two-factor authentication disabled"
+
+	MsgAPIKeyRevoked = "API key revoked"
+
+	MsgPasskeyDeleted = "Passkey deleted"
+
+	MsgReauthSuccess = "Identity confirmed"
+
+	MsgPasswordChanged = "Password changed"
 )
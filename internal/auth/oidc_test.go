@@ -0,0 +1,421 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// mockProvider is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that checks the PKCE verifier of codes issued by authorize.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key, codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET "+oidcDiscoveryPath, func(w http.ResponseWriter, _ *http.Request) {
+			_ = json.NewEncoder(w).Encode(
+				oidcDiscovery{
+					Issuer:                p.server.URL,
+					AuthorizationEndpoint: p.server.URL + "/authorize",
+					TokenEndpoint:         p.server.URL + "/token",
+					JWKSURI:               p.server.URL + "/jwks",
+				},
+			)
+		},
+	)
+	mux.HandleFunc(
+		"GET /jwks", func(w http.ResponseWriter, _ *http.Request) {
+			enc := base64.RawURLEncoding
+			_ = json.NewEncoder(w).Encode(
+				JWKSResponse{
+					Keys: []JWK{
+						{
+							KeyType:   "RSA",
+							KeyID:     "mock",
+							Use:       "sig",
+							Algorithm: AlgRS256,
+							N:         enc.EncodeToString(key.N.Bytes()),
+							E:         enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+						},
+					},
+				},
+			)
+		},
+	)
+	mux.HandleFunc(
+		"POST /token", func(w http.ResponseWriter, r *http.Request) {
+			p.mu.Lock()
+			grant, ok := p.codes[r.FormValue("code")]
+			delete(p.codes, r.FormValue("code"))
+			p.mu.Unlock()
+
+			sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(oidcTokenResponse{Error: "invalid_grant"})
+				return
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+			token.Header["kid"] = "mock"
+			signed, err := token.SignedString(key)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(oidcTokenResponse{IDToken: signed})
+		},
+	)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize plays the user logging in at the provider: it returns a code for
+// the authorization URL, with the nonce of the request unless claims set one.
+func (p *mockProvider) authorize(
+	t *testing.T,
+	authURL string,
+	claims jwt.MapClaims,
+) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q", q.Get("code_challenge_method"))
+	}
+
+	now := time.Now()
+	full := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   q.Get("client_id"),
+		"sub":   "subject-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+
+	code := rand.Text()
+	p.mu.Lock()
+	p.codes[code] = mockGrant{challenge: q.Get("code_challenge"), claims: full}
+	p.mu.Unlock()
+	return code
+}
+
+type fakeOIDCUserStore struct {
+	postgresql.UserStore
+	users map[string]*postgresql.UserResponseDTO
+}
+
+func (f *fakeOIDCUserStore) GetUserByID(_ context.Context, userID int) (*postgresql.UserResponseDTO, error) {
+	for _, u := range f.users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, postgresql.ErrUserNotFound
+}
+
+func (f *fakeOIDCUserStore) GetUserByUsername(_ context.Context, username string) (*postgresql.UserResponseDTO, error) {
+	if u, ok := f.users[username]; ok {
+		return u, nil
+	}
+	return nil, postgresql.ErrUserNotFound
+}
+
+func (f *fakeOIDCUserStore) CreateUser(
+	_ context.Context,
+	username, _ string,
+	active bool,
+) (*postgresql.UserResponseDTO, error) {
+	u := &postgresql.UserResponseDTO{ID: len(f.users) + 1, Username: username, Active: active}
+	f.users[username] = u
+	return u, nil
+}
+
+type fakeIdentityStore map[string]int
+
+func (f fakeIdentityStore) GetIdentityUser(_ context.Context, issuer, subject string) (int, error) {
+	if id, ok := f[issuer+"|"+subject]; ok {
+		return id, nil
+	}
+	return 0, postgresql.ErrIdentityNotFound
+}
+
+func (f fakeIdentityStore) LinkIdentity(_ context.Context, issuer, subject string, userID int) error {
+	f[issuer+"|"+subject] = userID
+	return nil
+}
+
+type fakeRoleStore struct {
+	postgresql.PermissionStore
+	known []string
+	roles map[int][]string
+}
+
+func (f *fakeRoleStore) GetUserRoles(_ context.Context, userID int) ([]string, error) {
+	return f.roles[userID], nil
+}
+
+func (f *fakeRoleStore) AssignRoleToUser(_ context.Context, userID int, role string) error {
+	if !slices.Contains(f.known, role) {
+		return postgresql.ErrRoleNotFound
+	}
+	f.roles[userID] = append(f.roles[userID], role)
+	return nil
+}
+
+func (f *fakeRoleStore) RemoveRoleFromUser(_ context.Context, userID int, role string) error {
+	f.roles[userID] = slices.DeleteFunc(f.roles[userID], func(r string) bool { return r == role })
+	return nil
+}
+
+type oidcFixture struct {
+	svc        *OIDCService
+	provider   *mockProvider
+	users      *fakeOIDCUserStore
+	identities fakeIdentityStore
+	roles      *fakeRoleStore
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	provider := newMockProvider(t)
+	cfg := config.OIDCConfig{
+		Enabled:       true,
+		Issuer:        provider.server.URL,
+		ClientID:      "vps-control",
+		RedirectURL:   "http://localhost/api/auth/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		RoleMapping:   map[string][]string{"vps-admins": {"admin"}, "vps-ops": {"operator", "missing"}},
+		DefaultRoles:  []string{"user"},
+		AutoCreate:    true,
+		StateTTL:      time.Minute,
+		HTTPTimeout:   5 * time.Second,
+	}
+	f := &oidcFixture{
+		provider:   provider,
+		users:      &fakeOIDCUserStore{users: make(map[string]*postgresql.UserResponseDTO)},
+		identities: fakeIdentityStore{},
+		roles:      &fakeRoleStore{known: []string{"user", "admin", "operator"}, roles: make(map[int][]string)},
+	}
+	f.svc = NewOIDCService(cfg, f.users, f.identities, f.roles, zap.NewNop())
+	return f
+}
+
+// login runs the whole flow and returns the result of Authenticate.
+func (f *oidcFixture) login(t *testing.T, claims jwt.MapClaims) (*OIDCLogin, error) {
+	authURL, state, err := f.svc.AuthCodeURL(context.Background())
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error: %v", err)
+	}
+	code := f.provider.authorize(t, authURL, claims)
+	return f.svc.Authenticate(context.Background(), state, code)
+}
+
+func assertAppError(t *testing.T, err error, want *apierror.AppError) {
+	t.Helper()
+	var appErr *apierror.AppError
+	if !errors.As(err, &appErr) || appErr.Code != want.Code {
+		t.Fatalf("error = %v, want %s", err, want.Code)
+	}
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	login, err := f.login(
+		t, jwt.MapClaims{
+			"preferred_username": "alice",
+			"groups":             []string{"vps-admins", "vps-ops"},
+			"amr":                []string{"pwd", "mfa"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Authenticate() error: %v", err)
+	}
+	if login.Username != "alice" || !slices.Equal(login.AMR, []string{AMROIDC, AMRMFA}) {
+		t.Errorf("login = %+v", login)
+	}
+	roles := f.roles.roles[login.UserID]
+	slices.Sort(roles)
+	if !slices.Equal(roles, []string{"admin", "operator", "user"}) {
+		t.Errorf("roles = %v", roles)
+	}
+
+	// Leaving a group removes the mapped role; the identity is found by subject.
+	login2, err := f.login(t, jwt.MapClaims{"preferred_username": "renamed", "groups": "vps-ops"})
+	if err != nil {
+		t.Fatalf("second Authenticate() error: %v", err)
+	}
+	if login2.UserID != login.UserID || len(f.users.users) != 1 {
+		t.Errorf("second login created a new user: %+v", login2)
+	}
+	roles = f.roles.roles[login.UserID]
+	slices.Sort(roles)
+	if !slices.Equal(roles, []string{"operator", "user"}) {
+		t.Errorf("roles after group removal = %v", roles)
+	}
+}
+
+func TestOIDCLoginRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		setup  func(f *oidcFixture)
+		want   *apierror.AppError
+	}{
+		{
+			name:   "nonce mismatch",
+			claims: jwt.MapClaims{"preferred_username": "alice", "nonce": "other"},
+			want:   apierror.Errors.OIDC_LOGIN_FAILED,
+		},
+		{
+			name:   "wrong audience",
+			claims: jwt.MapClaims{"preferred_username": "alice", "aud": "someone-else"},
+			want:   apierror.Errors.OIDC_LOGIN_FAILED,
+		},
+		{
+			name:   "expired token",
+			claims: jwt.MapClaims{"preferred_username": "alice", "exp": time.Now().Add(-time.Hour).Unix()},
+			want:   apierror.Errors.OIDC_LOGIN_FAILED,
+		},
+		{
+			name:   "invalid username",
+			claims: jwt.MapClaims{"preferred_username": "alice@example.com"},
+			want:   apierror.Errors.OIDC_USERNAME_INVALID,
+		},
+		{
+			name:   "existing local account",
+			claims: jwt.MapClaims{"preferred_username": "admin"},
+			setup: func(f *oidcFixture) {
+				f.users.users["admin"] = &postgresql.UserResponseDTO{ID: 7, Username: "admin", Active: true}
+			},
+			want: apierror.Errors.OIDC_ACCOUNT_CONFLICT,
+		},
+		{
+			name:   "auto create disabled",
+			claims: jwt.MapClaims{"preferred_username": "bob"},
+			setup:  func(f *oidcFixture) { f.svc.cfg.AutoCreate = false },
+			want:   apierror.Errors.OIDC_USER_NOT_PROVISIONED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				f := newOIDCFixture(t)
+				if tt.setup != nil {
+					tt.setup(f)
+				}
+				_, err := f.login(t, tt.claims)
+				assertAppError(t, err, tt.want)
+			},
+		)
+	}
+}
+
+func TestOIDCLinkExisting(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.svc.cfg.LinkExisting = true
+	f.users.users["admin"] = &postgresql.UserResponseDTO{ID: 7, Username: "admin", Active: true}
+
+	login, err := f.login(t, jwt.MapClaims{"preferred_username": "admin"})
+	if err != nil {
+		t.Fatalf("Authenticate() error: %v", err)
+	}
+	if login.UserID != 7 || f.identities[f.provider.server.URL+"|subject-1"] != 7 {
+		t.Errorf("identity not linked to the existing user: %+v", login)
+	}
+}
+
+func TestOIDCStateAndPKCE(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"unknown state", func(t *testing.T) {
+			f := newOIDCFixture(t)
+			_, err := f.svc.Authenticate(ctx, "forged", "code")
+			assertAppError(t, err, apierror.Errors.OIDC_STATE_INVALID)
+		},
+	)
+
+	t.Run(
+		"state is single use", func(t *testing.T) {
+			f := newOIDCFixture(t)
+			authURL, state, _ := f.svc.AuthCodeURL(ctx)
+			code := f.provider.authorize(t, authURL, jwt.MapClaims{"preferred_username": "alice"})
+			if _, err := f.svc.Authenticate(ctx, state, code); err != nil {
+				t.Fatalf("Authenticate() error: %v", err)
+			}
+			_, err := f.svc.Authenticate(ctx, state, code)
+			assertAppError(t, err, apierror.Errors.OIDC_STATE_INVALID)
+		},
+	)
+
+	t.Run(
+		"expired state", func(t *testing.T) {
+			f := newOIDCFixture(t)
+			authURL, state, _ := f.svc.AuthCodeURL(ctx)
+			code := f.provider.authorize(t, authURL, jwt.MapClaims{"preferred_username": "alice"})
+			f.svc.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+			_, err := f.svc.Authenticate(ctx, state, code)
+			assertAppError(t, err, apierror.Errors.OIDC_STATE_INVALID)
+		},
+	)
+
+	t.Run(
+		"code of another login", func(t *testing.T) {
+			f := newOIDCFixture(t)
+			authURL, _, _ := f.svc.AuthCodeURL(ctx)
+			_, otherState, _ := f.svc.AuthCodeURL(ctx)
+			code := f.provider.authorize(t, authURL, jwt.MapClaims{"preferred_username": "alice"})
+
+			// The verifier of the other login does not match the code challenge.
+			_, err := f.svc.Authenticate(ctx, otherState, code)
+			assertAppError(t, err, apierror.Errors.OIDC_LOGIN_FAILED)
+		},
+	)
+}
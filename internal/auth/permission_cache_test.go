@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/nats"
+
+	"go.uber.org/zap"
+)
+
+type fakePermissionStore struct {
+	postgresql.PermissionStore
+	permissions map[int][]string
+	loads       int
+}
+
+func (f *fakePermissionStore) GetUserRoles(context.Context, int) ([]string, error) {
+	return []string{"user"}, nil
+}
+
+func (f *fakePermissionStore) GetUserPermissions(_ context.Context, userID int) ([]string, error) {
+	f.loads++
+	return f.permissions[userID], nil
+}
+
+func (f *fakePermissionStore) RemoveRoleFromUser(_ context.Context, userID int, _ string) error {
+	f.permissions[userID] = nil
+	return nil
+}
+
+func (f *fakePermissionStore) SetRolePermissions(_ context.Context, _ int, permissions []string) error {
+	for id := range f.permissions {
+		f.permissions[id] = permissions
+	}
+	return nil
+}
+
+type fakePublisher struct {
+	published []PermissionInvalidation
+}
+
+func (f *fakePublisher) Publish(_ string, data any) error {
+	f.published = append(f.published, data.(PermissionInvalidation))
+	return nil
+}
+
+func newTestPermissionCache() (*PermissionCache, *fakePermissionStore, *fakePublisher) {
+	store := &fakePermissionStore{
+		permissions: map[int][]string{
+			1: {PermPM2ViewBasic, PermPM2ControlRestart},
+			2: {PermPM2ViewBasic},
+		},
+	}
+	pub := &fakePublisher{}
+	return NewPermissionCache(config.PermissionCacheConfig{TTL: time.Minute}, store, pub, zap.NewNop()), store, pub
+}
+
+func TestPermissionCache_Access(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"cached until ttl", func(t *testing.T) {
+			cache, store, _ := newTestPermissionCache()
+			now := time.Now()
+			cache.now = func() time.Time { return now }
+
+			for range 3 {
+				if _, err := cache.Access(ctx, 1); err != nil {
+					t.Fatalf("Access() error: %v", err)
+				}
+			}
+			if store.loads != 1 {
+				t.Errorf("loads = %d, want 1", store.loads)
+			}
+
+			now = now.Add(time.Minute)
+			if _, err := cache.Access(ctx, 1); err != nil {
+				t.Fatalf("Access() error: %v", err)
+			}
+			if store.loads != 2 {
+				t.Errorf("loads after ttl = %d, want 2", store.loads)
+			}
+		},
+	)
+
+	t.Run(
+		"role removal applies immediately", func(t *testing.T) {
+			cache, _, pub := newTestPermissionCache()
+			if _, err := cache.Access(ctx, 1); err != nil {
+				t.Fatalf("Access() error: %v", err)
+			}
+
+			if err := cache.RemoveRoleFromUser(ctx, 1, "operator"); err != nil {
+				t.Fatalf("RemoveRoleFromUser() error: %v", err)
+			}
+			access, err := cache.Access(ctx, 1)
+			if err != nil {
+				t.Fatalf("Access() error: %v", err)
+			}
+			if len(access.Permissions) != 0 {
+				t.Errorf("permissions = %v, want none", access.Permissions)
+			}
+			if len(pub.published) != 1 || !slices.Equal(pub.published[0].UserIDs, []int{1}) {
+				t.Errorf("published = %+v", pub.published)
+			}
+		},
+	)
+
+	t.Run(
+		"role permission change invalidates everyone", func(t *testing.T) {
+			cache, _, pub := newTestPermissionCache()
+			_, _ = cache.Access(ctx, 1)
+			_, _ = cache.Access(ctx, 2)
+
+			if err := cache.SetRolePermissions(ctx, 3, []string{PermF2BViewStatus}); err != nil {
+				t.Fatalf("SetRolePermissions() error: %v", err)
+			}
+			for _, id := range []int{1, 2} {
+				perms, _ := cache.GetUserPermissions(ctx, id)
+				if !slices.Equal(perms, []string{PermF2BViewStatus}) {
+					t.Errorf("user %d permissions = %v", id, perms)
+				}
+			}
+			if len(pub.published) != 1 || !pub.published[0].All {
+				t.Errorf("published = %+v", pub.published)
+			}
+		},
+	)
+}
+
+func TestPermissionCache_RemoteInvalidation(t *testing.T) {
+	ctx := context.Background()
+	cache, store, _ := newTestPermissionCache()
+	_, _ = cache.Access(ctx, 1)
+
+	own := nats.EventPayload[PermissionInvalidation]{
+		Data: PermissionInvalidation{Origin: cache.instanceID, UserIDs: []int{1}},
+	}
+	_ = cache.handleInvalidation(own)
+	_, _ = cache.Access(ctx, 1)
+	if store.loads != 1 {
+		t.Errorf("own message should be ignored, loads = %d", store.loads)
+	}
+
+	remote := nats.EventPayload[PermissionInvalidation]{
+		Data: PermissionInvalidation{Origin: "other", UserIDs: []int{1}},
+	}
+	_ = cache.handleInvalidation(remote)
+	_, _ = cache.Access(ctx, 1)
+	if store.loads != 2 {
+		t.Errorf("remote message should drop the entry, loads = %d", store.loads)
+	}
+}
@@ -15,6 +15,34 @@ const (
 	PermF2BControlUnban = "f2b.control.unban"
 )
 
+const (
+	PermF2BViewServer       = "f2b.view.server"
+	PermF2BControlReload    = "f2b.control.reload"
+	PermF2BControlJailStart = "f2b.control.jail.start"
+	PermF2BControlJailStop  = "f2b.control.jail.stop"
+	PermF2BRegexTest        = "f2b.regex.test"
+)
+
+const (
+	PermF2BViewConfig     = "f2b.view.config"
+	PermF2BConfigBanTime  = "f2b.config.bantime"
+	PermF2BConfigFindTime = "f2b.config.findtime"
+	PermF2BConfigMaxRetry = "f2b.config.maxretry"
+	PermF2BConfigIgnoreIP = "f2b.config.ignoreip"
+)
+
+const (
+	PermF2BViewBlocks    = "f2b.view.blocks"
+	PermF2BControlBlocks = "f2b.control.blocks"
+	PermF2BViewEvents    = "f2b.view.events"
+)
+
+const (
+	PermF2BBlocklistExport = "f2b.blocklist.export"
+	PermF2BBlocklistImport = "f2b.blocklist.import"
+	PermF2BSelfUnban       = "f2b.self.unban"
+)
+
 const (
 	PermUserView        = "user.view"
 	PermUserCreate      = "user.create"
@@ -23,6 +51,17 @@ const (
 	PermUserRolesAssign = "user.roles.assign"
 )
 
+const (
+	PermRoleView   = "role.view"
+	PermRoleCreate = "role.create"
+	PermRoleEdit   = "role.edit"
+	PermRoleDelete = "role.delete"
+)
+
+const (
+	PermAPIKeyManage = "apikey.manage"
+)
+
 const (
 	PermAuthLogin  = "auth.login"
 	PermAuthLogout = "auth.logout"
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+type fakeRefreshStore struct {
+	tokens  map[string]*sqlite3_local.RefreshTokenEntity
+	revoked map[string]string
+}
+
+func newFakeRefreshStore() *fakeRefreshStore {
+	return &fakeRefreshStore{
+		tokens:  map[string]*sqlite3_local.RefreshTokenEntity{},
+		revoked: map[string]string{},
+	}
+}
+
+func (f *fakeRefreshStore) SaveRefreshToken(entry sqlite3_local.RefreshTokenEntity) error {
+	f.tokens[entry.TokenHash] = &entry
+	return nil
+}
+
+func (f *fakeRefreshStore) GetRefreshToken(tokenHash string) (*sqlite3_local.RefreshTokenEntity, error) {
+	e, ok := f.tokens[tokenHash]
+	if !ok {
+		return nil, sqlite3_local.ErrRefreshTokenNotFound
+	}
+	cp := *e
+	return &cp, nil
+}
+
+func (f *fakeRefreshStore) RotateRefreshToken(
+	oldHash string,
+	next sqlite3_local.RefreshTokenEntity,
+	_ int64,
+) error {
+	old, ok := f.tokens[oldHash]
+	if !ok || old.Used || old.Revoked {
+		return sqlite3_local.ErrRefreshTokenReused
+	}
+	old.Used = true
+	f.tokens[next.TokenHash] = &next
+	return nil
+}
+
+func (f *fakeRefreshStore) RevokeRefreshFamily(familyID, byUsername string) error {
+	for _, e := range f.tokens {
+		if e.FamilyID == familyID {
+			e.Revoked = true
+		}
+	}
+	f.revoked[familyID] = byUsername
+	return nil
+}
+
+func (f *fakeRefreshStore) GetFamilyByAccessJTI(jti string) (string, error) {
+	for _, e := range f.tokens {
+		if e.AccessJTI == jti {
+			return e.FamilyID, nil
+		}
+	}
+	return "", sqlite3_local.ErrRefreshTokenNotFound
+}
+
+func TestRefreshRotation(t *testing.T) {
+	store := newFakeRefreshStore()
+	svc := NewRefreshService(time.Hour, store, zap.NewNop())
+
+	first, err := svc.Issue(1, "alice", "jti-1", []string{AMRPassword, AMROTP})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	current, err := svc.Lookup(first)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if got := SplitAMR(current.AMR); len(got) != 2 || got[1] != AMROTP {
+		t.Errorf("amr = %v, want [pwd otp]", got)
+	}
+
+	second, err := svc.Rotate(first, current, "jti-2")
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if second == first {
+		t.Fatal("rotated token must differ from the original")
+	}
+
+	next, err := svc.Lookup(second)
+	if err != nil {
+		t.Fatalf("Lookup rotated: %v", err)
+	}
+	if next.FamilyID != current.FamilyID || next.AccessJTI != "jti-2" {
+		t.Errorf("rotated token = %+v, want same family bound to jti-2", next)
+	}
+
+	t.Run(
+		"reuse revokes family", func(t *testing.T) {
+			_, err := svc.Lookup(first)
+			if !errors.Is(err, apierror.Errors.REFRESH_TOKEN_REUSED) {
+				t.Fatalf("err = %v, want REFRESH_TOKEN_REUSED", err)
+			}
+			if store.revoked[current.FamilyID] != sqlite3_local.RevokedByRefreshReuse {
+				t.Error("family was not revoked on reuse")
+			}
+			if _, err := svc.Lookup(second); !errors.Is(err, apierror.Errors.REFRESH_TOKEN_INVALID) {
+				t.Errorf("successor err = %v, want REFRESH_TOKEN_INVALID", err)
+			}
+		},
+	)
+}
+
+func TestRefreshLookupInvalid(t *testing.T) {
+	store := newFakeRefreshStore()
+	svc := NewRefreshService(time.Hour, store, zap.NewNop())
+
+	raw, err := svc.Issue(1, "alice", "jti-1", nil)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		now  time.Time
+	}{
+		{"unknown token", "nope", time.Now()},
+		{"expired token", raw, time.Now().Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				svc.now = func() time.Time { return tt.now }
+				if _, err := svc.Lookup(tt.raw); !errors.Is(err, apierror.Errors.REFRESH_TOKEN_INVALID) {
+					t.Errorf("err = %v, want REFRESH_TOKEN_INVALID", err)
+				}
+			},
+		)
+	}
+}
+
+func TestRefreshRevokeByAccessJTI(t *testing.T) {
+	store := newFakeRefreshStore()
+	svc := NewRefreshService(time.Hour, store, zap.NewNop())
+
+	if _, err := svc.Issue(1, "alice", "jti-1", nil); err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if err := svc.RevokeByAccessJTI("unknown"); err != nil {
+		t.Errorf("unknown jti: %v", err)
+	}
+	if err := svc.RevokeByAccessJTI("jti-1"); err != nil {
+		t.Fatalf("RevokeByAccessJTI: %v", err)
+	}
+	if len(store.revoked) != 1 {
+		t.Errorf("revoked families = %d, want 1", len(store.revoked))
+	}
+}
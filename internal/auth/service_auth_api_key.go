@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/database/sqlite3_local"
+	"VPS-control/internal/permission"
+
+	"go.uber.org/zap"
+)
+
+var _ APIKeyManager = (*APIKeyService)(nil)
+var _ APIKeyInspector = (*APIKeyService)(nil)
+
+// apiKeyTouchInterval throttles last-used bookkeeping so a busy CI job does
+// not turn every request into a SQLite write.
+const apiKeyTouchInterval = time.Minute
+
+const apiKeyPrefixLen = 8
+
+// APIKeyService manages personal API keys. A key looks like
+// vpsk_<prefix>_<secret>: the prefix is stored in clear to find the key, the
+// secret only as a SHA-256 hash.
+type APIKeyService struct {
+	cfg         config.APIKeysConfig
+	store       sqlite3_local.APIKeyStore
+	authManager AuthManager
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+func NewAPIKeyService(
+	cfg config.APIKeysConfig,
+	store sqlite3_local.APIKeyStore,
+	am AuthManager,
+	logger *zap.Logger,
+) *APIKeyService {
+	return &APIKeyService{
+		cfg:         cfg,
+		store:       store,
+		authManager: am,
+		now:         time.Now,
+		logger:      logger.Named("api_keys"),
+	}
+}
+
+// Create issues a key limited to permissions, which must all be held by the
+// user right now. The returned key is never retrievable again.
+func (s *APIKeyService) Create(
+	ctx context.Context,
+	userID int,
+	username string,
+	req CreateAPIKeyRequest,
+) (*APIKeyCreatedResponse, error) {
+	ttl := s.cfg.DefaultTTL
+	if req.ExpiresInDays > 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	if ttl > s.cfg.MaxTTL {
+		return nil, apierror.Errors.INVALID_REQUEST.WithMeta(
+			map[string]any{"max_expires_in_days": int(s.cfg.MaxTTL.Hours() / 24)},
+		)
+	}
+
+	owned, err := s.authManager.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	scope := normalizeScope(req.Permissions)
+	for _, p := range scope {
+		if !permission.Valid(p) {
+			return nil, apierror.Errors.INVALID_REQUEST.WithMeta(map[string]any{"permission": p})
+		}
+		// Deny entries only narrow the key and need no grant.
+		if !permission.IsDeny(p) && !permission.Allows(owned, p) {
+			return nil, apierror.Errors.API_KEY_SCOPE_EXCEEDED.WithMeta(map[string]any{"permission": p})
+		}
+	}
+
+	existing, err := s.store.GetUserAPIKeys(int64(userID))
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	now := s.now()
+	active := 0
+	for _, k := range existing {
+		if !k.Revoked && k.ExpiresAt > now.Unix() {
+			active++
+		}
+	}
+	if active >= s.cfg.MaxPerUser {
+		return nil, apierror.Errors.API_KEY_LIMIT_REACHED.WithMeta(map[string]any{"max_per_user": s.cfg.MaxPerUser})
+	}
+
+	prefix := strings.ToLower(rand.Text()[:apiKeyPrefixLen])
+	secret := rand.Text()
+	entity := sqlite3_local.APIKeyEntity{
+		Prefix:      prefix,
+		SecretHash:  hashAPIKeySecret(secret),
+		UserID:      int64(userID),
+		Username:    username,
+		Name:        req.Name,
+		Permissions: strings.Join(scope, ","),
+		ExpiresAt:   now.Add(ttl).Unix(),
+		CreatedAt:   now.Unix(),
+	}
+	entity.ID, err = s.store.SaveAPIKey(entity)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	s.logger.Info(
+		"API key created",
+		zap.String("username", username),
+		zap.String("prefix", prefix),
+		zap.Strings("permissions", scope),
+	)
+
+	return &APIKeyCreatedResponse{
+		APIKeyResponse: toAPIKeyResponse(entity),
+		Key:            APIKeyPrefix + prefix + "_" + secret,
+	}, nil
+}
+
+func (s *APIKeyService) List(userID int) ([]APIKeyResponse, error) {
+	keys, err := s.store.GetUserAPIKeys(int64(userID))
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	res := make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, toAPIKeyResponse(k))
+	}
+	return res, nil
+}
+
+func (s *APIKeyService) Revoke(
+	userID int,
+	id int64,
+) error {
+	if err := s.store.RevokeAPIKey(id, int64(userID)); err != nil {
+		if errors.Is(err, sqlite3_local.ErrAPIKeyNotFound) {
+			return apierror.Errors.API_KEY_NOT_FOUND
+		}
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return nil
+}
+
+// Authenticate resolves a raw key into claims like Inspect and records the
+// use of the key from ip.
+func (s *APIKeyService) Authenticate(
+	ctx context.Context,
+	raw, ip string,
+) (*CustomClaims, error) {
+	key, claims, err := s.resolve(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().Unix()
+	if now-key.LastUsedAt >= int64(apiKeyTouchInterval.Seconds()) {
+		if err := s.store.TouchAPIKey(key.ID, now, ip); err != nil {
+			s.logger.Warn("Failed to update API key usage", zap.String("prefix", key.Prefix), zap.Error(err))
+		}
+	}
+	return claims, nil
+}
+
+// Inspect resolves a raw key into claims without recording a use, for
+// callers that check a key on behalf of someone else.
+func (s *APIKeyService) Inspect(
+	ctx context.Context,
+	raw string,
+) (*CustomClaims, error) {
+	_, claims, err := s.resolve(ctx, raw)
+	return claims, err
+}
+
+// resolve checks a raw key. The key's scope is intersected with the owner's
+// current permissions, so losing a role also shrinks every key, and a
+// deactivated owner disables all of them.
+func (s *APIKeyService) resolve(
+	ctx context.Context,
+	raw string,
+) (*sqlite3_local.APIKeyEntity, *CustomClaims, error) {
+	prefix, secret, ok := ParseAPIKey(raw)
+	if !ok {
+		return nil, nil, apierror.Errors.API_KEY_INVALID
+	}
+
+	key, err := s.store.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		if errors.Is(err, sqlite3_local.ErrAPIKeyNotFound) {
+			return nil, nil, apierror.Errors.API_KEY_INVALID
+		}
+		return nil, nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, nil, apierror.Errors.API_KEY_INVALID
+	}
+	if key.Revoked || s.now().Unix() >= key.ExpiresAt {
+		return nil, nil, apierror.Errors.API_KEY_INVALID
+	}
+
+	result, err := s.authManager.Reload(ctx, int(key.UserID))
+	if err != nil {
+		if errors.Is(err, postgresql.ErrUserNotFound) || errors.Is(err, postgresql.ErrUserInactive) {
+			return nil, nil, apierror.Errors.API_KEY_INVALID
+		}
+		return nil, nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	// The user's own deny entries are carried over: without them a wildcard
+	// in the scope would reach permissions the user is denied.
+	permissions := permission.Denies(result.Permissions)
+	for _, p := range splitList(key.Permissions) {
+		if permission.IsDeny(p) || permission.Allows(result.Permissions, p) {
+			permissions = append(permissions, p)
+		}
+	}
+
+	return key, &CustomClaims{
+		Username:    result.User.Username,
+		UserID:      result.User.ID,
+		JTI:         APIKeyPrefix + prefix,
+		Permissions: permissions,
+		AMR:         []string{AMRAPIKey},
+	}, nil
+}
+
+// ParseAPIKey splits vpsk_<prefix>_<secret> into its parts.
+func ParseAPIKey(raw string) (prefix, secret string, ok bool) {
+	rest, found := strings.CutPrefix(raw, APIKeyPrefix)
+	if !found {
+		return "", "", false
+	}
+	prefix, secret, found = strings.Cut(rest, "_")
+	if !found || len(prefix) != apiKeyPrefixLen || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+func toAPIKeyResponse(k sqlite3_local.APIKeyEntity) APIKeyResponse {
+	return APIKeyResponse{
+		ID:          k.ID,
+		Name:        k.Name,
+		Prefix:      APIKeyPrefix + k.Prefix,
+		Permissions: splitList(k.Permissions),
+		ExpiresAt:   k.ExpiresAt,
+		LastUsedAt:  k.LastUsedAt,
+		LastUsedIP:  k.LastUsedIP,
+		Revoked:     k.Revoked,
+		CreatedAt:   k.CreatedAt,
+	}
+}
+
+func normalizeScope(perms []string) []string {
+	scope := slices.Clone(perms)
+	slices.Sort(scope)
+	return slices.Compact(scope)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+var _ ChallengeStore = (*ChallengeService)(nil)
+
+// LoginChallenge is the pending second step of a login whose password was
+// already verified. Enrollment is set when the user's role requires 2FA but
+// the user has not confirmed a TOTP secret yet. FirstFactor is the method
+// that verified the user before the challenge; empty means the password.
+type LoginChallenge struct {
+	ID          string
+	Result      *AuthResult
+	Enrollment  bool
+	Device      string
+	FirstFactor string
+	ExpiresAt   time.Time
+	attempts    int
+}
+
+// ChallengeService keeps login challenges in memory. Challenges are short
+// lived, so losing them on restart only means logging in again.
+type ChallengeService struct {
+	mu          sync.Mutex
+	challenges  map[string]*LoginChallenge
+	ttl         time.Duration
+	maxAttempts int
+	now         func() time.Time
+}
+
+func NewChallengeService(
+	ttl time.Duration,
+	maxAttempts int,
+) *ChallengeService {
+	return &ChallengeService{
+		challenges:  make(map[string]*LoginChallenge),
+		ttl:         ttl,
+		maxAttempts: maxAttempts,
+		now:         time.Now,
+	}
+}
+
+func (s *ChallengeService) Create(
+	result *AuthResult,
+	enrollment bool,
+	device string,
+) *LoginChallenge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, ch := range s.challenges {
+		if now.After(ch.ExpiresAt) {
+			delete(s.challenges, id)
+		}
+	}
+
+	ch := &LoginChallenge{
+		ID:         rand.Text(),
+		Result:     result,
+		Enrollment: enrollment,
+		Device:     device,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	s.challenges[ch.ID] = ch
+	return ch
+}
+
+func (s *ChallengeService) Get(id string) (*LoginChallenge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.challenges[id]
+	if !ok {
+		return nil, false
+	}
+	if s.now().After(ch.ExpiresAt) {
+		delete(s.challenges, id)
+		return nil, false
+	}
+	return ch, true
+}
+
+// Fail counts a wrong code and drops the challenge once the attempts are used
+// up. It returns the number of attempts left.
+func (s *ChallengeService) Fail(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.challenges[id]
+	if !ok {
+		return 0
+	}
+	ch.attempts++
+	left := s.maxAttempts - ch.attempts
+	if left <= 0 {
+		delete(s.challenges, id)
+		return 0
+	}
+	return left
+}
+
+func (s *ChallengeService) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challenges, id)
+}
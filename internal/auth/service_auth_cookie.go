@@ -11,11 +11,17 @@ import (
 var _ SetAuthCookie = (*AuthCookieService)(nil)
 
 type AuthCookieService struct {
-	name     string
-	maxAge   int
-	secure   bool
-	httpOnly bool
-	sameSite http.SameSite
+	name          string
+	maxAge        int
+	refreshName   string
+	refreshPath   string
+	refreshMaxAge int
+	stateName     string
+	stateMaxAge   int
+	csrfName      string
+	secure        bool
+	httpOnly      bool
+	sameSite      http.SameSite
 }
 
 func NewAuthCookieService(cfg *config.Config) *AuthCookieService {
@@ -28,11 +34,17 @@ func NewAuthCookieService(cfg *config.Config) *AuthCookieService {
 	}
 
 	return &AuthCookieService{
-		name:     cfg.Cookie.Name,
-		maxAge:   int(cfg.JWT.TTL.Seconds()),
-		secure:   cfg.Cookie.Secure,
-		httpOnly: cfg.Cookie.HttpOnly,
-		sameSite: sameSite,
+		name:          cfg.Cookie.Name,
+		maxAge:        int(cfg.JWT.TTL.Seconds()),
+		refreshName:   cfg.Cookie.RefreshName,
+		refreshPath:   cfg.Cookie.RefreshPath,
+		refreshMaxAge: int(cfg.JWT.RefreshTTL.Seconds()),
+		stateName:     cfg.Cookie.Name + "_oidc_state",
+		stateMaxAge:   int(cfg.Auth.OIDC.StateTTL.Seconds()),
+		csrfName:      cfg.Cookie.CSRFName,
+		secure:        cfg.Cookie.Secure,
+		httpOnly:      cfg.Cookie.HttpOnly,
+		sameSite:      sameSite,
 	}
 }
 
@@ -68,3 +80,105 @@ func (s *AuthCookieService) ClearAuthCookie(c *gin.Context) {
 		s.httpOnly,
 	)
 }
+
+// The refresh cookie is always HttpOnly and scoped to the auth routes, so it
+// is never sent with regular API requests.
+func (s *AuthCookieService) SetRefreshCookie(
+	c *gin.Context,
+	token string,
+) {
+	c.SetSameSite(s.sameSite)
+	c.SetCookie(
+		s.refreshName,
+		token,
+		s.refreshMaxAge,
+		s.refreshPath,
+		"",
+		s.secure,
+		true,
+	)
+}
+
+func (s *AuthCookieService) GetRefreshCookie(c *gin.Context) (string, error) {
+	return c.Cookie(s.refreshName)
+}
+
+func (s *AuthCookieService) ClearRefreshCookie(c *gin.Context) {
+	c.SetSameSite(s.sameSite)
+	c.SetCookie(
+		s.refreshName,
+		"",
+		-1,
+		s.refreshPath,
+		"",
+		s.secure,
+		true,
+	)
+}
+
+// The OIDC state cookie binds a pending login to the browser that started it.
+// It must be Lax: the callback is a cross-site redirect from the provider.
+func (s *AuthCookieService) SetOIDCStateCookie(
+	c *gin.Context,
+	state string,
+) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		s.stateName,
+		state,
+		s.stateMaxAge,
+		s.refreshPath,
+		"",
+		s.secure,
+		true,
+	)
+}
+
+func (s *AuthCookieService) GetOIDCStateCookie(c *gin.Context) (string, error) {
+	return c.Cookie(s.stateName)
+}
+
+func (s *AuthCookieService) ClearOIDCStateCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		s.stateName,
+		"",
+		-1,
+		s.refreshPath,
+		"",
+		s.secure,
+		true,
+	)
+}
+
+// The CSRF cookie is deliberately readable by scripts: the frontend copies
+// its value into the X-CSRF-Token header. It lives as long as the access
+// token it belongs to.
+func (s *AuthCookieService) SetCSRFCookie(
+	c *gin.Context,
+	token string,
+) {
+	c.SetSameSite(s.sameSite)
+	c.SetCookie(
+		s.csrfName,
+		token,
+		s.maxAge,
+		"/",
+		"",
+		s.secure,
+		false,
+	)
+}
+
+func (s *AuthCookieService) ClearCSRFCookie(c *gin.Context) {
+	c.SetSameSite(s.sameSite)
+	c.SetCookie(
+		s.csrfName,
+		"",
+		-1,
+		"/",
+		"",
+		s.secure,
+		false,
+	)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"VPS-control/internal/config"
+
+	"go.uber.org/zap"
+)
+
+var _ FailureLogger = (*FailureLogService)(nil)
+
+// Формат строки согласован с filter.d/vps-control-auth.conf из пакета fail2ban:
+// адрес всегда последний, имя пользователя в кавычках без кавычек внутри.
+const (
+	FailureLogTag        = "vps-control[auth]"
+	FailureLogTimeLayout = "2006-01-02T15:04:05Z07:00"
+	failureLogFormat     = "%s %s: authentication failure user=\"%s\" reason=%s from %s\n"
+	failureLogMaxUser    = 64
+)
+
+const (
+	FailureReasonUnknownUser  = "unknown_user"
+	FailureReasonBadPassword  = "bad_password"
+	FailureReasonInactiveUser = "inactive_user"
+	FailureReasonBadOTP       = "bad_otp"
+	FailureReasonBadPasskey   = "bad_passkey"
+	FailureReasonLocked       = "account_locked"
+)
+
+// FailureLogService appends failed login attempts to a dedicated file that
+// fail2ban watches. The file is reopened on every write, so logrotate can
+// move it away without signalling the API.
+type FailureLogService struct {
+	enabled bool
+	path    string
+	now     func() time.Time
+	mu      sync.Mutex
+	logger  *zap.Logger
+}
+
+func NewFailureLogService(
+	cfg config.AuthJailConfig,
+	logger *zap.Logger,
+) *FailureLogService {
+	return &FailureLogService{
+		enabled: cfg.Enabled,
+		path:    cfg.LogPath,
+		now:     time.Now,
+		logger:  logger.Named("auth_failure_log"),
+	}
+}
+
+func (s *FailureLogService) LogFailure(
+	ip, username, reason string,
+) {
+	if !s.enabled || ip == "" {
+		return
+	}
+
+	line := fmt.Sprintf(
+		failureLogFormat,
+		s.now().UTC().Format(FailureLogTimeLayout),
+		FailureLogTag,
+		sanitizeLogUsername(username),
+		reason,
+		ip,
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendLine(line); err != nil {
+		s.logger.Warn("Failed to write auth failure log", zap.String("path", s.path), zap.Error(err))
+	}
+}
+
+func (s *FailureLogService) appendLine(line string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) //nolint:gosec // path from config
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sanitizeLogUsername keeps attacker-controlled input from forging extra
+// lines or a different "from <HOST>" part in the fail2ban log.
+func sanitizeLogUsername(username string) string {
+	var b strings.Builder
+	for _, r := range username {
+		if b.Len() >= failureLogMaxUser {
+			break
+		}
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteRune('_')
+		case unicode.IsControl(r) || unicode.IsSpace(r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+	"VPS-control/internal/nats"
+
+	"go.uber.org/zap"
+)
+
+var _ Introspector = (*IntrospectionService)(nil)
+
+// IntrospectionService tells other services whether a token issued here is
+// still usable and whom it belongs to. Session JWTs must verify and still be
+// present and unrevoked in the token store; API keys are checked like in the
+// auth middleware, but an introspection does not count as a use of the key.
+// Roles and permissions are the current ones, not those at issue time.
+type IntrospectionService struct {
+	clients map[string][sha256.Size]byte
+	jwt     JwtProvider
+	tokens  sqlite3_local.TokenStore
+	apiKeys APIKeyInspector
+	access  AccessResolver
+	logger  *zap.Logger
+}
+
+func NewIntrospectionService(
+	cfg config.IntrospectionConfig,
+	jwt JwtProvider,
+	tokens sqlite3_local.TokenStore,
+	apiKeys APIKeyInspector,
+	access AccessResolver,
+	logger *zap.Logger,
+) *IntrospectionService {
+	clients := make(map[string][sha256.Size]byte, len(cfg.Clients))
+	for _, client := range cfg.Clients {
+		clients[client.ID] = sha256.Sum256([]byte(client.Secret))
+	}
+
+	return &IntrospectionService{
+		clients: clients,
+		jwt:     jwt,
+		tokens:  tokens,
+		apiKeys: apiKeys,
+		access:  access,
+		logger:  logger.Named("introspection"),
+	}
+}
+
+// AuthenticateClient checks service credentials. Hashing both sides keeps the
+// comparison constant-time regardless of the secret lengths.
+func (s *IntrospectionService) AuthenticateClient(
+	id, secret string,
+) bool {
+	want, ok := s.clients[id]
+	if !ok || secret == "" {
+		return false
+	}
+	got := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(got[:], want[:]) == 1
+}
+
+// Introspect returns an inactive response for any unusable token. An error
+// means the answer is unknown, e.g. the database failed.
+func (s *IntrospectionService) Introspect(
+	ctx context.Context,
+	token string,
+) (*IntrospectionResponse, error) {
+	inactive := &IntrospectionResponse{Active: false}
+	if token == "" {
+		return inactive, nil
+	}
+	if IsAPIKey(token) {
+		return s.introspectAPIKey(ctx, token)
+	}
+
+	claims, err := s.jwt.ValidateToken(token)
+	if err != nil || claims.Username == "" {
+		return inactive, nil
+	}
+	if err := s.tokens.ValidateToken(claims.JTI); err != nil {
+		if errors.Is(err, sqlite3_local.ErrTokenNotFound) || errors.Is(err, sqlite3_local.ErrTokenRevoked) {
+			return inactive, nil
+		}
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	userAccess, err := s.access.Access(ctx, claims.UserID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	resp := &IntrospectionResponse{
+		Active:      true,
+		TokenType:   TokenTypeAccess,
+		Scope:       strings.Join(userAccess.Permissions, " "),
+		Username:    claims.Username,
+		Subject:     claims.Subject,
+		UserID:      claims.UserID,
+		Issuer:      claims.Issuer,
+		JTI:         claims.JTI,
+		Roles:       userAccess.Roles,
+		Permissions: userAccess.Permissions,
+		AMR:         claims.AMR,
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		resp.NotBefore = claims.NotBefore.Unix()
+	}
+	return resp, nil
+}
+
+// Listen answers introspection requests on the NATS subject. Every instance
+// joins the same queue, so each request is handled once.
+func (s *IntrospectionService) Listen(
+	broker nats.Broker,
+	subject, queue string,
+) error {
+	_, err := nats.Reply(broker, subject, queue, s.handleRequest, s.logger)
+	return err
+}
+
+func (s *IntrospectionService) handleRequest(req IntrospectionRequest) IntrospectionResponse {
+	if !s.AuthenticateClient(req.ClientID, req.ClientSecret) {
+		s.logger.Warn("Introspection client rejected", zap.String("client_id", req.ClientID))
+		return IntrospectionResponse{Error: IntrospectErrInvalidClient}
+	}
+
+	resp, err := s.Introspect(context.Background(), req.Token)
+	if err != nil {
+		s.logger.Error("Introspection failed", zap.String("client_id", req.ClientID), zap.Error(err))
+		return IntrospectionResponse{Error: IntrospectErrServer}
+	}
+	return *resp
+}
+
+func (s *IntrospectionService) introspectAPIKey(
+	ctx context.Context,
+	key string,
+) (*IntrospectionResponse, error) {
+	claims, err := s.apiKeys.Inspect(ctx, key)
+	if err != nil {
+		if errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+			return &IntrospectionResponse{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	return &IntrospectionResponse{
+		Active:      true,
+		TokenType:   TokenTypeAPIKey,
+		Scope:       strings.Join(claims.Permissions, " "),
+		Username:    claims.Username,
+		Subject:     claims.Username,
+		UserID:      claims.UserID,
+		JTI:         claims.JTI,
+		Permissions: claims.Permissions,
+		AMR:         claims.AMR,
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"VPS-control/internal/config"
+	"VPS-control/internal/permission"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
@@ -13,44 +14,77 @@ import (
 
 var _ JwtProvider = (*AuthJwtService)(nil)
 
+// AuthJwtService signs access tokens with HS256 or, when an asymmetric
+// algorithm is configured, with the current key of the keyset. While a
+// JWT_SECRET is still set, HS256 tokens issued before the switch keep
+// validating until they expire.
 type AuthJwtService struct {
 	secretKey []byte
+	keys      *KeySet
 	issuer    string
 	ttl       time.Duration
 	logger    *zap.Logger
 }
 
+// CustomClaims carries identity only. Roles and Permissions are not part of
+// the token: the auth middleware fills them from the permission cache on every
+// request, so role changes apply without a new login.
 type CustomClaims struct {
 	Username    string   `json:"username"`
 	UserID      int      `json:"uid"`
 	JTI         string   `json:"jti"`
-	Roles       []string `json:"roles,omitempty"`
-	Permissions []string `json:"permissions,omitempty"`
+	Roles       []string `json:"-"`
+	Permissions []string `json:"-"`
+	AMR         []string `json:"amr,omitempty"`
+	CSRF        string   `json:"csrf,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type TokenData struct {
-	UserID      int
-	Username    string
-	JTI         string
-	Roles       []string
-	Permissions []string
+	UserID   int
+	Username string
+	JTI      string
+	AMR      []string
+	CSRF     string
 }
 
 func NewAuthJwtService(
 	cfg *config.Config,
 	logger *zap.Logger,
 ) *AuthJwtService {
-	if cfg.JWT.Secret == "" {
-		logger.Fatal("JWT_SECRET environment variable is not set")
-	}
-
-	return &AuthJwtService{
+	s := &AuthJwtService{
 		secretKey: []byte(cfg.JWT.Secret),
 		issuer:    cfg.JWT.Issuer,
 		ttl:       cfg.JWT.TTL,
 		logger:    logger.Named("jwt"),
 	}
+
+	if cfg.JWT.Algorithm == "" || cfg.JWT.Algorithm == AlgHS256 {
+		if cfg.JWT.Secret == "" {
+			logger.Fatal("JWT_SECRET environment variable is not set")
+		}
+		return s
+	}
+
+	keys, err := LoadKeySet(cfg.JWT.KeysDir, cfg.JWT.Algorithm, cfg.JWT.SigningKeyID, logger)
+	if err != nil {
+		logger.Fatal("Failed to load JWT signing keys", zap.Error(err))
+	}
+	s.keys = keys
+	return s
+}
+
+// Keys returns the asymmetric keyset, or nil when tokens are signed with HS256.
+func (s *AuthJwtService) Keys() *KeySet {
+	return s.keys
+}
+
+// JWKS returns the public verification keys. It is empty for HS256.
+func (s *AuthJwtService) JWKS() JWKSResponse {
+	if s.keys == nil {
+		return JWKSResponse{Keys: []JWK{}}
+	}
+	return s.keys.JWKS()
 }
 
 func (s *AuthJwtService) GenerateToken(data TokenData) (string, error) {
@@ -61,11 +95,11 @@ func (s *AuthJwtService) GenerateToken(data TokenData) (string, error) {
 
 	now := time.Now()
 	claims := CustomClaims{
-		Username:    data.Username,
-		UserID:      data.UserID,
-		JTI:         data.JTI,
-		Roles:       data.Roles,
-		Permissions: data.Permissions,
+		Username: data.Username,
+		UserID:   data.UserID,
+		JTI:      data.JTI,
+		AMR:      data.AMR,
+		CSRF:     data.CSRF,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        data.JTI,
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
@@ -76,8 +110,19 @@ func (s *AuthJwtService) GenerateToken(data TokenData) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.secretKey)
+	var (
+		token   *jwt.Token
+		signKey any = s.secretKey
+	)
+	if s.keys == nil {
+		token = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	} else {
+		key := s.keys.Signing()
+		token = jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+		token.Header["kid"] = key.ID
+		signKey = key.Private
+	}
+	signedToken, err := token.SignedString(signKey)
 	if err != nil {
 		s.logger.Error(
 			"Failed to sign token",
@@ -92,8 +137,6 @@ func (s *AuthJwtService) GenerateToken(data TokenData) (string, error) {
 		zap.String("user", data.Username),
 		zap.Int("user_id", data.UserID),
 		zap.String("jti", data.JTI),
-		zap.Strings("roles", data.Roles),
-		zap.Int("permissions_count", len(data.Permissions)),
 		zap.Time("expires_at", claims.ExpiresAt.Time),
 	)
 
@@ -109,17 +152,8 @@ func (s *AuthJwtService) ValidateToken(tokenString string) (*CustomClaims, error
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&CustomClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				s.logger.Warn(
-					"Unexpected signing method",
-					zap.String("method", fmt.Sprintf("%v", token.Header["alg"])),
-				)
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return s.secretKey, nil
-		},
-		jwt.WithValidMethods([]string{"HS256"}),
+		s.verificationKey,
+		jwt.WithValidMethods(s.validMethods()),
 		jwt.WithIssuer(s.issuer),
 		jwt.WithLeeway(5*time.Second),
 	)
@@ -164,24 +198,51 @@ func (s *AuthJwtService) ValidateToken(tokenString string) (*CustomClaims, error
 	return claims, nil
 }
 
-func (c *CustomClaims) HasPermission(permission string) bool {
-	for _, p := range c.Permissions {
-		if p == permission {
-			return true
+// verificationKey picks the key by the "kid" header. Tokens without a kid are
+// legacy HS256 tokens and are accepted only while a secret is configured.
+func (s *AuthJwtService) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" || s.keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(s.secretKey) == 0 {
+			s.logger.Warn(
+				"Unexpected signing method",
+				zap.String("method", fmt.Sprintf("%v", token.Header["alg"])),
+			)
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		return s.secretKey, nil
 	}
-	return false
+
+	key, ok := s.keys.Get(kid)
+	if !ok {
+		s.logger.Warn("Unknown signing key", zap.String("kid", kid))
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("signing method %s does not match key %q", token.Method.Alg(), kid)
+	}
+	return key.Private.Public(), nil
 }
 
-func (c *CustomClaims) HasAnyPermission(permissions ...string) bool {
-	for _, required := range permissions {
-		for _, p := range c.Permissions {
-			if p == required {
-				return true
-			}
-		}
+func (s *AuthJwtService) validMethods() []string {
+	var methods []string
+	if len(s.secretKey) > 0 {
+		methods = append(methods, AlgHS256)
 	}
-	return false
+	if s.keys != nil {
+		methods = append(methods, s.keys.Algorithms()...)
+	}
+	return methods
+}
+
+// HasPermission matches permission against the granted entries, including
+// wildcards and deny entries (see package permission).
+func (c *CustomClaims) HasPermission(name string) bool {
+	return permission.Allows(c.Permissions, name)
+}
+
+func (c *CustomClaims) HasAnyPermission(names ...string) bool {
+	return permission.AllowsAny(c.Permissions, names...)
 }
 
 func (c *CustomClaims) HasRole(role string) bool {
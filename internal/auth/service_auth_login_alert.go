@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"VPS-control/internal/config"
+
+	"go.uber.org/zap"
+)
+
+var _ LoginAlertNotifier = (*LoginAlertService)(nil)
+
+// LoginAlertService delivers login anomaly alerts by webhook and by email,
+// whichever is configured.
+type LoginAlertService struct {
+	webhook  config.LoginAlertWebhookConfig
+	email    config.LoginAlertEmailConfig
+	client   *http.Client
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	logger   *zap.Logger
+}
+
+func NewLoginAlertService(
+	cfg config.LoginAlertsConfig,
+	logger *zap.Logger,
+) *LoginAlertService {
+	return &LoginAlertService{
+		webhook:  cfg.Webhook,
+		email:    cfg.Email,
+		client:   &http.Client{Timeout: cfg.Webhook.Timeout},
+		sendMail: smtp.SendMail,
+		logger:   logger.Named("login_alert"),
+	}
+}
+
+// Enabled reports whether any delivery channel is configured.
+func (s *LoginAlertService) Enabled() bool {
+	return s.webhook.URL != "" || s.emailEnabled()
+}
+
+// Notify tries every configured channel; a failure of one does not skip the
+// other.
+func (s *LoginAlertService) Notify(
+	ctx context.Context,
+	event LoginAnomalyEvent,
+) error {
+	var errs []error
+	if s.webhook.URL != "" {
+		if err := s.postWebhook(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if s.emailEnabled() {
+		if err := s.sendEmail(event); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	s.logger.Debug("Login alert sent", zap.String("username", event.Username))
+	return nil
+}
+
+func (s *LoginAlertService) emailEnabled() bool {
+	return s.email.Host != "" && len(s.email.To) > 0
+}
+
+func (s *LoginAlertService) postWebhook(
+	ctx context.Context,
+	event LoginAnomalyEvent,
+) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *LoginAlertService) sendEmail(event LoginAnomalyEvent) error {
+	var a smtp.Auth
+	if s.email.Username != "" {
+		a = smtp.PlainAuth("", s.email.Username, s.email.Password, s.email.Host)
+	}
+	addr := net.JoinHostPort(s.email.Host, strconv.Itoa(s.email.Port))
+	return s.sendMail(addr, a, s.email.From, s.email.To, s.message(event))
+}
+
+func (s *LoginAlertService) message(event LoginAnomalyEvent) []byte {
+	var sources []string
+	if event.NewIP {
+		sources = append(sources, "IP address")
+	}
+	if event.NewDevice {
+		sources = append(sources, "device")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.email.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.email.To, ", "))
+	fmt.Fprintf(&b, "Subject: New login for %s\r\n", event.Username)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Unix(event.Timestamp, 0).UTC().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "User %q logged in from a new %s.\r\n\r\n", event.Username, strings.Join(sources, " and "))
+	fmt.Fprintf(&b, "IP address: %s\r\n", event.IP)
+	fmt.Fprintf(&b, "Device:     %s\r\n", event.Device)
+	fmt.Fprintf(&b, "Methods:    %s\r\n", strings.Join(event.AMR, ", "))
+	fmt.Fprintf(&b, "Time:       %s\r\n", time.Unix(event.Timestamp, 0).UTC().Format(time.RFC3339))
+	b.WriteString("\r\nIf this was not you, revoke the session and change the password.\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+const (
+	loginMonitorPurgeInterval = time.Hour
+	loginAlertTimeout         = time.Minute
+)
+
+var _ LoginMonitor = (*LoginMonitorService)(nil)
+
+// LoginMonitorService keeps a persistent record of login attempts. Failures
+// are counted per username regardless of the client address, so guessing
+// spread over many IPs is slowed down as well, and the counters survive a
+// restart. A successful login from an IP or device the user never logged in
+// from before is published over NATS and sent to the optional notifier.
+type LoginMonitorService struct {
+	cfg       config.LockoutConfig
+	attempts  sqlite3_local.LoginAttemptStore
+	lockouts  sqlite3_local.AccountLockoutStore
+	publisher eventPublisher
+	notifier  LoginAlertNotifier
+	alerts    sync.WaitGroup
+	now       func() time.Time
+	logger    *zap.Logger
+}
+
+func NewLoginMonitorService(
+	cfg config.LockoutConfig,
+	attempts sqlite3_local.LoginAttemptStore,
+	lockouts sqlite3_local.AccountLockoutStore,
+	publisher eventPublisher,
+	notifier LoginAlertNotifier,
+	logger *zap.Logger,
+) *LoginMonitorService {
+	return &LoginMonitorService{
+		cfg:       cfg,
+		attempts:  attempts,
+		lockouts:  lockouts,
+		publisher: publisher,
+		notifier:  notifier,
+		now:       time.Now,
+		logger:    logger.Named("login_monitor"),
+	}
+}
+
+// Check returns how long the username stays locked. A refused attempt is
+// recorded but does not extend the lock.
+func (s *LoginMonitorService) Check(attempt LoginAttempt) (time.Duration, error) {
+	lockout, err := s.lockouts.GetLockout(attempt.Username)
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	remaining := time.Unix(lockout.LockedUntil, 0).Sub(now)
+	if remaining <= 0 {
+		return 0, nil
+	}
+
+	attempt.Reason = FailureReasonLocked
+	s.record(attempt, sqlite3_local.LoginResultLocked, now)
+	return remaining.Round(time.Second), nil
+}
+
+// Failed records a failed attempt and locks the username once it has failed
+// FreeAttempts times in a row.
+func (s *LoginMonitorService) Failed(attempt LoginAttempt) {
+	now := s.now()
+	s.record(attempt, sqlite3_local.LoginResultFailure, now)
+	if attempt.Username == "" {
+		return
+	}
+
+	failures, err := s.lockouts.AddLoginFailure(
+		attempt.Username, now.Unix(), now.Add(-s.cfg.ResetAfter).Unix(),
+	)
+	if err != nil {
+		s.logger.Error("Failed to count login failure", zap.String("username", attempt.Username), zap.Error(err))
+		return
+	}
+	delay := s.lockDelay(failures)
+	if delay == 0 {
+		return
+	}
+	if err := s.lockouts.LockAccount(attempt.Username, now.Add(delay).Unix()); err != nil {
+		s.logger.Error("Failed to lock account", zap.String("username", attempt.Username), zap.Error(err))
+		return
+	}
+	s.logger.Warn(
+		"Account locked after failed logins",
+		zap.String("username", attempt.Username),
+		zap.String("ip", attempt.IP),
+		zap.Int("failures", failures),
+		zap.Duration("delay", delay),
+	)
+}
+
+// Succeeded records a completed login, clears the failure counter and raises
+// an alert when the IP or the device is new for this user. The very first
+// login of a user has nothing to compare with and is not reported.
+func (s *LoginMonitorService) Succeeded(attempt LoginAttempt) {
+	now := s.now()
+	device := DeviceLabel(attempt.UserAgent)
+
+	sources, historyErr := s.attempts.GetLoginSources(attempt.Username, attempt.IP, device)
+	if historyErr != nil {
+		s.logger.Warn("Failed to load login history", zap.String("username", attempt.Username), zap.Error(historyErr))
+	}
+	s.record(attempt, sqlite3_local.LoginResultSuccess, now)
+	if err := s.lockouts.ResetLockout(attempt.Username); err != nil {
+		s.logger.Warn("Failed to reset login failures", zap.String("username", attempt.Username), zap.Error(err))
+	}
+
+	if historyErr != nil || sources.Logins == 0 || (sources.KnownIP && sources.KnownDevice) {
+		return
+	}
+	s.alert(
+		LoginAnomalyEvent{
+			UserID:    attempt.UserID,
+			Username:  attempt.Username,
+			IP:        attempt.IP,
+			UserAgent: attempt.UserAgent,
+			Device:    device,
+			NewIP:     !sources.KnownIP,
+			NewDevice: !sources.KnownDevice,
+			AMR:       attempt.AMR,
+			Timestamp: now.Unix(),
+		},
+	)
+}
+
+func (s *LoginMonitorService) Attempts(filter sqlite3_local.LoginAttemptFilter) ([]LoginAttemptResponse, error) {
+	entities, err := s.attempts.GetLoginAttempts(filter)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	attempts := make([]LoginAttemptResponse, 0, len(entities))
+	for _, e := range entities {
+		attempts = append(
+			attempts, LoginAttemptResponse{
+				ID:        e.ID,
+				Username:  e.Username,
+				IP:        e.IP,
+				UserAgent: e.UserAgent,
+				Device:    e.Device,
+				Result:    e.Result,
+				Reason:    e.Reason,
+				Timestamp: e.CreatedAt,
+			},
+		)
+	}
+	return attempts, nil
+}
+
+// Run purges old attempts and stale counters until ctx is cancelled, then
+// waits for alerts still being delivered.
+func (s *LoginMonitorService) Run(ctx context.Context) {
+	s.purge()
+
+	ticker := time.NewTicker(loginMonitorPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.alerts.Wait()
+			return
+		case <-ticker.C:
+			s.purge()
+		}
+	}
+}
+
+// lockDelay doubles BaseDelay for every failure past FreeAttempts.
+func (s *LoginMonitorService) lockDelay(failures int) time.Duration {
+	if failures < s.cfg.FreeAttempts {
+		return 0
+	}
+	delay := s.cfg.BaseDelay
+	for range failures - s.cfg.FreeAttempts {
+		delay *= 2
+		if delay >= s.cfg.MaxDelay {
+			break
+		}
+	}
+	return min(delay, s.cfg.MaxDelay)
+}
+
+func (s *LoginMonitorService) record(
+	attempt LoginAttempt,
+	result string,
+	now time.Time,
+) {
+	err := s.attempts.RecordLoginAttempt(
+		sqlite3_local.LoginAttemptEntity{
+			Username:  attempt.Username,
+			IP:        attempt.IP,
+			UserAgent: attempt.UserAgent,
+			Device:    DeviceLabel(attempt.UserAgent),
+			Result:    result,
+			Reason:    attempt.Reason,
+			CreatedAt: now.Unix(),
+		},
+	)
+	if err != nil {
+		s.logger.Error("Failed to record login attempt", zap.String("username", attempt.Username), zap.Error(err))
+	}
+}
+
+func (s *LoginMonitorService) alert(event LoginAnomalyEvent) {
+	s.logger.Warn(
+		"Login from a new source",
+		zap.String("username", event.Username),
+		zap.String("ip", event.IP),
+		zap.String("device", event.Device),
+		zap.Bool("new_ip", event.NewIP),
+		zap.Bool("new_device", event.NewDevice),
+	)
+	if s.publisher != nil {
+		if err := s.publisher.Publish(SubjectLoginAnomaly, event); err != nil {
+			s.logger.Warn("Failed to publish login anomaly", zap.String("username", event.Username), zap.Error(err))
+		}
+	}
+	if s.notifier == nil {
+		return
+	}
+
+	// Delivery must not delay the login response.
+	s.alerts.Go(
+		func() {
+			ctx, cancel := context.WithTimeout(context.Background(), loginAlertTimeout)
+			defer cancel()
+			if err := s.notifier.Notify(ctx, event); err != nil {
+				s.logger.Warn("Failed to send login alert", zap.String("username", event.Username), zap.Error(err))
+			}
+		},
+	)
+}
+
+func (s *LoginMonitorService) purge() {
+	now := s.now()
+	if err := s.attempts.DeleteLoginAttemptsBefore(now.Add(-s.cfg.Retention).Unix()); err != nil {
+		s.logger.Warn("Failed to purge old login attempts", zap.Error(err))
+	}
+	if err := s.lockouts.DeleteStaleLockouts(now.Add(-s.cfg.ResetAfter).Unix(), now.Unix()); err != nil {
+		s.logger.Warn("Failed to purge stale lockouts", zap.Error(err))
+	}
+}
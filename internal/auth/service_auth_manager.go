@@ -3,30 +3,43 @@ package auth
 import (
 	"context"
 
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
 	"VPS-control/internal/database/postgresql"
+
+	"github.com/gin-gonic/gin"
 )
 
 var _ AuthManager = (*ManagerService)(nil)
 
 type ManagerService struct {
-	userRepo postgresql.UserStore
-	permRepo postgresql.PermissionStore
+	userRepo      postgresql.UserStore
+	permRepo      postgresql.PermissionStore
+	policy        PasswordPolicy
+	singleSession bool
 }
 
 func NewAuthManagerService(
+	cfg config.SessionsConfig,
 	userRepo postgresql.UserStore,
 	permRepo postgresql.PermissionStore,
+	policy PasswordPolicy,
 ) *ManagerService {
 	return &ManagerService{
-		userRepo: userRepo,
-		permRepo: permRepo,
+		userRepo:      userRepo,
+		permRepo:      permRepo,
+		policy:        policy,
+		singleSession: cfg.SingleSession,
 	}
 }
 
+// AuthResult is an authenticated user. SingleSession means a new login must
+// revoke the user's other sessions, by global config or by one of their roles.
 type AuthResult struct {
-	User        *postgresql.UserResponseDTO
-	Roles       []string
-	Permissions []string
+	User          *postgresql.UserResponseDTO
+	Roles         []string
+	Permissions   []string
+	SingleSession bool
 }
 
 func (s *ManagerService) Login(
@@ -38,6 +51,57 @@ func (s *ManagerService) Login(
 		return nil, err
 	}
 
+	return s.load(ctx, user)
+}
+
+// Reload re-reads an already authenticated user, e.g. when a refresh token is
+// exchanged, so a deactivated user cannot obtain a new access token.
+func (s *ManagerService) Reload(
+	ctx context.Context,
+	userID int,
+) (*AuthResult, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, postgresql.ErrUserInactive
+	}
+
+	return s.load(ctx, user)
+}
+
+// ChangePassword replaces the user's password once current is verified.
+// Credential errors are returned as the store reports them, so the caller can
+// log them like failed logins; a rejected new password is
+// PASSWORD_POLICY_VIOLATION with the broken rules in the meta.
+func (s *ManagerService) ChangePassword(
+	ctx context.Context,
+	userID int,
+	username, current, next string,
+) error {
+	if err := s.userRepo.CheckPassword(ctx, userID, current); err != nil {
+		return err
+	}
+
+	violations := s.policy.Validate(username, next)
+	if next == current {
+		violations = append(violations, "must differ from the current password")
+	}
+	if len(violations) > 0 {
+		return apierror.Errors.PASSWORD_POLICY_VIOLATION.WithMeta(gin.H{"violations": violations})
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, next); err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return nil
+}
+
+func (s *ManagerService) load(
+	ctx context.Context,
+	user *postgresql.UserResponseDTO,
+) (*AuthResult, error) {
 	roles, err := s.permRepo.GetUserRoles(ctx, user.ID)
 	if err != nil {
 		return nil, err
@@ -48,10 +112,18 @@ func (s *ManagerService) Login(
 		return nil, err
 	}
 
+	singleSession := s.singleSession
+	if !singleSession {
+		if singleSession, err = s.permRepo.UserSingleSession(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	return &AuthResult{
-		User:        user,
-		Roles:       roles,
-		Permissions: permissions,
+		User:          user,
+		Roles:         roles,
+		Permissions:   permissions,
+		SingleSession: singleSession,
 	}, nil
 }
 
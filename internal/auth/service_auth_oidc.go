@@ -0,0 +1,624 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcDiscoveryPath  = "/.well-known/openid-configuration"
+	oidcJWKSRefetchGap = time.Minute
+	oidcClockSkew      = time.Minute
+	oidcMaxBodySize    = 1 << 20
+)
+
+var (
+	_ OIDCAuthenticator = (*OIDCService)(nil)
+
+	// Local usernames have the same format as accounts created by admins.
+	oidcUsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9]{3,32}$`)
+
+	oidcSigningMethods = []string{
+		"RS256", "RS384", "RS512",
+		"PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512",
+		"EdDSA",
+	}
+)
+
+// OIDCService implements the OpenID Connect authorization code flow with PKCE
+// against a single identity provider. Users are matched by the issuer and
+// subject of the ID token, created on first login when allowed, and their
+// mapped roles are synchronized on every login.
+type OIDCService struct {
+	cfg        config.OIDCConfig
+	client     *http.Client
+	users      postgresql.UserStore
+	identities postgresql.IdentityStore
+	perms      postgresql.PermissionStore
+	now        func() time.Time
+	logger     *zap.Logger
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	pending     map[string]oidcPending
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oidcPending is a login started by AuthCodeURL and not yet completed.
+type oidcPending struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+type oidcIdentity struct {
+	subject  string
+	username string
+	groups   []string
+	mfa      bool
+}
+
+func NewOIDCService(
+	cfg config.OIDCConfig,
+	users postgresql.UserStore,
+	identities postgresql.IdentityStore,
+	perms postgresql.PermissionStore,
+	logger *zap.Logger,
+) *OIDCService {
+	return &OIDCService{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: cfg.HTTPTimeout},
+		users:      users,
+		identities: identities,
+		perms:      perms,
+		now:        time.Now,
+		logger:     logger.Named("oidc"),
+		keys:       make(map[string]crypto.PublicKey),
+		pending:    make(map[string]oidcPending),
+	}
+}
+
+func (s *OIDCService) SuccessURL() string {
+	return s.cfg.SuccessRedirect
+}
+
+// AuthCodeURL starts a login: it remembers a fresh state, nonce and PKCE
+// verifier and returns the provider URL to redirect the browser to.
+func (s *OIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	disc, err := s.getDiscovery(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, nonce, verifier := randomToken(), randomToken(), randomToken()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	s.mu.Lock()
+	now := s.now()
+	for k, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, k)
+		}
+	}
+	s.pending[state] = oidcPending{nonce: nonce, verifier: verifier, expiresAt: now.Add(s.cfg.StateTTL)}
+	s.mu.Unlock()
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.cfg.ClientID},
+		"redirect_uri":          {s.cfg.RedirectURL},
+		"scope":                 {strings.Join(s.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(disc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return disc.AuthorizationEndpoint + sep + q.Encode(), state, nil
+}
+
+// Authenticate completes a login started by AuthCodeURL: it exchanges the
+// code, verifies the ID token and maps the identity to a local user.
+func (s *OIDCService) Authenticate(
+	ctx context.Context,
+	state, code string,
+) (*OIDCLogin, error) {
+	pending, ok := s.takePending(state)
+	if !ok {
+		return nil, apierror.Errors.OIDC_STATE_INVALID
+	}
+	if code == "" {
+		return nil, apierror.Errors.OIDC_LOGIN_FAILED.WithMeta("authorization code is missing")
+	}
+
+	disc, err := s.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, err := s.exchange(ctx, disc, code, pending.verifier)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := s.verifyIDToken(ctx, rawIDToken, pending.nonce)
+	if err != nil {
+		s.logger.Warn("ID token rejected", zap.Error(err))
+		return nil, apierror.Errors.OIDC_LOGIN_FAILED.Wrap(err)
+	}
+
+	user, err := s.provision(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncRoles(ctx, user.ID, identity.groups); err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	amr := []string{AMROIDC}
+	if identity.mfa {
+		amr = append(amr, AMRMFA)
+	}
+	return &OIDCLogin{UserID: user.ID, Username: user.Username, AMR: amr}, nil
+}
+
+func (s *OIDCService) takePending(state string) (oidcPending, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[state]
+	if !ok {
+		return oidcPending{}, false
+	}
+	delete(s.pending, state)
+	return p, s.now().Before(p.expiresAt)
+}
+
+func (s *OIDCService) exchange(
+	ctx context.Context,
+	disc *oidcDiscovery,
+	code, verifier string,
+) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if s.cfg.ClientSecret == "" {
+		form.Set("client_id", s.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", apierror.Errors.INTERNAL_ERROR.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", apierror.Errors.OIDC_PROVIDER_UNAVAILABLE.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	var body oidcTokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBodySize)).Decode(&body); err != nil {
+		return "", apierror.Errors.OIDC_PROVIDER_UNAVAILABLE.Wrap(err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		// 4xx means the provider rejected the code or the verifier.
+		s.logger.Warn(
+			"Token exchange rejected",
+			zap.Int("status", resp.StatusCode),
+			zap.String("error", body.Error),
+			zap.String("description", body.ErrorDescription),
+		)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return "", apierror.Errors.OIDC_PROVIDER_UNAVAILABLE
+		}
+		return "", apierror.Errors.OIDC_LOGIN_FAILED.WithMeta(body.Error)
+	}
+	if body.IDToken == "" {
+		return "", apierror.Errors.OIDC_LOGIN_FAILED.WithMeta("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(
+	ctx context.Context,
+	raw, nonce string,
+) (*oidcIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(
+		raw,
+		claims,
+		func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+			return s.publicKey(ctx, kid)
+		},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(s.cfg.Issuer),
+		jwt.WithAudience(s.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	// With several audiences the token must name us as the authorized party.
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != s.cfg.ClientID {
+			return nil, errors.New("azp does not match client_id")
+		}
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, errors.New("sub claim is missing")
+	}
+	username, _ := claims[s.cfg.UsernameClaim].(string)
+
+	return &oidcIdentity{
+		subject:  subject,
+		username: username,
+		groups:   stringList(claims[s.cfg.GroupsClaim]),
+		mfa:      slices.Contains(stringList(claims["amr"]), AMRMFA),
+	}, nil
+}
+
+// provision returns the local user of the identity. An unknown identity is
+// linked to the user of the same name when LinkExisting is set, or to a new
+// user when AutoCreate is set. Without LinkExisting an existing local account
+// is never taken over, since the provider may let anyone pick that name.
+func (s *OIDCService) provision(
+	ctx context.Context,
+	identity *oidcIdentity,
+) (*postgresql.UserResponseDTO, error) {
+	userID, err := s.identities.GetIdentityUser(ctx, s.cfg.Issuer, identity.subject)
+	switch {
+	case err == nil:
+		user, err := s.users.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, s.userError(err)
+		}
+		return user, nil
+	case !errors.Is(err, postgresql.ErrIdentityNotFound):
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	if !oidcUsernamePattern.MatchString(identity.username) {
+		return nil, apierror.Errors.OIDC_USERNAME_INVALID.WithMeta(gin.H{"claim": s.cfg.UsernameClaim})
+	}
+
+	user, err := s.users.GetUserByUsername(ctx, identity.username)
+	switch {
+	case err == nil:
+		if !s.cfg.LinkExisting {
+			return nil, apierror.Errors.OIDC_ACCOUNT_CONFLICT
+		}
+	case errors.Is(err, postgresql.ErrUserNotFound):
+		if !s.cfg.AutoCreate {
+			return nil, apierror.Errors.OIDC_USER_NOT_PROVISIONED
+		}
+		// The local password is never disclosed: the user signs in through the provider.
+		user, err = s.users.CreateUser(ctx, identity.username, rand.Text()+rand.Text(), true)
+		if err != nil {
+			if errors.Is(err, postgresql.ErrUserAlreadyExists) {
+				return nil, apierror.Errors.OIDC_ACCOUNT_CONFLICT
+			}
+			return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+		}
+		s.logger.Info("User created from OIDC identity", zap.String("username", user.Username), zap.Int("user_id", user.ID))
+	default:
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	if err := s.identities.LinkIdentity(ctx, s.cfg.Issuer, identity.subject, user.ID); err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return user, nil
+}
+
+func (s *OIDCService) userError(err error) error {
+	if errors.Is(err, postgresql.ErrUserNotFound) {
+		return apierror.Errors.OIDC_USER_NOT_PROVISIONED
+	}
+	return apierror.Errors.DATABASE_ERROR.Wrap(err)
+}
+
+// syncRoles grants the default roles and the roles mapped from the groups of
+// the identity, and removes mapped roles the identity no longer has. Roles
+// that do not appear in the mapping are managed locally and left untouched.
+func (s *OIDCService) syncRoles(
+	ctx context.Context,
+	userID int,
+	groups []string,
+) error {
+	desired := slices.Clone(s.cfg.DefaultRoles)
+	var managed []string
+	for group, roles := range s.cfg.RoleMapping {
+		managed = append(managed, roles...)
+		if slices.Contains(groups, group) {
+			desired = append(desired, roles...)
+		}
+	}
+
+	current, err := s.perms.GetUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range desired {
+		if slices.Contains(current, role) {
+			continue
+		}
+		if err := s.perms.AssignRoleToUser(ctx, userID, role); err != nil {
+			if errors.Is(err, postgresql.ErrRoleNotFound) {
+				s.logger.Warn("Mapped role does not exist", zap.String("role", role))
+				continue
+			}
+			return err
+		}
+		current = append(current, role)
+	}
+	for _, role := range current {
+		if !slices.Contains(managed, role) || slices.Contains(desired, role) {
+			continue
+		}
+		if err := s.perms.RemoveRoleFromUser(ctx, userID, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OIDCService) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	s.mu.Lock()
+	disc := s.discovery
+	s.mu.Unlock()
+	if disc != nil {
+		return disc, nil
+	}
+
+	disc = &oidcDiscovery{}
+	if err := s.getJSON(ctx, strings.TrimSuffix(s.cfg.Issuer, "/")+oidcDiscoveryPath, disc); err != nil {
+		return nil, apierror.Errors.OIDC_PROVIDER_UNAVAILABLE.Wrap(err)
+	}
+	if disc.Issuer != s.cfg.Issuer {
+		return nil, apierror.Errors.OIDC_PROVIDER_UNAVAILABLE.Wrap(
+			fmt.Errorf("discovery issuer %q does not match %q", disc.Issuer, s.cfg.Issuer),
+		)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, apierror.Errors.OIDC_PROVIDER_UNAVAILABLE.Wrap(errors.New("discovery document is incomplete"))
+	}
+
+	s.mu.Lock()
+	s.discovery = disc
+	s.mu.Unlock()
+	return disc, nil
+}
+
+// publicKey returns the provider key with the given ID. An unknown ID refetches
+// the key set, since the provider may have rotated its keys, but not more often
+// than once per oidcJWKSRefetchGap.
+func (s *OIDCService) publicKey(
+	ctx context.Context,
+	kid string,
+) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.lookupKey(kid)
+	stale := s.now().Sub(s.keysFetched) >= oidcJWKSRefetchGap
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	disc, err := s.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set JWKSResponse
+	if err := s.getJSON(ctx, disc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := parseJWK(jwk)
+		if err != nil {
+			s.logger.Debug("Skipping provider key", zap.String("kid", jwk.KeyID), zap.Error(err))
+			continue
+		}
+		keys[jwk.KeyID] = pub
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.keysFetched = s.now()
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a key by ID; a token without kid is accepted only when the
+// provider publishes a single key. Callers hold s.mu.
+func (s *OIDCService) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *OIDCService) getJSON(
+	ctx context.Context,
+	target string,
+	out any,
+) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBodySize)).Decode(out)
+}
+
+func parseJWK(jwk JWK) (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := dec.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := dec.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC point size")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := dec.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+// stringList reads a claim that is either a single string or a list of strings.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// randomToken returns 32 random bytes in base64url: used for the state, the
+// nonce and the PKCE verifier (RFC 7636 allows 43 to 128 characters).
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
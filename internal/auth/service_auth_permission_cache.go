@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/nats"
+	"VPS-control/internal/permission"
+
+	"go.uber.org/zap"
+)
+
+var (
+	_ AccessResolver             = (*PermissionCache)(nil)
+	_ postgresql.PermissionStore = (*PermissionCache)(nil)
+)
+
+// PermissionCache wraps the permission store and keeps the roles and
+// permissions of recently active users. Role changes made through it drop the
+// affected entries here and, over NATS, on the other instances, so a revoked
+// right stops working on the next request. Entries also expire after ttl to
+// pick up changes made directly in the database.
+type PermissionCache struct {
+	postgresql.PermissionStore
+	ttl        time.Duration
+	publisher  eventPublisher
+	instanceID string
+	mu         sync.Mutex
+	entries    map[int]cachedAccess
+	generation uint64
+	now        func() time.Time
+	logger     *zap.Logger
+}
+
+type cachedAccess struct {
+	access    *UserAccess
+	expiresAt time.Time
+}
+
+func NewPermissionCache(
+	cfg config.PermissionCacheConfig,
+	store postgresql.PermissionStore,
+	publisher eventPublisher,
+	logger *zap.Logger,
+) *PermissionCache {
+	return &PermissionCache{
+		PermissionStore: store,
+		ttl:             cfg.TTL,
+		publisher:       publisher,
+		instanceID:      rand.Text(),
+		entries:         make(map[int]cachedAccess),
+		now:             time.Now,
+		logger:          logger.Named("permission_cache"),
+	}
+}
+
+// Access returns the current roles and permissions of the user, loading them
+// from the store on a miss. The result is shared and must not be modified.
+func (c *PermissionCache) Access(
+	ctx context.Context,
+	userID int,
+) (*UserAccess, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.access, nil
+	}
+
+	roles, err := c.PermissionStore.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := c.PermissionStore.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	access := &UserAccess{Roles: roles, Permissions: permissions}
+
+	// An invalidation during the load may have made the result stale: serve
+	// it to this request but do not cache it.
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[userID] = cachedAccess{access: access, expiresAt: c.now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return access, nil
+}
+
+func (c *PermissionCache) GetUserRoles(
+	ctx context.Context,
+	userID int,
+) ([]string, error) {
+	access, err := c.Access(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return access.Roles, nil
+}
+
+func (c *PermissionCache) GetUserPermissions(
+	ctx context.Context,
+	userID int,
+) ([]string, error) {
+	access, err := c.Access(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return access.Permissions, nil
+}
+
+func (c *PermissionCache) HasPermission(
+	ctx context.Context,
+	userID int,
+	name string,
+) (bool, error) {
+	access, err := c.Access(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return permission.Allows(access.Permissions, name), nil
+}
+
+func (c *PermissionCache) HasAnyPermission(
+	ctx context.Context,
+	userID int,
+	names []string,
+) (bool, error) {
+	access, err := c.Access(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return permission.AllowsAny(access.Permissions, names...), nil
+}
+
+func (c *PermissionCache) AssignRoleToUser(
+	ctx context.Context,
+	userID int,
+	roleName string,
+) error {
+	if err := c.PermissionStore.AssignRoleToUser(ctx, userID, roleName); err != nil {
+		return err
+	}
+	c.Invalidate(userID)
+	return nil
+}
+
+func (c *PermissionCache) RemoveRoleFromUser(
+	ctx context.Context,
+	userID int,
+	roleName string,
+) error {
+	if err := c.PermissionStore.RemoveRoleFromUser(ctx, userID, roleName); err != nil {
+		return err
+	}
+	c.Invalidate(userID)
+	return nil
+}
+
+// UpdateRole may rename the role, which changes the role list of its holders.
+func (c *PermissionCache) UpdateRole(
+	ctx context.Context,
+	roleID int,
+	name, description string,
+	policy postgresql.RolePolicy,
+) error {
+	if err := c.PermissionStore.UpdateRole(ctx, roleID, name, description, policy); err != nil {
+		return err
+	}
+	c.InvalidateAll()
+	return nil
+}
+
+func (c *PermissionCache) SetRolePermissions(
+	ctx context.Context,
+	roleID int,
+	permissions []string,
+) error {
+	if err := c.PermissionStore.SetRolePermissions(ctx, roleID, permissions); err != nil {
+		return err
+	}
+	c.InvalidateAll()
+	return nil
+}
+
+func (c *PermissionCache) DeleteRole(
+	ctx context.Context,
+	roleID int,
+) error {
+	if err := c.PermissionStore.DeleteRole(ctx, roleID); err != nil {
+		return err
+	}
+	c.InvalidateAll()
+	return nil
+}
+
+// Invalidate drops the entries of the given users on every instance.
+func (c *PermissionCache) Invalidate(userIDs ...int) {
+	c.drop(PermissionInvalidation{UserIDs: userIDs})
+	c.broadcast(PermissionInvalidation{Origin: c.instanceID, UserIDs: userIDs})
+}
+
+// InvalidateAll empties the cache on every instance. Role-wide changes use it:
+// they are rare and the holders are not known by ID.
+func (c *PermissionCache) InvalidateAll() {
+	c.drop(PermissionInvalidation{All: true})
+	c.broadcast(PermissionInvalidation{Origin: c.instanceID, All: true})
+}
+
+// Listen applies invalidations published by the other instances.
+func (c *PermissionCache) Listen(broker nats.Broker) error {
+	_, err := nats.Subscribe(broker, SubjectPermissionsInvalidate, c.handleInvalidation, c.logger)
+	return err
+}
+
+func (c *PermissionCache) handleInvalidation(payload nats.EventPayload[PermissionInvalidation]) error {
+	if payload.Data.Origin == c.instanceID {
+		return nil
+	}
+	c.drop(payload.Data)
+	return nil
+}
+
+func (c *PermissionCache) drop(msg PermissionInvalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if msg.All {
+		clear(c.entries)
+		return
+	}
+	for _, id := range msg.UserIDs {
+		delete(c.entries, id)
+	}
+}
+
+func (c *PermissionCache) broadcast(msg PermissionInvalidation) {
+	if c.publisher == nil {
+		return
+	}
+	if err := c.publisher.Publish(SubjectPermissionsInvalidate, msg); err != nil {
+		// Other instances catch up when their entries expire.
+		c.logger.Warn("Failed to broadcast permission invalidation", zap.Ints("user_ids", msg.UserIDs), zap.Error(err))
+	}
+}
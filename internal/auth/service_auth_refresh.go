@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+var _ RefreshManager = (*RefreshService)(nil)
+
+// RefreshService issues rotating refresh tokens. Every refresh consumes the
+// presented token and issues a successor in the same family; presenting a
+// consumed token again means it was stolen (or replayed), so the whole family
+// and the access tokens issued from it are revoked.
+type RefreshService struct {
+	store  sqlite3_local.RefreshTokenStore
+	ttl    time.Duration
+	now    func() time.Time
+	logger *zap.Logger
+}
+
+func NewRefreshService(
+	ttl time.Duration,
+	store sqlite3_local.RefreshTokenStore,
+	logger *zap.Logger,
+) *RefreshService {
+	return &RefreshService{
+		store:  store,
+		ttl:    ttl,
+		now:    time.Now,
+		logger: logger.Named("refresh"),
+	}
+}
+
+// Issue starts a new token family for a fresh login.
+func (s *RefreshService) Issue(
+	userID int,
+	username, accessJTI string,
+	amr []string,
+) (string, error) {
+	raw := rand.Text()
+	err := s.store.SaveRefreshToken(
+		sqlite3_local.RefreshTokenEntity{
+			TokenHash: hashRefreshToken(raw),
+			FamilyID:  rand.Text(),
+			UserID:    int64(userID),
+			Username:  username,
+			AccessJTI: accessJTI,
+			AMR:       strings.Join(amr, ","),
+			ExpiresAt: s.now().Add(s.ttl).Unix(),
+		},
+	)
+	if err != nil {
+		return "", apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return raw, nil
+}
+
+// Lookup returns the stored token if it can still be rotated.
+func (s *RefreshService) Lookup(raw string) (*sqlite3_local.RefreshTokenEntity, error) {
+	current, err := s.store.GetRefreshToken(hashRefreshToken(raw))
+	if err != nil {
+		if errors.Is(err, sqlite3_local.ErrRefreshTokenNotFound) {
+			return nil, apierror.Errors.REFRESH_TOKEN_INVALID
+		}
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	if current.Revoked || s.now().Unix() >= current.ExpiresAt {
+		return nil, apierror.Errors.REFRESH_TOKEN_INVALID
+	}
+	if current.Used {
+		s.revokeReusedFamily(current)
+		return nil, apierror.Errors.REFRESH_TOKEN_REUSED
+	}
+	return current, nil
+}
+
+// Rotate consumes current and returns its successor bound to accessJTI.
+func (s *RefreshService) Rotate(
+	raw string,
+	current *sqlite3_local.RefreshTokenEntity,
+	accessJTI string,
+) (string, error) {
+	next := rand.Text()
+	now := s.now()
+	err := s.store.RotateRefreshToken(
+		hashRefreshToken(raw),
+		sqlite3_local.RefreshTokenEntity{
+			TokenHash: hashRefreshToken(next),
+			FamilyID:  current.FamilyID,
+			UserID:    current.UserID,
+			Username:  current.Username,
+			AccessJTI: accessJTI,
+			AMR:       current.AMR,
+			ExpiresAt: now.Add(s.ttl).Unix(),
+		},
+		now.Unix(),
+	)
+	if err != nil {
+		if errors.Is(err, sqlite3_local.ErrRefreshTokenReused) {
+			s.revokeReusedFamily(current)
+			return "", apierror.Errors.REFRESH_TOKEN_REUSED
+		}
+		return "", apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return next, nil
+}
+
+func (s *RefreshService) RevokeFamily(familyID string) error {
+	return s.store.RevokeRefreshFamily(familyID, "SYSTEM")
+}
+
+// RevokeByAccessJTI revokes the family the access token was issued from.
+// Sessions without a refresh token are ignored.
+func (s *RefreshService) RevokeByAccessJTI(jti string) error {
+	familyID, err := s.store.GetFamilyByAccessJTI(jti)
+	if err != nil {
+		if errors.Is(err, sqlite3_local.ErrRefreshTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.RevokeFamily(familyID)
+}
+
+func (s *RefreshService) revokeReusedFamily(current *sqlite3_local.RefreshTokenEntity) {
+	s.logger.Warn(
+		"Refresh token reuse detected, revoking token family",
+		zap.String("username", current.Username),
+		zap.String("family_id", current.FamilyID),
+	)
+	if err := s.store.RevokeRefreshFamily(current.FamilyID, sqlite3_local.RevokedByRefreshReuse); err != nil {
+		s.logger.Error("Failed to revoke refresh token family", zap.String("family_id", current.FamilyID), zap.Error(err))
+	}
+}
+
+// SplitAMR restores the authentication methods stored with a refresh token.
+func SplitAMR(amr string) []string {
+	if amr == "" {
+		return nil
+	}
+	return strings.Split(amr, ",")
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+var _ TwoFactorManager = (*TwoFactorService)(nil)
+
+// TwoFactorService manages RFC 6238 TOTP enrollment and verification. A secret
+// stays pending until the first valid code confirms it; only confirmed
+// secrets are enforced at login.
+type TwoFactorService struct {
+	store  postgresql.TwoFactorStore
+	perms  postgresql.PermissionStore
+	cfg    config.TwoFactorConfig
+	now    func() time.Time
+	logger *zap.Logger
+}
+
+func NewTwoFactorService(
+	cfg config.TwoFactorConfig,
+	store postgresql.TwoFactorStore,
+	perms postgresql.PermissionStore,
+	logger *zap.Logger,
+) *TwoFactorService {
+	return &TwoFactorService{
+		store:  store,
+		perms:  perms,
+		cfg:    cfg,
+		now:    time.Now,
+		logger: logger.Named("two_factor"),
+	}
+}
+
+func (s *TwoFactorService) LoginRequirement(
+	ctx context.Context,
+	userID int,
+) (bool, bool, error) {
+	totp, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		return false, false, err
+	}
+	required, err := s.perms.UserRequiresTwoFactor(ctx, userID)
+	if err != nil {
+		return false, false, err
+	}
+	return totp != nil && totp.Confirmed, required, nil
+}
+
+func (s *TwoFactorService) Status(
+	ctx context.Context,
+	userID int,
+) (*TwoFactorStatusResponse, error) {
+	enabled, required, err := s.LoginRequirement(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	res := &TwoFactorStatusResponse{Enabled: enabled, Required: required}
+	if !enabled {
+		totp, err := s.getTOTP(ctx, userID)
+		if err != nil {
+			return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+		}
+		res.Pending = totp != nil
+		return res, nil
+	}
+
+	res.RecoveryCodesLeft, err = s.store.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return res, nil
+}
+
+// BeginEnrollment issues a new pending secret, replacing any previous pending one.
+func (s *TwoFactorService) BeginEnrollment(
+	ctx context.Context,
+	userID int,
+	username string,
+) (*TOTPEnrollmentResponse, error) {
+	totp, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if totp != nil && totp.Confirmed {
+		return nil, apierror.Errors.TWO_FACTOR_ALREADY_ENABLED
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, apierror.Errors.INTERNAL_ERROR.Wrap(err)
+	}
+	if err := s.store.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	return &TOTPEnrollmentResponse{
+		Secret: secret,
+		URI:    TOTPURI(s.cfg.Issuer, username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables 2FA and returns the recovery codes in plain text.
+// They are stored hashed and cannot be shown again.
+func (s *TwoFactorService) ConfirmEnrollment(
+	ctx context.Context,
+	userID int,
+	code string,
+) ([]string, error) {
+	totp, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if totp == nil {
+		return nil, apierror.Errors.TWO_FACTOR_NOT_ENABLED.WithMeta("no pending enrollment")
+	}
+	if totp.Confirmed {
+		return nil, apierror.Errors.TWO_FACTOR_ALREADY_ENABLED
+	}
+
+	step, ok := MatchTOTP(totp.Secret, code, s.now(), s.cfg.Skew)
+	if !ok {
+		return nil, apierror.Errors.INVALID_TWO_FACTOR_CODE
+	}
+
+	codes := GenerateRecoveryCodes(s.cfg.RecoveryCodes)
+	if err := s.store.ConfirmTOTP(ctx, userID, step, hashRecoveryCodes(codes)); err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	s.logger.Info("Two-factor authentication enabled", zap.Int("user_id", userID))
+	return codes, nil
+}
+
+// Verify accepts either a TOTP code or an unused recovery code.
+func (s *TwoFactorService) Verify(
+	ctx context.Context,
+	userID int,
+	code, recoveryCode string,
+) error {
+	totp, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if totp == nil || !totp.Confirmed {
+		return apierror.Errors.TWO_FACTOR_NOT_ENABLED
+	}
+
+	if recoveryCode != "" {
+		ok, err := s.store.ConsumeRecoveryCode(ctx, userID, HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return apierror.Errors.DATABASE_ERROR.Wrap(err)
+		}
+		if !ok {
+			return apierror.Errors.INVALID_TWO_FACTOR_CODE
+		}
+		s.logger.Info("Recovery code used", zap.Int("user_id", userID))
+		return nil
+	}
+
+	step, ok := MatchTOTP(totp.Secret, code, s.now(), s.cfg.Skew)
+	if !ok || step <= totp.LastUsedStep {
+		return apierror.Errors.INVALID_TWO_FACTOR_CODE
+	}
+	fresh, err := s.store.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if !fresh {
+		return apierror.Errors.INVALID_TWO_FACTOR_CODE
+	}
+	return nil
+}
+
+func (s *TwoFactorService) Disable(
+	ctx context.Context,
+	userID int,
+	code, recoveryCode string,
+) error {
+	required, err := s.perms.UserRequiresTwoFactor(ctx, userID)
+	if err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if required {
+		return apierror.Errors.TWO_FACTOR_REQUIRED
+	}
+
+	if err := s.Verify(ctx, userID, code, recoveryCode); err != nil {
+		return err
+	}
+	if err := s.store.DeleteTOTP(ctx, userID); err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	s.logger.Info("Two-factor authentication disabled", zap.Int("user_id", userID))
+	return nil
+}
+
+func (s *TwoFactorService) RegenerateRecoveryCodes(
+	ctx context.Context,
+	userID int,
+	code string,
+) ([]string, error) {
+	if err := s.Verify(ctx, userID, code, ""); err != nil {
+		return nil, err
+	}
+
+	codes := GenerateRecoveryCodes(s.cfg.RecoveryCodes)
+	if err := s.store.ReplaceRecoveryCodes(ctx, userID, hashRecoveryCodes(codes)); err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return codes, nil
+}
+
+// getTOTP returns nil without error when the user has no secret at all.
+func (s *TwoFactorService) getTOTP(
+	ctx context.Context,
+	userID int,
+) (*postgresql.TOTPEntity, error) {
+	totp, err := s.store.GetTOTP(ctx, userID)
+	if errors.Is(err, postgresql.ErrTOTPNotFound) {
+		return nil, nil
+	}
+	return totp, err
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return hashes
+}
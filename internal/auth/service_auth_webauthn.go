@@ -0,0 +1,446 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+const (
+	webAuthnChallengeSize = 32
+	webAuthnPublicKey     = "public-key"
+
+	userVerificationRequired  = "required"
+	userVerificationPreferred = "preferred"
+)
+
+var _ WebAuthnManager = (*WebAuthnService)(nil)
+
+// WebAuthnService runs the passkey registration and login ceremonies.
+// Ceremonies are kept in memory and keyed by their challenge, which the
+// browser echoes in clientDataJSON; like login challenges they only have to
+// survive a few minutes.
+type WebAuthnService struct {
+	cfg        config.WebAuthnConfig
+	store      postgresql.WebAuthnStore
+	rpIDHash   [32]byte
+	mu         sync.Mutex
+	ceremonies map[string]*webAuthnCeremony
+	now        func() time.Time
+	logger     *zap.Logger
+}
+
+// webAuthnCeremony is a started registration or login. UserID is zero for a
+// passwordless login, where the user is only known from the credential.
+type webAuthnCeremony struct {
+	kind             string
+	userID           int
+	userVerification string
+	expiresAt        time.Time
+}
+
+func NewWebAuthnService(
+	cfg config.WebAuthnConfig,
+	store postgresql.WebAuthnStore,
+	logger *zap.Logger,
+) *WebAuthnService {
+	return &WebAuthnService{
+		cfg:        cfg,
+		store:      store,
+		rpIDHash:   sha256.Sum256([]byte(cfg.RPID)),
+		ceremonies: make(map[string]*webAuthnCeremony),
+		now:        time.Now,
+		logger:     logger.Named("webauthn"),
+	}
+}
+
+func (s *WebAuthnService) BeginRegistration(
+	ctx context.Context,
+	userID int,
+	username string,
+) (*WebAuthnCreationOptions, error) {
+	existing, err := s.store.GetWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if len(existing) >= s.cfg.MaxPerUser {
+		return nil, apierror.Errors.WEBAUTHN_LIMIT_REACHED.WithMeta(map[string]any{"max": s.cfg.MaxPerUser})
+	}
+
+	challenge := s.start(CeremonyWebAuthnCreate, userID, userVerificationPreferred)
+	return &WebAuthnCreationOptions{
+		PublicKey: WebAuthnCreationOptionsJSON{
+			RP:        WebAuthnRP{ID: s.cfg.RPID, Name: s.cfg.RPName},
+			User:      WebAuthnUser{ID: userHandle(userID), Name: username, DisplayName: username},
+			Challenge: challenge,
+			PubKeyCredParams: []WebAuthnCredentialParam{
+				{Type: webAuthnPublicKey, Alg: COSEAlgES256},
+				{Type: webAuthnPublicKey, Alg: COSEAlgEdDSA},
+				{Type: webAuthnPublicKey, Alg: COSEAlgRS256},
+			},
+			Timeout:            s.cfg.Timeout.Milliseconds(),
+			ExcludeCredentials: descriptors(existing),
+			AuthenticatorSelection: WebAuthnAuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: userVerificationPreferred,
+			},
+			Attestation: "none",
+		},
+	}, nil
+}
+
+func (s *WebAuthnService) FinishRegistration(
+	ctx context.Context,
+	userID int,
+	req WebAuthnRegisterRequest,
+) (*PasskeyResponse, error) {
+	_, cd, ceremony, err := s.clientData(req.Credential.Response.ClientDataJSON, CeremonyWebAuthnCreate)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.userID != userID {
+		return nil, apierror.Errors.WEBAUTHN_CHALLENGE_INVALID
+	}
+
+	attestation, err := decodeBase64URL(req.Credential.Response.AttestationObject)
+	if err != nil {
+		return nil, s.verificationFailed("attestationObject is not base64url", err)
+	}
+	data, err := parseAttestationObject(attestation)
+	if err != nil {
+		return nil, s.verificationFailed("invalid attestation object", err)
+	}
+	if err := s.checkAuthenticatorData(data, ceremony); err != nil {
+		return nil, err
+	}
+	if data.publicKey == nil {
+		return nil, s.verificationFailed("attested credential data is missing", nil)
+	}
+	if id, err := decodeBase64URL(req.Credential.ID); err != nil || !bytes.Equal(id, data.credentialID) {
+		return nil, s.verificationFailed("credential ID does not match authenticator data", err)
+	}
+	s.logger.Debug("Passkey attested", zap.Int("user_id", userID), zap.String("origin", cd.Origin))
+
+	der, err := x509.MarshalPKIXPublicKey(data.publicKey)
+	if err != nil {
+		return nil, apierror.Errors.INTERNAL_ERROR.Wrap(err)
+	}
+
+	existing, err := s.store.GetWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if len(existing) >= s.cfg.MaxPerUser {
+		return nil, apierror.Errors.WEBAUTHN_LIMIT_REACHED.WithMeta(map[string]any{"max": s.cfg.MaxPerUser})
+	}
+
+	entity := postgresql.WebAuthnCredentialEntity{
+		UserID:       userID,
+		CredentialID: data.credentialID,
+		PublicKey:    der,
+		Algorithm:    data.algorithm,
+		SignCount:    int64(data.signCount),
+		Name:         req.Name,
+		Transports:   req.Credential.Response.Transports,
+		CreatedAt:    s.now(),
+	}
+	if entity.Transports == nil {
+		entity.Transports = []string{}
+	}
+	entity.ID, err = s.store.SaveWebAuthnCredential(ctx, entity)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrWebAuthnCredentialExists) {
+			return nil, apierror.Errors.WEBAUTHN_CREDENTIAL_EXISTS
+		}
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	res := toPasskeyResponse(entity)
+	return &res, nil
+}
+
+// BeginLogin starts an assertion. With a userID the user's passkeys are
+// offered as a second factor; without one any discoverable passkey may be
+// used, and user verification is required so that the key alone is a
+// multi-factor login.
+func (s *WebAuthnService) BeginLogin(
+	ctx context.Context,
+	userID int,
+) (*WebAuthnRequestOptions, error) {
+	options := &WebAuthnRequestOptions{
+		PublicKey: WebAuthnRequestOptionsJSON{
+			Timeout:          s.cfg.Timeout.Milliseconds(),
+			RPID:             s.cfg.RPID,
+			AllowCredentials: []WebAuthnCredentialDescriptor{},
+			UserVerification: userVerificationRequired,
+		},
+	}
+
+	if userID != 0 {
+		existing, err := s.store.GetWebAuthnCredentials(ctx, userID)
+		if err != nil {
+			return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+		}
+		if len(existing) == 0 {
+			return nil, apierror.Errors.WEBAUTHN_CREDENTIAL_NOT_FOUND
+		}
+		options.PublicKey.AllowCredentials = descriptors(existing)
+		options.PublicKey.UserVerification = userVerificationPreferred
+	}
+
+	options.PublicKey.Challenge = s.start(CeremonyWebAuthnGet, userID, options.PublicKey.UserVerification)
+	return options, nil
+}
+
+// FinishLogin verifies an assertion for the ceremony started by BeginLogin
+// with the same userID.
+func (s *WebAuthnService) FinishLogin(
+	ctx context.Context,
+	userID int,
+	assertion WebAuthnAssertion,
+) (*WebAuthnLogin, error) {
+	clientJSON, _, ceremony, err := s.clientData(assertion.Response.ClientDataJSON, CeremonyWebAuthnGet)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.userID != userID {
+		return nil, apierror.Errors.WEBAUTHN_CHALLENGE_INVALID
+	}
+
+	credentialID, err := decodeBase64URL(assertion.ID)
+	if err != nil {
+		return nil, s.verificationFailed("credential ID is not base64url", err)
+	}
+	credential, err := s.store.GetWebAuthnCredential(ctx, credentialID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrWebAuthnCredentialNotFound) {
+			return nil, s.verificationFailed("unknown credential", nil)
+		}
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if userID != 0 && credential.UserID != userID {
+		return nil, s.verificationFailed("credential belongs to another user", nil)
+	}
+	if handle := assertion.Response.UserHandle; handle != "" && handle != userHandle(credential.UserID) {
+		return nil, s.verificationFailed("user handle does not match credential", nil)
+	}
+
+	rawAuthData, err := decodeBase64URL(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return nil, s.verificationFailed("authenticatorData is not base64url", err)
+	}
+	data, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, s.verificationFailed("invalid authenticator data", err)
+	}
+	if err := s.checkAuthenticatorData(data, ceremony); err != nil {
+		return nil, err
+	}
+
+	signature, err := decodeBase64URL(assertion.Response.Signature)
+	if err != nil {
+		return nil, s.verificationFailed("signature is not base64url", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, apierror.Errors.INTERNAL_ERROR.Wrap(err)
+	}
+	if err := verifyAssertionSignature(credential.Algorithm, pub, rawAuthData, clientJSON, signature); err != nil {
+		return nil, s.verificationFailed("signature verification failed", err)
+	}
+
+	advanced, err := s.store.UseWebAuthnCredential(ctx, credential.ID, int64(data.signCount))
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if !advanced {
+		s.logger.Warn(
+			"Passkey signature counter did not advance, possible cloned authenticator",
+			zap.Int("user_id", credential.UserID),
+			zap.Int("credential", credential.ID),
+		)
+		return nil, apierror.Errors.WEBAUTHN_VERIFICATION_FAILED
+	}
+
+	return &WebAuthnLogin{UserID: credential.UserID, UserVerified: data.userVerified()}, nil
+}
+
+func (s *WebAuthnService) HasPasskeys(
+	ctx context.Context,
+	userID int,
+) (bool, error) {
+	existing, err := s.store.GetWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(existing) > 0, nil
+}
+
+func (s *WebAuthnService) List(
+	ctx context.Context,
+	userID int,
+) ([]PasskeyResponse, error) {
+	existing, err := s.store.GetWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	res := make([]PasskeyResponse, 0, len(existing))
+	for _, c := range existing {
+		res = append(res, toPasskeyResponse(c))
+	}
+	return res, nil
+}
+
+func (s *WebAuthnService) Delete(
+	ctx context.Context,
+	userID, id int,
+) error {
+	if err := s.store.DeleteWebAuthnCredential(ctx, userID, id); err != nil {
+		if errors.Is(err, postgresql.ErrWebAuthnCredentialNotFound) {
+			return apierror.Errors.WEBAUTHN_CREDENTIAL_NOT_FOUND
+		}
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return nil
+}
+
+// start registers a new ceremony and returns its base64url challenge.
+func (s *WebAuthnService) start(
+	kind string,
+	userID int,
+	userVerification string,
+) string {
+	b := make([]byte, webAuthnChallengeSize)
+	_, _ = rand.Read(b)
+	challenge := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, c := range s.ceremonies {
+		if now.After(c.expiresAt) {
+			delete(s.ceremonies, k)
+		}
+	}
+	s.ceremonies[challenge] = &webAuthnCeremony{
+		kind:             kind,
+		userID:           userID,
+		userVerification: userVerification,
+		expiresAt:        now.Add(s.cfg.Timeout),
+	}
+	return challenge
+}
+
+// clientData decodes clientDataJSON, checks its type and origin and takes the
+// ceremony named by its challenge. A ceremony is consumed even when the rest
+// of the verification fails, so each challenge gets a single attempt.
+func (s *WebAuthnService) clientData(
+	encoded, kind string,
+) ([]byte, *clientData, *webAuthnCeremony, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, nil, nil, s.verificationFailed("clientDataJSON is not base64url", err)
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, nil, nil, s.verificationFailed("clientDataJSON is not valid JSON", err)
+	}
+
+	s.mu.Lock()
+	ceremony, ok := s.ceremonies[cd.Challenge]
+	delete(s.ceremonies, cd.Challenge)
+	s.mu.Unlock()
+	if !ok || ceremony.kind != kind || s.now().After(ceremony.expiresAt) {
+		return nil, nil, nil, apierror.Errors.WEBAUTHN_CHALLENGE_INVALID
+	}
+
+	if cd.Type != kind {
+		return nil, nil, nil, s.verificationFailed("unexpected clientData type "+cd.Type, nil)
+	}
+	if !slices.Contains(s.cfg.Origins, cd.Origin) || cd.CrossOrigin {
+		return nil, nil, nil, s.verificationFailed("origin not allowed: "+cd.Origin, nil)
+	}
+	return raw, &cd, ceremony, nil
+}
+
+func (s *WebAuthnService) checkAuthenticatorData(
+	data *authenticatorData,
+	ceremony *webAuthnCeremony,
+) error {
+	if !bytes.Equal(data.rpIDHash, s.rpIDHash[:]) {
+		return s.verificationFailed("RP ID hash mismatch", nil)
+	}
+	if !data.userPresent() {
+		return s.verificationFailed("user presence flag not set", nil)
+	}
+	if ceremony.userVerification == userVerificationRequired && !data.userVerified() {
+		return s.verificationFailed("user verification required", nil)
+	}
+	return nil
+}
+
+// verificationFailed logs the reason and hides it from the client.
+func (s *WebAuthnService) verificationFailed(
+	reason string,
+	err error,
+) error {
+	s.logger.Info("Passkey verification failed", zap.String("reason", reason), zap.Error(err))
+	return apierror.Errors.WEBAUTHN_VERIFICATION_FAILED
+}
+
+// userHandle is the WebAuthn user.id. The numeric user ID is stable across
+// renames and carries no personal data.
+func userHandle(userID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(userID)))
+}
+
+func descriptors(credentials []postgresql.WebAuthnCredentialEntity) []WebAuthnCredentialDescriptor {
+	res := make([]WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		res = append(
+			res, WebAuthnCredentialDescriptor{
+				Type:       webAuthnPublicKey,
+				ID:         base64.RawURLEncoding.EncodeToString(c.CredentialID),
+				Transports: c.Transports,
+			},
+		)
+	}
+	return res
+}
+
+func toPasskeyResponse(c postgresql.WebAuthnCredentialEntity) PasskeyResponse {
+	res := PasskeyResponse{
+		ID:         c.ID,
+		Name:       c.Name,
+		Transports: c.Transports,
+		CreatedAt:  c.CreatedAt.Unix(),
+	}
+	if c.LastUsedAt != nil {
+		res.LastUsedAt = c.LastUsedAt.Unix()
+	}
+	return res
+}
+
+// decodeBase64URL accepts base64url with or without padding, as browsers
+// differ in what they send.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
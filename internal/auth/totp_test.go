@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 key "12345678901234567890", last six digits.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFCVectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP_Skew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	prev, _ := TOTPCode(rfcSecret, TOTPStep(now)-1)
+	old, _ := TOTPCode(rfcSecret, TOTPStep(now)-2)
+
+	if step, ok := MatchTOTP(rfcSecret, prev, now, 1); !ok || step != TOTPStep(now)-1 {
+		t.Errorf("previous step code not accepted with skew 1: step=%d ok=%v", step, ok)
+	}
+	if _, ok := MatchTOTP(rfcSecret, prev, now, 0); ok {
+		t.Error("previous step code accepted with skew 0")
+	}
+	if _, ok := MatchTOTP(rfcSecret, old, now, 1); ok {
+		t.Error("code two steps old accepted with skew 1")
+	}
+	if _, ok := MatchTOTP(rfcSecret, "12345", now, 1); ok {
+		t.Error("short code accepted")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("VPS API", "admin", "ABC")
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("invalid uri %q: %v", uri, err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/VPS API:admin" {
+		t.Errorf("uri = %q", uri)
+	}
+	if q := u.Query(); q.Get("secret") != "ABC" || q.Get("issuer") != "VPS API" || q.Get("digits") != "6" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(10)
+	if len(codes) != 10 {
+		t.Fatalf("len = %d, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected format %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != len(codes) {
+		t.Error("duplicate recovery codes")
+	}
+
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))) {
+		t.Error("hash does not normalize case, dashes and spaces")
+	}
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+type fakeTwoFactorStore struct {
+	postgresql.TwoFactorStore
+	totp     *postgresql.TOTPEntity
+	recovery map[string]bool
+}
+
+func (f *fakeTwoFactorStore) GetTOTP(
+	_ context.Context,
+	_ int,
+) (*postgresql.TOTPEntity, error) {
+	if f.totp == nil {
+		return nil, postgresql.ErrTOTPNotFound
+	}
+	cp := *f.totp
+	return &cp, nil
+}
+
+func (f *fakeTwoFactorStore) SaveTOTPSecret(
+	_ context.Context,
+	userID int,
+	secret string,
+) error {
+	f.totp = &postgresql.TOTPEntity{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (f *fakeTwoFactorStore) ConfirmTOTP(
+	_ context.Context,
+	_ int,
+	step int64,
+	hashes []string,
+) error {
+	f.totp.Confirmed = true
+	f.totp.LastUsedStep = step
+	f.recovery = map[string]bool{}
+	for _, h := range hashes {
+		f.recovery[h] = false
+	}
+	return nil
+}
+
+func (f *fakeTwoFactorStore) UseTOTPStep(
+	_ context.Context,
+	_ int,
+	step int64,
+) (bool, error) {
+	if step <= f.totp.LastUsedStep {
+		return false, nil
+	}
+	f.totp.LastUsedStep = step
+	return true, nil
+}
+
+func (f *fakeTwoFactorStore) ConsumeRecoveryCode(
+	_ context.Context,
+	_ int,
+	hash string,
+) (bool, error) {
+	used, ok := f.recovery[hash]
+	if !ok || used {
+		return false, nil
+	}
+	f.recovery[hash] = true
+	return true, nil
+}
+
+func (f *fakeTwoFactorStore) DeleteTOTP(
+	_ context.Context,
+	_ int,
+) error {
+	f.totp = nil
+	f.recovery = nil
+	return nil
+}
+
+type fakeRequirementStore struct {
+	postgresql.PermissionStore
+	required bool
+}
+
+func (f *fakeRequirementStore) UserRequiresTwoFactor(
+	_ context.Context,
+	_ int,
+) (bool, error) {
+	return f.required, nil
+}
+
+func newTestTwoFactorService(now *time.Time) (*TwoFactorService, *fakeTwoFactorStore, *fakeRequirementStore) {
+	store := &fakeTwoFactorStore{}
+	perms := &fakeRequirementStore{}
+	svc := NewTwoFactorService(
+		config.TwoFactorConfig{Issuer: "test", Skew: 1, RecoveryCodes: 3},
+		store, perms, zap.NewNop(),
+	)
+	svc.now = func() time.Time { return *now }
+	return svc, store, perms
+}
+
+func TestTwoFactorService_EnrollAndVerify(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	svc, store, _ := newTestTwoFactorService(&now)
+	ctx := context.Background()
+
+	enrollment, err := svc.BeginEnrollment(ctx, 1, "admin")
+	if err != nil {
+		t.Fatalf("BeginEnrollment() error: %v", err)
+	}
+	if enabled, _, _ := svc.LoginRequirement(ctx, 1); enabled {
+		t.Fatal("pending secret must not be enforced at login")
+	}
+
+	code, _ := TOTPCode(enrollment.Secret, TOTPStep(now))
+	codes, err := svc.ConfirmEnrollment(ctx, 1, code)
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error: %v", err)
+	}
+	if len(codes) != 3 || !store.totp.Confirmed {
+		t.Fatalf("codes = %v, confirmed = %v", codes, store.totp.Confirmed)
+	}
+
+	if err := svc.Verify(ctx, 1, code, ""); !errors.Is(err, apierror.Errors.INVALID_TWO_FACTOR_CODE) {
+		t.Errorf("replayed code error = %v, want INVALID_TWO_FACTOR_CODE", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	next, _ := TOTPCode(enrollment.Secret, TOTPStep(now))
+	if err := svc.Verify(ctx, 1, next, ""); err != nil {
+		t.Errorf("Verify(next step) error: %v", err)
+	}
+
+	if err := svc.Verify(ctx, 1, "", codes[0]); err != nil {
+		t.Errorf("Verify(recovery) error: %v", err)
+	}
+	if err := svc.Verify(ctx, 1, "", codes[0]); !errors.Is(err, apierror.Errors.INVALID_TWO_FACTOR_CODE) {
+		t.Errorf("reused recovery code error = %v", err)
+	}
+
+	if _, err := svc.BeginEnrollment(ctx, 1, "admin"); !errors.Is(err, apierror.Errors.TWO_FACTOR_ALREADY_ENABLED) {
+		t.Errorf("re-enrollment error = %v", err)
+	}
+}
+
+func TestTwoFactorService_DisableRequiredByRole(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	svc, store, perms := newTestTwoFactorService(&now)
+	ctx := context.Background()
+
+	enrollment, _ := svc.BeginEnrollment(ctx, 1, "admin")
+	code, _ := TOTPCode(enrollment.Secret, TOTPStep(now))
+	codes, err := svc.ConfirmEnrollment(ctx, 1, code)
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error: %v", err)
+	}
+
+	perms.required = true
+	if err := svc.Disable(ctx, 1, "", codes[0]); !errors.Is(err, apierror.Errors.TWO_FACTOR_REQUIRED) {
+		t.Errorf("Disable() with role requirement error = %v", err)
+	}
+
+	perms.required = false
+	if err := svc.Disable(ctx, 1, "", codes[0]); err != nil {
+		t.Fatalf("Disable() error: %v", err)
+	}
+	if store.totp != nil {
+		t.Error("secret not removed")
+	}
+}
+
+func TestChallengeService(t *testing.T) {
+	svc := NewChallengeService(time.Minute, 2)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	ch := svc.Create(&AuthResult{User: &postgresql.UserResponseDTO{ID: 1}}, false, "")
+	if _, ok := svc.Get(ch.ID); !ok {
+		t.Fatal("fresh challenge not found")
+	}
+
+	if left := svc.Fail(ch.ID); left != 1 {
+		t.Errorf("attempts left = %d, want 1", left)
+	}
+	if left := svc.Fail(ch.ID); left != 0 {
+		t.Errorf("attempts left = %d, want 0", left)
+	}
+	if _, ok := svc.Get(ch.ID); ok {
+		t.Error("challenge must be dropped after max attempts")
+	}
+
+	expiring := svc.Create(&AuthResult{}, false, "")
+	now = now.Add(2 * time.Minute)
+	if _, ok := svc.Get(expiring.ID); ok {
+		t.Error("expired challenge returned")
+	}
+}
@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for passkeys, in order of
+// preference.
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// Authenticator data flags (WebAuthn §6.1).
+const (
+	authDataFlagUP = 0x01
+	authDataFlagUV = 0x04
+	authDataFlagAT = 0x40
+	authDataFlagED = 0x80
+
+	authDataMinLen = 37
+	cborMaxDepth   = 16
+)
+
+var errCBOR = errors.New("malformed CBOR")
+
+// authenticatorData is the parsed binary structure signed by the
+// authenticator. The attested credential fields are set only during
+// registration.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    crypto.PublicKey
+	algorithm    int
+}
+
+func (d *authenticatorData) userPresent() bool  { return d.flags&authDataFlagUP != 0 }
+func (d *authenticatorData) userVerified() bool { return d.flags&authDataFlagUV != 0 }
+
+// clientData is the JSON the browser builds for a ceremony; its hash is part
+// of what the authenticator signs.
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < authDataMinLen {
+		return nil, errors.New("authenticator data too short")
+	}
+	d := &authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[authDataMinLen:]
+
+	if d.flags&authDataFlagAT != 0 {
+		// aaguid (16) | credential ID length (2) | credential ID | COSE key
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data too short")
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || len(rest) < idLen {
+			return nil, errors.New("invalid credential ID length")
+		}
+		d.credentialID, rest = rest[:idLen], rest[idLen:]
+
+		key, next, err := decodeCBOR(rest, 0)
+		if err != nil {
+			return nil, fmt.Errorf("credential public key: %w", err)
+		}
+		if d.publicKey, d.algorithm, err = parseCOSEKey(key); err != nil {
+			return nil, err
+		}
+		rest = next
+	}
+	if d.flags&authDataFlagED != 0 {
+		_, next, err := decodeCBOR(rest, 0)
+		if err != nil {
+			return nil, fmt.Errorf("extensions: %w", err)
+		}
+		rest = next
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing bytes in authenticator data")
+	}
+	return d, nil
+}
+
+// parseAttestationObject returns the authenticator data of a registration.
+// The attestation statement is not verified: passkeys are registered by an
+// already authenticated user and "none" attestation is requested.
+func parseAttestationObject(b []byte) (*authenticatorData, error) {
+	v, rest, err := decodeCBOR(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return nil, errors.New("attestation object is not a CBOR map")
+	}
+	raw, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authData")
+	}
+	return parseAuthenticatorData(raw)
+}
+
+// parseCOSEKey converts a COSE_Key map (RFC 9052 §7) to a Go public key.
+func parseCOSEKey(v any) (crypto.PublicKey, int, error) {
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, 0, errors.New("COSE key is not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid P-256 key")
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		return pub, COSEAlgES256, err
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), COSEAlgEdDSA, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		exp := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > math.MaxInt32 {
+			return nil, 0, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, COSEAlgRS256, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported COSE key (kty %d, alg %d)", kty, alg)
+	}
+}
+
+// verifyAssertionSignature checks the signature over authData || SHA-256(clientDataJSON).
+func verifyAssertionSignature(
+	algorithm int,
+	pub crypto.PublicKey,
+	authData, clientDataJSON, signature []byte,
+) error {
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientHash[:]...)
+
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if algorithm != COSEAlgES256 {
+			break
+		}
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if algorithm != COSEAlgEdDSA {
+			break
+		}
+		if !ed25519.Verify(key, signed, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if algorithm != COSEAlgRS256 {
+			break
+		}
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	}
+	return fmt.Errorf("key does not match algorithm %d", algorithm)
+}
+
+// decodeCBOR decodes one CBOR data item (RFC 8949) and returns the remaining
+// bytes. Only what WebAuthn uses is supported: integers, byte and text
+// strings, arrays, maps, booleans and null. Integers decode to int64, maps to
+// map[any]any.
+func decodeCBOR(b []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth || len(b) == 0 {
+		return nil, nil, errCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		default:
+			return nil, nil, errCBOR
+		}
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(b) < n {
+			return nil, nil, errCBOR
+		}
+		for _, c := range b[:n] {
+			arg = arg<<8 | uint64(c)
+		}
+		b = b[n:]
+	default:
+		// Indefinite lengths are not used by authenticators (CTAP2 canonical CBOR).
+		return nil, nil, errCBOR
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(arg), b, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(arg), b, nil
+	case 2, 3:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		data, rest := b[:arg], b[arg:]
+		if major == 3 {
+			return string(data), rest, nil
+		}
+		return data, rest, nil
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		items := make([]any, 0, arg)
+		for range arg {
+			var (
+				item any
+				err  error
+			)
+			if item, b, err = decodeCBOR(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, b, nil
+	case 5:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		m := make(map[any]any, arg)
+		for range arg {
+			var (
+				key, val any
+				err      error
+			)
+			if key, b, err = decodeCBOR(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if val, b, err = decodeCBOR(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = val
+		}
+		return m, b, nil
+	default:
+		return nil, nil, errCBOR
+	}
+}
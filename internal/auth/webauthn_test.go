@@ -0,0 +1,473 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+const (
+	testRPID   = "vps.example.com"
+	testOrigin = "https://vps.example.com"
+)
+
+// cborPair keeps map keys in insertion order so encodings are deterministic.
+type cborPair struct {
+	key, value any
+}
+
+func cborHeader(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+}
+
+func cborEncode(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return cborHeader(1, uint64(-1-v))
+		}
+		return cborHeader(0, uint64(v))
+	case []byte:
+		return append(cborHeader(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHeader(3, uint64(len(v))), v...)
+	case []cborPair:
+		out := cborHeader(5, uint64(len(v)))
+		for _, p := range v {
+			out = append(out, cborEncode(p.key)...)
+			out = append(out, cborEncode(p.value)...)
+		}
+		return out
+	}
+	panic("unsupported CBOR value")
+}
+
+// softAuthenticator is a software passkey: it creates credentials and signs
+// assertions the way a platform authenticator does.
+type softAuthenticator struct {
+	rpID         string
+	origin       string
+	credentialID []byte
+	ecKey        *ecdsa.PrivateKey
+	edKey        ed25519.PrivateKey
+	signCount    uint32
+	userVerified bool
+	userHandle   string
+}
+
+func newSoftAuthenticator(t *testing.T, eddsa bool) *softAuthenticator {
+	a := &softAuthenticator{rpID: testRPID, origin: testOrigin, credentialID: make([]byte, 16), userVerified: true}
+	_, _ = rand.Read(a.credentialID)
+	var err error
+	if eddsa {
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	if a.edKey != nil {
+		return cborEncode(
+			[]cborPair{
+				{1, 1}, {3, COSEAlgEdDSA}, {-1, 6}, {-2, []byte(a.edKey.Public().(ed25519.PublicKey))},
+			},
+		)
+	}
+	pub, _ := a.ecKey.PublicKey.Bytes()
+	return cborEncode([]cborPair{{1, 2}, {3, COSEAlgES256}, {-1, 1}, {-2, pub[1:33]}, {-3, pub[33:]}})
+}
+
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(authDataFlagUP)
+	if a.userVerified {
+		flags |= authDataFlagUV
+	}
+	if attested {
+		flags |= authDataFlagAT
+	}
+	out := append(rpHash[:], flags)
+	out = binary.BigEndian.AppendUint32(out, a.signCount)
+	if attested {
+		out = append(out, make([]byte, 16)...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credentialID)))
+		out = append(out, a.credentialID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func (a *softAuthenticator) clientDataJSON(kind, challenge string) []byte {
+	b, _ := json.Marshal(clientData{Type: kind, Challenge: challenge, Origin: a.origin})
+	return b
+}
+
+func (a *softAuthenticator) create(options *WebAuthnCreationOptions) WebAuthnAttestation {
+	enc := base64.RawURLEncoding
+	attestation := cborEncode(
+		[]cborPair{{"fmt", "none"}, {"attStmt", []cborPair{}}, {"authData", a.authData(true)}},
+	)
+
+	var res WebAuthnAttestation
+	res.ID = enc.EncodeToString(a.credentialID)
+	res.RawID = res.ID
+	res.Type = webAuthnPublicKey
+	res.Response.ClientDataJSON = enc.EncodeToString(a.clientDataJSON(CeremonyWebAuthnCreate, options.PublicKey.Challenge))
+	res.Response.AttestationObject = enc.EncodeToString(attestation)
+	res.Response.Transports = []string{"internal"}
+	a.userHandle = options.PublicKey.User.ID
+	return res
+}
+
+func (a *softAuthenticator) get(options *WebAuthnRequestOptions) WebAuthnAssertion {
+	enc := base64.RawURLEncoding
+	a.signCount++
+	authData := a.authData(false)
+	clientJSON := a.clientDataJSON(CeremonyWebAuthnGet, options.PublicKey.Challenge)
+	clientHash := sha256.Sum256(clientJSON)
+	signed := append(append([]byte{}, authData...), clientHash[:]...)
+
+	var sig []byte
+	if a.edKey != nil {
+		sig = ed25519.Sign(a.edKey, signed)
+	} else {
+		digest := sha256.Sum256(signed)
+		sig, _ = ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:])
+	}
+
+	var res WebAuthnAssertion
+	res.ID = enc.EncodeToString(a.credentialID)
+	res.RawID = res.ID
+	res.Type = webAuthnPublicKey
+	res.Response.ClientDataJSON = enc.EncodeToString(clientJSON)
+	res.Response.AuthenticatorData = enc.EncodeToString(authData)
+	res.Response.Signature = enc.EncodeToString(sig)
+	res.Response.UserHandle = a.userHandle
+	return res
+}
+
+type fakeWebAuthnStore struct {
+	credentials []postgresql.WebAuthnCredentialEntity
+}
+
+func (f *fakeWebAuthnStore) GetWebAuthnCredentials(_ context.Context, userID int) ([]postgresql.WebAuthnCredentialEntity, error) {
+	var res []postgresql.WebAuthnCredentialEntity
+	for _, c := range f.credentials {
+		if c.UserID == userID {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeWebAuthnStore) GetWebAuthnCredential(_ context.Context, id []byte) (*postgresql.WebAuthnCredentialEntity, error) {
+	for _, c := range f.credentials {
+		if string(c.CredentialID) == string(id) {
+			return &c, nil
+		}
+	}
+	return nil, postgresql.ErrWebAuthnCredentialNotFound
+}
+
+func (f *fakeWebAuthnStore) SaveWebAuthnCredential(_ context.Context, c postgresql.WebAuthnCredentialEntity) (int, error) {
+	if _, err := f.GetWebAuthnCredential(context.Background(), c.CredentialID); err == nil {
+		return 0, postgresql.ErrWebAuthnCredentialExists
+	}
+	c.ID = len(f.credentials) + 1
+	f.credentials = append(f.credentials, c)
+	return c.ID, nil
+}
+
+func (f *fakeWebAuthnStore) UseWebAuthnCredential(_ context.Context, id int, signCount int64) (bool, error) {
+	for i := range f.credentials {
+		c := &f.credentials[i]
+		if c.ID == id && (c.SignCount < signCount || (c.SignCount == 0 && signCount == 0)) {
+			c.SignCount = signCount
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeWebAuthnStore) DeleteWebAuthnCredential(_ context.Context, userID, id int) error {
+	for i, c := range f.credentials {
+		if c.ID == id && c.UserID == userID {
+			f.credentials = append(f.credentials[:i], f.credentials[i+1:]...)
+			return nil
+		}
+	}
+	return postgresql.ErrWebAuthnCredentialNotFound
+}
+
+func newTestWebAuthnService() (*WebAuthnService, *fakeWebAuthnStore) {
+	store := &fakeWebAuthnStore{}
+	cfg := config.WebAuthnConfig{
+		Enabled:    true,
+		RPID:       testRPID,
+		RPName:     "VPS Control",
+		Origins:    []string{testOrigin},
+		Timeout:    time.Minute,
+		MaxPerUser: 2,
+	}
+	return NewWebAuthnService(cfg, store, zap.NewNop()), store
+}
+
+// registerPasskey runs a registration ceremony for the authenticator.
+func registerPasskey(
+	t *testing.T,
+	svc *WebAuthnService,
+	a *softAuthenticator,
+	userID int,
+) {
+	t.Helper()
+	ctx := context.Background()
+	options, err := svc.BeginRegistration(ctx, userID, "admin")
+	if err != nil {
+		t.Fatalf("BeginRegistration() error: %v", err)
+	}
+	req := WebAuthnRegisterRequest{Name: "laptop", Credential: a.create(options)}
+	if _, err := svc.FinishRegistration(ctx, userID, req); err != nil {
+		t.Fatalf("FinishRegistration() error: %v", err)
+	}
+}
+
+func TestWebAuthnRegisterAndLogin(t *testing.T) {
+	for _, eddsa := range []bool{false, true} {
+		name := "ES256"
+		if eddsa {
+			name = "EdDSA"
+		}
+		t.Run(
+			name, func(t *testing.T) {
+				ctx := context.Background()
+				svc, store := newTestWebAuthnService()
+				a := newSoftAuthenticator(t, eddsa)
+				registerPasskey(t, svc, a, 1)
+
+				if has, _ := svc.HasPasskeys(ctx, 1); !has {
+					t.Fatal("HasPasskeys() = false after registration")
+				}
+
+				// Second factor for user 1.
+				options, err := svc.BeginLogin(ctx, 1)
+				if err != nil {
+					t.Fatalf("BeginLogin() error: %v", err)
+				}
+				if len(options.PublicKey.AllowCredentials) != 1 {
+					t.Errorf("allowCredentials = %+v", options.PublicKey.AllowCredentials)
+				}
+				login, err := svc.FinishLogin(ctx, 1, a.get(options))
+				if err != nil {
+					t.Fatalf("FinishLogin() error: %v", err)
+				}
+				if login.UserID != 1 || !login.UserVerified {
+					t.Errorf("login = %+v", login)
+				}
+
+				// Passwordless: the user comes from the credential.
+				options, _ = svc.BeginLogin(ctx, 0)
+				login, err = svc.FinishLogin(ctx, 0, a.get(options))
+				if err != nil || login.UserID != 1 {
+					t.Fatalf("passwordless FinishLogin() = %+v, %v", login, err)
+				}
+				if store.credentials[0].SignCount != 2 {
+					t.Errorf("sign count = %d, want 2", store.credentials[0].SignCount)
+				}
+			},
+		)
+	}
+}
+
+func TestWebAuthnRejected(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		userID int
+		before func(a *softAuthenticator)
+		after  func(assertion *WebAuthnAssertion)
+		want   *apierror.AppError
+	}{
+		{
+			name:   "wrong origin",
+			userID: 1,
+			before: func(a *softAuthenticator) { a.origin = "https://evil.example.com" },
+			want:   apierror.Errors.WEBAUTHN_VERIFICATION_FAILED,
+		},
+		{
+			name:   "wrong rp id",
+			userID: 1,
+			before: func(a *softAuthenticator) { a.rpID = "evil.example.com" },
+			want:   apierror.Errors.WEBAUTHN_VERIFICATION_FAILED,
+		},
+		{
+			name:   "bad signature",
+			userID: 1,
+			after: func(assertion *WebAuthnAssertion) {
+				assertion.Response.Signature = base64.RawURLEncoding.EncodeToString([]byte("forged"))
+			},
+			want: apierror.Errors.WEBAUTHN_VERIFICATION_FAILED,
+		},
+		{
+			name:   "cloned authenticator",
+			userID: 1,
+			before: func(a *softAuthenticator) { a.signCount = 0 },
+			want:   apierror.Errors.WEBAUTHN_VERIFICATION_FAILED,
+		},
+		{
+			name:   "passwordless without user verification",
+			userID: 0,
+			before: func(a *softAuthenticator) { a.userVerified = false },
+			want:   apierror.Errors.WEBAUTHN_VERIFICATION_FAILED,
+		},
+		{
+			name:   "other user's passkey",
+			userID: 2,
+			want:   apierror.Errors.WEBAUTHN_VERIFICATION_FAILED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				svc, store := newTestWebAuthnService()
+				a := newSoftAuthenticator(t, false)
+				registerPasskey(t, svc, a, 1)
+				// User 2 needs a passkey of its own to start a second-factor ceremony.
+				registerPasskey(t, svc, newSoftAuthenticator(t, false), 2)
+				store.credentials[0].SignCount = 5
+				a.signCount = 5
+
+				options, err := svc.BeginLogin(ctx, tt.userID)
+				if err != nil {
+					t.Fatalf("BeginLogin() error: %v", err)
+				}
+				if tt.before != nil {
+					tt.before(a)
+				}
+				assertion := a.get(options)
+				if tt.after != nil {
+					tt.after(&assertion)
+				}
+
+				_, err = svc.FinishLogin(ctx, tt.userID, assertion)
+				assertAppError(t, err, tt.want)
+			},
+		)
+	}
+}
+
+func TestWebAuthnCeremony(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"challenge is single use", func(t *testing.T) {
+			svc, _ := newTestWebAuthnService()
+			a := newSoftAuthenticator(t, false)
+			registerPasskey(t, svc, a, 1)
+
+			options, _ := svc.BeginLogin(ctx, 1)
+			assertion := a.get(options)
+			if _, err := svc.FinishLogin(ctx, 1, assertion); err != nil {
+				t.Fatalf("FinishLogin() error: %v", err)
+			}
+			_, err := svc.FinishLogin(ctx, 1, assertion)
+			assertAppError(t, err, apierror.Errors.WEBAUTHN_CHALLENGE_INVALID)
+		},
+	)
+
+	t.Run(
+		"ceremony bound to user", func(t *testing.T) {
+			svc, _ := newTestWebAuthnService()
+			a := newSoftAuthenticator(t, false)
+			registerPasskey(t, svc, a, 1)
+
+			// A passwordless challenge cannot complete another user's second factor.
+			options, _ := svc.BeginLogin(ctx, 0)
+			_, err := svc.FinishLogin(ctx, 1, a.get(options))
+			assertAppError(t, err, apierror.Errors.WEBAUTHN_CHALLENGE_INVALID)
+		},
+	)
+
+	t.Run(
+		"expired challenge", func(t *testing.T) {
+			svc, _ := newTestWebAuthnService()
+			a := newSoftAuthenticator(t, false)
+			registerPasskey(t, svc, a, 1)
+
+			options, _ := svc.BeginLogin(ctx, 1)
+			svc.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+			_, err := svc.FinishLogin(ctx, 1, a.get(options))
+			assertAppError(t, err, apierror.Errors.WEBAUTHN_CHALLENGE_INVALID)
+		},
+	)
+
+	t.Run(
+		"duplicate and limit", func(t *testing.T) {
+			svc, _ := newTestWebAuthnService()
+			a := newSoftAuthenticator(t, false)
+			registerPasskey(t, svc, a, 1)
+
+			options, _ := svc.BeginRegistration(ctx, 1, "admin")
+			_, err := svc.FinishRegistration(ctx, 1, WebAuthnRegisterRequest{Name: "again", Credential: a.create(options)})
+			assertAppError(t, err, apierror.Errors.WEBAUTHN_CREDENTIAL_EXISTS)
+
+			registerPasskey(t, svc, newSoftAuthenticator(t, true), 1)
+			_, err = svc.BeginRegistration(ctx, 1, "admin")
+			assertAppError(t, err, apierror.Errors.WEBAUTHN_LIMIT_REACHED)
+		},
+	)
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated length", []byte{0x19, 0x01}},
+		{"byte string past end", []byte{0x45, 0x01, 0x02}},
+		{"indefinite length", []byte{0x5f}},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+		{"array key", []byte{0xa1, 0x80, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if _, _, err := decodeCBOR(tt.data, 0); !errors.Is(err, errCBOR) {
+					t.Errorf("decodeCBOR() error = %v, want errCBOR", err)
+				}
+			},
+		)
+	}
+}
@@ -11,15 +11,58 @@ func RegisterAuthRoutes(
 	rg *gin.RouterGroup,
 	h auth.Handler,
 	authMW gin.HandlerFunc,
+	stepUp gin.HandlerFunc,
 ) {
 	rg.POST("/login", h.Login)
+	rg.POST("/refresh", h.Refresh)
+	rg.POST("/introspect", h.Introspect)
 	rg.POST("/verify", authMW, h.Verify)
 	rg.POST("/logout", authMW, h.Logout)
+	rg.POST("/reauth", authMW, h.Reauthenticate)
+	rg.POST("/reauth/passkey", authMW, h.BeginReauthPasskey)
+	rg.POST("/password", authMW, h.ChangePassword)
+
+	rg.GET("/oidc/login", h.OIDCLogin)
+	rg.GET("/oidc/callback", h.OIDCCallback)
+
+	rg.POST("/2fa/verify", h.VerifyTwoFactor)
+	twoFactor := rg.Group("/2fa")
+	twoFactor.Use(authMW)
+	{
+		twoFactor.GET("", h.GetTwoFactorStatus)
+		twoFactor.POST("/enroll", h.EnrollTwoFactor)
+		twoFactor.POST("/confirm", h.ConfirmTwoFactor)
+		twoFactor.POST("/disable", h.DisableTwoFactor)
+		twoFactor.POST("/recovery/regenerate", h.RegenerateRecoveryCodes)
+	}
+
+	rg.POST("/passkeys/login/begin", h.BeginPasskeyLogin)
+	rg.POST("/passkeys/login/finish", h.FinishPasskeyLogin)
+	passkeys := rg.Group("/passkeys")
+	passkeys.Use(authMW)
+	{
+		passkeys.GET("", h.ListPasskeys)
+		passkeys.POST("/register/begin", h.BeginPasskeyRegistration)
+		passkeys.POST("/register/finish", h.FinishPasskeyRegistration)
+		passkeys.POST("/delete", h.DeletePasskey)
+	}
+
+	apiKeys := rg.Group("/api-keys")
+	apiKeys.Use(authMW, middleware.RequirePermission(auth.PermAPIKeyManage))
+	{
+		apiKeys.GET("", h.ListAPIKeys)
+		apiKeys.POST("/create", stepUp, h.CreateAPIKey)
+		apiKeys.POST("/revoke", h.RevokeAPIKey)
+	}
 
 	sessions := rg.Group("/sessions")
 	sessions.Use(authMW)
 	{
 		sessions.GET("", middleware.RequirePermission(auth.PermUserView), h.GetSessions)
-		sessions.POST("/revoke", middleware.RequirePermission(auth.PermUserEdit), h.RevokeSession)
+		sessions.POST("/revoke", middleware.RequirePermission(auth.PermUserEdit), stepUp, h.RevokeSession)
+		sessions.GET("/me", h.GetMySessions)
+		sessions.POST("/me/revoke", h.RevokeMySession)
 	}
+
+	rg.GET("/login-attempts", authMW, middleware.RequirePermission(auth.PermUserView), h.GetLoginAttempts)
 }
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,6 +19,9 @@ type Config struct {
 	JWT       JWTConfig       `yaml:"jwt"`
 	RateLimit RateLimitConfig `yaml:"rate_limit"`
 	Cookie    CookieConfig    `yaml:"cookie"`
+	Fail2Ban  Fail2BanConfig  `yaml:"fail2ban"`
+	Sanitizer SanitizerConfig `yaml:"sanitizer"`
+	Auth      AuthConfig      `yaml:"auth"`
 }
 
 type StorageConfig struct {
@@ -28,8 +34,9 @@ type NATSConfig struct {
 }
 
 type ServerConfig struct {
-	Port  string `yaml:"port"`
-	Debug bool   `yaml:"debug"`
+	Port           string   `yaml:"port"`
+	Debug          bool     `yaml:"debug"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
@@ -48,10 +55,19 @@ func (db DatabaseConfig) GetDSN() string {
 	)
 }
 
+// JWTConfig selects how access tokens are signed. HS256 uses Secret; RS256
+// and EdDSA use private keys from KeysDir (generated there when missing).
+// With RotationInterval set a new key is generated once the signing key is
+// older than the interval; older keys keep verifying until their tokens expire.
 type JWTConfig struct {
-	Secret string        `yaml:"secret"`
-	Issuer string        `yaml:"issuer"`
-	TTL    time.Duration `yaml:"ttl"`
+	Secret           string        `yaml:"secret"`
+	Issuer           string        `yaml:"issuer"`
+	TTL              time.Duration `yaml:"ttl"`
+	RefreshTTL       time.Duration `yaml:"refresh_ttl"`
+	Algorithm        string        `yaml:"algorithm"`
+	KeysDir          string        `yaml:"keys_dir"`
+	SigningKeyID     string        `yaml:"signing_kid"`
+	RotationInterval time.Duration `yaml:"rotation_interval"`
 }
 
 type RateLimitConfig struct {
@@ -70,10 +86,265 @@ type APIRateLimit struct {
 }
 
 type CookieConfig struct {
-	Name     string `yaml:"name"`
-	Secure   bool   `yaml:"secure"`
-	HttpOnly bool   `yaml:"http_only"`
-	SameSite string `yaml:"same_site"`
+	Name        string `yaml:"name"`
+	RefreshName string `yaml:"refresh_name"`
+	RefreshPath string `yaml:"refresh_path"`
+	CSRFName    string `yaml:"csrf_name"`
+	Secure      bool   `yaml:"secure"`
+	HttpOnly    bool   `yaml:"http_only"`
+	SameSite    string `yaml:"same_site"`
+}
+
+type Fail2BanConfig struct {
+	ConfigDir   string           `yaml:"config_dir"`
+	ManagedFile string           `yaml:"managed_file"`
+	BackupsKept int              `yaml:"backups_kept"`
+	RegexTest   RegexTestConfig  `yaml:"regex_test"`
+	AuthJail    AuthJailConfig   `yaml:"auth_jail"`
+	BanWatcher  BanWatcherConfig `yaml:"ban_watcher"`
+	Blocklist   BlocklistConfig  `yaml:"blocklist"`
+	SelfUnban   SelfUnbanConfig  `yaml:"self_unban"`
+}
+
+type RegexTestConfig struct {
+	LogAllowlist []string      `yaml:"log_allowlist"`
+	MaxLines     int           `yaml:"max_lines"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+type AuthJailConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Name     string        `yaml:"name"`
+	LogPath  string        `yaml:"log_path"`
+	Port     string        `yaml:"port"`
+	MaxRetry int           `yaml:"max_retry"`
+	FindTime time.Duration `yaml:"find_time"`
+	BanTime  time.Duration `yaml:"ban_time"`
+}
+
+type BanWatcherConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Interval  time.Duration `yaml:"interval"`
+	Retention time.Duration `yaml:"retention"`
+}
+
+type BlocklistConfig struct {
+	Import BlocklistImportConfig `yaml:"import"`
+}
+
+// BlocklistImportConfig describes where to pull blocklists from and how to ban them.
+// Imported bans are lifted by the importer itself once Expiry passes without the
+// IP being seen again in any source, unless fail2ban banned the IP on its own.
+type BlocklistImportConfig struct {
+	Enabled    bool              `yaml:"enabled"`
+	Jail       string            `yaml:"jail"`
+	Interval   time.Duration     `yaml:"interval"`
+	Expiry     time.Duration     `yaml:"expiry"`
+	Timeout    time.Duration     `yaml:"timeout"`
+	MaxEntries int               `yaml:"max_entries"`
+	Allowlist  []string          `yaml:"allowlist"`
+	Sources    []BlocklistSource `yaml:"sources"`
+}
+
+// BlocklistSource is either a local file (Path) or a feed of another instance (URL).
+// Token is sent as a Bearer token to the feed.
+type BlocklistSource struct {
+	Name  string `yaml:"name"`
+	URL   string `yaml:"url"`
+	Path  string `yaml:"path"`
+	Token string `yaml:"token"`
+}
+
+// SelfUnbanConfig controls the temporary ignoreip added for an operator's own IP.
+type SelfUnbanConfig struct {
+	IgnoreDuration time.Duration `yaml:"ignore_duration"`
+}
+
+type SanitizerConfig struct {
+	Escalation EscalationConfig `yaml:"escalation"`
+}
+
+// EscalationConfig controls banning of IPs that repeatedly trip the input sanitizer.
+// Jail is used for fail2ban bans; BlockTime applies to the internal fallback block list.
+// Allowlist holds IPs and CIDRs that are never banned (loopback always is),
+// e.g. trusted proxies and admin networks.
+type EscalationConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+	Jail      string        `yaml:"jail"`
+	BlockTime time.Duration `yaml:"block_time"`
+	Allowlist []string      `yaml:"allowlist"`
+}
+
+type AuthConfig struct {
+	TwoFactor       TwoFactorConfig       `yaml:"two_factor"`
+	APIKeys         APIKeysConfig         `yaml:"api_keys"`
+	Sessions        SessionsConfig        `yaml:"sessions"`
+	PermissionCache PermissionCacheConfig `yaml:"permission_cache"`
+	OIDC            OIDCConfig            `yaml:"oidc"`
+	WebAuthn        WebAuthnConfig        `yaml:"webauthn"`
+	StepUp          StepUpConfig          `yaml:"step_up"`
+	Lockout         LockoutConfig         `yaml:"lockout"`
+	LoginAlerts     LoginAlertsConfig     `yaml:"login_alerts"`
+	Password        PasswordConfig        `yaml:"password"`
+	Introspection   IntrospectionConfig   `yaml:"introspection"`
+	Grants          GrantsConfig          `yaml:"grants"`
+}
+
+// GrantsConfig bounds temporary grants: a grant, whether given directly or
+// through an approved access request, may last at most MaxDuration.
+type GrantsConfig struct {
+	MaxDuration time.Duration `yaml:"max_duration"`
+}
+
+// IntrospectionConfig lets other services check tokens issued here, over
+// POST /auth/introspect and the NATS request subject Subject. Only the listed
+// clients may ask; clients without a secret are ignored.
+type IntrospectionConfig struct {
+	Enabled bool                  `yaml:"enabled"`
+	Subject string                `yaml:"subject"`
+	Queue   string                `yaml:"queue"`
+	Clients []IntrospectionClient `yaml:"clients"`
+}
+
+type IntrospectionClient struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
+}
+
+// PasswordConfig is the policy for new passwords. MaxLength is in bytes and
+// capped at 72 for bcrypt, which ignores anything longer. BreachedList is an
+// optional file of known leaked passwords, plain or SHA-1 hex, one per line.
+type PasswordConfig struct {
+	MinLength    int                `yaml:"min_length"`
+	MaxLength    int                `yaml:"max_length"`
+	MinClasses   int                `yaml:"min_classes"`
+	BreachedList string             `yaml:"breached_list"`
+	Hash         PasswordHashConfig `yaml:"hash"`
+}
+
+// PasswordHashConfig selects how new hashes are made: "bcrypt" or
+// "argon2id". Stored hashes made with another algorithm or other parameters
+// are replaced on the next successful login. Argon2Memory is in KiB.
+type PasswordHashConfig struct {
+	Algorithm     string `yaml:"algorithm"`
+	BcryptCost    int    `yaml:"bcrypt_cost"`
+	Argon2Time    uint32 `yaml:"argon2_time"`
+	Argon2Memory  uint32 `yaml:"argon2_memory"`
+	Argon2Threads uint8  `yaml:"argon2_threads"`
+}
+
+// LockoutConfig slows down password guessing against one account from any
+// number of addresses. After FreeAttempts consecutive failures each further
+// failure locks the username for BaseDelay, doubled every time up to
+// MaxDelay. The counter starts over after ResetAfter without failures.
+// Login attempts are kept for Retention.
+type LockoutConfig struct {
+	FreeAttempts int           `yaml:"free_attempts"`
+	BaseDelay    time.Duration `yaml:"base_delay"`
+	MaxDelay     time.Duration `yaml:"max_delay"`
+	ResetAfter   time.Duration `yaml:"reset_after"`
+	Retention    time.Duration `yaml:"retention"`
+}
+
+// LoginAlertsConfig sends a notification when a user logs in from an IP or
+// device never seen in a successful login before. The NATS event is always
+// published; webhook and email are optional.
+type LoginAlertsConfig struct {
+	Webhook LoginAlertWebhookConfig `yaml:"webhook"`
+	Email   LoginAlertEmailConfig   `yaml:"email"`
+}
+
+// LoginAlertWebhookConfig posts the alert as JSON to URL when it is set.
+type LoginAlertWebhookConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// LoginAlertEmailConfig mails the alert to To through an SMTP server when
+// Host is set. Password is read from SMTP_PASSWORD.
+type LoginAlertEmailConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"-"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// StepUpConfig guards sensitive operations: the session must have been
+// authenticated, at login or through /auth/reauth, within Window. API keys
+// cannot use these operations at all.
+type StepUpConfig struct {
+	Window time.Duration `yaml:"window"`
+}
+
+// WebAuthnConfig enables passkeys. RPID is the domain credentials are bound to
+// and Origins lists the exact origins the browser may report (scheme, host and
+// port); both must match the deployment or every ceremony fails. Timeout is
+// the lifetime of a registration or login ceremony.
+type WebAuthnConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	RPID       string        `yaml:"rp_id"`
+	RPName     string        `yaml:"rp_name"`
+	Origins    []string      `yaml:"origins"`
+	Timeout    time.Duration `yaml:"timeout"`
+	MaxPerUser int           `yaml:"max_per_user"`
+}
+
+// OIDCConfig enables login through an OpenID Connect provider with the
+// authorization code flow and PKCE. RoleMapping maps values of GroupsClaim to
+// local roles and DefaultRoles go to every OIDC user; roles listed in either
+// are kept in sync with the provider on each login. ClientSecret is read from
+// OIDC_CLIENT_SECRET and may be empty for public clients.
+type OIDCConfig struct {
+	Enabled         bool                `yaml:"enabled"`
+	Issuer          string              `yaml:"issuer"`
+	ClientID        string              `yaml:"client_id"`
+	ClientSecret    string              `yaml:"-"`
+	RedirectURL     string              `yaml:"redirect_url"`
+	Scopes          []string            `yaml:"scopes"`
+	UsernameClaim   string              `yaml:"username_claim"`
+	GroupsClaim     string              `yaml:"groups_claim"`
+	RoleMapping     map[string][]string `yaml:"role_mapping"`
+	DefaultRoles    []string            `yaml:"default_roles"`
+	AutoCreate      bool                `yaml:"auto_create"`
+	LinkExisting    bool                `yaml:"link_existing"`
+	StateTTL        time.Duration       `yaml:"state_ttl"`
+	SuccessRedirect string              `yaml:"success_redirect"`
+	HTTPTimeout     time.Duration       `yaml:"http_timeout"`
+}
+
+// PermissionCacheConfig bounds how long a cached permission set is trusted.
+// Role changes through the API invalidate it at once; TTL only matters for
+// changes made directly in the database.
+type PermissionCacheConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// SessionsConfig controls concurrent logins. With SingleSession set every
+// login revokes the user's other sessions; roles can also enforce it per user.
+type SessionsConfig struct {
+	SingleSession bool `yaml:"single_session"`
+}
+
+// TwoFactorConfig controls TOTP second factor. Skew is the number of 30s steps
+// accepted on either side of the current one to tolerate clock drift.
+type TwoFactorConfig struct {
+	Issuer        string        `yaml:"issuer"`
+	ChallengeTTL  time.Duration `yaml:"challenge_ttl"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	Skew          int           `yaml:"skew"`
+	RecoveryCodes int           `yaml:"recovery_codes"`
+}
+
+// APIKeysConfig limits personal API keys. Every key expires; DefaultTTL is
+// used when the request does not ask for a lifetime.
+type APIKeysConfig struct {
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	MaxTTL     time.Duration `yaml:"max_ttl"`
+	MaxPerUser int           `yaml:"max_per_user"`
 }
 
 func Load(path string) (*Config, error) {
@@ -90,9 +361,219 @@ func Load(path string) (*Config, error) {
 	cfg.JWT.Secret = os.Getenv("JWT_SECRET")
 	cfg.JWT.Issuer = getEnvOrDefault("JWT_ISSUER", cfg.JWT.Issuer)
 
+	if cfg.JWT.Algorithm == "" {
+		cfg.JWT.Algorithm = "HS256"
+	}
+	if cfg.JWT.RotationInterval < 0 {
+		cfg.JWT.RotationInterval = 0
+	}
+	if cfg.JWT.RefreshTTL <= 0 {
+		cfg.JWT.RefreshTTL = 7 * 24 * time.Hour
+	}
+	if cfg.Cookie.RefreshName == "" {
+		cfg.Cookie.RefreshName = cfg.Cookie.Name + "_refresh"
+	}
+	if cfg.Cookie.CSRFName == "" {
+		cfg.Cookie.CSRFName = cfg.Cookie.Name + "_csrf"
+	}
+	if cfg.Cookie.RefreshPath == "" {
+		cfg.Cookie.RefreshPath = "/api/auth"
+	}
+
 	if cfg.Storage.LocalDBPath == "" {
 		cfg.Storage.LocalDBPath = "./data/tokens.db"
 	}
+	if cfg.JWT.KeysDir == "" {
+		cfg.JWT.KeysDir = filepath.Join(filepath.Dir(cfg.Storage.LocalDBPath), "jwt_keys")
+	}
+
+	if cfg.Fail2Ban.ConfigDir == "" {
+		cfg.Fail2Ban.ConfigDir = "/etc/fail2ban"
+	}
+	if cfg.Fail2Ban.ManagedFile == "" {
+		cfg.Fail2Ban.ManagedFile = "vps-control.local"
+	}
+	if cfg.Fail2Ban.BackupsKept <= 0 {
+		cfg.Fail2Ban.BackupsKept = 10
+	}
+	if cfg.Fail2Ban.RegexTest.MaxLines <= 0 {
+		cfg.Fail2Ban.RegexTest.MaxLines = 5000
+	}
+	if cfg.Fail2Ban.RegexTest.Timeout <= 0 {
+		cfg.Fail2Ban.RegexTest.Timeout = 20 * time.Second
+	}
+	if cfg.Fail2Ban.AuthJail.Name == "" {
+		cfg.Fail2Ban.AuthJail.Name = "vps-control-auth"
+	}
+	if cfg.Fail2Ban.AuthJail.LogPath == "" {
+		cfg.Fail2Ban.AuthJail.LogPath = "/var/log/vps-control/auth-failures.log"
+	}
+	if cfg.Fail2Ban.AuthJail.Port == "" {
+		cfg.Fail2Ban.AuthJail.Port = "http,https"
+	}
+	if cfg.Fail2Ban.AuthJail.MaxRetry <= 0 {
+		cfg.Fail2Ban.AuthJail.MaxRetry = 10
+	}
+	if cfg.Fail2Ban.AuthJail.FindTime <= 0 {
+		cfg.Fail2Ban.AuthJail.FindTime = 10 * time.Minute
+	}
+	if cfg.Fail2Ban.AuthJail.BanTime <= 0 {
+		cfg.Fail2Ban.AuthJail.BanTime = time.Hour
+	}
+	if cfg.Fail2Ban.BanWatcher.Interval <= 0 {
+		cfg.Fail2Ban.BanWatcher.Interval = 30 * time.Second
+	}
+	if cfg.Fail2Ban.BanWatcher.Retention <= 0 {
+		cfg.Fail2Ban.BanWatcher.Retention = 7 * 24 * time.Hour
+	}
+	if cfg.Fail2Ban.Blocklist.Import.Jail == "" {
+		cfg.Fail2Ban.Blocklist.Import.Jail = cfg.Fail2Ban.AuthJail.Name
+	}
+	if cfg.Fail2Ban.Blocklist.Import.Interval <= 0 {
+		cfg.Fail2Ban.Blocklist.Import.Interval = time.Hour
+	}
+	if cfg.Fail2Ban.Blocklist.Import.Expiry <= 0 {
+		cfg.Fail2Ban.Blocklist.Import.Expiry = 24 * time.Hour
+	}
+	if cfg.Fail2Ban.Blocklist.Import.Timeout <= 0 {
+		cfg.Fail2Ban.Blocklist.Import.Timeout = 30 * time.Second
+	}
+	if cfg.Fail2Ban.Blocklist.Import.MaxEntries <= 0 {
+		cfg.Fail2Ban.Blocklist.Import.MaxEntries = 10000
+	}
+	if cfg.Fail2Ban.SelfUnban.IgnoreDuration <= 0 {
+		cfg.Fail2Ban.SelfUnban.IgnoreDuration = time.Hour
+	}
+
+	if cfg.Sanitizer.Escalation.Threshold <= 0 {
+		cfg.Sanitizer.Escalation.Threshold = 5
+	}
+	if cfg.Sanitizer.Escalation.Window <= 0 {
+		cfg.Sanitizer.Escalation.Window = 10 * time.Minute
+	}
+	if cfg.Sanitizer.Escalation.BlockTime <= 0 {
+		cfg.Sanitizer.Escalation.BlockTime = time.Hour
+	}
+
+	if cfg.Auth.TwoFactor.Issuer == "" {
+		cfg.Auth.TwoFactor.Issuer = cfg.JWT.Issuer
+	}
+	if cfg.Auth.TwoFactor.ChallengeTTL <= 0 {
+		cfg.Auth.TwoFactor.ChallengeTTL = 5 * time.Minute
+	}
+	if cfg.Auth.TwoFactor.MaxAttempts <= 0 {
+		cfg.Auth.TwoFactor.MaxAttempts = 5
+	}
+	if cfg.Auth.TwoFactor.Skew < 0 {
+		cfg.Auth.TwoFactor.Skew = 0
+	}
+	if cfg.Auth.TwoFactor.RecoveryCodes <= 0 {
+		cfg.Auth.TwoFactor.RecoveryCodes = 10
+	}
+	if cfg.Auth.APIKeys.DefaultTTL <= 0 {
+		cfg.Auth.APIKeys.DefaultTTL = 90 * 24 * time.Hour
+	}
+	if cfg.Auth.APIKeys.MaxTTL < cfg.Auth.APIKeys.DefaultTTL {
+		cfg.Auth.APIKeys.MaxTTL = max(cfg.Auth.APIKeys.DefaultTTL, 365*24*time.Hour)
+	}
+	if cfg.Auth.APIKeys.MaxPerUser <= 0 {
+		cfg.Auth.APIKeys.MaxPerUser = 10
+	}
+	if cfg.Auth.PermissionCache.TTL <= 0 {
+		cfg.Auth.PermissionCache.TTL = 5 * time.Minute
+	}
+	if cfg.Auth.Grants.MaxDuration <= 0 {
+		cfg.Auth.Grants.MaxDuration = 7 * 24 * time.Hour
+	}
+	cfg.Auth.OIDC.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	if !slices.Contains(cfg.Auth.OIDC.Scopes, "openid") {
+		cfg.Auth.OIDC.Scopes = append([]string{"openid"}, cfg.Auth.OIDC.Scopes...)
+	}
+	if cfg.Auth.OIDC.UsernameClaim == "" {
+		cfg.Auth.OIDC.UsernameClaim = "preferred_username"
+	}
+	if cfg.Auth.OIDC.GroupsClaim == "" {
+		cfg.Auth.OIDC.GroupsClaim = "groups"
+	}
+	if cfg.Auth.OIDC.StateTTL <= 0 {
+		cfg.Auth.OIDC.StateTTL = 10 * time.Minute
+	}
+	if cfg.Auth.OIDC.SuccessRedirect == "" {
+		cfg.Auth.OIDC.SuccessRedirect = "/"
+	}
+	if cfg.Auth.OIDC.HTTPTimeout <= 0 {
+		cfg.Auth.OIDC.HTTPTimeout = 10 * time.Second
+	}
+	if cfg.Auth.WebAuthn.RPName == "" {
+		cfg.Auth.WebAuthn.RPName = cfg.JWT.Issuer
+	}
+	if cfg.Auth.WebAuthn.Timeout <= 0 {
+		cfg.Auth.WebAuthn.Timeout = 5 * time.Minute
+	}
+	if cfg.Auth.WebAuthn.MaxPerUser <= 0 {
+		cfg.Auth.WebAuthn.MaxPerUser = 10
+	}
+	if cfg.Auth.StepUp.Window <= 0 {
+		cfg.Auth.StepUp.Window = 5 * time.Minute
+	}
+	if cfg.Auth.Lockout.FreeAttempts <= 0 {
+		cfg.Auth.Lockout.FreeAttempts = 5
+	}
+	if cfg.Auth.Lockout.BaseDelay <= 0 {
+		cfg.Auth.Lockout.BaseDelay = 30 * time.Second
+	}
+	if cfg.Auth.Lockout.MaxDelay < cfg.Auth.Lockout.BaseDelay {
+		cfg.Auth.Lockout.MaxDelay = max(cfg.Auth.Lockout.BaseDelay, time.Hour)
+	}
+	if cfg.Auth.Lockout.ResetAfter <= 0 {
+		cfg.Auth.Lockout.ResetAfter = 24 * time.Hour
+	}
+	if cfg.Auth.Lockout.Retention <= 0 {
+		cfg.Auth.Lockout.Retention = 30 * 24 * time.Hour
+	}
+	if cfg.Auth.LoginAlerts.Webhook.Timeout <= 0 {
+		cfg.Auth.LoginAlerts.Webhook.Timeout = 10 * time.Second
+	}
+	if cfg.Auth.LoginAlerts.Email.Port <= 0 {
+		cfg.Auth.LoginAlerts.Email.Port = 587
+	}
+	cfg.Auth.LoginAlerts.Email.Password = os.Getenv("SMTP_PASSWORD")
+
+	if cfg.Auth.Introspection.Subject == "" {
+		cfg.Auth.Introspection.Subject = "auth.token.introspect"
+	}
+	if cfg.Auth.Introspection.Queue == "" {
+		cfg.Auth.Introspection.Queue = "vps-control-introspect"
+	}
+	cfg.Auth.Introspection.Clients = slices.DeleteFunc(
+		cfg.Auth.Introspection.Clients, func(client IntrospectionClient) bool {
+			return client.ID == "" || client.Secret == ""
+		},
+	)
+
+	hash := &cfg.Auth.Password.Hash
+	if hash.BcryptCost < bcrypt.MinCost || hash.BcryptCost > bcrypt.MaxCost {
+		hash.BcryptCost = bcrypt.DefaultCost
+	}
+	if hash.Argon2Time == 0 {
+		hash.Argon2Time = 3
+	}
+	if hash.Argon2Memory == 0 {
+		hash.Argon2Memory = 64 * 1024
+	}
+	if hash.Argon2Threads == 0 {
+		hash.Argon2Threads = 2
+	}
+	if cfg.Auth.Password.MinLength <= 0 {
+		cfg.Auth.Password.MinLength = 8
+	}
+	if cfg.Auth.Password.MaxLength <= 0 {
+		cfg.Auth.Password.MaxLength = 128
+	}
+	if hash.Algorithm != "argon2id" {
+		hash.Algorithm = "bcrypt"
+		cfg.Auth.Password.MaxLength = min(cfg.Auth.Password.MaxLength, 72)
+	}
 
 	return &cfg, nil
 }
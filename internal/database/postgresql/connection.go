@@ -47,10 +47,106 @@ func NewConnection(
 
 	dbLogger.Info("Successfully connected to PostgreSQL", zap.String("host", cfg.Host))
 
-	return &Database{
+	db := &Database{
 		Pool:   pool,
 		logger: dbLogger,
-	}, nil
+	}
+
+	if err := db.initSchema(ctx); err != nil {
+		dbLogger.Error("Failed to initialize schema", zap.Error(err))
+		pool.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// initSchema creates the tables owned by this service. The user, role and
+// permission tables are provisioned externally and only extended here.
+func (db *Database) initSchema(ctx context.Context) error {
+	schema := `
+    ALTER TABLE roles ADD COLUMN IF NOT EXISTS require_2fa BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE roles ADD COLUMN IF NOT EXISTS single_session BOOLEAN NOT NULL DEFAULT FALSE;
+
+    CREATE TABLE IF NOT EXISTS user_totp (
+        user_id INTEGER PRIMARY KEY REFERENCES vps_data_auth(id) ON DELETE CASCADE,
+        secret TEXT NOT NULL,
+        confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+        last_used_step BIGINT NOT NULL DEFAULT 0,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        confirmed_at TIMESTAMPTZ
+    );
+
+    CREATE TABLE IF NOT EXISTS user_recovery_codes (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES vps_data_auth(id) ON DELETE CASCADE,
+        code_hash TEXT NOT NULL,
+        used_at TIMESTAMPTZ
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);
+
+    CREATE TABLE IF NOT EXISTS user_identities (
+        issuer TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        user_id INTEGER NOT NULL REFERENCES vps_data_auth(id) ON DELETE CASCADE,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (issuer, subject)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+    CREATE TABLE IF NOT EXISTS webauthn_credentials (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES vps_data_auth(id) ON DELETE CASCADE,
+        credential_id BYTEA NOT NULL UNIQUE,
+        public_key BYTEA NOT NULL,
+        algorithm INTEGER NOT NULL,
+        sign_count BIGINT NOT NULL DEFAULT 0,
+        name TEXT NOT NULL DEFAULT '',
+        transports TEXT[] NOT NULL DEFAULT '{}',
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        last_used_at TIMESTAMPTZ
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+    CREATE TABLE IF NOT EXISTS access_requests (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES vps_data_auth(id) ON DELETE CASCADE,
+        permission TEXT NOT NULL,
+        reason TEXT NOT NULL,
+        duration_minutes INTEGER NOT NULL,
+        status TEXT NOT NULL DEFAULT 'pending',
+        decided_by TEXT NOT NULL DEFAULT '',
+        decision_note TEXT NOT NULL DEFAULT '',
+        decided_at TIMESTAMPTZ,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_access_requests_status ON access_requests(status);
+    CREATE UNIQUE INDEX IF NOT EXISTS idx_access_requests_pending
+        ON access_requests(user_id, permission) WHERE status = 'pending';
+
+    CREATE TABLE IF NOT EXISTS temporary_grants (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES vps_data_auth(id) ON DELETE CASCADE,
+        role_id INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+        permission_id INTEGER REFERENCES permissions(id) ON DELETE CASCADE,
+        reason TEXT NOT NULL DEFAULT '',
+        granted_by TEXT NOT NULL,
+        request_id INTEGER REFERENCES access_requests(id) ON DELETE SET NULL,
+        expires_at TIMESTAMPTZ NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        CHECK ((role_id IS NULL) <> (permission_id IS NULL))
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_temporary_grants_user_id ON temporary_grants(user_id);
+    CREATE INDEX IF NOT EXISTS idx_temporary_grants_expires_at ON temporary_grants(expires_at);
+    `
+
+	_, err := db.Pool.Exec(ctx, schema)
+	return err
 }
 
 func (db *Database) Close() {
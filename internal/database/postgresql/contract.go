@@ -2,6 +2,7 @@ package postgresql
 
 import (
 	"context"
+	"time"
 )
 
 type DB interface {
@@ -43,6 +44,57 @@ type PermissionStore interface {
 		userID int,
 		roleName string,
 	) error
+	GetRole(
+		ctx context.Context,
+		roleID int,
+	) (*RoleDTO, error)
+	GetRolePermissions(
+		ctx context.Context,
+		roleID int,
+	) ([]string, error)
+	GetRoleUsernames(
+		ctx context.Context,
+		roleID int,
+	) ([]string, error)
+	CreateRole(
+		ctx context.Context,
+		name, description string,
+		policy RolePolicy,
+		permissions []string,
+	) (*RoleDTO, error)
+	UpdateRole(
+		ctx context.Context,
+		roleID int,
+		name, description string,
+		policy RolePolicy,
+	) error
+	SetRolePermissions(
+		ctx context.Context,
+		roleID int,
+		permissions []string,
+	) error
+	DeleteRole(
+		ctx context.Context,
+		roleID int,
+	) error
+	UserRequiresTwoFactor(
+		ctx context.Context,
+		userID int,
+	) (bool, error)
+	UserSingleSession(
+		ctx context.Context,
+		userID int,
+	) (bool, error)
+}
+
+// PasswordHasher hashes passwords for the user table and tells when a stored
+// hash is outdated.
+type PasswordHasher interface {
+	Hash(raw string) (string, error)
+	Verify(
+		hash, raw string,
+	) (bool, error)
+	NeedsRehash(hash string) bool
 }
 
 type UserStore interface {
@@ -50,8 +102,166 @@ type UserStore interface {
 		ctx context.Context,
 		username, rawPassword string,
 	) (*UserResponseDTO, error)
+	CheckPassword(
+		ctx context.Context,
+		userID int,
+		rawPassword string,
+	) error
 	UpdateLastLogin(
 		ctx context.Context,
 		userID int,
 	) error
+	GetUserByID(
+		ctx context.Context,
+		userID int,
+	) (*UserResponseDTO, error)
+	GetUserByUsername(
+		ctx context.Context,
+		username string,
+	) (*UserResponseDTO, error)
+	ListUsers(
+		ctx context.Context,
+		limit, offset int,
+	) ([]UserResponseDTO, int, error)
+	CreateUser(
+		ctx context.Context,
+		username, rawPassword string,
+		active bool,
+	) (*UserResponseDTO, error)
+	SetUserActive(
+		ctx context.Context,
+		userID int,
+		active bool,
+	) error
+	UpdatePassword(
+		ctx context.Context,
+		userID int,
+		rawPassword string,
+	) error
+	DeleteUser(
+		ctx context.Context,
+		userID int,
+	) error
+}
+
+type TwoFactorStore interface {
+	GetTOTP(
+		ctx context.Context,
+		userID int,
+	) (*TOTPEntity, error)
+	SaveTOTPSecret(
+		ctx context.Context,
+		userID int,
+		secret string,
+	) error
+	ConfirmTOTP(
+		ctx context.Context,
+		userID int,
+		step int64,
+		recoveryHashes []string,
+	) error
+	UseTOTPStep(
+		ctx context.Context,
+		userID int,
+		step int64,
+	) (bool, error)
+	DeleteTOTP(
+		ctx context.Context,
+		userID int,
+	) error
+	ReplaceRecoveryCodes(
+		ctx context.Context,
+		userID int,
+		hashes []string,
+	) error
+	ConsumeRecoveryCode(
+		ctx context.Context,
+		userID int,
+		hash string,
+	) (bool, error)
+	CountRecoveryCodes(
+		ctx context.Context,
+		userID int,
+	) (int, error)
+}
+
+type IdentityStore interface {
+	GetIdentityUser(
+		ctx context.Context,
+		issuer, subject string,
+	) (int, error)
+	LinkIdentity(
+		ctx context.Context,
+		issuer, subject string,
+		userID int,
+	) error
+}
+
+type WebAuthnStore interface {
+	GetWebAuthnCredentials(
+		ctx context.Context,
+		userID int,
+	) ([]WebAuthnCredentialEntity, error)
+	GetWebAuthnCredential(
+		ctx context.Context,
+		credentialID []byte,
+	) (*WebAuthnCredentialEntity, error)
+	SaveWebAuthnCredential(
+		ctx context.Context,
+		credential WebAuthnCredentialEntity,
+	) (int, error)
+	UseWebAuthnCredential(
+		ctx context.Context,
+		id int,
+		signCount int64,
+	) (bool, error)
+	DeleteWebAuthnCredential(
+		ctx context.Context,
+		userID, id int,
+	) error
+}
+
+// GrantStore keeps temporary grants and access requests. Grants count towards
+// the user's roles and permissions in PermissionStore until they expire;
+// DeleteExpiredGrants removes them afterwards.
+type GrantStore interface {
+	CreateGrant(
+		ctx context.Context,
+		grant GrantDTO,
+	) (*GrantDTO, error)
+	ListGrants(
+		ctx context.Context,
+		userID int,
+	) ([]GrantDTO, error)
+	RevokeGrant(
+		ctx context.Context,
+		id int,
+	) (*GrantDTO, error)
+	DeleteExpiredGrants(ctx context.Context) ([]GrantDTO, error)
+	CreateAccessRequest(
+		ctx context.Context,
+		userID int,
+		permission, reason string,
+		durationMinutes int,
+	) (*AccessRequestDTO, error)
+	GetAccessRequest(
+		ctx context.Context,
+		id int,
+	) (*AccessRequestDTO, error)
+	ListAccessRequests(
+		ctx context.Context,
+		userID int,
+		status string,
+	) ([]AccessRequestDTO, error)
+	ApproveAccessRequest(
+		ctx context.Context,
+		id int,
+		decidedBy, note string,
+		expiresAt time.Time,
+	) (*GrantDTO, error)
+	DenyAccessRequest(
+		ctx context.Context,
+		id int,
+		decidedBy, note string,
+	) (*AccessRequestDTO, error)
 }
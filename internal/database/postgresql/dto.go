@@ -20,10 +20,19 @@ type PermissionDTO struct {
 	Description string `json:"description,omitempty"`
 }
 
+// RolePolicy holds the login rules a role imposes on its members. A user is
+// subject to a rule when any of their roles enables it.
+type RolePolicy struct {
+	RequireTwoFactor bool `json:"require_2fa"`
+	SingleSession    bool `json:"single_session"`
+}
+
 type RoleDTO struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
+	RolePolicy
+	Permissions []string `json:"permissions,omitempty"`
 }
 
 type UserPermissionsDTO struct {
@@ -32,3 +41,40 @@ type UserPermissionsDTO struct {
 	Roles       []string `json:"roles"`
 	Permissions []string `json:"permissions"`
 }
+
+// Access request states. A request is decided once and then kept as history.
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+// GrantDTO is a role or a single permission entry given to a user until
+// ExpiresAt. Exactly one of Role and Permission is set. RequestID links the
+// access request the grant was approved from.
+type GrantDTO struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role,omitempty"`
+	Permission string    `json:"permission,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	GrantedBy  string    `json:"granted_by"`
+	RequestID  *int      `json:"request_id,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type AccessRequestDTO struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	Username        string     `json:"username"`
+	Permission      string     `json:"permission"`
+	Reason          string     `json:"reason"`
+	DurationMinutes int        `json:"duration_minutes"`
+	Status          string     `json:"status"`
+	DecidedBy       string     `json:"decided_by,omitempty"`
+	DecisionNote    string     `json:"decision_note,omitempty"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
@@ -0,0 +1,385 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrGrantNotFound          = errors.New("temporary grant not found")
+	ErrAccessRequestNotFound  = errors.New("access request not found")
+	ErrAccessRequestDecided   = errors.New("access request already decided")
+	ErrAccessRequestDuplicate = errors.New("pending access request already exists")
+)
+
+// maxAccessRequests bounds a listing; requests are returned newest first.
+const maxAccessRequests = 200
+
+var _ GrantStore = (*GrantRepository)(nil)
+
+type GrantRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewGrantRepository(
+	db *pgxpool.Pool,
+	logger *zap.Logger,
+) *GrantRepository {
+	return &GrantRepository{
+		db:     db,
+		logger: logger.Named("grant_repository"),
+	}
+}
+
+// grantColumns and grantJoins read grants from a relation aliased g, which is
+// either the table itself or the rows returned by a DELETE.
+const (
+	grantColumns = `g.id, g.user_id, u.username, COALESCE(r.name, ''), COALESCE(p.name, ''),
+        g.reason, g.granted_by, g.request_id, g.expires_at, g.created_at`
+	grantJoins = `
+        JOIN vps_data_auth u ON u.id = g.user_id
+        LEFT JOIN roles r ON r.id = g.role_id
+        LEFT JOIN permissions p ON p.id = g.permission_id`
+)
+
+const (
+	accessRequestColumns = `a.id, a.user_id, u.username, a.permission, a.reason, a.duration_minutes,
+        a.status, a.decided_by, a.decision_note, a.decided_at, a.created_at`
+	accessRequestJoins = `
+        JOIN vps_data_auth u ON u.id = a.user_id`
+)
+
+// CreateGrant stores grant.Role or grant.Permission for the user. Wildcard
+// entries get a permissions row on first use, like in role permission sets.
+func (r *GrantRepository) CreateGrant(
+	ctx context.Context,
+	grant GrantDTO,
+) (*GrantDTO, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := insertGrant(ctx, tx, &grant); err != nil {
+		if !isGrantLookupError(err) {
+			r.logger.Error("failed to create grant", zap.Int("user_id", grant.UserID), zap.Error(err))
+		}
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info(
+		"grant created",
+		zap.Int("grant_id", grant.ID),
+		zap.Int("user_id", grant.UserID),
+		zap.String("role", grant.Role),
+		zap.String("permission", grant.Permission),
+		zap.Time("expires_at", grant.ExpiresAt),
+	)
+	return &grant, nil
+}
+
+// ListGrants returns the unexpired grants of the user, or of every user when
+// userID is 0, soonest expiry first.
+func (r *GrantRepository) ListGrants(
+	ctx context.Context,
+	userID int,
+) ([]GrantDTO, error) {
+	query := `SELECT ` + grantColumns + ` FROM temporary_grants g` + grantJoins + `
+        WHERE g.expires_at > NOW() AND ($1 = 0 OR g.user_id = $1)
+        ORDER BY g.expires_at, g.id`
+
+	return r.queryGrants(ctx, query, userID)
+}
+
+func (r *GrantRepository) RevokeGrant(
+	ctx context.Context,
+	id int,
+) (*GrantDTO, error) {
+	query := `WITH g AS (DELETE FROM temporary_grants WHERE id = $1 RETURNING *)
+        SELECT ` + grantColumns + ` FROM g` + grantJoins
+
+	grants, err := r.queryGrants(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(grants) == 0 {
+		return nil, ErrGrantNotFound
+	}
+
+	r.logger.Info("grant revoked", zap.Int("grant_id", id), zap.Int("user_id", grants[0].UserID))
+	return &grants[0], nil
+}
+
+// DeleteExpiredGrants removes and returns the grants past their expiry. When
+// several instances sweep at once each grant is returned to only one of them.
+func (r *GrantRepository) DeleteExpiredGrants(ctx context.Context) ([]GrantDTO, error) {
+	query := `WITH g AS (DELETE FROM temporary_grants WHERE expires_at <= NOW() RETURNING *)
+        SELECT ` + grantColumns + ` FROM g` + grantJoins
+
+	return r.queryGrants(ctx, query)
+}
+
+func (r *GrantRepository) CreateAccessRequest(
+	ctx context.Context,
+	userID int,
+	permission, reason string,
+	durationMinutes int,
+) (*AccessRequestDTO, error) {
+	query := `WITH a AS (
+            INSERT INTO access_requests (user_id, permission, reason, duration_minutes)
+            VALUES ($1, $2, $3, $4)
+            RETURNING *
+        )
+        SELECT ` + accessRequestColumns + ` FROM a` + accessRequestJoins
+
+	var req AccessRequestDTO
+	err := scanAccessRequest(r.db.QueryRow(ctx, query, userID, permission, reason, durationMinutes), &req)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return nil, ErrAccessRequestDuplicate
+		}
+		r.logger.Error("failed to create access request", zap.Int("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	r.logger.Info(
+		"access request created",
+		zap.Int("request_id", req.ID),
+		zap.Int("user_id", userID),
+		zap.String("permission", permission),
+	)
+	return &req, nil
+}
+
+func (r *GrantRepository) GetAccessRequest(
+	ctx context.Context,
+	id int,
+) (*AccessRequestDTO, error) {
+	query := `SELECT ` + accessRequestColumns + ` FROM access_requests a` + accessRequestJoins + ` WHERE a.id = $1`
+
+	var req AccessRequestDTO
+	if err := scanAccessRequest(r.db.QueryRow(ctx, query, id), &req); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAccessRequestNotFound
+		}
+		r.logger.Error("failed to get access request", zap.Int("request_id", id), zap.Error(err))
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ListAccessRequests filters by user unless userID is 0 and by status unless
+// status is empty.
+func (r *GrantRepository) ListAccessRequests(
+	ctx context.Context,
+	userID int,
+	status string,
+) ([]AccessRequestDTO, error) {
+	query := `SELECT ` + accessRequestColumns + ` FROM access_requests a` + accessRequestJoins + `
+        WHERE ($1 = 0 OR a.user_id = $1) AND ($2 = '' OR a.status = $2)
+        ORDER BY a.id DESC
+        LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, userID, status, maxAccessRequests)
+	if err != nil {
+		r.logger.Error("failed to list access requests", zap.Int("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []AccessRequestDTO{}
+	for rows.Next() {
+		var req AccessRequestDTO
+		if err := scanAccessRequest(rows, &req); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// ApproveAccessRequest marks a pending request approved and grants the
+// requested permission until expiresAt in one transaction.
+func (r *GrantRepository) ApproveAccessRequest(
+	ctx context.Context,
+	id int,
+	decidedBy, note string,
+	expiresAt time.Time,
+) (*GrantDTO, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	grant := GrantDTO{GrantedBy: decidedBy, RequestID: &id, ExpiresAt: expiresAt}
+	var status string
+	err = tx.QueryRow(
+		ctx,
+		`SELECT user_id, permission, reason, status FROM access_requests WHERE id = $1 FOR UPDATE`,
+		id,
+	).Scan(&grant.UserID, &grant.Permission, &grant.Reason, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAccessRequestNotFound
+		}
+		return nil, err
+	}
+	if status != AccessRequestPending {
+		return nil, ErrAccessRequestDecided
+	}
+
+	if err := insertGrant(ctx, tx, &grant); err != nil {
+		if !isGrantLookupError(err) {
+			r.logger.Error("failed to grant access request", zap.Int("request_id", id), zap.Error(err))
+		}
+		return nil, err
+	}
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE access_requests SET status = $2, decided_by = $3, decision_note = $4, decided_at = NOW() WHERE id = $1`,
+		id, AccessRequestApproved, decidedBy, note,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info(
+		"access request approved",
+		zap.Int("request_id", id),
+		zap.Int("grant_id", grant.ID),
+		zap.String("approver", decidedBy),
+	)
+	return &grant, nil
+}
+
+func (r *GrantRepository) DenyAccessRequest(
+	ctx context.Context,
+	id int,
+	decidedBy, note string,
+) (*AccessRequestDTO, error) {
+	query := `WITH a AS (
+            UPDATE access_requests
+            SET status = $2, decided_by = $3, decision_note = $4, decided_at = NOW()
+            WHERE id = $1 AND status = $5
+            RETURNING *
+        )
+        SELECT ` + accessRequestColumns + ` FROM a` + accessRequestJoins
+
+	var req AccessRequestDTO
+	err := scanAccessRequest(
+		r.db.QueryRow(ctx, query, id, AccessRequestDenied, decidedBy, note, AccessRequestPending), &req,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := r.GetAccessRequest(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAccessRequestDecided
+	}
+	if err != nil {
+		r.logger.Error("failed to deny access request", zap.Int("request_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	r.logger.Info("access request denied", zap.Int("request_id", id), zap.String("approver", decidedBy))
+	return &req, nil
+}
+
+// insertGrant resolves the user, role and permission of grant inside tx,
+// stores it and fills in the generated fields.
+func insertGrant(
+	ctx context.Context,
+	tx pgx.Tx,
+	grant *GrantDTO,
+) error {
+	err := tx.QueryRow(ctx, "SELECT username FROM vps_data_auth WHERE id = $1", grant.UserID).Scan(&grant.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	var roleID, permissionID *int
+	if grant.Role != "" {
+		err = tx.QueryRow(ctx, "SELECT id FROM roles WHERE name = $1", grant.Role).Scan(&roleID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRoleNotFound
+		}
+	} else {
+		if err := provisionPatterns(ctx, tx, []string{grant.Permission}); err != nil {
+			return err
+		}
+		err = tx.QueryRow(ctx, "SELECT id FROM permissions WHERE name = $1", grant.Permission).Scan(&permissionID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPermissionNotFound
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryRow(
+		ctx,
+		`INSERT INTO temporary_grants (user_id, role_id, permission_id, reason, granted_by, request_id, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)
+         RETURNING id, created_at`,
+		grant.UserID, roleID, permissionID, grant.Reason, grant.GrantedBy, grant.RequestID, grant.ExpiresAt,
+	).Scan(&grant.ID, &grant.CreatedAt)
+}
+
+func isGrantLookupError(err error) bool {
+	return errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrRoleNotFound) || errors.Is(err, ErrPermissionNotFound)
+}
+
+func (r *GrantRepository) queryGrants(
+	ctx context.Context,
+	query string,
+	args ...any,
+) ([]GrantDTO, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("failed to query grants", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []GrantDTO{}
+	for rows.Next() {
+		var g GrantDTO
+		err := rows.Scan(
+			&g.ID, &g.UserID, &g.Username, &g.Role, &g.Permission,
+			&g.Reason, &g.GrantedBy, &g.RequestID, &g.ExpiresAt, &g.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func scanAccessRequest(
+	row pgx.Row,
+	req *AccessRequestDTO,
+) error {
+	return row.Scan(
+		&req.ID, &req.UserID, &req.Username, &req.Permission, &req.Reason, &req.DurationMinutes,
+		&req.Status, &req.DecidedBy, &req.DecisionNote, &req.DecidedAt, &req.CreatedAt,
+	)
+}
@@ -0,0 +1,69 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var ErrIdentityNotFound = errors.New("identity not linked")
+
+var _ IdentityStore = (*IdentityRepository)(nil)
+
+// IdentityRepository links external identities (OIDC issuer and subject) to
+// local users. The subject is stable at the provider, unlike the username.
+type IdentityRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewIdentityRepository(
+	db *pgxpool.Pool,
+	logger *zap.Logger,
+) *IdentityRepository {
+	return &IdentityRepository{
+		db:     db,
+		logger: logger.Named("identity_repository"),
+	}
+}
+
+func (r *IdentityRepository) GetIdentityUser(
+	ctx context.Context,
+	issuer, subject string,
+) (int, error) {
+	var userID int
+	err := r.db.QueryRow(
+		ctx, "SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2", issuer, subject,
+	).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrIdentityNotFound
+		}
+		r.logger.Error("failed to get identity", zap.String("issuer", issuer), zap.Error(err))
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *IdentityRepository) LinkIdentity(
+	ctx context.Context,
+	issuer, subject string,
+	userID int,
+) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)
+         ON CONFLICT (issuer, subject) DO UPDATE SET user_id = EXCLUDED.user_id`,
+		issuer, subject, userID,
+	)
+	if err != nil {
+		r.logger.Error("failed to link identity", zap.String("issuer", issuer), zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	r.logger.Info("identity linked", zap.String("issuer", issuer), zap.Int("user_id", userID))
+	return nil
+}
@@ -21,3 +21,27 @@ type RoleEntity struct {
 	Name        string `db:"name"`
 	Description string `db:"description"`
 }
+
+type TOTPEntity struct {
+	UserID       int        `db:"user_id"`
+	Secret       string     `db:"secret"`
+	Confirmed    bool       `db:"confirmed"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+}
+
+// WebAuthnCredentialEntity is a registered passkey. PublicKey is PKIX DER and
+// Algorithm the COSE algorithm identifier it signs with.
+type WebAuthnCredentialEntity struct {
+	ID           int        `db:"id"`
+	UserID       int        `db:"user_id"`
+	CredentialID []byte     `db:"credential_id"`
+	PublicKey    []byte     `db:"public_key"`
+	Algorithm    int        `db:"algorithm"`
+	SignCount    int64      `db:"sign_count"`
+	Name         string     `db:"name"`
+	Transports   []string   `db:"transports"`
+	CreatedAt    time.Time  `db:"created_at"`
+	LastUsedAt   *time.Time `db:"last_used_at"`
+}
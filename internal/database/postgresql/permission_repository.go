@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 
+	"VPS-control/internal/permission"
+
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -12,10 +15,19 @@ import (
 var (
 	ErrPermissionNotFound = errors.New("permission not found")
 	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleAlreadyExists  = errors.New("role already exists")
 )
 
 var _ PermissionStore = (*PermissionRepository)(nil)
 
+// userRoleIDs selects the roles held by user $1: assigned ones and unexpired
+// temporary grants.
+const userRoleIDs = `
+            SELECT role_id FROM user_roles WHERE user_id = $1
+            UNION
+            SELECT role_id FROM temporary_grants
+            WHERE user_id = $1 AND role_id IS NOT NULL AND expires_at > NOW()`
+
 type PermissionRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -36,12 +48,16 @@ func (r *PermissionRepository) GetUserPermissions(
 	userID int,
 ) ([]string, error) {
 	query := `
-        SELECT DISTINCT p.name
+        SELECT p.name
         FROM permissions p
         JOIN role_permissions rp ON p.id = rp.permission_id
-        JOIN user_roles ur ON rp.role_id = ur.role_id
-        WHERE ur.user_id = $1
-        ORDER BY p.name
+        WHERE rp.role_id IN (` + userRoleIDs + `)
+        UNION
+        SELECT p.name
+        FROM permissions p
+        JOIN temporary_grants g ON p.id = g.permission_id
+        WHERE g.user_id = $1 AND g.expires_at > NOW()
+        ORDER BY name
     `
 
 	rows, err := r.db.Query(ctx, query, userID)
@@ -70,8 +86,7 @@ func (r *PermissionRepository) GetUserRoles(
 	query := `
         SELECT r.name
         FROM roles r
-        JOIN user_roles ur ON r.id = ur.role_id
-        WHERE ur.user_id = $1
+        WHERE r.id IN (` + userRoleIDs + `)
         ORDER BY r.name
     `
 
@@ -94,34 +109,18 @@ func (r *PermissionRepository) GetUserRoles(
 	return roles, nil
 }
 
+// HasPermission loads every entry granted to the user and matches them in Go,
+// so wildcards and deny entries behave exactly as in the auth middleware.
 func (r *PermissionRepository) HasPermission(
 	ctx context.Context,
 	userID int,
 	permissionName string,
 ) (bool, error) {
-	query := `
-        SELECT EXISTS (
-            SELECT 1
-            FROM permissions p
-            JOIN role_permissions rp ON p.id = rp.permission_id
-            JOIN user_roles ur ON rp.role_id = ur.role_id
-            WHERE ur.user_id = $1 AND p.name = $2
-        )
-    `
-
-	var exists bool
-	err := r.db.QueryRow(ctx, query, userID, permissionName).Scan(&exists)
+	granted, err := r.GetUserPermissions(ctx, userID)
 	if err != nil {
-		r.logger.Error(
-			"failed to check permission",
-			zap.Int("user_id", userID),
-			zap.String("permission", permissionName),
-			zap.Error(err),
-		)
 		return false, err
 	}
-
-	return exists, nil
+	return permission.Allows(granted, permissionName), nil
 }
 
 func (r *PermissionRepository) HasAnyPermission(
@@ -129,24 +128,11 @@ func (r *PermissionRepository) HasAnyPermission(
 	userID int,
 	permissionNames []string,
 ) (bool, error) {
-	query := `
-        SELECT EXISTS (
-            SELECT 1
-            FROM permissions p
-            JOIN role_permissions rp ON p.id = rp.permission_id
-            JOIN user_roles ur ON rp.role_id = ur.role_id
-            WHERE ur.user_id = $1 AND p.name = ANY($2)
-        )
-    `
-
-	var exists bool
-	err := r.db.QueryRow(ctx, query, userID, permissionNames).Scan(&exists)
+	granted, err := r.GetUserPermissions(ctx, userID)
 	if err != nil {
-		r.logger.Error("failed to check permissions", zap.Int("user_id", userID), zap.Error(err))
 		return false, err
 	}
-
-	return exists, nil
+	return permission.AllowsAny(granted, permissionNames...), nil
 }
 
 func (r *PermissionRepository) GetUserFullPermissions(
@@ -207,7 +193,7 @@ func (r *PermissionRepository) GetAllPermissions(
 func (r *PermissionRepository) GetAllRoles(
 	ctx context.Context,
 ) ([]RoleDTO, error) {
-	query := `SELECT id, name, COALESCE(description, '') FROM roles ORDER BY name`
+	query := `SELECT id, name, COALESCE(description, ''), require_2fa, single_session FROM roles ORDER BY name`
 
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
@@ -219,7 +205,7 @@ func (r *PermissionRepository) GetAllRoles(
 	var roles []RoleDTO
 	for rows.Next() {
 		var role RoleDTO
-		if err := rows.Scan(&role.ID, &role.Name, &role.Description); err != nil {
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.RequireTwoFactor, &role.SingleSession); err != nil {
 			return nil, err
 		}
 		roles = append(roles, role)
@@ -251,7 +237,14 @@ func (r *PermissionRepository) AssignRoleToUser(
 	}
 
 	if result.RowsAffected() == 0 {
-		return ErrRoleNotFound
+		var exists bool
+		if err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", roleName).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRoleNotFound
+		}
+		return nil
 	}
 
 	r.logger.Info(
@@ -286,3 +279,316 @@ func (r *PermissionRepository) RemoveRoleFromUser(
 
 	return nil
 }
+
+func (r *PermissionRepository) GetRole(
+	ctx context.Context,
+	roleID int,
+) (*RoleDTO, error) {
+	query := `SELECT id, name, COALESCE(description, ''), require_2fa, single_session FROM roles WHERE id = $1`
+
+	var role RoleDTO
+	err := r.db.QueryRow(ctx, query, roleID).Scan(&role.ID, &role.Name, &role.Description, &role.RequireTwoFactor, &role.SingleSession)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRoleNotFound
+		}
+		r.logger.Error("failed to get role", zap.Int("role_id", roleID), zap.Error(err))
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (r *PermissionRepository) GetRolePermissions(
+	ctx context.Context,
+	roleID int,
+) ([]string, error) {
+	query := `
+        SELECT p.name
+        FROM permissions p
+        JOIN role_permissions rp ON p.id = rp.permission_id
+        WHERE rp.role_id = $1
+        ORDER BY p.name
+    `
+
+	return r.queryNames(ctx, query, roleID)
+}
+
+func (r *PermissionRepository) GetRoleUsernames(
+	ctx context.Context,
+	roleID int,
+) ([]string, error) {
+	query := `
+        SELECT u.username
+        FROM vps_data_auth u
+        JOIN user_roles ur ON u.id = ur.user_id
+        WHERE ur.role_id = $1
+        ORDER BY u.username
+    `
+
+	return r.queryNames(ctx, query, roleID)
+}
+
+func (r *PermissionRepository) CreateRole(
+	ctx context.Context,
+	name, description string,
+	policy RolePolicy,
+	permissions []string,
+) (*RoleDTO, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	role := &RoleDTO{Name: name, Description: description, RolePolicy: policy}
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO roles (name, description, require_2fa, single_session) VALUES ($1, $2, $3, $4) RETURNING id`,
+		name, description, policy.RequireTwoFactor, policy.SingleSession,
+	).Scan(&role.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return nil, ErrRoleAlreadyExists
+		}
+		r.logger.Error("failed to create role", zap.String("role", name), zap.Error(err))
+		return nil, err
+	}
+
+	if err := replaceRolePermissions(ctx, tx, role.ID, permissions); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	role.Permissions = permissions
+	r.logger.Info("role created", zap.Int("role_id", role.ID), zap.String("role", name))
+
+	return role, nil
+}
+
+func (r *PermissionRepository) UpdateRole(
+	ctx context.Context,
+	roleID int,
+	name, description string,
+	policy RolePolicy,
+) error {
+	result, err := r.db.Exec(
+		ctx,
+		`UPDATE roles SET name = $2, description = $3, require_2fa = $4, single_session = $5 WHERE id = $1`,
+		roleID, name, description, policy.RequireTwoFactor, policy.SingleSession,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return ErrRoleAlreadyExists
+		}
+		r.logger.Error("failed to update role", zap.Int("role_id", roleID), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func (r *PermissionRepository) SetRolePermissions(
+	ctx context.Context,
+	roleID int,
+	permissions []string,
+) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM roles WHERE id = $1)", roleID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	if err := replaceRolePermissions(ctx, tx, roleID, permissions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	r.logger.Info("role permissions updated", zap.Int("role_id", roleID), zap.Strings("permissions", permissions))
+	return nil
+}
+
+func (r *PermissionRepository) DeleteRole(
+	ctx context.Context,
+	roleID int,
+) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	for _, query := range []string{
+		"DELETE FROM role_permissions WHERE role_id = $1",
+		"DELETE FROM user_roles WHERE role_id = $1",
+		"DELETE FROM temporary_grants WHERE role_id = $1",
+	} {
+		if _, err := tx.Exec(ctx, query, roleID); err != nil {
+			r.logger.Error("failed to delete role references", zap.Int("role_id", roleID), zap.Error(err))
+			return err
+		}
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM roles WHERE id = $1", roleID)
+	if err != nil {
+		r.logger.Error("failed to delete role", zap.Int("role_id", roleID), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRoleNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	r.logger.Info("role deleted", zap.Int("role_id", roleID))
+	return nil
+}
+
+// UserRequiresTwoFactor reports whether any role of the user requires 2FA.
+func (r *PermissionRepository) UserRequiresTwoFactor(
+	ctx context.Context,
+	userID int,
+) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM roles r
+            WHERE r.id IN (` + userRoleIDs + `) AND r.require_2fa
+        )
+    `
+
+	var required bool
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&required); err != nil {
+		r.logger.Error("failed to check 2fa requirement", zap.Int("user_id", userID), zap.Error(err))
+		return false, err
+	}
+	return required, nil
+}
+
+// UserSingleSession reports whether any role of the user limits them to one
+// active session.
+func (r *PermissionRepository) UserSingleSession(
+	ctx context.Context,
+	userID int,
+) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM roles r
+            WHERE r.id IN (` + userRoleIDs + `) AND r.single_session
+        )
+    `
+
+	var single bool
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&single); err != nil {
+		r.logger.Error("failed to check single session policy", zap.Int("user_id", userID), zap.Error(err))
+		return false, err
+	}
+	return single, nil
+}
+
+// replaceRolePermissions swaps the permission set of a role inside tx.
+// Unknown permission names fail with ErrPermissionNotFound; wildcard and deny
+// entries are not provisioned upfront and get a permissions row on first use.
+func replaceRolePermissions(
+	ctx context.Context,
+	tx pgx.Tx,
+	roleID int,
+	permissions []string,
+) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM role_permissions WHERE role_id = $1", roleID); err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	if err := provisionPatterns(ctx, tx, permissions); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		ctx,
+		`INSERT INTO role_permissions (role_id, permission_id)
+         SELECT $1, id FROM permissions WHERE name = ANY($2)`,
+		roleID, permissions,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() != int64(len(permissions)) {
+		return ErrPermissionNotFound
+	}
+	return nil
+}
+
+// provisionPatterns adds a permissions row for each wildcard or deny entry
+// among names that does not have one yet.
+func provisionPatterns(
+	ctx context.Context,
+	tx pgx.Tx,
+	names []string,
+) error {
+	patterns := make([]string, 0)
+	for _, p := range names {
+		if permission.IsPattern(p) {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO permissions (name, description)
+         SELECT n, 'pattern' FROM unnest($1::text[]) AS n
+         WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE name = n)`,
+		patterns,
+	)
+	return err
+}
+
+func (r *PermissionRepository) queryNames(
+	ctx context.Context,
+	query string,
+	args ...any,
+) ([]string, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("failed to query names", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
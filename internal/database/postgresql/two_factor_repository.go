@@ -0,0 +1,224 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var ErrTOTPNotFound = errors.New("totp not configured")
+
+var _ TwoFactorStore = (*TwoFactorRepository)(nil)
+
+type TwoFactorRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewTwoFactorRepository(
+	db *pgxpool.Pool,
+	logger *zap.Logger,
+) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		db:     db,
+		logger: logger.Named("two_factor_repository"),
+	}
+}
+
+func (r *TwoFactorRepository) GetTOTP(
+	ctx context.Context,
+	userID int,
+) (*TOTPEntity, error) {
+	query := `
+        SELECT user_id, secret, confirmed, last_used_step, created_at, confirmed_at
+        FROM user_totp WHERE user_id = $1
+    `
+
+	var entity TOTPEntity
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&entity.UserID,
+		&entity.Secret,
+		&entity.Confirmed,
+		&entity.LastUsedStep,
+		&entity.CreatedAt,
+		&entity.ConfirmedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTOTPNotFound
+		}
+		r.logger.Error("failed to get totp", zap.Int("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+// SaveTOTPSecret stores a pending secret. A confirmed secret is never
+// overwritten; it has to be deleted first.
+func (r *TwoFactorRepository) SaveTOTPSecret(
+	ctx context.Context,
+	userID int,
+	secret string,
+) error {
+	query := `
+        INSERT INTO user_totp (user_id, secret, confirmed, last_used_step, created_at)
+        VALUES ($1, $2, FALSE, 0, NOW())
+        ON CONFLICT (user_id) DO UPDATE
+            SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+            WHERE user_totp.confirmed = FALSE
+    `
+
+	if _, err := r.db.Exec(ctx, query, userID, secret); err != nil {
+		r.logger.Error("failed to save totp secret", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *TwoFactorRepository) ConfirmTOTP(
+	ctx context.Context,
+	userID int,
+	step int64,
+	recoveryHashes []string,
+) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	result, err := tx.Exec(
+		ctx,
+		`UPDATE user_totp SET confirmed = TRUE, confirmed_at = NOW(), last_used_step = $2 WHERE user_id = $1`,
+		userID, step,
+	)
+	if err != nil {
+		r.logger.Error("failed to confirm totp", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTOTPNotFound
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPStep records step as consumed. It returns false when the step (or a
+// later one) was already used, which rejects replay of an intercepted code.
+func (r *TwoFactorRepository) UseTOTPStep(
+	ctx context.Context,
+	userID int,
+	step int64,
+) (bool, error) {
+	result, err := r.db.Exec(
+		ctx,
+		`UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`,
+		userID, step,
+	)
+	if err != nil {
+		r.logger.Error("failed to update totp step", zap.Int("user_id", userID), zap.Error(err))
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *TwoFactorRepository) DeleteTOTP(
+	ctx context.Context,
+	userID int,
+) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID); err != nil {
+		r.logger.Error("failed to delete totp", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(
+	ctx context.Context,
+	userID int,
+	hashes []string,
+) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *TwoFactorRepository) ConsumeRecoveryCode(
+	ctx context.Context,
+	userID int,
+	hash string,
+) (bool, error) {
+	query := `
+        UPDATE user_recovery_codes SET used_at = NOW()
+        WHERE id = (
+            SELECT id FROM user_recovery_codes
+            WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+            LIMIT 1
+        )
+    `
+
+	result, err := r.db.Exec(ctx, query, userID, hash)
+	if err != nil {
+		r.logger.Error("failed to consume recovery code", zap.Int("user_id", userID), zap.Error(err))
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *TwoFactorRepository) CountRecoveryCodes(
+	ctx context.Context,
+	userID int,
+) (int, error) {
+	var count int
+	err := r.db.QueryRow(
+		ctx,
+		"SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+func replaceRecoveryCodes(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID int,
+	hashes []string,
+) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO user_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`,
+		userID, hashes,
+	)
+	return err
+}
@@ -5,29 +5,37 @@ import (
 	"errors"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserInactive       = errors.New("user inactive")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUserAlreadyExists  = errors.New("user already exists")
 )
+
+// pgUniqueViolation is the SQLSTATE for unique_violation.
+const pgUniqueViolation = "23505"
+
 var _ UserStore = (*UserRepository)(nil)
 
 type UserRepository struct {
 	db     *pgxpool.Pool
+	hasher PasswordHasher
 	logger *zap.Logger
 }
 
 func NewUserRepository(
 	db *pgxpool.Pool,
+	hasher PasswordHasher,
 	logger *zap.Logger,
 ) *UserRepository {
 	return &UserRepository{
 		db:     db,
+		hasher: hasher,
 		logger: logger.Named("user_repository"),
 	}
 }
@@ -59,8 +67,8 @@ func (r *UserRepository) Authenticate(
 		return nil, ErrUserInactive
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(entity.Password), []byte(rawPassword)); err != nil {
-		return nil, ErrInvalidCredentials
+	if err := r.verifyPassword(ctx, entity.ID, entity.Password, rawPassword); err != nil {
+		return nil, err
 	}
 
 	go func() {
@@ -74,6 +82,61 @@ func (r *UserRepository) Authenticate(
 	}, nil
 }
 
+// CheckPassword verifies the password of an active user without counting it
+// as a login.
+func (r *UserRepository) CheckPassword(
+	ctx context.Context,
+	userID int,
+	rawPassword string,
+) error {
+	query := `SELECT password, active FROM vps_data_auth WHERE id = $1`
+
+	var (
+		hash   string
+		active bool
+	)
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&hash, &active); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		r.logger.Error("database query error", zap.Error(err))
+		return err
+	}
+	if !active {
+		return ErrUserInactive
+	}
+
+	return r.verifyPassword(ctx, userID, hash, rawPassword)
+}
+
+// verifyPassword compares rawPassword with the stored hash and, when it
+// matches, replaces a hash made with outdated settings. A failed upgrade is
+// only logged: the old hash still works.
+func (r *UserRepository) verifyPassword(
+	ctx context.Context,
+	userID int,
+	hash, rawPassword string,
+) error {
+	ok, err := r.hasher.Verify(hash, rawPassword)
+	if err != nil {
+		r.logger.Error("unreadable password hash", zap.Int("user_id", userID), zap.Error(err))
+		return ErrInvalidCredentials
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	if !r.hasher.NeedsRehash(hash) {
+		return nil
+	}
+	if err := r.UpdatePassword(ctx, userID, rawPassword); err != nil {
+		r.logger.Warn("failed to upgrade password hash", zap.Int("user_id", userID), zap.Error(err))
+		return nil
+	}
+	r.logger.Info("password hash upgraded", zap.Int("user_id", userID))
+	return nil
+}
+
 func (r *UserRepository) UpdateLastLogin(
 	ctx context.Context,
 	userID int,
@@ -84,3 +147,168 @@ func (r *UserRepository) UpdateLastLogin(
 	}
 	return err
 }
+
+func (r *UserRepository) GetUserByID(
+	ctx context.Context,
+	userID int,
+) (*UserResponseDTO, error) {
+	query := `SELECT id, username, active, last_login FROM vps_data_auth WHERE id = $1`
+
+	var user UserResponseDTO
+	err := r.db.QueryRow(ctx, query, userID).Scan(&user.ID, &user.Username, &user.Active, &user.LastLogin)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		r.logger.Error("failed to get user", zap.Int("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) GetUserByUsername(
+	ctx context.Context,
+	username string,
+) (*UserResponseDTO, error) {
+	query := `SELECT id, username, active, last_login FROM vps_data_auth WHERE username = $1`
+
+	var user UserResponseDTO
+	err := r.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.Active, &user.LastLogin)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		r.logger.Error("failed to get user", zap.String("username", username), zap.Error(err))
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) ListUsers(
+	ctx context.Context,
+	limit, offset int,
+) ([]UserResponseDTO, int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM vps_data_auth").Scan(&total); err != nil {
+		r.logger.Error("failed to count users", zap.Error(err))
+		return nil, 0, err
+	}
+
+	query := `SELECT id, username, active, last_login FROM vps_data_auth ORDER BY id LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		r.logger.Error("failed to list users", zap.Error(err))
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]UserResponseDTO, 0, limit)
+	for rows.Next() {
+		var user UserResponseDTO
+		if err := rows.Scan(&user.ID, &user.Username, &user.Active, &user.LastLogin); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+func (r *UserRepository) CreateUser(
+	ctx context.Context,
+	username, rawPassword string,
+	active bool,
+) (*UserResponseDTO, error) {
+	hash, err := r.hasher.Hash(rawPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO vps_data_auth (username, password, active) VALUES ($1, $2, $3) RETURNING id`
+
+	user := &UserResponseDTO{Username: username, Active: active}
+	if err := r.db.QueryRow(ctx, query, username, hash, active).Scan(&user.ID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return nil, ErrUserAlreadyExists
+		}
+		r.logger.Error("failed to create user", zap.String("username", username), zap.Error(err))
+		return nil, err
+	}
+
+	r.logger.Info("user created", zap.Int("user_id", user.ID), zap.String("username", username))
+
+	return user, nil
+}
+
+func (r *UserRepository) SetUserActive(
+	ctx context.Context,
+	userID int,
+	active bool,
+) error {
+	result, err := r.db.Exec(ctx, "UPDATE vps_data_auth SET active = $2 WHERE id = $1", userID, active)
+	if err != nil {
+		r.logger.Error("failed to update user status", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(
+	ctx context.Context,
+	userID int,
+	rawPassword string,
+) error {
+	hash, err := r.hasher.Hash(rawPassword)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(ctx, "UPDATE vps_data_auth SET password = $2 WHERE id = $1", userID, hash)
+	if err != nil {
+		r.logger.Error("failed to update password", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) DeleteUser(
+	ctx context.Context,
+	userID int,
+) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1", userID); err != nil {
+		r.logger.Error("failed to delete user roles", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM vps_data_auth WHERE id = $1", userID)
+	if err != nil {
+		r.logger.Error("failed to delete user", zap.Int("user_id", userID), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	r.logger.Info("user deleted", zap.Int("user_id", userID))
+	return nil
+}
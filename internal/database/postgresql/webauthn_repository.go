@@ -0,0 +1,158 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential already registered")
+)
+
+var _ WebAuthnStore = (*WebAuthnRepository)(nil)
+
+type WebAuthnRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewWebAuthnRepository(
+	db *pgxpool.Pool,
+	logger *zap.Logger,
+) *WebAuthnRepository {
+	return &WebAuthnRepository{
+		db:     db,
+		logger: logger.Named("webauthn_repository"),
+	}
+}
+
+const webAuthnColumns = `id, user_id, credential_id, public_key, algorithm, sign_count, name, transports, created_at, last_used_at`
+
+func (r *WebAuthnRepository) GetWebAuthnCredentials(
+	ctx context.Context,
+	userID int,
+) ([]WebAuthnCredentialEntity, error) {
+	rows, err := r.db.Query(
+		ctx, "SELECT "+webAuthnColumns+" FROM webauthn_credentials WHERE user_id = $1 ORDER BY id", userID,
+	)
+	if err != nil {
+		r.logger.Error("failed to list webauthn credentials", zap.Int("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credentials []WebAuthnCredentialEntity
+	for rows.Next() {
+		var credential WebAuthnCredentialEntity
+		if err := scanWebAuthnCredential(rows, &credential); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, rows.Err()
+}
+
+func (r *WebAuthnRepository) GetWebAuthnCredential(
+	ctx context.Context,
+	credentialID []byte,
+) (*WebAuthnCredentialEntity, error) {
+	var credential WebAuthnCredentialEntity
+	row := r.db.QueryRow(ctx, "SELECT "+webAuthnColumns+" FROM webauthn_credentials WHERE credential_id = $1", credentialID)
+	if err := scanWebAuthnCredential(row, &credential); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+		r.logger.Error("failed to get webauthn credential", zap.Error(err))
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *WebAuthnRepository) SaveWebAuthnCredential(
+	ctx context.Context,
+	credential WebAuthnCredentialEntity,
+) (int, error) {
+	query := `
+        INSERT INTO webauthn_credentials (user_id, credential_id, public_key, algorithm, sign_count, name, transports)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id
+    `
+
+	var id int
+	err := r.db.QueryRow(
+		ctx, query,
+		credential.UserID, credential.CredentialID, credential.PublicKey, credential.Algorithm,
+		credential.SignCount, credential.Name, credential.Transports,
+	).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return 0, ErrWebAuthnCredentialExists
+		}
+		r.logger.Error("failed to save webauthn credential", zap.Int("user_id", credential.UserID), zap.Error(err))
+		return 0, err
+	}
+
+	r.logger.Info("webauthn credential registered", zap.Int("user_id", credential.UserID), zap.Int("id", id))
+	return id, nil
+}
+
+// UseWebAuthnCredential records a successful assertion. It returns false when
+// the signature counter did not advance, which points to a cloned
+// authenticator. Authenticators that do not count always report zero.
+func (r *WebAuthnRepository) UseWebAuthnCredential(
+	ctx context.Context,
+	id int,
+	signCount int64,
+) (bool, error) {
+	result, err := r.db.Exec(
+		ctx,
+		`UPDATE webauthn_credentials SET sign_count = $2, last_used_at = NOW()
+         WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))`,
+		id, signCount,
+	)
+	if err != nil {
+		r.logger.Error("failed to update webauthn credential", zap.Int("id", id), zap.Error(err))
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *WebAuthnRepository) DeleteWebAuthnCredential(
+	ctx context.Context,
+	userID, id int,
+) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		r.logger.Error("failed to delete webauthn credential", zap.Int("id", id), zap.Error(err))
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return nil
+}
+
+func scanWebAuthnCredential(
+	row pgx.Row,
+	c *WebAuthnCredentialEntity,
+) error {
+	return row.Scan(
+		&c.ID,
+		&c.UserID,
+		&c.CredentialID,
+		&c.PublicKey,
+		&c.Algorithm,
+		&c.SignCount,
+		&c.Name,
+		&c.Transports,
+		&c.CreatedAt,
+		&c.LastUsedAt,
+	)
+}
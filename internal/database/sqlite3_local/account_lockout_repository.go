@@ -0,0 +1,69 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+var _ AccountLockoutStore = (*AccountLockoutRepository)(nil)
+
+type AccountLockoutRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewAccountLockoutRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *AccountLockoutRepository {
+	return &AccountLockoutRepository{
+		db:     localDB.DB,
+		logger: logger.Named("account_lockout_repository"),
+	}
+}
+
+// GetLockout returns the failure counter of username. A username without
+// recent failures yields an empty entity, not an error.
+func (r *AccountLockoutRepository) GetLockout(username string) (*AccountLockoutEntity, error) {
+	l := AccountLockoutEntity{Username: username}
+	err := r.db.QueryRow(QuerySelectLockout, username).Scan(&l.Username, &l.Failures, &l.LastFailureAt, &l.LockedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// AddLoginFailure increments the counter and returns the new value. A counter
+// whose last failure is older than resetBefore starts over at one.
+func (r *AccountLockoutRepository) AddLoginFailure(
+	username string,
+	now, resetBefore int64,
+) (int, error) {
+	var failures int
+	err := r.db.QueryRow(QueryAddLoginFailure, username, now, resetBefore).Scan(&failures)
+	return failures, err
+}
+
+func (r *AccountLockoutRepository) LockAccount(
+	username string,
+	until int64,
+) error {
+	_, err := r.db.Exec(QueryLockAccount, until, username)
+	return err
+}
+
+func (r *AccountLockoutRepository) ResetLockout(username string) error {
+	_, err := r.db.Exec(QueryDeleteLockout, username)
+	return err
+}
+
+// DeleteStaleLockouts forgets counters that are no longer locked and whose
+// last failure is older than failedBefore.
+func (r *AccountLockoutRepository) DeleteStaleLockouts(
+	failedBefore, now int64,
+) error {
+	_, err := r.db.Exec(QueryDeleteStaleLockouts, failedBefore, now)
+	return err
+}
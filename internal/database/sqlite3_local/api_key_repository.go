@@ -0,0 +1,110 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+var _ APIKeyStore = (*APIKeyRepository)(nil)
+
+type APIKeyRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewAPIKeyRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:     localDB.DB,
+		logger: logger.Named("api_key_repository"),
+	}
+}
+
+func (r *APIKeyRepository) SaveAPIKey(key APIKeyEntity) (int64, error) {
+	result, err := r.db.Exec(
+		QueryInsertAPIKey,
+		key.Prefix, key.SecretHash, key.UserID, key.Username, key.Name, key.Permissions, key.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *APIKeyRepository) GetAPIKeyByPrefix(prefix string) (*APIKeyEntity, error) {
+	key, err := scanAPIKey(r.db.QueryRow(QuerySelectAPIKeyByPrefix, prefix))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *APIKeyRepository) GetUserAPIKeys(userID int64) ([]APIKeyEntity, error) {
+	rows, err := r.db.Query(QuerySelectUserAPIKeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := make([]APIKeyEntity, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) TouchAPIKey(
+	id, usedAt int64,
+	ip string,
+) error {
+	_, err := r.db.Exec(QueryTouchAPIKey, usedAt, ip, id)
+	return err
+}
+
+// RevokeAPIKey revokes a key owned by userID. Revoking a foreign or already
+// revoked key reports ErrAPIKeyNotFound.
+func (r *APIKeyRepository) RevokeAPIKey(
+	id, userID int64,
+) error {
+	result, err := r.db.Exec(QueryRevokeAPIKey, id, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKeyEntity, error) {
+	var (
+		key     APIKeyEntity
+		revoked int
+	)
+	err := row.Scan(
+		&key.ID, &key.Prefix, &key.SecretHash, &key.UserID, &key.Username, &key.Name, &key.Permissions,
+		&key.ExpiresAt, &key.LastUsedAt, &key.LastUsedIP, &revoked, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.Revoked = revoked == 1
+	return &key, nil
+}
@@ -0,0 +1,61 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+var _ AuditStore = (*AuditRepository)(nil)
+
+type AuditRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewAuditRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *AuditRepository {
+	return &AuditRepository{
+		db:     localDB.DB,
+		logger: logger.Named("audit_repository"),
+	}
+}
+
+func (r *AuditRepository) RecordAudit(entry AuditEntity) error {
+	success := 0
+	if entry.Success {
+		success = 1
+	}
+	_, err := r.db.Exec(
+		QueryInsertAudit,
+		entry.ActorID, entry.ActorUsername, entry.Action,
+		entry.Target, entry.Details, entry.IP, success,
+	)
+	return err
+}
+
+func (r *AuditRepository) GetAuditEntries(limit int) ([]AuditEntity, error) {
+	rows, err := r.db.Query(QuerySelectAudit, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []AuditEntity
+	for rows.Next() {
+		var e AuditEntity
+		var success int
+		err := rows.Scan(
+			&e.ID, &e.ActorID, &e.ActorUsername, &e.Action,
+			&e.Target, &e.Details, &e.IP, &success, &e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Success = success == 1
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,75 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+var _ BanEventStore = (*BanEventRepository)(nil)
+
+type BanEventRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewBanEventRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *BanEventRepository {
+	return &BanEventRepository{
+		db:     localDB.DB,
+		logger: logger.Named("ban_event_repository"),
+	}
+}
+
+func (r *BanEventRepository) RecordBanEvents(events []BanEventEntity) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare(QueryInsertBanEvent)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, e := range events {
+		if _, err := stmt.Exec(e.Jail, e.IP, e.Event, e.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *BanEventRepository) GetBanEvents(filter BanEventFilter) ([]BanEventEntity, error) {
+	rows, err := r.db.Query(
+		QuerySelectBanEvents,
+		filter.Jail, filter.Jail, filter.IP, filter.IP, filter.Since, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []BanEventEntity
+	for rows.Next() {
+		var e BanEventEntity
+		if err := rows.Scan(&e.ID, &e.Jail, &e.IP, &e.Event, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *BanEventRepository) DeleteBanEventsBefore(before int64) error {
+	_, err := r.db.Exec(QueryDeleteBanEventsBefore, before)
+	return err
+}
@@ -0,0 +1,63 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+var _ BlockStore = (*BlockRepository)(nil)
+
+type BlockRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewBlockRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *BlockRepository {
+	return &BlockRepository{
+		db:     localDB.DB,
+		logger: logger.Named("block_repository"),
+	}
+}
+
+func (r *BlockRepository) SaveBlock(block BlockEntity) error {
+	_, err := r.db.Exec(
+		QueryUpsertBlock,
+		block.IP, block.Reason, block.Detections, block.ExpiresAt, block.CreatedAt,
+	)
+	return err
+}
+
+func (r *BlockRepository) DeleteBlock(ip string) (int64, error) {
+	res, err := r.db.Exec(QueryDeleteBlock, ip)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *BlockRepository) GetActiveBlocks(now int64) ([]BlockEntity, error) {
+	rows, err := r.db.Query(QuerySelectActiveBlocks, now)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var blocks []BlockEntity
+	for rows.Next() {
+		var b BlockEntity
+		if err := rows.Scan(&b.IP, &b.Reason, &b.Detections, &b.ExpiresAt, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, rows.Err()
+}
+
+func (r *BlockRepository) DeleteExpiredBlocks(now int64) error {
+	_, err := r.db.Exec(QueryDeleteExpiredBlocks, now)
+	return err
+}
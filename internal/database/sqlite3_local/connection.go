@@ -61,16 +61,180 @@ func (l *LocalDB) initSchema() error {
         revoked_by_id INTEGER,
         revoked_by_username TEXT,
         expires_at INTEGER NOT NULL,
-        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+        ip TEXT NOT NULL DEFAULT '',
+        user_agent TEXT NOT NULL DEFAULT '',
+        device TEXT NOT NULL DEFAULT '',
+        last_seen_at INTEGER NOT NULL DEFAULT 0,
+        auth_at INTEGER NOT NULL DEFAULT 0
     );
 
     CREATE INDEX IF NOT EXISTS idx_tokens_jti ON tokens(jti);
     CREATE INDEX IF NOT EXISTS idx_tokens_username ON tokens(username);
     CREATE INDEX IF NOT EXISTS idx_tokens_revoked ON tokens(revoked);
+
+    CREATE TABLE IF NOT EXISTS audit_log (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        actor_id INTEGER NOT NULL,
+        actor_username TEXT NOT NULL,
+        action TEXT NOT NULL,
+        target TEXT NOT NULL DEFAULT '',
+        details TEXT NOT NULL DEFAULT '',
+        ip TEXT NOT NULL DEFAULT '',
+        success INTEGER NOT NULL DEFAULT 1,
+        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+    CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+
+    CREATE TABLE IF NOT EXISTS ip_blocks (
+        ip TEXT PRIMARY KEY,
+        reason TEXT NOT NULL DEFAULT '',
+        detections INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL,
+        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_ip_blocks_expires_at ON ip_blocks(expires_at);
+
+    CREATE TABLE IF NOT EXISTS f2b_ban_events (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        jail TEXT NOT NULL,
+        ip TEXT NOT NULL,
+        event TEXT NOT NULL,
+        created_at INTEGER NOT NULL
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_f2b_ban_events_created_at ON f2b_ban_events(created_at);
+    CREATE INDEX IF NOT EXISTS idx_f2b_ban_events_ip ON f2b_ban_events(ip);
+
+    CREATE TABLE IF NOT EXISTS f2b_imported_bans (
+        ip TEXT PRIMARY KEY,
+        jail TEXT NOT NULL,
+        source TEXT NOT NULL,
+        expires_at INTEGER NOT NULL,
+        banned_at INTEGER NOT NULL DEFAULT 0,
+        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_f2b_imported_bans_expires_at ON f2b_imported_bans(expires_at);
+
+    CREATE TABLE IF NOT EXISTS f2b_temp_ignores (
+        ip TEXT NOT NULL,
+        jail TEXT NOT NULL,
+        username TEXT NOT NULL DEFAULT '',
+        expires_at INTEGER NOT NULL,
+        PRIMARY KEY (ip, jail)
+    );
+
+    CREATE TABLE IF NOT EXISTS refresh_tokens (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        token_hash TEXT NOT NULL UNIQUE,
+        family_id TEXT NOT NULL,
+        user_id INTEGER NOT NULL,
+        username TEXT NOT NULL,
+        access_jti TEXT NOT NULL,
+        amr TEXT NOT NULL DEFAULT '',
+        used INTEGER NOT NULL DEFAULT 0,
+        revoked INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL,
+        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+    CREATE INDEX IF NOT EXISTS idx_refresh_tokens_username ON refresh_tokens(username);
+    CREATE INDEX IF NOT EXISTS idx_refresh_tokens_access_jti ON refresh_tokens(access_jti);
+
+    CREATE TABLE IF NOT EXISTS api_keys (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        prefix TEXT NOT NULL UNIQUE,
+        secret_hash TEXT NOT NULL,
+        user_id INTEGER NOT NULL,
+        username TEXT NOT NULL,
+        name TEXT NOT NULL,
+        permissions TEXT NOT NULL DEFAULT '',
+        expires_at INTEGER NOT NULL DEFAULT 0,
+        last_used_at INTEGER NOT NULL DEFAULT 0,
+        last_used_ip TEXT NOT NULL DEFAULT '',
+        revoked INTEGER NOT NULL DEFAULT 0,
+        created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+    CREATE TABLE IF NOT EXISTS login_attempts (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        username TEXT NOT NULL,
+        ip TEXT NOT NULL,
+        user_agent TEXT NOT NULL DEFAULT '',
+        device TEXT NOT NULL DEFAULT '',
+        result TEXT NOT NULL,
+        reason TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_login_attempts_username ON login_attempts(username, result);
+    CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts(created_at);
+
+    CREATE TABLE IF NOT EXISTS account_lockouts (
+        username TEXT PRIMARY KEY,
+        failures INTEGER NOT NULL DEFAULT 0,
+        last_failure_at INTEGER NOT NULL DEFAULT 0,
+        locked_until INTEGER NOT NULL DEFAULT 0
+    );
     `
 
-	_, err := l.DB.Exec(schema)
-	return err
+	if _, err := l.DB.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the first release; CREATE TABLE IF NOT EXISTS does
+	// not touch tables created by older versions.
+	return l.addMissingColumns(
+		"tokens", map[string]string{
+			"ip":           "TEXT NOT NULL DEFAULT ''",
+			"user_agent":   "TEXT NOT NULL DEFAULT ''",
+			"device":       "TEXT NOT NULL DEFAULT ''",
+			"last_seen_at": "INTEGER NOT NULL DEFAULT 0",
+			"auth_at":      "INTEGER NOT NULL DEFAULT 0",
+		},
+	)
+}
+
+func (l *LocalDB) addMissingColumns(
+	table string,
+	columns map[string]string,
+) error {
+	rows, err := l.DB.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for name, def := range columns {
+		if existing[name] {
+			continue
+		}
+		// Table and column names come from the constants above, never from input.
+		if _, err := l.DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + name + ` ` + def); err != nil { //nolint:gosec // see above
+			return err
+		}
+		l.logger.Info("Added column", zap.String("table", table), zap.String("column", name))
+	}
+	return nil
 }
 
 func (l *LocalDB) Close() {
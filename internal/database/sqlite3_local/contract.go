@@ -10,15 +10,127 @@ type TokenStore interface {
 		jti, username string,
 		expiresAt int64,
 	) error
-	SaveTokenExclusive(
-		jti, username string,
-		expiresAt int64,
+	SaveSession(
+		session TokenEntity,
+		exclusive bool,
 	) (int64, error)
+	GetToken(jti string) (*TokenEntity, error)
+	GetUserTokens(
+		username string,
+		now int64,
+	) ([]TokenEntity, error)
 	ValidateToken(jti string) error
+	TouchToken(
+		jti string,
+		now int64,
+	) error
+	SetAuthTime(
+		jti string,
+		at int64,
+	) error
 	RevokeToken(
 		jti string,
 		byID int,
 		byUsername string,
 	) error
+	RevokeUserToken(
+		jti, username string,
+		byID int,
+		byUsername string,
+	) error
+	RevokeAllUserTokens(
+		username string,
+		byID int,
+		byUsername string,
+	) (int64, error)
+	RevokeOtherUserTokens(
+		username, keepJTI string,
+		byID int,
+		byUsername string,
+	) (int64, error)
 	GetAllTokens() ([]TokenEntity, error)
 }
+
+type RefreshTokenStore interface {
+	SaveRefreshToken(entry RefreshTokenEntity) error
+	GetRefreshToken(tokenHash string) (*RefreshTokenEntity, error)
+	RotateRefreshToken(
+		oldHash string,
+		next RefreshTokenEntity,
+		now int64,
+	) error
+	RevokeRefreshFamily(
+		familyID, byUsername string,
+	) error
+	GetFamilyByAccessJTI(jti string) (string, error)
+}
+
+type APIKeyStore interface {
+	SaveAPIKey(key APIKeyEntity) (int64, error)
+	GetAPIKeyByPrefix(prefix string) (*APIKeyEntity, error)
+	GetUserAPIKeys(userID int64) ([]APIKeyEntity, error)
+	TouchAPIKey(
+		id, usedAt int64,
+		ip string,
+	) error
+	RevokeAPIKey(
+		id, userID int64,
+	) error
+}
+
+type AuditStore interface {
+	RecordAudit(entry AuditEntity) error
+	GetAuditEntries(limit int) ([]AuditEntity, error)
+}
+
+type BlockStore interface {
+	SaveBlock(block BlockEntity) error
+	DeleteBlock(ip string) (int64, error)
+	GetActiveBlocks(now int64) ([]BlockEntity, error)
+	DeleteExpiredBlocks(now int64) error
+}
+
+type BanEventStore interface {
+	RecordBanEvents(events []BanEventEntity) error
+	GetBanEvents(filter BanEventFilter) ([]BanEventEntity, error)
+	DeleteBanEventsBefore(before int64) error
+}
+
+type ImportedBanStore interface {
+	UpsertImportedBan(ban ImportedBanEntity) error
+	GetImportedBans() ([]ImportedBanEntity, error)
+	GetExpiredImportedBans(now int64) ([]ImportedBanEntity, error)
+	DeleteImportedBan(ip string) error
+}
+
+type TempIgnoreStore interface {
+	SaveTempIgnore(entry TempIgnoreEntity) error
+	GetExpiredTempIgnores(now int64) ([]TempIgnoreEntity, error)
+	GetJailTempIgnores(jail string) ([]TempIgnoreEntity, error)
+	DeleteTempIgnore(ip, jail string) error
+}
+
+type LoginAttemptStore interface {
+	RecordLoginAttempt(attempt LoginAttemptEntity) error
+	GetLoginAttempts(filter LoginAttemptFilter) ([]LoginAttemptEntity, error)
+	GetLoginSources(
+		username, ip, device string,
+	) (LoginSources, error)
+	DeleteLoginAttemptsBefore(before int64) error
+}
+
+type AccountLockoutStore interface {
+	GetLockout(username string) (*AccountLockoutEntity, error)
+	AddLoginFailure(
+		username string,
+		now, resetBefore int64,
+	) (int, error)
+	LockAccount(
+		username string,
+		until int64,
+	) error
+	ResetLockout(username string) error
+	DeleteStaleLockouts(
+		failedBefore, now int64,
+	) error
+}
@@ -0,0 +1,63 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+var _ ImportedBanStore = (*ImportedBanRepository)(nil)
+
+type ImportedBanRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewImportedBanRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *ImportedBanRepository {
+	return &ImportedBanRepository{
+		db:     localDB.DB,
+		logger: logger.Named("imported_ban_repository"),
+	}
+}
+
+func (r *ImportedBanRepository) UpsertImportedBan(ban ImportedBanEntity) error {
+	_, err := r.db.Exec(QueryUpsertImportedBan, ban.IP, ban.Jail, ban.Source, ban.ExpiresAt, ban.BannedAt)
+	return err
+}
+
+func (r *ImportedBanRepository) GetImportedBans() ([]ImportedBanEntity, error) {
+	return r.query(QuerySelectImportedBans)
+}
+
+func (r *ImportedBanRepository) GetExpiredImportedBans(now int64) ([]ImportedBanEntity, error) {
+	return r.query(QuerySelectExpiredImportedBans, now)
+}
+
+func (r *ImportedBanRepository) DeleteImportedBan(ip string) error {
+	_, err := r.db.Exec(QueryDeleteImportedBan, ip)
+	return err
+}
+
+func (r *ImportedBanRepository) query(
+	query string,
+	args ...any,
+) ([]ImportedBanEntity, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var bans []ImportedBanEntity
+	for rows.Next() {
+		var b ImportedBanEntity
+		if err := rows.Scan(&b.IP, &b.Jail, &b.Source, &b.ExpiresAt, &b.BannedAt, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
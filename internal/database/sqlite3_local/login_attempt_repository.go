@@ -0,0 +1,85 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+const (
+	LoginResultSuccess = "success"
+	LoginResultFailure = "failure"
+	LoginResultLocked  = "locked"
+)
+
+var _ LoginAttemptStore = (*LoginAttemptRepository)(nil)
+
+type LoginAttemptRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewLoginAttemptRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		db:     localDB.DB,
+		logger: logger.Named("login_attempt_repository"),
+	}
+}
+
+func (r *LoginAttemptRepository) RecordLoginAttempt(attempt LoginAttemptEntity) error {
+	_, err := r.db.Exec(
+		QueryInsertLoginAttempt,
+		attempt.Username, attempt.IP, attempt.UserAgent, attempt.Device,
+		attempt.Result, attempt.Reason, attempt.CreatedAt,
+	)
+	return err
+}
+
+func (r *LoginAttemptRepository) GetLoginAttempts(filter LoginAttemptFilter) ([]LoginAttemptEntity, error) {
+	rows, err := r.db.Query(
+		QuerySelectLoginAttempts,
+		filter.Username, filter.Username, filter.IP, filter.IP, filter.Since, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var attempts []LoginAttemptEntity
+	for rows.Next() {
+		var a LoginAttemptEntity
+		err := rows.Scan(&a.ID, &a.Username, &a.IP, &a.UserAgent, &a.Device, &a.Result, &a.Reason, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// GetLoginSources looks only at successful logins, so failed guesses from an
+// attacker's address never make that address "known".
+func (r *LoginAttemptRepository) GetLoginSources(
+	username, ip, device string,
+) (LoginSources, error) {
+	var (
+		sources            LoginSources
+		fromIP, fromDevice int64
+	)
+	err := r.db.QueryRow(QuerySelectLoginSources, ip, device, username, LoginResultSuccess).
+		Scan(&sources.Logins, &fromIP, &fromDevice)
+	if err != nil {
+		return LoginSources{}, err
+	}
+	sources.KnownIP = fromIP > 0
+	sources.KnownDevice = fromDevice > 0
+	return sources, nil
+}
+
+func (r *LoginAttemptRepository) DeleteLoginAttemptsBefore(before int64) error {
+	_, err := r.db.Exec(QueryDeleteLoginAttemptsBefore, before)
+	return err
+}
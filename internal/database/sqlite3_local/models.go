@@ -11,6 +11,11 @@ type TokenEntity struct {
 	RevokedByUsername sql.NullString `db:"revoked_by_username"`
 	ExpiresAt         int64          `db:"expires_at"`
 	CreatedAt         int64          `db:"created_at"`
+	IP                string         `db:"ip"`
+	UserAgent         string         `db:"user_agent"`
+	Device            string         `db:"device"`
+	LastSeenAt        int64          `db:"last_seen_at"`
+	AuthAt            int64          `db:"auth_at"`
 }
 
 type TokenStatus struct {
@@ -21,3 +26,126 @@ type TokenStatus struct {
 type RevokeResult struct {
 	Count int64
 }
+
+type AuditEntity struct {
+	ID            int64  `db:"id"`
+	ActorID       int64  `db:"actor_id"`
+	ActorUsername string `db:"actor_username"`
+	Action        string `db:"action"`
+	Target        string `db:"target"`
+	Details       string `db:"details"`
+	IP            string `db:"ip"`
+	Success       bool   `db:"success"`
+	CreatedAt     int64  `db:"created_at"`
+}
+
+type BlockEntity struct {
+	IP         string `db:"ip"`
+	Reason     string `db:"reason"`
+	Detections int    `db:"detections"`
+	ExpiresAt  int64  `db:"expires_at"`
+	CreatedAt  int64  `db:"created_at"`
+}
+
+type BanEventEntity struct {
+	ID        int64  `db:"id"`
+	Jail      string `db:"jail"`
+	IP        string `db:"ip"`
+	Event     string `db:"event"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+type BanEventFilter struct {
+	Jail  string
+	IP    string
+	Since int64
+	Limit int
+}
+
+type ImportedBanEntity struct {
+	IP        string `db:"ip"`
+	Jail      string `db:"jail"`
+	Source    string `db:"source"`
+	ExpiresAt int64  `db:"expires_at"`
+	// BannedAt is when the importer last banned the IP itself, 0 while the
+	// jail holds no ban of the importer (fail2ban banned it on its own or
+	// the import ban was lifted by the jail's bantime).
+	BannedAt  int64 `db:"banned_at"`
+	CreatedAt int64 `db:"created_at"`
+}
+
+type TempIgnoreEntity struct {
+	IP        string `db:"ip"`
+	Jail      string `db:"jail"`
+	Username  string `db:"username"`
+	ExpiresAt int64  `db:"expires_at"`
+}
+
+// RefreshTokenEntity is one link of a refresh token rotation chain. All links
+// of a login share FamilyID; Used marks a token that was already rotated.
+type RefreshTokenEntity struct {
+	ID        int64  `db:"id"`
+	TokenHash string `db:"token_hash"`
+	FamilyID  string `db:"family_id"`
+	UserID    int64  `db:"user_id"`
+	Username  string `db:"username"`
+	AccessJTI string `db:"access_jti"`
+	AMR       string `db:"amr"`
+	Used      bool   `db:"used"`
+	Revoked   bool   `db:"revoked"`
+	ExpiresAt int64  `db:"expires_at"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+// APIKeyEntity is a long-lived credential for automation. Only the hash of the
+// secret is stored; Prefix identifies the key. ExpiresAt 0 means no expiry.
+type APIKeyEntity struct {
+	ID          int64  `db:"id"`
+	Prefix      string `db:"prefix"`
+	SecretHash  string `db:"secret_hash"`
+	UserID      int64  `db:"user_id"`
+	Username    string `db:"username"`
+	Name        string `db:"name"`
+	Permissions string `db:"permissions"`
+	ExpiresAt   int64  `db:"expires_at"`
+	LastUsedAt  int64  `db:"last_used_at"`
+	LastUsedIP  string `db:"last_used_ip"`
+	Revoked     bool   `db:"revoked"`
+	CreatedAt   int64  `db:"created_at"`
+}
+
+// LoginAttemptEntity is one authentication attempt. Result is "success",
+// "failure" or "locked"; Reason explains failures.
+type LoginAttemptEntity struct {
+	ID        int64  `db:"id"`
+	Username  string `db:"username"`
+	IP        string `db:"ip"`
+	UserAgent string `db:"user_agent"`
+	Device    string `db:"device"`
+	Result    string `db:"result"`
+	Reason    string `db:"reason"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+type LoginAttemptFilter struct {
+	Username string
+	IP       string
+	Since    int64
+	Limit    int
+}
+
+// LoginSources summarizes earlier successful logins of a user: how many
+// there were and whether any came from the given IP and device.
+type LoginSources struct {
+	Logins      int64
+	KnownIP     bool
+	KnownDevice bool
+}
+
+// AccountLockoutEntity counts consecutive failed logins of a username.
+type AccountLockoutEntity struct {
+	Username      string `db:"username"`
+	Failures      int    `db:"failures"`
+	LastFailureAt int64  `db:"last_failure_at"`
+	LockedUntil   int64  `db:"locked_until"`
+}
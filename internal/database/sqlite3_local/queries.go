@@ -15,5 +15,111 @@ const (
 
 	QueryCountActiveTokens = `SELECT COUNT(*) FROM tokens WHERE username = ? AND revoked = 0 AND expires_at > ?` //nolint:gosec // SQL query, not credentials
 
-	QuerySelectAllTokens = `SELECT id, jti, username, revoked, revoked_by_id, revoked_by_username, expires_at, created_at FROM tokens ORDER BY created_at DESC` //nolint:gosec // SQL query, not credentials
+	QuerySelectAllTokens = `SELECT id, jti, username, revoked, revoked_by_id, revoked_by_username, expires_at, created_at, ip, user_agent, device, last_seen_at, auth_at FROM tokens ORDER BY created_at DESC` //nolint:gosec // SQL query, not credentials
+
+	QueryInsertSession = `INSERT INTO tokens (jti, username, revoked, expires_at, ip, user_agent, device, last_seen_at, auth_at) VALUES (?, ?, 0, ?, ?, ?, ?, ?, ?)` //nolint:gosec // SQL query, not credentials
+
+	QuerySelectToken = `SELECT id, jti, username, revoked, revoked_by_id, revoked_by_username, expires_at, created_at, ip, user_agent, device, last_seen_at, auth_at FROM tokens WHERE jti = ?` //nolint:gosec // SQL query, not credentials
+
+	QuerySelectUserActiveTokens = `SELECT id, jti, username, revoked, revoked_by_id, revoked_by_username, expires_at, created_at, ip, user_agent, device, last_seen_at, auth_at FROM tokens WHERE username = ? AND revoked = 0 AND expires_at > ? ORDER BY created_at DESC` //nolint:gosec // SQL query, not credentials
+
+	QueryTouchToken = `UPDATE tokens SET last_seen_at = ? WHERE jti = ? AND last_seen_at < ?` //nolint:gosec // SQL query, not credentials
+
+	QuerySetTokenAuthTime = `UPDATE tokens SET auth_at = ? WHERE jti = ? AND revoked = 0` //nolint:gosec // SQL query, not credentials
+
+	QueryRevokeUserToken = `UPDATE tokens SET revoked = 1, revoked_by_id = ?, revoked_by_username = ? WHERE jti = ? AND username = ? AND revoked = 0` //nolint:gosec // SQL query, not credentials
+
+	QueryInsertAudit = `INSERT INTO audit_log (actor_id, actor_username, action, target, details, ip, success) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	QuerySelectAudit = `SELECT id, actor_id, actor_username, action, target, details, ip, success, created_at FROM audit_log ORDER BY id DESC LIMIT ?`
+
+	QueryUpsertBlock = `INSERT INTO ip_blocks (ip, reason, detections, expires_at, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(ip) DO UPDATE SET reason = excluded.reason, detections = excluded.detections, expires_at = excluded.expires_at, created_at = excluded.created_at`
+
+	QueryDeleteBlock = `DELETE FROM ip_blocks WHERE ip = ?`
+
+	QuerySelectActiveBlocks = `SELECT ip, reason, detections, expires_at, created_at FROM ip_blocks WHERE expires_at > ? ORDER BY created_at DESC`
+
+	QueryDeleteExpiredBlocks = `DELETE FROM ip_blocks WHERE expires_at <= ?`
+
+	QueryInsertBanEvent = `INSERT INTO f2b_ban_events (jail, ip, event, created_at) VALUES (?, ?, ?, ?)`
+
+	QuerySelectBanEvents = `SELECT id, jail, ip, event, created_at FROM f2b_ban_events
+		WHERE (? = '' OR jail = ?) AND (? = '' OR ip = ?) AND created_at >= ?
+		ORDER BY id DESC LIMIT ?`
+
+	QueryDeleteBanEventsBefore = `DELETE FROM f2b_ban_events WHERE created_at < ?`
+
+	QueryUpsertImportedBan = `INSERT INTO f2b_imported_bans (ip, jail, source, expires_at, banned_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(ip) DO UPDATE SET jail = excluded.jail, source = excluded.source, expires_at = excluded.expires_at,
+		banned_at = excluded.banned_at`
+
+	QuerySelectImportedBans = `SELECT ip, jail, source, expires_at, banned_at, created_at FROM f2b_imported_bans`
+
+	QuerySelectExpiredImportedBans = `SELECT ip, jail, source, expires_at, banned_at, created_at FROM f2b_imported_bans WHERE expires_at <= ?`
+
+	QueryDeleteImportedBan = `DELETE FROM f2b_imported_bans WHERE ip = ?`
+
+	QueryUpsertTempIgnore = `INSERT INTO f2b_temp_ignores (ip, jail, username, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(ip, jail) DO UPDATE SET username = excluded.username, expires_at = excluded.expires_at`
+
+	QuerySelectExpiredTempIgnores = `SELECT ip, jail, username, expires_at FROM f2b_temp_ignores WHERE expires_at <= ?`
+
+	QuerySelectJailTempIgnores = `SELECT ip, jail, username, expires_at FROM f2b_temp_ignores WHERE jail = ?`
+
+	QueryDeleteTempIgnore = `DELETE FROM f2b_temp_ignores WHERE ip = ? AND jail = ?`
+
+	QueryInsertRefreshToken = `INSERT INTO refresh_tokens (token_hash, family_id, user_id, username, access_jti, amr, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)` //nolint:gosec // SQL query, not credentials
+
+	QuerySelectRefreshToken = `SELECT id, token_hash, family_id, user_id, username, access_jti, amr, used, revoked, expires_at, created_at FROM refresh_tokens WHERE token_hash = ?` //nolint:gosec // SQL query, not credentials
+
+	QueryMarkRefreshTokenUsed = `UPDATE refresh_tokens SET used = 1 WHERE token_hash = ? AND used = 0 AND revoked = 0 AND expires_at > ?` //nolint:gosec // SQL query, not credentials
+
+	QueryRevokeRefreshFamily = `UPDATE refresh_tokens SET revoked = 1 WHERE family_id = ? AND revoked = 0` //nolint:gosec // SQL query, not credentials
+
+	QueryRevokeFamilyAccessTokens = `UPDATE tokens SET revoked = 1, revoked_by_id = 0, revoked_by_username = ? WHERE revoked = 0 AND jti IN (SELECT access_jti FROM refresh_tokens WHERE family_id = ?)` //nolint:gosec // SQL query, not credentials
+
+	QuerySelectFamilyByAccessJTI = `SELECT family_id FROM refresh_tokens WHERE access_jti = ? LIMIT 1` //nolint:gosec // SQL query, not credentials
+
+	QueryRevokeUserRefreshTokens = `UPDATE refresh_tokens SET revoked = 1 WHERE username = ? AND revoked = 0` //nolint:gosec // SQL query, not credentials
+
+	QueryRevokeOtherUserTokens = `UPDATE tokens SET revoked = 1, revoked_by_id = ?, revoked_by_username = ? WHERE username = ? AND jti != ? AND revoked = 0` //nolint:gosec // SQL query, not credentials
+
+	QueryRevokeOtherUserRefreshTokens = `UPDATE refresh_tokens SET revoked = 1 WHERE username = ? AND revoked = 0
+		AND family_id NOT IN (SELECT family_id FROM refresh_tokens WHERE access_jti = ?)` //nolint:gosec // SQL query, not credentials
+
+	QueryInsertAPIKey = `INSERT INTO api_keys (prefix, secret_hash, user_id, username, name, permissions, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	QuerySelectAPIKeyByPrefix = `SELECT id, prefix, secret_hash, user_id, username, name, permissions, expires_at, last_used_at, last_used_ip, revoked, created_at FROM api_keys WHERE prefix = ?`
+
+	QuerySelectUserAPIKeys = `SELECT id, prefix, secret_hash, user_id, username, name, permissions, expires_at, last_used_at, last_used_ip, revoked, created_at FROM api_keys WHERE user_id = ? ORDER BY id DESC`
+
+	QueryTouchAPIKey = `UPDATE api_keys SET last_used_at = ?, last_used_ip = ? WHERE id = ?`
+
+	QueryRevokeAPIKey = `UPDATE api_keys SET revoked = 1 WHERE id = ? AND user_id = ? AND revoked = 0`
+
+	QueryInsertLoginAttempt = `INSERT INTO login_attempts (username, ip, user_agent, device, result, reason, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	QuerySelectLoginAttempts = `SELECT id, username, ip, user_agent, device, result, reason, created_at FROM login_attempts
+		WHERE (? = '' OR username = ?) AND (? = '' OR ip = ?) AND created_at >= ?
+		ORDER BY id DESC LIMIT ?`
+
+	QuerySelectLoginSources = `SELECT COUNT(*), COALESCE(SUM(ip = ?), 0), COALESCE(SUM(device = ?), 0) FROM login_attempts
+		WHERE username = ? AND result = ?`
+
+	QueryDeleteLoginAttemptsBefore = `DELETE FROM login_attempts WHERE created_at < ?`
+
+	QuerySelectLockout = `SELECT username, failures, last_failure_at, locked_until FROM account_lockouts WHERE username = ?`
+
+	QueryAddLoginFailure = `INSERT INTO account_lockouts (username, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT(username) DO UPDATE SET
+			failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING failures`
+
+	QueryLockAccount = `UPDATE account_lockouts SET locked_until = ? WHERE username = ?`
+
+	QueryDeleteLockout = `DELETE FROM account_lockouts WHERE username = ?`
+
+	QueryDeleteStaleLockouts = `DELETE FROM account_lockouts WHERE last_failure_at < ? AND locked_until < ?`
 )
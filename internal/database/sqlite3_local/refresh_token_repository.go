@@ -0,0 +1,134 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token already used")
+)
+
+// RevokedByRefreshReuse is recorded as the revoker of access tokens killed by
+// reuse detection.
+const RevokedByRefreshReuse = "REFRESH_REUSE"
+
+var _ RefreshTokenStore = (*RefreshTokenRepository)(nil)
+
+type RefreshTokenRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewRefreshTokenRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db:     localDB.DB,
+		logger: logger.Named("refresh_token_repository"),
+	}
+}
+
+func (r *RefreshTokenRepository) SaveRefreshToken(entry RefreshTokenEntity) error {
+	_, err := r.db.Exec(
+		QueryInsertRefreshToken,
+		entry.TokenHash, entry.FamilyID, entry.UserID, entry.Username, entry.AccessJTI, entry.AMR, entry.ExpiresAt,
+	)
+	return err
+}
+
+func (r *RefreshTokenRepository) GetRefreshToken(tokenHash string) (*RefreshTokenEntity, error) {
+	var (
+		e       RefreshTokenEntity
+		used    int
+		revoked int
+	)
+	err := r.db.QueryRow(QuerySelectRefreshToken, tokenHash).Scan(
+		&e.ID, &e.TokenHash, &e.FamilyID, &e.UserID, &e.Username, &e.AccessJTI, &e.AMR,
+		&used, &revoked, &e.ExpiresAt, &e.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	e.Used = used == 1
+	e.Revoked = revoked == 1
+	return &e, nil
+}
+
+// RotateRefreshToken marks the token as used and stores its successor in one
+// transaction. ErrRefreshTokenReused means the token was consumed concurrently
+// or is no longer valid; the caller must treat it as reuse.
+func (r *RefreshTokenRepository) RotateRefreshToken(
+	oldHash string,
+	next RefreshTokenEntity,
+	now int64,
+) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.Warn("rollback failed", zap.Error(rbErr))
+		}
+	}()
+
+	result, err := tx.Exec(QueryMarkRefreshTokenUsed, oldHash, now)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(
+		QueryInsertRefreshToken,
+		next.TokenHash, next.FamilyID, next.UserID, next.Username, next.AccessJTI, next.AMR, next.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshFamily revokes every refresh token of the family together with
+// the access tokens issued from it.
+func (r *RefreshTokenRepository) RevokeRefreshFamily(
+	familyID, byUsername string,
+) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.Warn("rollback failed", zap.Error(rbErr))
+		}
+	}()
+
+	if _, err := tx.Exec(QueryRevokeFamilyAccessTokens, byUsername, familyID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(QueryRevokeRefreshFamily, familyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *RefreshTokenRepository) GetFamilyByAccessJTI(jti string) (string, error) {
+	var familyID string
+	err := r.db.QueryRow(QuerySelectFamilyByAccessJTI, jti).Scan(&familyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrRefreshTokenNotFound
+	}
+	return familyID, err
+}
@@ -0,0 +1,63 @@
+package sqlite3_local
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+var _ TempIgnoreStore = (*TempIgnoreRepository)(nil)
+
+type TempIgnoreRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewTempIgnoreRepository(
+	localDB *LocalDB,
+	logger *zap.Logger,
+) *TempIgnoreRepository {
+	return &TempIgnoreRepository{
+		db:     localDB.DB,
+		logger: logger.Named("temp_ignore_repository"),
+	}
+}
+
+func (r *TempIgnoreRepository) SaveTempIgnore(entry TempIgnoreEntity) error {
+	_, err := r.db.Exec(QueryUpsertTempIgnore, entry.IP, entry.Jail, entry.Username, entry.ExpiresAt)
+	return err
+}
+
+func (r *TempIgnoreRepository) GetExpiredTempIgnores(now int64) ([]TempIgnoreEntity, error) {
+	return r.queryTempIgnores(QuerySelectExpiredTempIgnores, now)
+}
+
+func (r *TempIgnoreRepository) GetJailTempIgnores(jail string) ([]TempIgnoreEntity, error) {
+	return r.queryTempIgnores(QuerySelectJailTempIgnores, jail)
+}
+
+func (r *TempIgnoreRepository) DeleteTempIgnore(ip, jail string) error {
+	_, err := r.db.Exec(QueryDeleteTempIgnore, ip, jail)
+	return err
+}
+
+func (r *TempIgnoreRepository) queryTempIgnores(
+	query string,
+	args ...any,
+) ([]TempIgnoreEntity, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []TempIgnoreEntity
+	for rows.Next() {
+		var e TempIgnoreEntity
+		if err := rows.Scan(&e.IP, &e.Jail, &e.Username, &e.ExpiresAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -19,6 +19,8 @@ var (
 const (
 	jtiRandomBytesLen = 8
 	jtiFormat         = "%s_%d%s"
+
+	tokenTouchInterval = 60 // seconds
 )
 
 var _ TokenStore = (*TokenRepository)(nil)
@@ -52,9 +54,12 @@ func (r *TokenRepository) SaveToken(
 	return err
 }
 
-func (r *TokenRepository) SaveTokenExclusive(
-	jti, username string,
-	expiresAt int64,
+// SaveSession stores a login session with its client metadata. With exclusive
+// set, every other access and refresh token of the user is revoked first and
+// the number of revoked access tokens is returned.
+func (r *TokenRepository) SaveSession(
+	session TokenEntity,
+	exclusive bool,
 ) (int64, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -66,13 +71,24 @@ func (r *TokenRepository) SaveTokenExclusive(
 		}
 	}()
 
-	result, err := tx.Exec(QueryRevokeAllUserTokens, 0, "SYSTEM", username)
-	if err != nil {
-		return 0, err
+	var revokedCount int64
+	if exclusive {
+		result, err := tx.Exec(QueryRevokeAllUserTokens, 0, "SYSTEM", session.Username)
+		if err != nil {
+			return 0, err
+		}
+		revokedCount, _ = result.RowsAffected()
+
+		if _, err := tx.Exec(QueryRevokeUserRefreshTokens, session.Username); err != nil {
+			return 0, err
+		}
 	}
-	revokedCount, _ := result.RowsAffected()
 
-	_, err = tx.Exec(QueryInsertToken, jti, username, expiresAt)
+	_, err = tx.Exec(
+		QueryInsertSession,
+		session.JTI, session.Username, session.ExpiresAt,
+		session.IP, session.UserAgent, session.Device, session.LastSeenAt, session.AuthAt,
+	)
 	if err != nil {
 		return 0, err
 	}
@@ -80,6 +96,39 @@ func (r *TokenRepository) SaveTokenExclusive(
 	return revokedCount, tx.Commit()
 }
 
+func (r *TokenRepository) GetToken(jti string) (*TokenEntity, error) {
+	t, err := scanToken(r.db.QueryRow(QuerySelectToken, jti))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetUserTokens returns the user's sessions that are neither revoked nor expired.
+func (r *TokenRepository) GetUserTokens(
+	username string,
+	now int64,
+) ([]TokenEntity, error) {
+	rows, err := r.db.Query(QuerySelectUserActiveTokens, username, now)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tokens := make([]TokenEntity, 0)
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
 func (r *TokenRepository) ValidateToken(jti string) error {
 	var status TokenStatus
 	err := r.db.QueryRow(QuerySelectTokenValidation, jti).Scan(&status.Revoked, &status.ExpiresAt)
@@ -95,6 +144,32 @@ func (r *TokenRepository) ValidateToken(jti string) error {
 	return nil
 }
 
+// TouchToken records activity on a session. Updates are throttled to one
+// per tokenTouchInterval to keep the hot path read-mostly.
+func (r *TokenRepository) TouchToken(
+	jti string,
+	now int64,
+) error {
+	_, err := r.db.Exec(QueryTouchToken, now, jti, now-tokenTouchInterval)
+	return err
+}
+
+// SetAuthTime records that the session owner proved their identity again,
+// e.g. before a sensitive operation.
+func (r *TokenRepository) SetAuthTime(
+	jti string,
+	at int64,
+) error {
+	result, err := r.db.Exec(QuerySetTokenAuthTime, at, jti)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
 func (r *TokenRepository) RevokeToken(
 	jti string,
 	byID int,
@@ -111,6 +186,82 @@ func (r *TokenRepository) RevokeToken(
 	return nil
 }
 
+// RevokeUserToken revokes a session only if it belongs to username.
+func (r *TokenRepository) RevokeUserToken(
+	jti, username string,
+	byID int,
+	byUsername string,
+) error {
+	result, err := r.db.Exec(QueryRevokeUserToken, byID, byUsername, jti, username)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// RevokeAllUserTokens revokes every access and refresh token of the user and
+// returns the number of access tokens revoked.
+func (r *TokenRepository) RevokeAllUserTokens(
+	username string,
+	byID int,
+	byUsername string,
+) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.Warn("rollback failed", zap.Error(rbErr))
+		}
+	}()
+
+	result, err := tx.Exec(QueryRevokeAllUserTokens, byID, byUsername, username)
+	if err != nil {
+		return 0, err
+	}
+	revoked, _ := result.RowsAffected()
+
+	if _, err := tx.Exec(QueryRevokeUserRefreshTokens, username); err != nil {
+		return 0, err
+	}
+
+	return revoked, tx.Commit()
+}
+
+// RevokeOtherUserTokens is RevokeAllUserTokens except for the session keepJTI
+// and its refresh token family.
+func (r *TokenRepository) RevokeOtherUserTokens(
+	username, keepJTI string,
+	byID int,
+	byUsername string,
+) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.Warn("rollback failed", zap.Error(rbErr))
+		}
+	}()
+
+	result, err := tx.Exec(QueryRevokeOtherUserTokens, byID, byUsername, username, keepJTI)
+	if err != nil {
+		return 0, err
+	}
+	revoked, _ := result.RowsAffected()
+
+	if _, err := tx.Exec(QueryRevokeOtherUserRefreshTokens, username, keepJTI); err != nil {
+		return 0, err
+	}
+
+	return revoked, tx.Commit()
+}
+
 func (r *TokenRepository) GetAllTokens() ([]TokenEntity, error) {
 	rows, err := r.db.Query(QuerySelectAllTokens)
 	if err != nil {
@@ -120,18 +271,29 @@ func (r *TokenRepository) GetAllTokens() ([]TokenEntity, error) {
 
 	var tokens []TokenEntity
 	for rows.Next() {
-		var t TokenEntity
-		var revoked int
-		err := rows.Scan(
-			&t.ID, &t.JTI, &t.Username, &revoked,
-			&t.RevokedByID, &t.RevokedByUsername,
-			&t.ExpiresAt, &t.CreatedAt,
-		)
+		t, err := scanToken(rows)
 		if err != nil {
 			return nil, err
 		}
-		t.Revoked = revoked == 1
-		tokens = append(tokens, t)
+		tokens = append(tokens, *t)
 	}
 	return tokens, nil
 }
+
+func scanToken(row rowScanner) (*TokenEntity, error) {
+	var (
+		t       TokenEntity
+		revoked int
+	)
+	err := row.Scan(
+		&t.ID, &t.JTI, &t.Username, &revoked,
+		&t.RevokedByID, &t.RevokedByUsername,
+		&t.ExpiresAt, &t.CreatedAt,
+		&t.IP, &t.UserAgent, &t.Device, &t.LastSeenAt, &t.AuthAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.Revoked = revoked == 1
+	return &t, nil
+}
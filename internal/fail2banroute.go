@@ -11,11 +11,37 @@ import (
 func RegisterFail2BanRoutes(
 	rg *gin.RouterGroup,
 	h fail2ban.Handler,
+	stepUp gin.HandlerFunc,
 ) {
 	f2bGroup := rg.Group("/fail2ban")
 	{
 		f2bGroup.GET("/status", middleware.RequirePermission(auth.PermF2BViewStatus), h.GetStatus)
 		f2bGroup.GET("/jail", middleware.RequirePermission(auth.PermF2BViewJail), h.GetJailDetails)
-		f2bGroup.POST("/unban", middleware.RequirePermission(auth.PermF2BControlUnban), h.Unban)
+		f2bGroup.POST("/unban", middleware.RequirePermission(auth.PermF2BControlUnban), stepUp, h.Unban)
+
+		f2bGroup.GET("/server/ping", middleware.RequirePermission(auth.PermF2BViewServer), h.Ping)
+		f2bGroup.GET("/server/version", middleware.RequirePermission(auth.PermF2BViewServer), h.Version)
+		f2bGroup.POST("/reload", middleware.RequirePermission(auth.PermF2BControlReload), h.ReloadAll)
+		f2bGroup.POST("/jail/reload", middleware.RequirePermission(auth.PermF2BControlReload), h.ReloadJail)
+		f2bGroup.POST("/jail/start", middleware.RequirePermission(auth.PermF2BControlJailStart), h.StartJail)
+		f2bGroup.POST("/jail/stop", middleware.RequirePermission(auth.PermF2BControlJailStop), h.StopJail)
+
+		f2bGroup.POST("/regex/test", middleware.RequirePermission(auth.PermF2BRegexTest), h.TestRegex)
+
+		f2bGroup.GET("/events", middleware.RequirePermission(auth.PermF2BViewEvents), h.GetBanEvents)
+
+		f2bGroup.GET("/blocklist", middleware.RequirePermission(auth.PermF2BBlocklistExport), h.ExportBlocklist)
+		f2bGroup.POST("/blocklist/import", middleware.RequirePermission(auth.PermF2BBlocklistImport), stepUp, h.ImportBlocklist)
+
+		f2bGroup.POST("/self/unban", middleware.RequirePermission(auth.PermF2BSelfUnban), h.SelfUnban)
+
+		f2bGroup.GET("/blocks", middleware.RequirePermission(auth.PermF2BViewBlocks), h.ListBlocks)
+		f2bGroup.POST("/blocks/lift", middleware.RequirePermission(auth.PermF2BControlBlocks), stepUp, h.LiftBlock)
+
+		f2bGroup.GET("/config", middleware.RequirePermission(auth.PermF2BViewConfig), h.GetJailConfig)
+		f2bGroup.POST("/config/bantime", middleware.RequirePermission(auth.PermF2BConfigBanTime), h.SetBanTime)
+		f2bGroup.POST("/config/findtime", middleware.RequirePermission(auth.PermF2BConfigFindTime), h.SetFindTime)
+		f2bGroup.POST("/config/maxretry", middleware.RequirePermission(auth.PermF2BConfigMaxRetry), h.SetMaxRetry)
+		f2bGroup.POST("/config/ignoreip", middleware.RequirePermission(auth.PermF2BConfigIgnoreIP), h.UpdateIgnoreIP)
 	}
 }
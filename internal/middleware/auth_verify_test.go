@@ -1,12 +1,16 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"VPS-control/internal/apierror"
 	"VPS-control/internal/auth"
 	"VPS-control/internal/config"
 	"VPS-control/internal/database/sqlite3_local"
@@ -51,13 +55,42 @@ func setupAuthServices(t *testing.T) (*auth.AuthJwtService, *auth.AuthCookieServ
 	return jwtSvc, cookieSvc, tokenRepo
 }
 
+const testAPIKey = "vpsk_abcdefgh_SECRET"
+
+// fakeAPIKeys accepts only testAPIKey.
+type fakeAPIKeys struct{}
+
+func (fakeAPIKeys) Authenticate(_ context.Context, raw, _ string) (*auth.CustomClaims, error) {
+	if raw != testAPIKey {
+		return nil, apierror.Errors.API_KEY_INVALID
+	}
+	return &auth.CustomClaims{
+		UserID:      7,
+		Username:    "ci",
+		JTI:         "vpsk_abcdefgh",
+		Permissions: []string{auth.PermPM2ControlRestart},
+		AMR:         []string{auth.AMRAPIKey},
+	}, nil
+}
+
+// fakeAccess grants user 1 view and restart, every other user view only.
+type fakeAccess struct{}
+
+func (fakeAccess) Access(_ context.Context, userID int) (*auth.UserAccess, error) {
+	if userID == 1 {
+		return &auth.UserAccess{
+			Roles:       []string{"user"},
+			Permissions: []string{"pm2.view.basic", "pm2.control.restart"},
+		}, nil
+	}
+	return &auth.UserAccess{Roles: []string{"user"}, Permissions: []string{"pm2.view.basic"}}, nil
+}
+
 func testTokenData(username string) auth.TokenData {
 	return auth.TokenData{
-		UserID:      1,
-		Username:    username,
-		JTI:         "test-jti-" + username,
-		Roles:       []string{"user"},
-		Permissions: []string{"pm2.view.basic", "pm2.control.restart"},
+		UserID:   1,
+		Username: username,
+		JTI:      "test-jti-" + username,
 	}
 }
 
@@ -81,7 +114,7 @@ func TestAuthMiddleware_ValidCookie(t *testing.T) {
 		},
 	)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if c.IsAborted() {
@@ -135,7 +168,7 @@ func TestAuthMiddleware_ValidBearerToken(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 	c.Request.Header.Set("Authorization", "Bearer "+token)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if c.IsAborted() {
@@ -143,6 +176,63 @@ func TestAuthMiddleware_ValidBearerToken(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware_CSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
+
+	data := testTokenData("testuser")
+	data.CSRF = "csrf-secret"
+	token, _ := jwtSvc.GenerateToken(data)
+	_ = tokenRepo.SaveToken(data.JTI, data.Username, time.Now().Add(time.Hour).Unix())
+
+	legacy := testTokenData("legacy")
+	legacyToken, _ := jwtSvc.GenerateToken(legacy)
+	_ = tokenRepo.SaveToken(legacy.JTI, legacy.Username, time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name        string
+		method      string
+		token       string
+		bearer      bool
+		header      string
+		wantAborted bool
+	}{
+		{"cookie get without header", http.MethodGet, token, false, "", false},
+		{"cookie post with token", http.MethodPost, token, false, "csrf-secret", false},
+		{"cookie post without header", http.MethodPost, token, false, "", true},
+		{"cookie post with wrong token", http.MethodPost, token, false, "other", true},
+		{"cookie post on token without csrf", http.MethodPost, legacyToken, false, "", true},
+		{"bearer post without header", http.MethodPost, token, true, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(tt.method, "/", nil)
+				if tt.bearer {
+					c.Request.Header.Set("Authorization", "Bearer "+tt.token)
+				} else {
+					c.Request.AddCookie(&http.Cookie{Name: "test_token", Value: tt.token})
+				}
+				if tt.header != "" {
+					c.Request.Header.Set(auth.HeaderCSRFToken, tt.header)
+				}
+
+				AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
+
+				if c.IsAborted() != tt.wantAborted {
+					t.Fatalf("aborted = %v, want %v", c.IsAborted(), tt.wantAborted)
+				}
+				if tt.wantAborted && w.Code != http.StatusForbidden {
+					t.Errorf("status = %d, want 403", w.Code)
+				}
+			},
+		)
+	}
+}
+
 func TestAuthMiddleware_NoAuth(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
@@ -151,7 +241,7 @@ func TestAuthMiddleware_NoAuth(t *testing.T) {
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("GET", "/", nil)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if !c.IsAborted() {
@@ -168,7 +258,7 @@ func TestAuthMiddleware_InvalidToken(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 	c.Request.Header.Set("Authorization", "Bearer invalid.token.here")
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if !c.IsAborted() {
@@ -197,7 +287,7 @@ func TestAuthMiddleware_MalformedBearer(t *testing.T) {
 				c.Request = httptest.NewRequest("GET", "/", nil)
 				c.Request.Header.Set("Authorization", tt.header)
 
-				middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+				middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 				middleware(c)
 
 				if !c.IsAborted() {
@@ -247,7 +337,7 @@ func TestAuthMiddleware_ExpiredToken(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 	c.Request.Header.Set("Authorization", "Bearer "+token)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if !c.IsAborted() {
@@ -271,7 +361,7 @@ func TestAuthMiddleware_RevokedToken(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 	c.Request.Header.Set("Authorization", "Bearer "+token)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if !c.IsAborted() {
@@ -291,7 +381,7 @@ func TestAuthMiddleware_TokenNotInRepo(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 	c.Request.Header.Set("Authorization", "Bearer "+token)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	if !c.IsAborted() {
@@ -315,7 +405,7 @@ func TestRequirePermission(t *testing.T) {
 			c.Request = httptest.NewRequest("GET", "/", nil)
 			c.Request.Header.Set("Authorization", "Bearer "+token)
 
-			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())(c)
+			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
 			RequirePermission("pm2.view.basic")(c)
 
 			if c.IsAborted() {
@@ -327,11 +417,9 @@ func TestRequirePermission(t *testing.T) {
 	t.Run(
 		"no permission", func(t *testing.T) {
 			data := auth.TokenData{
-				UserID:      2,
-				Username:    "testuser2",
-				JTI:         "test-jti-user2",
-				Roles:       []string{"user"},
-				Permissions: []string{"pm2.view.basic"},
+				UserID:   2,
+				Username: "testuser2",
+				JTI:      "test-jti-user2",
 			}
 			token, _ := jwtSvc.GenerateToken(data)
 			expiresAt := time.Now().Add(time.Hour).Unix()
@@ -342,7 +430,7 @@ func TestRequirePermission(t *testing.T) {
 			c.Request = httptest.NewRequest("GET", "/", nil)
 			c.Request.Header.Set("Authorization", "Bearer "+token)
 
-			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())(c)
+			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
 			RequirePermission("f2b.control.unban")(c)
 
 			if !c.IsAborted() {
@@ -382,7 +470,7 @@ func TestRequireAnyPermission(t *testing.T) {
 			c.Request = httptest.NewRequest("GET", "/", nil)
 			c.Request.Header.Set("Authorization", "Bearer "+token)
 
-			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())(c)
+			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
 			RequireAnyPermission("f2b.view.status", "pm2.view.basic")(c)
 
 			if c.IsAborted() {
@@ -394,11 +482,9 @@ func TestRequireAnyPermission(t *testing.T) {
 	t.Run(
 		"has none of permissions", func(t *testing.T) {
 			data := auth.TokenData{
-				UserID:      3,
-				Username:    "testuser3",
-				JTI:         "test-jti-user3",
-				Roles:       []string{"user"},
-				Permissions: []string{"pm2.view.basic"},
+				UserID:   3,
+				Username: "testuser3",
+				JTI:      "test-jti-user3",
 			}
 			token, _ := jwtSvc.GenerateToken(data)
 			expiresAt := time.Now().Add(time.Hour).Unix()
@@ -409,7 +495,7 @@ func TestRequireAnyPermission(t *testing.T) {
 			c.Request = httptest.NewRequest("GET", "/", nil)
 			c.Request.Header.Set("Authorization", "Bearer "+token)
 
-			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())(c)
+			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
 			RequireAnyPermission("f2b.view.status", "user.delete")(c)
 
 			if !c.IsAborted() {
@@ -435,7 +521,7 @@ func TestRequireRole(t *testing.T) {
 			c.Request = httptest.NewRequest("GET", "/", nil)
 			c.Request.Header.Set("Authorization", "Bearer "+token)
 
-			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())(c)
+			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
 			RequireRole("user")(c)
 
 			if c.IsAborted() {
@@ -447,11 +533,9 @@ func TestRequireRole(t *testing.T) {
 	t.Run(
 		"no role", func(t *testing.T) {
 			data := auth.TokenData{
-				UserID:      4,
-				Username:    "testuser4",
-				JTI:         "test-jti-user4",
-				Roles:       []string{"user"},
-				Permissions: []string{"pm2.view.basic"},
+				UserID:   4,
+				Username: "testuser4",
+				JTI:      "test-jti-user4",
 			}
 			token, _ := jwtSvc.GenerateToken(data)
 			expiresAt := time.Now().Add(time.Hour).Unix()
@@ -462,7 +546,7 @@ func TestRequireRole(t *testing.T) {
 			c.Request = httptest.NewRequest("GET", "/", nil)
 			c.Request.Header.Set("Authorization", "Bearer "+token)
 
-			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())(c)
+			AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())(c)
 			RequireRole("admin")(c)
 
 			if !c.IsAborted() {
@@ -624,18 +708,14 @@ func TestAuthMiddleware_CookiePriorityOverHeader(t *testing.T) {
 	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
 
 	cookieData := auth.TokenData{
-		UserID:      1,
-		Username:    "cookie_user",
-		JTI:         "cookie-jti",
-		Roles:       []string{"user"},
-		Permissions: []string{"pm2.view.basic"},
+		UserID:   1,
+		Username: "cookie_user",
+		JTI:      "cookie-jti",
 	}
 	headerData := auth.TokenData{
-		UserID:      2,
-		Username:    "header_user",
-		JTI:         "header-jti",
-		Roles:       []string{"admin"},
-		Permissions: []string{"admin.all"},
+		UserID:   2,
+		Username: "header_user",
+		JTI:      "header-jti",
 	}
 
 	cookieToken, _ := jwtSvc.GenerateToken(cookieData)
@@ -656,7 +736,7 @@ func TestAuthMiddleware_CookiePriorityOverHeader(t *testing.T) {
 	)
 	c.Request.Header.Set("Authorization", "Bearer "+headerToken)
 
-	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, zap.NewNop())
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
 	middleware(c)
 
 	username, _ := c.Get(auth.CtxUsername)
@@ -664,3 +744,245 @@ func TestAuthMiddleware_CookiePriorityOverHeader(t *testing.T) {
 		t.Errorf("username = %v, want 'cookie_user' (cookie should have priority)", username)
 	}
 }
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		aborted bool
+	}{
+		{"x-api-key header", auth.HeaderAPIKey, testAPIKey, false},
+		{"bearer api key", "Authorization", "Bearer " + testAPIKey, false},
+		{"unknown x-api-key", auth.HeaderAPIKey, "vpsk_abcdefgh_WRONG", true},
+		{"unknown bearer api key", "Authorization", "Bearer vpsk_abcdefgh_WRONG", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest("GET", "/", nil)
+				c.Request.Header.Set(tt.header, tt.value)
+
+				middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
+				middleware(c)
+
+				if c.IsAborted() != tt.aborted {
+					t.Fatalf("aborted = %v, want %v", c.IsAborted(), tt.aborted)
+				}
+				if tt.aborted {
+					if w.Code != http.StatusUnauthorized {
+						t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+					}
+					return
+				}
+
+				if !auth.IsAPIKeyRequest(c) {
+					t.Error("request should be marked as API key request")
+				}
+				claims, ok := auth.GetClaims(c)
+				if !ok || claims.Username != "ci" || !claims.HasPermission(auth.PermPM2ControlRestart) {
+					t.Errorf("claims = %+v, want ci with pm2.control.restart", claims)
+				}
+			},
+		)
+	}
+}
+
+func TestAuthMiddleware_TouchesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
+
+	data := testTokenData("touchuser")
+	token, _ := jwtSvc.GenerateToken(data)
+	_, err := tokenRepo.SaveSession(
+		sqlite3_local.TokenEntity{
+			JTI:       data.JTI,
+			Username:  data.Username,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Device:    "Work laptop",
+		}, false,
+	)
+	if err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+token)
+
+	middleware := AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop())
+	middleware(c)
+
+	if c.IsAborted() {
+		t.Fatal("valid session should not abort")
+	}
+
+	session, err := tokenRepo.GetToken(data.JTI)
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if session.LastSeenAt == 0 {
+		t.Error("last_seen_at should be updated")
+	}
+	if session.Device != "Work laptop" {
+		t.Errorf("device = %q, want %q", session.Device, "Work laptop")
+	}
+}
+
+// accessFunc adapts a function to auth.AccessResolver.
+type accessFunc func(userID int) (*auth.UserAccess, error)
+
+func (f accessFunc) Access(_ context.Context, userID int) (*auth.UserAccess, error) {
+	return f(userID)
+}
+
+func TestAuthMiddleware_PermissionsFromResolver(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
+
+	data := testTokenData("resolveduser")
+	token, _ := jwtSvc.GenerateToken(data)
+	_ = tokenRepo.SaveToken(data.JTI, data.Username, time.Now().Add(time.Hour).Unix())
+
+	run := func(access auth.AccessResolver) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, access, zap.NewNop())(c)
+		if !c.IsAborted() {
+			RequirePermission("pm2.control.restart")(c)
+		}
+		return c
+	}
+
+	t.Run(
+		"granted", func(t *testing.T) {
+			if c := run(fakeAccess{}); c.IsAborted() {
+				t.Error("should not abort while the user holds the permission")
+			}
+		},
+	)
+
+	t.Run(
+		"revoked after login", func(t *testing.T) {
+			revoked := accessFunc(
+				func(int) (*auth.UserAccess, error) {
+					return &auth.UserAccess{Roles: []string{"user"}}, nil
+				},
+			)
+			if c := run(revoked); !c.IsAborted() {
+				t.Error("the same token should be refused once the permission is gone")
+			}
+		},
+	)
+
+	t.Run(
+		"store error", func(t *testing.T) {
+			failing := accessFunc(
+				func(int) (*auth.UserAccess, error) {
+					return nil, errors.New("db down")
+				},
+			)
+			c := run(failing)
+			if !c.IsAborted() {
+				t.Error("should abort when permissions cannot be loaded")
+			}
+			if _, ok := c.Get(auth.CtxClaims); ok {
+				t.Error("claims must not be set without permissions")
+			}
+		},
+	)
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, _, tokenRepo := setupAuthServices(t)
+
+	now := time.Now()
+	expiresAt := now.Add(time.Hour).Unix()
+	save := func(jti string, authAt int64) {
+		_, err := tokenRepo.SaveSession(
+			sqlite3_local.TokenEntity{JTI: jti, Username: "stepuser", ExpiresAt: expiresAt, AuthAt: authAt}, false,
+		)
+		if err != nil {
+			t.Fatalf("SaveSession: %v", err)
+		}
+	}
+	save("fresh", now.Add(-time.Minute).Unix())
+	save("stale", now.Add(-time.Hour).Unix())
+	save("reauthed", now.Add(-time.Hour).Unix())
+	_ = tokenRepo.SaveToken("legacy", "stepuser", expiresAt)
+
+	if err := tokenRepo.SetAuthTime("reauthed", now.Unix()); err != nil {
+		t.Fatalf("SetAuthTime: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		jti        string
+		apiKey     bool
+		wantStatus int
+	}{
+		{"recent login", "fresh", false, http.StatusOK},
+		{"stale session", "stale", false, http.StatusForbidden},
+		{"re-authenticated", "reauthed", false, http.StatusOK},
+		{"session without auth time", "legacy", false, http.StatusForbidden},
+		{"unknown session", "missing", false, http.StatusUnauthorized},
+		{"api key", "vpsk_abcdefgh", true, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest("POST", "/", nil)
+				c.Set(auth.CtxJTI, tt.jti)
+				if tt.apiKey {
+					c.Set(auth.CtxAPIKey, true)
+				}
+
+				RequireRecentAuth(tokenRepo, 5*time.Minute)(c)
+
+				if got := c.IsAborted(); got != (tt.wantStatus != http.StatusOK) {
+					t.Fatalf("aborted = %v, want status %d", got, tt.wantStatus)
+				}
+				if c.IsAborted() && w.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+			},
+		)
+	}
+}
+
+// TestRequireRecentAuth_APIKey: a key minted with a stolen session carries no
+// re-authentication, so it must not open a step-up route.
+func TestRequireRecentAuth_APIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtSvc, cookieSvc, tokenRepo := setupAuthServices(t)
+
+	reached := false
+	r := gin.New()
+	r.POST(
+		"/stop",
+		AuthMiddleware(jwtSvc, cookieSvc, tokenRepo, fakeAPIKeys{}, fakeAccess{}, zap.NewNop()),
+		RequireRecentAuth(tokenRepo, 5*time.Minute),
+		func(c *gin.Context) { reached = true },
+	)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/stop", nil)
+	req.Header.Set(auth.HeaderAPIKey, testAPIKey)
+	r.ServeHTTP(w, req)
+
+	if reached || w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "API_KEY_NOT_ALLOWED") {
+		t.Errorf("reached = %v, status = %d, body = %s", reached, w.Code, w.Body)
+	}
+}
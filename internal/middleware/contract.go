@@ -5,3 +5,10 @@ import "github.com/gin-gonic/gin"
 type Sanitizer interface {
 	Middleware() gin.HandlerFunc
 }
+
+type Escalator interface {
+	RecordDetection(
+		ip, source, path string,
+	)
+	IsBlocked(ip string) bool
+}
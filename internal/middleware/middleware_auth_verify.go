@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
 	"strings"
+	"time"
 
 	"VPS-control/internal/apierror"
 	"VPS-control/internal/auth"
@@ -11,16 +15,29 @@ import (
 	"go.uber.org/zap"
 )
 
+// AuthMiddleware accepts a session JWT from the cookie or the Authorization
+// header, and API keys from X-API-Key or Authorization: Bearer vpsk_...
+// Session tokens carry identity only; roles and permissions come from access.
+// A browser sends the cookie on its own, so a mutating request authenticated
+// by it must also carry the session's CSRF token in X-CSRF-Token.
 func AuthMiddleware(
 	jwtService auth.JwtProvider,
 	cookieService auth.SetAuthCookie,
 	tokenRepo sqlite3_local.TokenStore,
+	apiKeys auth.APIKeyAuthenticator,
+	access auth.AccessResolver,
 	logger *zap.Logger,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(auth.HeaderAPIKey); key != "" {
+			authenticateAPIKey(c, apiKeys, key, logger)
+			return
+		}
+
 		token, err := cookieService.GetAuthCookie(c)
+		fromCookie := err == nil && token != ""
 
-		if err != nil || token == "" {
+		if !fromCookie {
 			authHeader := c.GetHeader("Authorization")
 			if authHeader == "" {
 				apierror.Abort(c, apierror.Errors.PERMISSION_DENIED)
@@ -33,6 +50,11 @@ func AuthMiddleware(
 				return
 			}
 			token = parts[1]
+
+			if auth.IsAPIKey(token) {
+				authenticateAPIKey(c, apiKeys, token, logger)
+				return
+			}
 		}
 
 		if token == "" || strings.Count(token, ".") != 2 {
@@ -51,22 +73,86 @@ func AuthMiddleware(
 			return
 		}
 
+		if fromCookie && !validCSRF(c, claims) {
+			logger.Warn(
+				"CSRF token rejected",
+				zap.String("username", claims.Username),
+				zap.String("ip", c.ClientIP()),
+				zap.String("path", c.FullPath()),
+			)
+			apierror.Abort(c, apierror.Errors.CSRF_TOKEN_INVALID)
+			return
+		}
+
 		if err := tokenRepo.ValidateToken(claims.JTI); err != nil {
 			apierror.Abort(c, apierror.Errors.TOKEN_EXPIRED)
 			return
 		}
+		if err := tokenRepo.TouchToken(claims.JTI, time.Now().Unix()); err != nil {
+			logger.Warn("Failed to update session last seen", zap.String("jti", claims.JTI), zap.Error(err))
+		}
 
-		c.Set(auth.CtxUsername, claims.Username)
-		c.Set(auth.CtxUserID, claims.UserID)
-		c.Set(auth.CtxJTI, claims.JTI)
-		c.Set(auth.CtxRoles, claims.Roles)
-		c.Set(auth.CtxPermissions, claims.Permissions)
-		c.Set(auth.CtxClaims, claims)
+		userAccess, err := access.Access(c.Request.Context(), claims.UserID)
+		if err != nil {
+			logger.Error("Failed to load user permissions", zap.Int("user_id", claims.UserID), zap.Error(err))
+			apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+			return
+		}
+		claims.Roles = userAccess.Roles
+		claims.Permissions = userAccess.Permissions
 
+		setClaims(c, claims)
 		c.Next()
 	}
 }
 
+func authenticateAPIKey(
+	c *gin.Context,
+	apiKeys auth.APIKeyAuthenticator,
+	key string,
+	logger *zap.Logger,
+) {
+	claims, err := apiKeys.Authenticate(c.Request.Context(), key, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, apierror.Errors.API_KEY_INVALID) {
+			logger.Debug("API key rejected", zap.String("ip", c.ClientIP()))
+		}
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.Set(auth.CtxAPIKey, true)
+	setClaims(c, claims)
+	c.Next()
+}
+
+// validCSRF passes safe methods and otherwise compares the header with the
+// token bound into the session JWT. The JWT cookie is HttpOnly, so another
+// site can neither read nor plant the expected value.
+func validCSRF(
+	c *gin.Context,
+	claims *auth.CustomClaims,
+) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	header := c.GetHeader(auth.HeaderCSRFToken)
+	if header == "" || claims.CSRF == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header), []byte(claims.CSRF)) == 1
+}
+
+func setClaims(c *gin.Context, claims *auth.CustomClaims) {
+	c.Set(auth.CtxUsername, claims.Username)
+	c.Set(auth.CtxUserID, claims.UserID)
+	c.Set(auth.CtxJTI, claims.JTI)
+	c.Set(auth.CtxRoles, claims.Roles)
+	c.Set(auth.CtxPermissions, claims.Permissions)
+	c.Set(auth.CtxClaims, claims)
+}
+
 func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, ok := auth.GetClaims(c)
@@ -84,6 +170,45 @@ func RequirePermission(permission string) gin.HandlerFunc {
 	}
 }
 
+// RequireRecentAuth guards sensitive operations: the session must have been
+// authenticated within window, at login or through /auth/reauth. Refreshing
+// a session does not count. API keys are refused: a key cannot re-authenticate,
+// and one minted with a stolen session would otherwise skip the check.
+func RequireRecentAuth(
+	tokenRepo sqlite3_local.TokenStore,
+	window time.Duration,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.IsAPIKeyRequest(c) {
+			apierror.Abort(c, apierror.Errors.API_KEY_NOT_ALLOWED)
+			return
+		}
+
+		jti, ok := auth.GetJTI(c)
+		if !ok {
+			apierror.Abort(c, apierror.Errors.PERMISSION_DENIED)
+			return
+		}
+
+		session, err := tokenRepo.GetToken(jti)
+		if err != nil {
+			if errors.Is(err, sqlite3_local.ErrTokenNotFound) {
+				apierror.Abort(c, apierror.Errors.TOKEN_EXPIRED)
+				return
+			}
+			apierror.Abort(c, apierror.Errors.DATABASE_ERROR.Wrap(err))
+			return
+		}
+
+		if time.Since(time.Unix(session.AuthAt, 0)) > window {
+			apierror.Abort(c, apierror.Errors.REAUTH_REQUIRED.WithMeta(gin.H{"window_seconds": int(window.Seconds())}))
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // RequireAnyPermission возвращена в файл
 func RequireAnyPermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
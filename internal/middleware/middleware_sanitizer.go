@@ -12,16 +12,34 @@ import (
 	"go.uber.org/zap"
 )
 
+// Why isMalicious rejected an input. Only pattern matches count as attacks;
+// an oversized input is rejected but not escalated.
+const (
+	matchOversize = "oversize"
+	matchPath     = "path_traversal"
+	matchSQL      = "sql_injection"
+	matchShell    = "shell_injection"
+)
+
+// maxInputLength caps every checked value, the request body included.
+const maxInputLength = 10000
+
 type InputSanitizer struct {
-	logger  *zap.Logger
-	sqlRe   *regexp.Regexp
-	shellRe *regexp.Regexp
-	pathRe  *regexp.Regexp
+	logger    *zap.Logger
+	escalator Escalator
+	sqlRe     *regexp.Regexp
+	shellRe   *regexp.Regexp
+	pathRe    *regexp.Regexp
 }
 
-func NewInputSanitizer(logger *zap.Logger) *InputSanitizer {
+// NewInputSanitizer creates the sanitizer; escalator may be nil to only log detections.
+func NewInputSanitizer(
+	logger *zap.Logger,
+	escalator Escalator,
+) *InputSanitizer {
 	return &InputSanitizer{
-		logger: logger.Named("input-sanitizer"),
+		logger:    logger.Named("input-sanitizer"),
+		escalator: escalator,
 
 		sqlRe: regexp.MustCompile(`(?i)\b(SELECT|INSERT|UPDATE|DELETE|DROP|UNION|ALTER|TRUNCATE|EXEC|EXECUTE|DECLARE|CAST)\b.*\b(FROM|INTO|TABLE|WHERE|SET|VALUES)\b|--|\b(OR|AND)\b\s+\d+\s*=\s*\d+|'\s*(OR|AND)\s*'`),
 
@@ -33,9 +51,14 @@ func NewInputSanitizer(logger *zap.Logger) *InputSanitizer {
 
 func (s *InputSanitizer) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if s.escalator != nil && s.escalator.IsBlocked(c.ClientIP()) {
+			apierror.Abort(c, apierror.Errors.IP_BLOCKED)
+			return
+		}
+
 		for _, param := range c.Params {
-			if s.isMalicious(param.Value) {
-				s.logAttempt(c, "path_param", param.Key, param.Value)
+			if match, bad := s.isMalicious(param.Value); bad {
+				s.logAttempt(c, "path_param", param.Key, param.Value, match)
 				apierror.Abort(c, apierror.Errors.MALICIOUS_INPUT_DETECTED)
 				return
 			}
@@ -43,8 +66,8 @@ func (s *InputSanitizer) Middleware() gin.HandlerFunc {
 
 		for key, values := range c.Request.URL.Query() {
 			for _, val := range values {
-				if s.isMalicious(val) {
-					s.logAttempt(c, "query_param", key, val)
+				if match, bad := s.isMalicious(val); bad {
+					s.logAttempt(c, "query_param", key, val, match)
 					apierror.Abort(c, apierror.Errors.MALICIOUS_INPUT_DETECTED)
 					return
 				}
@@ -57,8 +80,8 @@ func (s *InputSanitizer) Middleware() gin.HandlerFunc {
 				c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
 
 				bodyStr := string(body)
-				if s.isMalicious(bodyStr) {
-					s.logAttempt(c, "body", "", bodyStr)
+				if match, bad := s.isMalicious(bodyStr); bad {
+					s.logAttempt(c, "body", "", bodyStr, match)
 					apierror.Abort(c, apierror.Errors.MALICIOUS_INPUT_DETECTED)
 					return
 				}
@@ -66,10 +89,12 @@ func (s *InputSanitizer) Middleware() gin.HandlerFunc {
 		}
 
 		for _, header := range []string{"X-Forwarded-For", "Referer", "User-Agent"} {
-			if val := c.GetHeader(header); val != "" && s.isMalicious(val) {
-				s.logAttempt(c, "header", header, val)
-				apierror.Abort(c, apierror.Errors.MALICIOUS_INPUT_DETECTED)
-				return
+			if val := c.GetHeader(header); val != "" {
+				if match, bad := s.isMalicious(val); bad {
+					s.logAttempt(c, "header", header, val, match)
+					apierror.Abort(c, apierror.Errors.MALICIOUS_INPUT_DETECTED)
+					return
+				}
 			}
 		}
 
@@ -77,21 +102,26 @@ func (s *InputSanitizer) Middleware() gin.HandlerFunc {
 	}
 }
 
-func (s *InputSanitizer) isMalicious(input string) bool {
-	if input == "" || len(input) > 10000 {
-		return len(input) > 10000
+// isMalicious reports whether input must be rejected and which check matched.
+func (s *InputSanitizer) isMalicious(input string) (string, bool) {
+	switch {
+	case input == "":
+		return "", false
+	case len(input) > maxInputLength:
+		return matchOversize, true
+	case s.pathRe.MatchString(strings.ToLower(input)):
+		return matchPath, true
+	case s.sqlRe.MatchString(input):
+		return matchSQL, true
+	case s.shellRe.MatchString(input):
+		return matchShell, true
 	}
-
-	normalized := strings.ToLower(input)
-
-	return s.pathRe.MatchString(normalized) ||
-		s.sqlRe.MatchString(input) ||
-		s.shellRe.MatchString(input)
+	return "", false
 }
 
 func (s *InputSanitizer) logAttempt(
 	c *gin.Context,
-	source, key, value string,
+	source, key, value, match string,
 ) {
 	if len(value) > 200 {
 		value = value[:200] + "..."
@@ -104,6 +134,11 @@ func (s *InputSanitizer) logAttempt(
 		zap.String("path", c.Request.URL.Path),
 		zap.String("source", source),
 		zap.String("key", key),
+		zap.String("match", match),
 		zap.String("value", value),
 	)
+
+	if s.escalator != nil && match != matchOversize {
+		s.escalator.RecordDetection(c.ClientIP(), source, c.Request.URL.Path)
+	}
 }
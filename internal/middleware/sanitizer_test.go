@@ -2,13 +2,17 @@
 package middleware
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 func TestIsMalicious(t *testing.T) {
-	s := NewInputSanitizer(zap.NewNop())
+	s := NewInputSanitizer(zap.NewNop(), nil)
 
 	malicious := []struct {
 		name  string
@@ -39,7 +43,7 @@ func TestIsMalicious(t *testing.T) {
 	for _, tt := range malicious {
 		t.Run(
 			"block_"+tt.name, func(t *testing.T) {
-				if !s.isMalicious(tt.input) {
+				if _, bad := s.isMalicious(tt.input); !bad {
 					t.Errorf("should block: %q", tt.input)
 				}
 			},
@@ -60,12 +64,13 @@ func TestIsMalicious(t *testing.T) {
 		{"uuid", "550e8400-e29b-41d4-a716-446655440000"},
 		{"json_simple", `{"name": "test", "value": 123}`},
 		{"url_path", "/api/vps/pm2/processes"},
+		{"base64_regex", `{"failregex_b64":"XiUoX19wcmVmaXhfbGluZSlzRmFpbGVkIHBhc3N3b3JkIGZvciAuKiBmcm9tIDxIT1NUPiAtLSAkKGlkKQ=="}`},
 	}
 
 	for _, tt := range legitimate {
 		t.Run(
 			"allow_"+tt.name, func(t *testing.T) {
-				if s.isMalicious(tt.input) {
+				if _, bad := s.isMalicious(tt.input); bad {
 					t.Errorf("should allow: %q", tt.input)
 				}
 			},
@@ -74,19 +79,74 @@ func TestIsMalicious(t *testing.T) {
 }
 
 func TestIsMalicious_LongInput(t *testing.T) {
-	s := NewInputSanitizer(zap.NewNop())
+	s := NewInputSanitizer(zap.NewNop(), nil)
 	input10k := make([]byte, 10000)
 	for i := range input10k {
 		input10k[i] = 'a'
 	}
-	if s.isMalicious(string(input10k)) {
+	if _, bad := s.isMalicious(string(input10k)); bad {
 		t.Error("10000 chars should be allowed")
 	}
 	input10001 := make([]byte, 10001)
 	for i := range input10001 {
 		input10001[i] = 'a'
 	}
-	if !s.isMalicious(string(input10001)) {
-		t.Error("10001 chars should be blocked")
+	if match, bad := s.isMalicious(string(input10001)); !bad || match != matchOversize {
+		t.Errorf("10001 chars should be blocked as oversize, got %q", match)
+	}
+}
+
+type fakeEscalator struct {
+	blocked    map[string]bool
+	detections []string
+}
+
+func (e *fakeEscalator) RecordDetection(ip, source, path string) {
+	e.detections = append(e.detections, ip+" "+source+" "+path)
+}
+
+func (e *fakeEscalator) IsBlocked(ip string) bool {
+	return e.blocked[ip]
+}
+
+func TestInputSanitizer_Escalation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	esc := &fakeEscalator{blocked: map[string]bool{"203.0.113.7": true}}
+	s := NewInputSanitizer(zap.NewNop(), esc)
+
+	r := gin.New()
+	r.Use(s.Middleware())
+	r.GET("/api/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		query      string
+		wantStatus int
+		wantHits   int
+	}{
+		{"blocked ip", "203.0.113.7:1234", "", http.StatusForbidden, 0},
+		{"clean request", "198.51.100.1:1234", "", http.StatusOK, 0},
+		{"malicious request recorded", "198.51.100.1:1234", "?q=..%2f..%2fetc%2fpasswd", http.StatusBadRequest, 1},
+		{"oversized request not recorded", "198.51.100.1:1234", "?q=" + strings.Repeat("a", 10001), http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				esc.detections = nil
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/api/test"+tt.query, nil)
+				req.RemoteAddr = tt.remoteAddr
+				r.ServeHTTP(w, req)
+
+				if w.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+				if len(esc.detections) != tt.wantHits {
+					t.Errorf("detections = %v, want %d", esc.detections, tt.wantHits)
+				}
+			},
+		)
 	}
 }
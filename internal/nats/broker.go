@@ -97,3 +97,43 @@ func QueueSubscribe[T any](
 		},
 	)
 }
+
+// ReplyError is sent back when a request cannot be decoded.
+type ReplyError struct {
+	Error string `json:"error"`
+}
+
+// Reply serves request/reply on subject, spread over the members of queue.
+// Requests and replies are plain JSON, not EventPayload, so any NATS client
+// can call it with a single request.
+func Reply[Req, Resp any](
+	b Broker,
+	subject string,
+	queue string,
+	handler func(req Req) Resp,
+	logger *zap.Logger,
+) (*nats.Subscription, error) {
+	return b.GetConn().QueueSubscribe(
+		subject, queue, func(msg *nats.Msg) {
+			var (
+				req   Req
+				reply any
+			)
+			if err := json.Unmarshal(msg.Data, &req); err != nil {
+				logger.Warn("malformed request", zap.String("subject", subject), zap.Error(err))
+				reply = ReplyError{Error: "invalid_request"}
+			} else {
+				reply = handler(req)
+			}
+
+			data, err := json.Marshal(reply)
+			if err != nil {
+				logger.Error("marshal reply failed", zap.String("subject", subject), zap.Error(err))
+				return
+			}
+			if err := msg.Respond(data); err != nil {
+				logger.Warn("reply failed", zap.String("subject", subject), zap.Error(err))
+			}
+		},
+	)
+}
@@ -0,0 +1,143 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"VPS-control/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+	argon2Prefix  = "$argon2id$"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher creates hashes with the configured algorithm and verifies hashes of
+// either algorithm, so stored passwords keep working after a switch and are
+// upgraded on the next successful login (see NeedsRehash).
+//
+// Argon2id hashes use the PHC string format:
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+type Hasher struct {
+	cfg config.PasswordHashConfig
+}
+
+func NewHasher(cfg config.PasswordHashConfig) *Hasher {
+	return &Hasher{cfg: cfg}
+}
+
+func (h *Hasher) Hash(raw string) (string, error) {
+	if h.cfg.Algorithm == AlgArgon2id {
+		return h.hashArgon2(raw)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(raw), h.cfg.BcryptCost)
+	return string(hash), err
+}
+
+// Verify reports whether raw matches hash. A malformed hash is an error, a
+// wrong password is not.
+func (h *Hasher) Verify(
+	hash, raw string,
+) (bool, error) {
+	if strings.HasPrefix(hash, argon2Prefix) {
+		params, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		got := argon2.IDKey([]byte(raw), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(got, key) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(raw))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	}
+	return false, err
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// parameters than the configured ones.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, argon2Prefix) {
+		if h.cfg.Algorithm != AlgArgon2id {
+			return true
+		}
+		params, _, _, err := parseArgon2(hash)
+		return err != nil ||
+			params.time != h.cfg.Argon2Time ||
+			params.memory != h.cfg.Argon2Memory ||
+			params.threads != h.cfg.Argon2Threads
+	}
+
+	if h.cfg.Algorithm == AlgArgon2id {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cfg.BcryptCost
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func (h *Hasher) hashArgon2(raw string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(raw), salt, h.cfg.Argon2Time, h.cfg.Argon2Memory, h.cfg.Argon2Threads, argon2KeyLen)
+
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix, argon2.Version, h.cfg.Argon2Memory, h.cfg.Argon2Time, h.cfg.Argon2Threads,
+		enc.EncodeToString(salt), enc.EncodeToString(key),
+	), nil
+}
+
+func parseArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var (
+		params  argon2Params
+		version int
+	)
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads)
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := enc.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"VPS-control/internal/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testHashConfig(algorithm string) config.PasswordHashConfig {
+	return config.PasswordHashConfig{
+		Algorithm:     algorithm,
+		BcryptCost:    bcrypt.MinCost,
+		Argon2Time:    1,
+		Argon2Memory:  1024,
+		Argon2Threads: 1,
+	}
+}
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	for _, alg := range []string{AlgBcrypt, AlgArgon2id} {
+		t.Run(
+			alg, func(t *testing.T) {
+				h := NewHasher(testHashConfig(alg))
+
+				hash, err := h.Hash("correct horse")
+				if err != nil {
+					t.Fatalf("Hash() error: %v", err)
+				}
+				if alg == AlgArgon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+					t.Errorf("hash = %q, want PHC argon2id format", hash)
+				}
+
+				if ok, err := h.Verify(hash, "correct horse"); err != nil || !ok {
+					t.Errorf("Verify(right) = %v, %v; want true", ok, err)
+				}
+				if ok, err := h.Verify(hash, "wrong horse"); err != nil || ok {
+					t.Errorf("Verify(wrong) = %v, %v; want false", ok, err)
+				}
+				if h.NeedsRehash(hash) {
+					t.Error("a fresh hash must not need a rehash")
+				}
+			},
+		)
+	}
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	bcryptHash, _ := NewHasher(testHashConfig(AlgBcrypt)).Hash("secret")
+	argonHash, _ := NewHasher(testHashConfig(AlgArgon2id)).Hash("secret")
+
+	higherCost := testHashConfig(AlgBcrypt)
+	higherCost.BcryptCost = bcrypt.MinCost + 1
+	moreMemory := testHashConfig(AlgArgon2id)
+	moreMemory.Argon2Memory = 2048
+
+	tests := []struct {
+		name string
+		cfg  config.PasswordHashConfig
+		hash string
+		want bool
+	}{
+		{"bcrypt to argon2id", testHashConfig(AlgArgon2id), bcryptHash, true},
+		{"argon2id to bcrypt", testHashConfig(AlgBcrypt), argonHash, true},
+		{"bcrypt cost raised", higherCost, bcryptHash, true},
+		{"argon2 memory raised", moreMemory, argonHash, true},
+		{"unchanged bcrypt", testHashConfig(AlgBcrypt), bcryptHash, false},
+		{"unchanged argon2id", testHashConfig(AlgArgon2id), argonHash, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := NewHasher(tt.cfg).NeedsRehash(tt.hash); got != tt.want {
+					t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestHasher_VerifyAcrossAlgorithms(t *testing.T) {
+	bcryptHash, _ := NewHasher(testHashConfig(AlgBcrypt)).Hash("secret")
+	h := NewHasher(testHashConfig(AlgArgon2id))
+
+	if ok, err := h.Verify(bcryptHash, "secret"); err != nil || !ok {
+		t.Errorf("argon2id hasher must still verify bcrypt hashes: %v, %v", ok, err)
+	}
+	if _, err := h.Verify("$argon2id$v=19$broken", "secret"); err == nil {
+		t.Error("malformed argon2id hash should be an error")
+	}
+}
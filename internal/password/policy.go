@@ -0,0 +1,131 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // SHA-1 only matches entries of public breach lists
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"VPS-control/internal/config"
+)
+
+// Policy checks new passwords. The breached list holds one password per
+// line, either in plain text or as a SHA-1 hex digest like the offline
+// "Pwned Passwords" files (anything after a ':' is ignored there).
+type Policy struct {
+	minLength  int
+	maxLength  int
+	minClasses int
+	breached   map[[sha1.Size]byte]struct{}
+}
+
+func NewPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	p := &Policy{
+		minLength:  cfg.MinLength,
+		maxLength:  cfg.MaxLength,
+		minClasses: cfg.MinClasses,
+		breached:   make(map[[sha1.Size]byte]struct{}),
+	}
+	if cfg.BreachedList == "" {
+		return p, nil
+	}
+	if err := p.loadBreached(cfg.BreachedList); err != nil {
+		return nil, fmt.Errorf("load breached password list: %w", err)
+	}
+	return p, nil
+}
+
+// BreachedCount is the number of entries loaded from the breached list.
+func (p *Policy) BreachedCount() int {
+	return len(p.breached)
+}
+
+// Validate returns every rule the password breaks, or nil.
+func (p *Policy) Validate(
+	username, raw string,
+) []string {
+	var violations []string
+
+	length := utf8.RuneCountInString(raw)
+	if length < p.minLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.minLength))
+	}
+	if p.maxLength > 0 && len(raw) > p.maxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.maxLength))
+	}
+	if classes := countClasses(raw); classes < p.minClasses {
+		violations = append(
+			violations,
+			fmt.Sprintf("must mix at least %d of: lowercase, uppercase, digits, symbols", p.minClasses),
+		)
+	}
+	if username != "" && strings.Contains(strings.ToLower(raw), strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if _, ok := p.breached[sha1.Sum([]byte(raw))]; ok { //nolint:gosec // see import
+		violations = append(violations, "appears in a list of breached passwords")
+	}
+	return violations
+}
+
+func (p *Policy) loadBreached(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if digest, ok := parseSHA1(line); ok {
+			p.breached[digest] = struct{}{}
+			continue
+		}
+		p.breached[sha1.Sum([]byte(line))] = struct{}{} //nolint:gosec // see import
+	}
+	return scanner.Err()
+}
+
+// parseSHA1 accepts "<40 hex digits>" optionally followed by ":<count>".
+func parseSHA1(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+	hexPart, _, _ := strings.Cut(line, ":")
+	if len(hexPart) != hex.EncodedLen(sha1.Size) {
+		return digest, false
+	}
+	if _, err := hex.Decode(digest[:], []byte(hexPart)); err != nil {
+		return digest, false
+	}
+	return digest, true
+}
+
+func countClasses(raw string) int {
+	var lower, upper, digit, other bool
+	for _, r := range raw {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, set := range []bool{lower, upper, digit, other} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
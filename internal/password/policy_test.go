@@ -0,0 +1,68 @@
+package password
+
+import (
+	"crypto/sha1" //nolint:gosec // matches the breached list format
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"VPS-control/internal/config"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	digest := sha1.Sum([]byte("Hunter2hunter2")) //nolint:gosec // see import
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	content := "Password123!\n" + strings.ToUpper(hex.EncodeToString(digest[:])) + ":4021\n\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPolicy(
+		config.PasswordConfig{
+			MinLength:    10,
+			MaxLength:    72,
+			MinClasses:   2,
+			BreachedList: list,
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewPolicy() error: %v", err)
+	}
+	if p.BreachedCount() != 2 {
+		t.Fatalf("BreachedCount() = %d, want 2", p.BreachedCount())
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     int
+	}{
+		{"valid", "blue-river-stone", 0},
+		{"too short", "ab-12", 1},
+		{"too long", strings.Repeat("a1", 40), 1},
+		{"single class", "onlylowercaseletters", 1},
+		{"contains username", "xx-Operator-99", 1},
+		{"breached plain", "Password123!", 1},
+		{"breached sha1", "Hunter2hunter2", 1},
+		{"short and single class", "abc", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := p.Validate("operator", tt.password); len(got) != tt.want {
+					t.Errorf("Validate(%q) = %v, want %d violations", tt.password, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestNewPolicy_MissingList(t *testing.T) {
+	_, err := NewPolicy(config.PasswordConfig{BreachedList: filepath.Join(t.TempDir(), "missing.txt")})
+	if err == nil {
+		t.Error("a configured but missing breached list should be an error")
+	}
+}
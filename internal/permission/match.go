@@ -0,0 +1,93 @@
+package permission
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Entries granted to a role are exact names ("pm2.control.restart"),
+// wildcards over a subtree ("pm2.*", "pm2.control.*"), All for everything, or
+// any of these prefixed with DenyPrefix. A matching deny always wins over any
+// allow, however specific the allow is.
+const (
+	All        = "*"
+	DenyPrefix = "!"
+
+	wildcardSuffix = ".*"
+)
+
+var entryRegex = regexp.MustCompile(`^!?(\*|[a-z0-9_]+(\.[a-z0-9_]+)*(\.\*)?)$`)
+
+// Allows reports whether the granted entries permit required.
+func Allows(
+	granted []string,
+	required string,
+) bool {
+	allowed := false
+	for _, entry := range granted {
+		if denied, ok := strings.CutPrefix(entry, DenyPrefix); ok {
+			if covers(denied, required) {
+				return false
+			}
+			continue
+		}
+		if covers(entry, required) {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// AllowsAny reports whether the granted entries permit at least one of required.
+func AllowsAny(
+	granted []string,
+	required ...string,
+) bool {
+	for _, r := range required {
+		if Allows(granted, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether entry is a well-formed name, wildcard or deny entry.
+func Valid(entry string) bool {
+	return entryRegex.MatchString(entry)
+}
+
+func IsDeny(entry string) bool {
+	return strings.HasPrefix(entry, DenyPrefix)
+}
+
+// IsPattern reports whether entry is a wildcard or a deny, i.e. anything
+// other than a plain permission name.
+func IsPattern(entry string) bool {
+	return IsDeny(entry) || entry == All || strings.HasSuffix(entry, wildcardSuffix)
+}
+
+// Denies returns the deny entries of granted.
+func Denies(granted []string) []string {
+	res := make([]string, 0)
+	for _, entry := range granted {
+		if IsDeny(entry) {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// covers reports whether the allow entry pattern matches name. A wildcard
+// matches its subtree, including narrower wildcards, but not its own prefix:
+// "pm2.*" covers "pm2.control.*" and "pm2.view.basic", not "pm2".
+func covers(
+	pattern, name string,
+) bool {
+	if pattern == All {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, wildcardSuffix); ok {
+		return strings.HasPrefix(name, prefix+".")
+	}
+	return pattern == name
+}
@@ -0,0 +1,76 @@
+package permission
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required string
+		want     bool
+	}{
+		{"exact", []string{"pm2.view.basic"}, "pm2.view.basic", true},
+		{"exact mismatch", []string{"pm2.view.basic"}, "pm2.view.full", false},
+		{"no entries", nil, "pm2.view.basic", false},
+		{"global wildcard", []string{"*"}, "user.delete", true},
+		{"top-level wildcard", []string{"pm2.*"}, "pm2.control.restart", true},
+		{"nested wildcard", []string{"pm2.control.*"}, "pm2.control.stop", true},
+		{"nested wildcard other branch", []string{"pm2.control.*"}, "pm2.view.basic", false},
+		{"wildcard does not match its prefix", []string{"pm2.*"}, "pm2", false},
+		{"wildcard needs segment boundary", []string{"pm2.*"}, "pm2x.view", false},
+		{"wildcard covers narrower wildcard", []string{"pm2.*"}, "pm2.control.*", true},
+		{"narrower wildcard does not cover wider", []string{"pm2.control.*"}, "pm2.*", false},
+		{"deny beats exact allow", []string{"pm2.control.stop", "!pm2.control.stop"}, "pm2.control.stop", false},
+		{"deny beats wildcard", []string{"pm2.*", "!pm2.control.stop"}, "pm2.control.stop", false},
+		{"deny leaves siblings", []string{"pm2.*", "!pm2.control.stop"}, "pm2.control.restart", true},
+		{"deny beats global wildcard", []string{"*", "!user.*"}, "user.delete", false},
+		{"deny wildcard beats more specific allow", []string{"pm2.control.stop", "!pm2.*"}, "pm2.control.stop", false},
+		{"deny order does not matter", []string{"!pm2.control.stop", "pm2.*"}, "pm2.control.stop", false},
+		{"deny alone grants nothing", []string{"!pm2.control.stop"}, "pm2.view.basic", false},
+		{"global deny", []string{"*", "!*"}, "pm2.view.basic", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := Allows(tt.granted, tt.required); got != tt.want {
+					t.Errorf("Allows(%v, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestAllowsAny(t *testing.T) {
+	granted := []string{"f2b.*", "!f2b.control.*"}
+
+	if !AllowsAny(granted, "pm2.view.basic", "f2b.view.status") {
+		t.Error("should allow f2b.view.status")
+	}
+	if AllowsAny(granted, "pm2.view.basic", "f2b.control.unban") {
+		t.Error("should allow none")
+	}
+}
+
+func TestValid(t *testing.T) {
+	for entry, want := range map[string]bool{
+		"pm2.view.basic":         true,
+		"f2b.control.jail.start": true,
+		"pm2.*":                  true,
+		"pm2.control.*":          true,
+		"*":                      true,
+		"!pm2.control.stop":      true,
+		"!*":                     true,
+		"":                       false,
+		"pm2.":                   false,
+		"pm2.*.stop":             false,
+		"pm2*":                   false,
+		"!!pm2.view":             false,
+		"PM2.view":               false,
+		"pm2 view":               false,
+	} {
+		if got := Valid(entry); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", entry, got, want)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 func RegisterPM2Routes(
 	rg *gin.RouterGroup,
 	h pm2.Handler,
+	stepUp gin.HandlerFunc,
 ) {
 	pm2Group := rg.Group("/pm2")
 	{
@@ -20,6 +21,6 @@ func RegisterPM2Routes(
 
 		pm2Group.POST("/restart", middleware.RequirePermission(auth.PermPM2ControlRestart), h.Restart)
 		pm2Group.POST("/start", middleware.RequirePermission(auth.PermPM2ControlStart), h.Start)
-		pm2Group.POST("/stop", middleware.RequirePermission(auth.PermPM2ControlStop), h.Stop)
+		pm2Group.POST("/stop", middleware.RequirePermission(auth.PermPM2ControlStop), stepUp, h.Stop)
 	}
 }
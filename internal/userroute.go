@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"VPS-control/internal/auth"
+	"VPS-control/internal/middleware"
+	"VPS-control/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterUserRoutes(
+	rg *gin.RouterGroup,
+	h users.Handler,
+	stepUp gin.HandlerFunc,
+) {
+	// Routes that hand out privilege need a recent re-authentication, so a
+	// stolen session cannot raise its own rights.
+	usersGroup := rg.Group("/users")
+	{
+		usersGroup.GET("", middleware.RequirePermission(auth.PermUserView), h.ListUsers)
+		usersGroup.GET("/:id", middleware.RequirePermission(auth.PermUserView), h.GetUser)
+		usersGroup.GET("/:id/permissions", middleware.RequirePermission(auth.PermUserView), h.GetUserPermissions)
+
+		usersGroup.POST("/create", middleware.RequirePermission(auth.PermUserCreate), stepUp, h.CreateUser)
+		usersGroup.POST("/activate", middleware.RequirePermission(auth.PermUserEdit), h.ActivateUser)
+		usersGroup.POST("/deactivate", middleware.RequirePermission(auth.PermUserEdit), h.DeactivateUser)
+		usersGroup.POST("/password/reset", middleware.RequirePermission(auth.PermUserEdit), stepUp, h.ResetPassword)
+		usersGroup.POST("/delete", middleware.RequirePermission(auth.PermUserDelete), stepUp, h.DeleteUser)
+
+		usersGroup.POST("/roles/assign", middleware.RequirePermission(auth.PermUserRolesAssign), stepUp, h.AssignRole)
+		usersGroup.POST("/roles/remove", middleware.RequirePermission(auth.PermUserRolesAssign), h.RemoveRole)
+	}
+
+	rolesGroup := rg.Group("/roles")
+	{
+		rolesGroup.GET("", middleware.RequirePermission(auth.PermRoleView), h.ListRoles)
+		rolesGroup.POST("/create", middleware.RequirePermission(auth.PermRoleCreate), stepUp, h.CreateRole)
+		rolesGroup.POST("/update", middleware.RequirePermission(auth.PermRoleEdit), stepUp, h.UpdateRole)
+		rolesGroup.POST("/delete", middleware.RequirePermission(auth.PermRoleDelete), h.DeleteRole)
+	}
+
+	rg.GET("/permissions", middleware.RequirePermission(auth.PermRoleView), h.ListPermissions)
+
+	grantsGroup := rg.Group("/grants")
+	{
+		grantsGroup.GET("", middleware.RequirePermission(auth.PermUserView), h.ListGrants)
+		grantsGroup.POST("/create", middleware.RequirePermission(auth.PermUserRolesAssign), stepUp, h.CreateGrant)
+		grantsGroup.POST("/revoke", middleware.RequirePermission(auth.PermUserRolesAssign), h.RevokeGrant)
+	}
+
+	// Any authenticated user may ask for access; deciding needs the right to
+	// assign roles.
+	accessGroup := rg.Group("/access-requests")
+	{
+		accessGroup.GET("/mine", h.ListOwnAccessRequests)
+		accessGroup.POST("/create", h.CreateAccessRequest)
+
+		accessGroup.GET("", middleware.RequirePermission(auth.PermUserRolesAssign), h.ListAccessRequests)
+		accessGroup.POST("/approve", middleware.RequirePermission(auth.PermUserRolesAssign), stepUp, h.ApproveAccessRequest)
+		accessGroup.POST("/deny", middleware.RequirePermission(auth.PermUserRolesAssign), h.DenyAccessRequest)
+	}
+}
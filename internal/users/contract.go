@@ -0,0 +1,145 @@
+package users
+
+import (
+	"context"
+
+	"VPS-control/internal/database/postgresql"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler interface {
+	ListUsers(c *gin.Context)
+	GetUser(c *gin.Context)
+	CreateUser(c *gin.Context)
+	ActivateUser(c *gin.Context)
+	DeactivateUser(c *gin.Context)
+	DeleteUser(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	GetUserPermissions(c *gin.Context)
+	AssignRole(c *gin.Context)
+	RemoveRole(c *gin.Context)
+	ListRoles(c *gin.Context)
+	ListPermissions(c *gin.Context)
+	CreateRole(c *gin.Context)
+	UpdateRole(c *gin.Context)
+	DeleteRole(c *gin.Context)
+	ListGrants(c *gin.Context)
+	CreateGrant(c *gin.Context)
+	RevokeGrant(c *gin.Context)
+	ListAccessRequests(c *gin.Context)
+	ListOwnAccessRequests(c *gin.Context)
+	CreateAccessRequest(c *gin.Context)
+	ApproveAccessRequest(c *gin.Context)
+	DenyAccessRequest(c *gin.Context)
+}
+
+type passwordPolicy interface {
+	Validate(
+		username, raw string,
+	) []string
+}
+
+// permissionInvalidator drops cached permission sets, see auth.PermissionCache.
+type permissionInvalidator interface {
+	Invalidate(userIDs ...int)
+}
+
+type userManager interface {
+	List(
+		ctx context.Context,
+		page, pageSize int,
+	) (*UserListResponse, error)
+	Get(
+		ctx context.Context,
+		userID int,
+	) (*postgresql.UserResponseDTO, error)
+	Create(
+		ctx context.Context,
+		req *CreateUserRequest,
+	) (*postgresql.UserResponseDTO, error)
+	SetActive(
+		ctx context.Context,
+		actor Actor,
+		userID int,
+		active bool,
+	) (int64, error)
+	Delete(
+		ctx context.Context,
+		actor Actor,
+		userID int,
+	) (int64, error)
+	ResetPassword(
+		ctx context.Context,
+		actor Actor,
+		userID int,
+		password string,
+	) (int64, error)
+}
+
+type roleManager interface {
+	ListRoles(ctx context.Context) ([]postgresql.RoleDTO, error)
+	ListPermissions(ctx context.Context) ([]postgresql.PermissionDTO, error)
+	GetUserPermissions(
+		ctx context.Context,
+		userID int,
+	) (*postgresql.UserPermissionsDTO, error)
+	AssignRole(
+		ctx context.Context,
+		userID int,
+		role string,
+	) error
+	RemoveRole(
+		ctx context.Context,
+		userID int,
+		role string,
+	) error
+	CreateRole(
+		ctx context.Context,
+		req *CreateRoleRequest,
+	) (*postgresql.RoleDTO, error)
+	UpdateRole(
+		ctx context.Context,
+		req *UpdateRoleRequest,
+	) error
+	DeleteRole(
+		ctx context.Context,
+		roleID int,
+	) error
+}
+
+type grantManager interface {
+	ListGrants(
+		ctx context.Context,
+		userID int,
+	) ([]postgresql.GrantDTO, error)
+	Grant(
+		ctx context.Context,
+		actor Actor,
+		req *CreateGrantRequest,
+	) (*postgresql.GrantDTO, error)
+	RevokeGrant(
+		ctx context.Context,
+		grantID int,
+	) (*postgresql.GrantDTO, error)
+	RequestAccess(
+		ctx context.Context,
+		actor Actor,
+		req *CreateAccessRequest,
+	) (*postgresql.AccessRequestDTO, error)
+	ListAccessRequests(
+		ctx context.Context,
+		userID int,
+		status string,
+	) ([]postgresql.AccessRequestDTO, error)
+	ApproveAccessRequest(
+		ctx context.Context,
+		actor Actor,
+		req *DecideAccessRequest,
+	) (*postgresql.GrantDTO, error)
+	DenyAccessRequest(
+		ctx context.Context,
+		actor Actor,
+		req *DecideAccessRequest,
+	) (*postgresql.AccessRequestDTO, error)
+}
@@ -0,0 +1,140 @@
+package users
+
+import (
+	"time"
+
+	"VPS-control/internal/database/postgresql"
+)
+
+// Пагинация списка пользователей
+const (
+	QueryParamPage     = "page"
+	QueryParamPageSize = "page_size"
+	DefaultPageSize    = 20
+	MaxPageSize        = 100
+)
+
+// Роли и наборы прав
+const (
+	ReRoleName            = `^[a-zA-Z0-9_-]{2,32}$`
+	AuditActionRoleAssign = "user.roles.assign"
+	AuditActionRoleRemove = "user.roles.remove"
+	AuditActionRoleCreate = "role.create"
+	AuditActionRoleUpdate = "role.update"
+	AuditActionRoleDelete = "role.delete"
+)
+
+const (
+	AuditActionCreate        = "user.create"
+	AuditActionActivate      = "user.activate"
+	AuditActionDeactivate    = "user.deactivate"
+	AuditActionDelete        = "user.delete"
+	AuditActionResetPassword = "user.password.reset"
+)
+
+// Временные права и запросы доступа
+const (
+	QueryParamUserID = "user_id"
+	QueryParamStatus = "status"
+
+	AuditActionGrantCreate   = "user.grant.create"
+	AuditActionGrantRevoke   = "user.grant.revoke"
+	AuditActionGrantExpire   = "user.grant.expire"
+	AuditActionAccessRequest = "access.request.create"
+	AuditActionAccessApprove = "access.request.approve"
+	AuditActionAccessDeny    = "access.request.deny"
+	AuditActorGrantExpiry    = "grant_expiry"
+	grantSweepInterval       = time.Minute
+)
+
+// Actor is the authenticated user performing a management action.
+type Actor struct {
+	ID       int
+	Username string
+}
+
+type UserListResponse struct {
+	Users    []postgresql.UserResponseDTO `json:"users"`
+	Total    int                          `json:"total" example:"42"`
+	Page     int                          `json:"page" example:"1"`
+	PageSize int                          `json:"page_size" example:"20"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username" example:"operator" binding:"required,min=3,max=32,alphanum"`
+	Password string `json:"password" example:"secret_pass" binding:"required,min=8,max=128,excludes= "`
+	Active   *bool  `json:"active,omitempty" example:"true"`
+}
+
+type UserIDRequest struct {
+	UserID int `json:"user_id" example:"2" binding:"required,min=1"`
+}
+
+type ResetPasswordRequest struct {
+	UserID   int    `json:"user_id" example:"2" binding:"required,min=1"`
+	Password string `json:"password" example:"new_secret_pass" binding:"required,min=8,max=128,excludes= "`
+}
+
+type UserActionResponse struct {
+	Success         bool   `json:"success" example:"true"`
+	Message         string `json:"message" example:"User deactivated"`
+	RevokedSessions int64  `json:"revoked_sessions" example:"1"`
+}
+
+type RoleAssignRequest struct {
+	UserID int    `json:"user_id" example:"2" binding:"required,min=1"`
+	Role   string `json:"role" example:"operator" binding:"required"`
+}
+
+// CreateRoleRequest permissions may include wildcards ("pm2.*", "*") and
+// deny entries ("!pm2.control.stop"), which win over any allow.
+type CreateRoleRequest struct {
+	Name             string   `json:"name" example:"operator" binding:"required"`
+	Description      string   `json:"description" example:"PM2 operator" binding:"max=255"`
+	RequireTwoFactor bool     `json:"require_2fa" example:"true"`
+	SingleSession    bool     `json:"single_session" example:"false"`
+	Permissions      []string `json:"permissions" example:"pm2.*,!pm2.control.stop" binding:"dive,required,max=64"`
+}
+
+// UpdateRoleRequest changes only the fields that are present. Permissions,
+// when present, replace the whole permission set of the role.
+type UpdateRoleRequest struct {
+	RoleID           int       `json:"role_id" example:"3" binding:"required,min=1"`
+	Name             string    `json:"name,omitempty" example:"operator"`
+	Description      *string   `json:"description,omitempty" example:"PM2 operator" binding:"omitempty,max=255"`
+	RequireTwoFactor *bool     `json:"require_2fa,omitempty" example:"true"`
+	SingleSession    *bool     `json:"single_session,omitempty" example:"false"`
+	Permissions      *[]string `json:"permissions,omitempty" example:"pm2.view.basic" binding:"omitempty,dive,required,max=64"`
+}
+
+type RoleIDRequest struct {
+	RoleID int `json:"role_id" example:"3" binding:"required,min=1"`
+}
+
+// CreateGrantRequest gives either a role or a single permission entry until
+// ExpiresAt (RFC 3339). Wildcards are allowed, deny entries are not.
+type CreateGrantRequest struct {
+	UserID     int       `json:"user_id" example:"2" binding:"required,min=1"`
+	Role       string    `json:"role,omitempty" example:"operator"`
+	Permission string    `json:"permission,omitempty" example:"pm2.control.restart" binding:"max=64"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2026-10-18T18:00:00Z" binding:"required"`
+	Reason     string    `json:"reason" example:"Contractor on-call this afternoon" binding:"max=500"`
+}
+
+type GrantIDRequest struct {
+	GrantID int `json:"grant_id" example:"5" binding:"required,min=1"`
+}
+
+type CreateAccessRequest struct {
+	Permission      string `json:"permission" example:"pm2.control.restart" binding:"required,max=64"`
+	Reason          string `json:"reason" example:"Deploying the billing hotfix" binding:"required,max=500"`
+	DurationMinutes int    `json:"duration_minutes" example:"240" binding:"required,min=1"`
+}
+
+// DecideAccessRequest approves or denies a request. On approval ExpiresAt,
+// when set, overrides the duration the requester asked for.
+type DecideAccessRequest struct {
+	RequestID int        `json:"request_id" example:"7" binding:"required,min=1"`
+	Note      string     `json:"note" example:"Approved for the release window" binding:"max=500"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2026-10-18T18:00:00Z"`
+}
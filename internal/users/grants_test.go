@@ -0,0 +1,248 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+type fakeGrantStore struct {
+	postgresql.GrantStore
+	created  []postgresql.GrantDTO
+	requests map[int]*postgresql.AccessRequestDTO
+	expired  []postgresql.GrantDTO
+}
+
+func (f *fakeGrantStore) CreateGrant(
+	_ context.Context,
+	grant postgresql.GrantDTO,
+) (*postgresql.GrantDTO, error) {
+	if grant.Role != "" && grant.Role != "operator" {
+		return nil, postgresql.ErrRoleNotFound
+	}
+	grant.ID = len(f.created) + 1
+	f.created = append(f.created, grant)
+	return &grant, nil
+}
+
+func (f *fakeGrantStore) GetAccessRequest(
+	_ context.Context,
+	id int,
+) (*postgresql.AccessRequestDTO, error) {
+	req, ok := f.requests[id]
+	if !ok {
+		return nil, postgresql.ErrAccessRequestNotFound
+	}
+	return req, nil
+}
+
+func (f *fakeGrantStore) ApproveAccessRequest(
+	_ context.Context,
+	id int,
+	decidedBy, _ string,
+	expiresAt time.Time,
+) (*postgresql.GrantDTO, error) {
+	req := f.requests[id]
+	req.Status = postgresql.AccessRequestApproved
+	grant := postgresql.GrantDTO{
+		ID:         len(f.created) + 1,
+		UserID:     req.UserID,
+		Permission: req.Permission,
+		GrantedBy:  decidedBy,
+		RequestID:  &id,
+		ExpiresAt:  expiresAt,
+	}
+	f.created = append(f.created, grant)
+	return &grant, nil
+}
+
+func (f *fakeGrantStore) DeleteExpiredGrants(context.Context) ([]postgresql.GrantDTO, error) {
+	expired := f.expired
+	f.expired = nil
+	return expired, nil
+}
+
+type fakeInvalidator struct {
+	userIDs []int
+}
+
+func (f *fakeInvalidator) Invalidate(userIDs ...int) {
+	f.userIDs = append(f.userIDs, userIDs...)
+}
+
+type fakeRecorder struct {
+	entries []audit.Entry
+}
+
+func (f *fakeRecorder) Record(entry audit.Entry) {
+	f.entries = append(f.entries, entry)
+}
+
+var testNow = time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)
+
+func newTestGrantService() (*GrantService, *fakeGrantStore, *fakeInvalidator, *fakeRecorder) {
+	store := &fakeGrantStore{
+		requests: map[int]*postgresql.AccessRequestDTO{
+			7: {
+				ID: 7, UserID: 2, Permission: "pm2.control.restart", DurationMinutes: 240,
+				Status: postgresql.AccessRequestPending,
+			},
+		},
+	}
+	cache, rec := &fakeInvalidator{}, &fakeRecorder{}
+	svc := NewGrantService(config.GrantsConfig{MaxDuration: 24 * time.Hour}, store, cache, rec, zap.NewNop())
+	svc.now = func() time.Time { return testNow }
+	return svc, store, cache, rec
+}
+
+func errorCode(err error) string {
+	var appErr *apierror.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ""
+}
+
+func TestGrantService_Grant(t *testing.T) {
+	ctx := context.Background()
+	admin := Actor{ID: 1, Username: "admin"}
+	until := testNow.Add(5 * time.Hour)
+
+	tests := []struct {
+		name string
+		req  CreateGrantRequest
+		want string
+	}{
+		{"role", CreateGrantRequest{UserID: 2, Role: "operator", ExpiresAt: until}, ""},
+		{"permission", CreateGrantRequest{UserID: 2, Permission: "pm2.control.restart", ExpiresAt: until}, ""},
+		{"wildcard", CreateGrantRequest{UserID: 2, Permission: "pm2.*", ExpiresAt: until}, ""},
+		{"both", CreateGrantRequest{UserID: 2, Role: "operator", Permission: "pm2.*", ExpiresAt: until}, "INVALID_REQUEST"},
+		{"neither", CreateGrantRequest{UserID: 2, ExpiresAt: until}, "INVALID_REQUEST"},
+		{"deny entry", CreateGrantRequest{UserID: 2, Permission: "!pm2.control.stop", ExpiresAt: until}, "INVALID_REQUEST"},
+		{"past", CreateGrantRequest{UserID: 2, Role: "operator", ExpiresAt: testNow.Add(-time.Minute)}, "GRANT_EXPIRY_INVALID"},
+		{"too long", CreateGrantRequest{UserID: 2, Role: "operator", ExpiresAt: testNow.Add(25 * time.Hour)}, "GRANT_EXPIRY_INVALID"},
+		{"unknown role", CreateGrantRequest{UserID: 2, Role: "missing", ExpiresAt: until}, "ROLE_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				svc, store, cache, _ := newTestGrantService()
+				grant, err := svc.Grant(ctx, admin, &tt.req)
+				if got := errorCode(err); got != tt.want {
+					t.Fatalf("error = %v, want %q", err, tt.want)
+				}
+				if tt.want != "" {
+					if len(store.created) != 0 || len(cache.userIDs) != 0 {
+						t.Errorf("rejected grant stored or invalidated: %v, %v", store.created, cache.userIDs)
+					}
+					return
+				}
+				if grant.GrantedBy != "admin" || !slices.Equal(cache.userIDs, []int{2}) {
+					t.Errorf("grant = %+v, invalidated = %v", grant, cache.userIDs)
+				}
+			},
+		)
+	}
+}
+
+func TestGrantService_ApproveAccessRequest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"own request", func(t *testing.T) {
+			svc, store, _, _ := newTestGrantService()
+			_, err := svc.ApproveAccessRequest(ctx, Actor{ID: 2, Username: "contractor"}, &DecideAccessRequest{RequestID: 7})
+			if !errors.Is(err, apierror.Errors.ACCESS_REQUEST_SELF_APPROVAL) || len(store.created) != 0 {
+				t.Errorf("error = %v, grants = %v", err, store.created)
+			}
+		},
+	)
+
+	t.Run(
+		"own grant", func(t *testing.T) {
+			svc, store, _, _ := newTestGrantService()
+			req := &CreateGrantRequest{UserID: 1, Permission: "*", ExpiresAt: testNow.Add(time.Hour)}
+			_, err := svc.Grant(ctx, Actor{ID: 1, Username: "admin"}, req)
+			if !errors.Is(err, apierror.Errors.ACCESS_REQUEST_SELF_APPROVAL) || len(store.created) != 0 {
+				t.Errorf("error = %v, grants = %v", err, store.created)
+			}
+		},
+	)
+
+	t.Run(
+		"requested duration", func(t *testing.T) {
+			svc, _, cache, _ := newTestGrantService()
+			grant, err := svc.ApproveAccessRequest(ctx, Actor{ID: 1, Username: "admin"}, &DecideAccessRequest{RequestID: 7})
+			if err != nil {
+				t.Fatalf("ApproveAccessRequest() error: %v", err)
+			}
+			if !grant.ExpiresAt.Equal(testNow.Add(4*time.Hour)) || grant.Permission != "pm2.control.restart" {
+				t.Errorf("grant = %+v", grant)
+			}
+			if !slices.Equal(cache.userIDs, []int{2}) {
+				t.Errorf("invalidated = %v, want [2]", cache.userIDs)
+			}
+
+			_, err = svc.ApproveAccessRequest(ctx, Actor{ID: 1, Username: "admin"}, &DecideAccessRequest{RequestID: 7})
+			if !errors.Is(err, apierror.Errors.ACCESS_REQUEST_ALREADY_DECIDED) {
+				t.Errorf("second approval error = %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"approver expiry", func(t *testing.T) {
+			svc, _, _, _ := newTestGrantService()
+			until := testNow.Add(time.Hour)
+			req := &DecideAccessRequest{RequestID: 7, ExpiresAt: &until}
+			grant, err := svc.ApproveAccessRequest(ctx, Actor{ID: 1, Username: "admin"}, req)
+			if err != nil || !grant.ExpiresAt.Equal(until) {
+				t.Errorf("grant = %+v, error = %v", grant, err)
+			}
+		},
+	)
+
+	t.Run(
+		"missing request", func(t *testing.T) {
+			svc, _, _, _ := newTestGrantService()
+			_, err := svc.ApproveAccessRequest(ctx, Actor{ID: 1, Username: "admin"}, &DecideAccessRequest{RequestID: 9})
+			if !errors.Is(err, apierror.Errors.ACCESS_REQUEST_NOT_FOUND) {
+				t.Errorf("error = %v, want ACCESS_REQUEST_NOT_FOUND", err)
+			}
+		},
+	)
+}
+
+func TestGrantService_ExpireGrants(t *testing.T) {
+	svc, store, cache, rec := newTestGrantService()
+	store.expired = []postgresql.GrantDTO{
+		{ID: 1, UserID: 2, Role: "operator", ExpiresAt: testNow},
+		{ID: 2, UserID: 3, Permission: "pm2.control.restart", ExpiresAt: testNow},
+	}
+
+	svc.expireGrants(context.Background())
+
+	if !slices.Equal(cache.userIDs, []int{2, 3}) {
+		t.Errorf("invalidated = %v, want [2 3]", cache.userIDs)
+	}
+	if len(rec.entries) != 2 {
+		t.Fatalf("audit entries = %d, want 2", len(rec.entries))
+	}
+	if e := rec.entries[0]; e.Action != AuditActionGrantExpire || e.Target != "2" || e.Actor != AuditActorGrantExpiry {
+		t.Errorf("audit entry = %+v", e)
+	}
+
+	svc.expireGrants(context.Background())
+	if len(rec.entries) != 2 || len(cache.userIDs) != 2 {
+		t.Error("nothing expired, nothing should be recorded")
+	}
+}
@@ -0,0 +1,747 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+	"VPS-control/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	manager userManager
+	roles   roleManager
+	grants  grantManager
+	audit   audit.Recorder
+	logger  *zap.Logger
+}
+
+func NewHandler(
+	um userManager,
+	rm roleManager,
+	gm grantManager,
+	ar audit.Recorder,
+	l *zap.Logger,
+) Handler {
+	return &handler{
+		manager: um,
+		roles:   rm,
+		grants:  gm,
+		audit:   ar,
+		logger:  l,
+	}
+}
+
+// ListUsers godoc
+// @Summary      List users
+// @Description  Returns a page of users ordered by ID
+// @Tags         users
+// @Security     CookieAuth
+// @Param        page       query  int  false  "Page number, starting at 1"
+// @Param        page_size  query  int  false  "Page size (max 100)"
+// @Produce      json
+// @Success      200  {object}  UserListResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /users [get]
+func (h *handler) ListUsers(c *gin.Context) {
+	page, err := queryInt(c, QueryParamPage)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("query parameter 'page' must be a number"))
+		return
+	}
+	pageSize, err := queryInt(c, QueryParamPageSize)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("query parameter 'page_size' must be a number"))
+		return
+	}
+
+	res, err := h.manager.List(c.Request.Context(), page, pageSize)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GetUser godoc
+// @Summary      Get user
+// @Tags         users
+// @Security     CookieAuth
+// @Param        id  path  int  true  "User ID"
+// @Produce      json
+// @Success      200  {object}  postgresql.UserResponseDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/{id} [get]
+func (h *handler) GetUser(c *gin.Context) {
+	userID, ok := pathUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.manager.Get(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUser godoc
+// @Summary      Create user
+// @Description  Creates a user with a bcrypt-hashed password. Users are active unless 'active' is false.
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  CreateUserRequest  true  "New user"
+// @Success      201  {object}  postgresql.UserResponseDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Router       /users/create [post]
+func (h *handler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	user, err := h.manager.Create(c.Request.Context(), &req)
+	h.recordAudit(c, AuditActionCreate, req.Username, "", err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// ActivateUser godoc
+// @Summary      Activate user
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  UserIDRequest  true  "User ID"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/activate [post]
+func (h *handler) ActivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// DeactivateUser godoc
+// @Summary      Deactivate user
+// @Description  Blocks login for the user and revokes all of their sessions
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  UserIDRequest  true  "User ID"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      403  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/deactivate [post]
+func (h *handler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *handler) setActive(
+	c *gin.Context,
+	active bool,
+) {
+	var req UserIDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	action, message := AuditActionActivate, "User activated"
+	if !active {
+		action, message = AuditActionDeactivate, "User deactivated"
+	}
+
+	revoked, err := h.manager.SetActive(c.Request.Context(), actorFrom(c), req.UserID, active)
+	h.recordAudit(c, action, strconv.Itoa(req.UserID), revokedDetails(revoked), err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: message, RevokedSessions: revoked})
+}
+
+// DeleteUser godoc
+// @Summary      Delete user
+// @Description  Deletes the user with their role assignments and revokes all of their sessions
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  UserIDRequest  true  "User ID"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      403  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/delete [post]
+func (h *handler) DeleteUser(c *gin.Context) {
+	var req UserIDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	revoked, err := h.manager.Delete(c.Request.Context(), actorFrom(c), req.UserID)
+	h.recordAudit(c, AuditActionDelete, strconv.Itoa(req.UserID), revokedDetails(revoked), err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "User deleted", RevokedSessions: revoked})
+}
+
+// ResetPassword godoc
+// @Summary      Reset user password
+// @Description  Sets a new password for the user and revokes all of their sessions
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  ResetPasswordRequest  true  "User ID and new password"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/password/reset [post]
+func (h *handler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	revoked, err := h.manager.ResetPassword(c.Request.Context(), actorFrom(c), req.UserID, req.Password)
+	h.recordAudit(c, AuditActionResetPassword, strconv.Itoa(req.UserID), revokedDetails(revoked), err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "Password reset", RevokedSessions: revoked})
+}
+
+// GetUserPermissions godoc
+// @Summary      Get user roles and permissions
+// @Tags         users
+// @Security     CookieAuth
+// @Param        id  path  int  true  "User ID"
+// @Produce      json
+// @Success      200  {object}  postgresql.UserPermissionsDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/{id}/permissions [get]
+func (h *handler) GetUserPermissions(c *gin.Context) {
+	userID, ok := pathUserID(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.roles.GetUserPermissions(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// AssignRole godoc
+// @Summary      Assign role to user
+// @Description  Assigns a role. The new permissions apply from the user's next request
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  RoleAssignRequest  true  "User ID and role name"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/roles/assign [post]
+func (h *handler) AssignRole(c *gin.Context) {
+	var req RoleAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	err := h.roles.AssignRole(c.Request.Context(), req.UserID, req.Role)
+	h.recordAudit(c, AuditActionRoleAssign, strconv.Itoa(req.UserID), "role="+req.Role, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "Role assigned"})
+}
+
+// RemoveRole godoc
+// @Summary      Remove role from user
+// @Description  Removes a role. The change applies from the user's next request
+// @Tags         users
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  RoleAssignRequest  true  "User ID and role name"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /users/roles/remove [post]
+func (h *handler) RemoveRole(c *gin.Context) {
+	var req RoleAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	err := h.roles.RemoveRole(c.Request.Context(), req.UserID, req.Role)
+	h.recordAudit(c, AuditActionRoleRemove, strconv.Itoa(req.UserID), "role="+req.Role, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "Role removed"})
+}
+
+// ListRoles godoc
+// @Summary      List roles
+// @Description  Returns all roles with their permission sets
+// @Tags         roles
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {array}   postgresql.RoleDTO
+// @Failure      500  {object}  apierror.AppError
+// @Router       /roles [get]
+func (h *handler) ListRoles(c *gin.Context) {
+	roles, err := h.roles.ListRoles(c.Request.Context())
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// ListPermissions godoc
+// @Summary      List permissions
+// @Tags         roles
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {array}   postgresql.PermissionDTO
+// @Failure      500  {object}  apierror.AppError
+// @Router       /permissions [get]
+func (h *handler) ListPermissions(c *gin.Context) {
+	perms, err := h.roles.ListPermissions(c.Request.Context())
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, perms)
+}
+
+// CreateRole godoc
+// @Summary      Create role
+// @Tags         roles
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  CreateRoleRequest  true  "Role with permission set"
+// @Success      201  {object}  postgresql.RoleDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Router       /roles/create [post]
+func (h *handler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	if !IsValidRoleName(req.Name) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("role name must match "+ReRoleName))
+		return
+	}
+
+	role, err := h.roles.CreateRole(c.Request.Context(), &req)
+	h.recordAudit(c, AuditActionRoleCreate, req.Name, strings.Join(req.Permissions, ","), err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole godoc
+// @Summary      Update role
+// @Description  Renames a role or replaces its permission set. Holders get the new permissions on their next request.
+// @Tags         roles
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  UpdateRoleRequest  true  "Role changes"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Router       /roles/update [post]
+func (h *handler) UpdateRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	if req.Name != "" && !IsValidRoleName(req.Name) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("role name must match "+ReRoleName))
+		return
+	}
+
+	details := ""
+	if req.Permissions != nil {
+		details = "permissions=" + strings.Join(*req.Permissions, ",")
+	}
+
+	err := h.roles.UpdateRole(c.Request.Context(), &req)
+	h.recordAudit(c, AuditActionRoleUpdate, strconv.Itoa(req.RoleID), details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "Role updated"})
+}
+
+// DeleteRole godoc
+// @Summary      Delete role
+// @Description  Deletes a role with its assignments. Former holders lose its permissions on their next request
+// @Tags         roles
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  RoleIDRequest  true  "Role ID"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /roles/delete [post]
+func (h *handler) DeleteRole(c *gin.Context) {
+	var req RoleIDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	err := h.roles.DeleteRole(c.Request.Context(), req.RoleID)
+	h.recordAudit(c, AuditActionRoleDelete, strconv.Itoa(req.RoleID), "", err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "Role deleted"})
+}
+
+// ListGrants godoc
+// @Summary      List temporary grants
+// @Description  Returns the unexpired temporary grants, of one user when user_id is set
+// @Tags         grants
+// @Security     CookieAuth
+// @Param        user_id  query  int  false  "User ID"
+// @Produce      json
+// @Success      200  {array}   postgresql.GrantDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /grants [get]
+func (h *handler) ListGrants(c *gin.Context) {
+	userID, err := queryInt(c, QueryParamUserID)
+	if err != nil || userID < 0 {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("query parameter 'user_id' must be a positive number"))
+		return
+	}
+
+	grants, err := h.grants.ListGrants(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// CreateGrant godoc
+// @Summary      Grant a role or permission until a time
+// @Description  Gives the user a role or one permission until expires_at. It is revoked automatically afterwards
+// @Description  Nobody can grant to themselves.
+// @Tags         grants
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  CreateGrantRequest  true  "Grant"
+// @Success      201  {object}  postgresql.GrantDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      403  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /grants/create [post]
+func (h *handler) CreateGrant(c *gin.Context) {
+	var req CreateGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	grant, err := h.grants.Grant(c.Request.Context(), actorFrom(c), &req)
+	details := "role=" + req.Role
+	if grant != nil {
+		details = grantDetails(grant)
+	} else if req.Permission != "" {
+		details = "permission=" + req.Permission
+	}
+	h.recordAudit(c, AuditActionGrantCreate, strconv.Itoa(req.UserID), details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, grant)
+}
+
+// RevokeGrant godoc
+// @Summary      Revoke temporary grant
+// @Description  Ends a grant before its expiry. The change applies from the user's next request
+// @Tags         grants
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  GrantIDRequest  true  "Grant ID"
+// @Success      200  {object}  UserActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /grants/revoke [post]
+func (h *handler) RevokeGrant(c *gin.Context) {
+	var req GrantIDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	grant, err := h.grants.RevokeGrant(c.Request.Context(), req.GrantID)
+	target, details := "", "id="+strconv.Itoa(req.GrantID)
+	if grant != nil {
+		target, details = strconv.Itoa(grant.UserID), grantDetails(grant)
+	}
+	h.recordAudit(c, AuditActionGrantRevoke, target, details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserActionResponse{Success: true, Message: "Grant revoked"})
+}
+
+// ListAccessRequests godoc
+// @Summary      List access requests
+// @Description  Returns up to 200 access requests, newest first, optionally filtered by user and status
+// @Tags         access-requests
+// @Security     CookieAuth
+// @Param        user_id  query  int     false  "User ID"
+// @Param        status   query  string  false  "pending, approved or denied"
+// @Produce      json
+// @Success      200  {array}   postgresql.AccessRequestDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /access-requests [get]
+func (h *handler) ListAccessRequests(c *gin.Context) {
+	userID, err := queryInt(c, QueryParamUserID)
+	if err != nil || userID < 0 {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("query parameter 'user_id' must be a positive number"))
+		return
+	}
+
+	res, err := h.grants.ListAccessRequests(c.Request.Context(), userID, c.Query(QueryParamStatus))
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// ListOwnAccessRequests godoc
+// @Summary      List my access requests
+// @Tags         access-requests
+// @Security     CookieAuth
+// @Param        status  query  string  false  "pending, approved or denied"
+// @Produce      json
+// @Success      200  {array}   postgresql.AccessRequestDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /access-requests/mine [get]
+func (h *handler) ListOwnAccessRequests(c *gin.Context) {
+	res, err := h.grants.ListAccessRequests(c.Request.Context(), actorFrom(c).ID, c.Query(QueryParamStatus))
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// CreateAccessRequest godoc
+// @Summary      Request a permission
+// @Description  Asks for a permission for a number of minutes. An approver with user.roles.assign accepts or denies it
+// @Tags         access-requests
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  CreateAccessRequest  true  "Permission, reason and duration"
+// @Success      201  {object}  postgresql.AccessRequestDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Router       /access-requests/create [post]
+func (h *handler) CreateAccessRequest(c *gin.Context) {
+	var req CreateAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	actor := actorFrom(c)
+	res, err := h.grants.RequestAccess(c.Request.Context(), actor, &req)
+	details := fmt.Sprintf("permission=%s duration_minutes=%d reason=%s", req.Permission, req.DurationMinutes, req.Reason)
+	if res != nil {
+		details += " id=" + strconv.Itoa(res.ID)
+	}
+	h.recordAudit(c, AuditActionAccessRequest, strconv.Itoa(actor.ID), details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// ApproveAccessRequest godoc
+// @Summary      Approve access request
+// @Description  Grants the requested permission for the requested duration, or until expires_at when set
+// @Tags         access-requests
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  DecideAccessRequest  true  "Request ID, note and optional expiry"
+// @Success      200  {object}  postgresql.GrantDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      403  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Router       /access-requests/approve [post]
+func (h *handler) ApproveAccessRequest(c *gin.Context) {
+	var req DecideAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	grant, err := h.grants.ApproveAccessRequest(c.Request.Context(), actorFrom(c), &req)
+	target, details := strconv.Itoa(req.RequestID), "note="+req.Note
+	if grant != nil {
+		details = "user_id=" + strconv.Itoa(grant.UserID) + " " + grantDetails(grant) + " " + details
+	}
+	h.recordAudit(c, AuditActionAccessApprove, target, details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, grant)
+}
+
+// DenyAccessRequest godoc
+// @Summary      Deny access request
+// @Tags         access-requests
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  DecideAccessRequest  true  "Request ID and note"
+// @Success      200  {object}  postgresql.AccessRequestDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Router       /access-requests/deny [post]
+func (h *handler) DenyAccessRequest(c *gin.Context) {
+	var req DecideAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	res, err := h.grants.DenyAccessRequest(c.Request.Context(), actorFrom(c), &req)
+	details := "note=" + req.Note
+	if res != nil {
+		details = fmt.Sprintf("user_id=%d permission=%s %s", res.UserID, res.Permission, details)
+	}
+	h.recordAudit(c, AuditActionAccessDeny, strconv.Itoa(req.RequestID), details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *handler) recordAudit(
+	c *gin.Context,
+	action, target, details string,
+	err error,
+) {
+	actorID, actor := auth.GetActor(c)
+	h.audit.Record(
+		audit.Entry{
+			ActorID: actorID,
+			Actor:   actor,
+			Action:  action,
+			Target:  target,
+			Details: details,
+			IP:      c.ClientIP(),
+			Success: err == nil,
+		},
+	)
+}
+
+func actorFrom(c *gin.Context) Actor {
+	id, username := auth.GetActor(c)
+	return Actor{ID: id, Username: username}
+}
+
+func revokedDetails(revoked int64) string {
+	if revoked == 0 {
+		return ""
+	}
+	return fmt.Sprintf("revoked_sessions=%d", revoked)
+}
+
+func pathUserID(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID < 1 {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("path parameter 'id' must be a positive number"))
+		return 0, false
+	}
+	return userID, true
+}
+
+// queryInt returns 0 for a missing parameter so the service applies defaults.
+func queryInt(
+	c *gin.Context,
+	key string,
+) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(raw)
+}
@@ -0,0 +1,212 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+type fakeUserStore struct {
+	postgresql.UserStore
+	users   map[int]*postgresql.UserResponseDTO
+	deleted []int
+}
+
+func (f *fakeUserStore) GetUserByID(
+	_ context.Context,
+	userID int,
+) (*postgresql.UserResponseDTO, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, postgresql.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeUserStore) SetUserActive(
+	_ context.Context,
+	userID int,
+	active bool,
+) error {
+	f.users[userID].Active = active
+	return nil
+}
+
+func (f *fakeUserStore) DeleteUser(
+	_ context.Context,
+	userID int,
+) error {
+	f.deleted = append(f.deleted, userID)
+	delete(f.users, userID)
+	return nil
+}
+
+func (f *fakeUserStore) UpdatePassword(
+	_ context.Context,
+	_ int,
+	_ string,
+) error {
+	return nil
+}
+
+func (f *fakeUserStore) ListUsers(
+	_ context.Context,
+	limit, offset int,
+) ([]postgresql.UserResponseDTO, int, error) {
+	return []postgresql.UserResponseDTO{{ID: offset + 1}}, limit, nil
+}
+
+func (f *fakeUserStore) CreateUser(
+	_ context.Context,
+	_, _ string,
+	_ bool,
+) (*postgresql.UserResponseDTO, error) {
+	return nil, postgresql.ErrUserAlreadyExists
+}
+
+type fakeTokenStore struct {
+	sqlite3_local.TokenStore
+	revokedFor []string
+	revokedBy  string
+}
+
+func (f *fakeTokenStore) RevokeAllUserTokens(
+	username string,
+	_ int,
+	byUsername string,
+) (int64, error) {
+	f.revokedFor = append(f.revokedFor, username)
+	f.revokedBy = byUsername
+	return 2, nil
+}
+
+// fakePolicy rejects only the password "weak".
+type fakePolicy struct{}
+
+func (fakePolicy) Validate(_, raw string) []string {
+	if raw == "weak" {
+		return []string{"too weak"}
+	}
+	return nil
+}
+
+func newTestManagementService() (*ManagementService, *fakeUserStore, *fakeTokenStore) {
+	us := &fakeUserStore{
+		users: map[int]*postgresql.UserResponseDTO{
+			1: {ID: 1, Username: "admin", Active: true},
+			2: {ID: 2, Username: "operator", Active: true},
+		},
+	}
+	ts := &fakeTokenStore{}
+	return NewManagementService(us, ts, fakePolicy{}, zap.NewNop()), us, ts
+}
+
+func TestManagementService_DeactivateRevokesSessions(t *testing.T) {
+	svc, us, ts := newTestManagementService()
+	admin := Actor{ID: 1, Username: "admin"}
+
+	revoked, err := svc.SetActive(context.Background(), admin, 2, false)
+	if err != nil {
+		t.Fatalf("SetActive() error: %v", err)
+	}
+	if revoked != 2 || us.users[2].Active {
+		t.Errorf("revoked = %d, active = %v; want 2, false", revoked, us.users[2].Active)
+	}
+	if len(ts.revokedFor) != 1 || ts.revokedFor[0] != "operator" || ts.revokedBy != "admin" {
+		t.Errorf("revocations = %v by %q", ts.revokedFor, ts.revokedBy)
+	}
+
+	ts.revokedFor = nil
+	if _, err := svc.SetActive(context.Background(), admin, 2, true); err != nil {
+		t.Fatalf("SetActive(true) error: %v", err)
+	}
+	if len(ts.revokedFor) != 0 {
+		t.Errorf("activation must not revoke sessions, got %v", ts.revokedFor)
+	}
+}
+
+func TestManagementService_DeleteAndReset(t *testing.T) {
+	svc, us, ts := newTestManagementService()
+	admin := Actor{ID: 1, Username: "admin"}
+
+	if _, err := svc.ResetPassword(context.Background(), admin, 2, "new_password"); err != nil {
+		t.Fatalf("ResetPassword() error: %v", err)
+	}
+	if _, err := svc.Delete(context.Background(), admin, 2); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if len(us.deleted) != 1 || len(ts.revokedFor) != 2 {
+		t.Errorf("deleted = %v, revocations = %v", us.deleted, ts.revokedFor)
+	}
+
+	if _, err := svc.Delete(context.Background(), admin, 2); !errors.Is(err, apierror.Errors.USER_NOT_FOUND) {
+		t.Errorf("Delete(missing) error = %v, want USER_NOT_FOUND", err)
+	}
+}
+
+func TestManagementService_PasswordPolicy(t *testing.T) {
+	svc, _, ts := newTestManagementService()
+	admin := Actor{ID: 1, Username: "admin"}
+
+	var appErr *apierror.AppError
+	_, err := svc.ResetPassword(context.Background(), admin, 2, "weak")
+	if !errors.As(err, &appErr) || appErr.Code != "PASSWORD_POLICY_VIOLATION" {
+		t.Errorf("ResetPassword(weak) error = %v, want PASSWORD_POLICY_VIOLATION", err)
+	}
+	if len(ts.revokedFor) != 0 {
+		t.Errorf("a rejected password must not revoke sessions, got %v", ts.revokedFor)
+	}
+
+	_, err = svc.Create(context.Background(), &CreateUserRequest{Username: "new", Password: "weak"})
+	if !errors.As(err, &appErr) || appErr.Code != "PASSWORD_POLICY_VIOLATION" {
+		t.Errorf("Create(weak) error = %v, want PASSWORD_POLICY_VIOLATION", err)
+	}
+}
+
+func TestManagementService_SelfModification(t *testing.T) {
+	svc, _, ts := newTestManagementService()
+	admin := Actor{ID: 1, Username: "admin"}
+
+	if _, err := svc.SetActive(context.Background(), admin, 1, false); !errors.Is(err, apierror.Errors.USER_SELF_MODIFICATION) {
+		t.Errorf("self deactivate error = %v", err)
+	}
+	if _, err := svc.Delete(context.Background(), admin, 1); !errors.Is(err, apierror.Errors.USER_SELF_MODIFICATION) {
+		t.Errorf("self delete error = %v", err)
+	}
+	if len(ts.revokedFor) != 0 {
+		t.Errorf("no sessions should be revoked, got %v", ts.revokedFor)
+	}
+}
+
+func TestManagementService_CreateDuplicate(t *testing.T) {
+	svc, _, _ := newTestManagementService()
+
+	_, err := svc.Create(context.Background(), &CreateUserRequest{Username: "admin", Password: "password"})
+	if !errors.Is(err, apierror.Errors.USER_ALREADY_EXISTS) {
+		t.Errorf("Create() error = %v, want USER_ALREADY_EXISTS", err)
+	}
+}
+
+func TestNormalizePage(t *testing.T) {
+	tests := []struct {
+		page, size         int
+		wantPage, wantSize int
+	}{
+		{0, 0, 1, DefaultPageSize},
+		{3, 10, 3, 10},
+		{-1, 1000, 1, MaxPageSize},
+	}
+
+	for _, tt := range tests {
+		page, size := normalizePage(tt.page, tt.size)
+		if page != tt.wantPage || size != tt.wantSize {
+			t.Errorf("normalizePage(%d, %d) = %d, %d", tt.page, tt.size, page, size)
+		}
+	}
+}
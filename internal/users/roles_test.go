@@ -0,0 +1,159 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/database/postgresql"
+
+	"go.uber.org/zap"
+)
+
+type fakePermissionStore struct {
+	postgresql.PermissionStore
+	roles     map[int]*postgresql.RoleDTO
+	setPerms  []string
+	renamedTo string
+}
+
+func (f *fakePermissionStore) GetRole(
+	_ context.Context,
+	roleID int,
+) (*postgresql.RoleDTO, error) {
+	role, ok := f.roles[roleID]
+	if !ok {
+		return nil, postgresql.ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (f *fakePermissionStore) UpdateRole(
+	_ context.Context,
+	_ int,
+	name, _ string,
+	_ postgresql.RolePolicy,
+) error {
+	f.renamedTo = name
+	return nil
+}
+
+func (f *fakePermissionStore) SetRolePermissions(
+	_ context.Context,
+	_ int,
+	permissions []string,
+) error {
+	f.setPerms = permissions
+	return nil
+}
+
+func (f *fakePermissionStore) DeleteRole(
+	_ context.Context,
+	roleID int,
+) error {
+	if _, ok := f.roles[roleID]; !ok {
+		return postgresql.ErrRoleNotFound
+	}
+	delete(f.roles, roleID)
+	return nil
+}
+
+func (f *fakePermissionStore) AssignRoleToUser(
+	_ context.Context,
+	_ int,
+	roleName string,
+) error {
+	if roleName != "operator" {
+		return postgresql.ErrRoleNotFound
+	}
+	return nil
+}
+
+func newTestRoleService() (*RoleService, *fakePermissionStore) {
+	ps := &fakePermissionStore{
+		roles: map[int]*postgresql.RoleDTO{3: {ID: 3, Name: "operator"}},
+	}
+	_, us, _ := newTestManagementService()
+	return NewRoleService(ps, us, zap.NewNop()), ps
+}
+
+func TestRoleService_UpdateRole(t *testing.T) {
+	t.Run(
+		"rename", func(t *testing.T) {
+			svc, ps := newTestRoleService()
+			if err := svc.UpdateRole(context.Background(), &UpdateRoleRequest{RoleID: 3, Name: "ops"}); err != nil {
+				t.Fatalf("UpdateRole() error: %v", err)
+			}
+			if ps.renamedTo != "ops" || ps.setPerms != nil {
+				t.Errorf("renamed = %q, permissions = %v", ps.renamedTo, ps.setPerms)
+			}
+		},
+	)
+
+	t.Run(
+		"permission change", func(t *testing.T) {
+			svc, ps := newTestRoleService()
+			perms := []string{"pm2.view.basic", "f2b.view.status", "pm2.view.basic"}
+			if err := svc.UpdateRole(context.Background(), &UpdateRoleRequest{RoleID: 3, Permissions: &perms}); err != nil {
+				t.Fatalf("UpdateRole() error: %v", err)
+			}
+			if !slices.Equal(ps.setPerms, []string{"f2b.view.status", "pm2.view.basic"}) {
+				t.Errorf("permissions = %v, want sorted and deduplicated", ps.setPerms)
+			}
+		},
+	)
+
+	t.Run(
+		"missing role", func(t *testing.T) {
+			svc, _ := newTestRoleService()
+			err := svc.UpdateRole(context.Background(), &UpdateRoleRequest{RoleID: 9, Name: "x"})
+			if !errors.Is(err, apierror.Errors.ROLE_NOT_FOUND) {
+				t.Errorf("error = %v, want ROLE_NOT_FOUND", err)
+			}
+		},
+	)
+}
+
+func TestRoleService_DeleteRole(t *testing.T) {
+	svc, ps := newTestRoleService()
+
+	if err := svc.DeleteRole(context.Background(), 3); err != nil {
+		t.Fatalf("DeleteRole() error: %v", err)
+	}
+	if _, ok := ps.roles[3]; ok {
+		t.Error("role should be deleted")
+	}
+	if err := svc.DeleteRole(context.Background(), 3); !errors.Is(err, apierror.Errors.ROLE_NOT_FOUND) {
+		t.Errorf("second delete error = %v, want ROLE_NOT_FOUND", err)
+	}
+}
+
+func TestRoleService_AssignRole(t *testing.T) {
+	svc, _ := newTestRoleService()
+
+	if err := svc.AssignRole(context.Background(), 2, "operator"); err != nil {
+		t.Fatalf("AssignRole() error: %v", err)
+	}
+	if err := svc.AssignRole(context.Background(), 2, "missing"); !errors.Is(err, apierror.Errors.ROLE_NOT_FOUND) {
+		t.Errorf("unknown role error = %v", err)
+	}
+	if err := svc.AssignRole(context.Background(), 42, "operator"); !errors.Is(err, apierror.Errors.USER_NOT_FOUND) {
+		t.Errorf("unknown user error = %v", err)
+	}
+}
+
+func TestIsValidRoleName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"operator":    true,
+		"super_admin": true,
+		"a":           false,
+		"bad name":    false,
+		"x;drop":      false,
+	} {
+		if got := IsValidRoleName(name); got != want {
+			t.Errorf("IsValidRoleName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
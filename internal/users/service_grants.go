@@ -0,0 +1,284 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/permission"
+
+	"go.uber.org/zap"
+)
+
+var _ grantManager = (*GrantService)(nil)
+
+// GrantService gives roles and permissions for a limited time, either
+// directly or by approving a user's access request, but never to the actor
+// themselves. Grants count as soon as they are stored and stop counting at
+// their expiry; Run deletes expired grants, drops the holders from the
+// permission cache and records it.
+type GrantService struct {
+	store       postgresql.GrantStore
+	cache       permissionInvalidator
+	audit       audit.Recorder
+	maxDuration time.Duration
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+func NewGrantService(
+	cfg config.GrantsConfig,
+	store postgresql.GrantStore,
+	cache permissionInvalidator,
+	ar audit.Recorder,
+	logger *zap.Logger,
+) *GrantService {
+	return &GrantService{
+		store:       store,
+		cache:       cache,
+		audit:       ar,
+		maxDuration: cfg.MaxDuration,
+		now:         time.Now,
+		logger:      logger.Named("grants"),
+	}
+}
+
+func (s *GrantService) ListGrants(
+	ctx context.Context,
+	userID int,
+) ([]postgresql.GrantDTO, error) {
+	grants, err := s.store.ListGrants(ctx, userID)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return grants, nil
+}
+
+func (s *GrantService) Grant(
+	ctx context.Context,
+	actor Actor,
+	req *CreateGrantRequest,
+) (*postgresql.GrantDTO, error) {
+	switch {
+	case req.UserID == actor.ID:
+		return nil, apierror.Errors.ACCESS_REQUEST_SELF_APPROVAL
+	case (req.Role == "") == (req.Permission == ""):
+		return nil, apierror.Errors.INVALID_REQUEST.WithMeta("exactly one of 'role' and 'permission' is required")
+	case req.Role != "" && !IsValidRoleName(req.Role):
+		return nil, apierror.Errors.INVALID_REQUEST.WithMeta("role name must match " + ReRoleName)
+	case req.Permission != "":
+		if err := checkGrantablePermission(req.Permission); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkExpiry(req.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	grant, err := s.store.CreateGrant(
+		ctx,
+		postgresql.GrantDTO{
+			UserID:     req.UserID,
+			Role:       req.Role,
+			Permission: req.Permission,
+			Reason:     req.Reason,
+			GrantedBy:  actor.Username,
+			ExpiresAt:  req.ExpiresAt,
+		},
+	)
+	if err != nil {
+		return nil, mapGrantError(err)
+	}
+	s.cache.Invalidate(grant.UserID)
+	return grant, nil
+}
+
+func (s *GrantService) RevokeGrant(
+	ctx context.Context,
+	grantID int,
+) (*postgresql.GrantDTO, error) {
+	grant, err := s.store.RevokeGrant(ctx, grantID)
+	if err != nil {
+		return nil, mapGrantError(err)
+	}
+	s.cache.Invalidate(grant.UserID)
+	return grant, nil
+}
+
+// RequestAccess files a request of the actor for a permission. A user has at
+// most one pending request per permission.
+func (s *GrantService) RequestAccess(
+	ctx context.Context,
+	actor Actor,
+	req *CreateAccessRequest,
+) (*postgresql.AccessRequestDTO, error) {
+	if err := checkGrantablePermission(req.Permission); err != nil {
+		return nil, err
+	}
+	if time.Duration(req.DurationMinutes)*time.Minute > s.maxDuration {
+		return nil, apierror.Errors.GRANT_EXPIRY_INVALID.WithMeta(map[string]any{"max_duration": s.maxDuration.String()})
+	}
+
+	res, err := s.store.CreateAccessRequest(ctx, actor.ID, req.Permission, req.Reason, req.DurationMinutes)
+	if err != nil {
+		return nil, mapGrantError(err)
+	}
+	return res, nil
+}
+
+// ListAccessRequests filters by user unless userID is 0 and by status unless
+// status is empty.
+func (s *GrantService) ListAccessRequests(
+	ctx context.Context,
+	userID int,
+	status string,
+) ([]postgresql.AccessRequestDTO, error) {
+	switch status {
+	case "", postgresql.AccessRequestPending, postgresql.AccessRequestApproved, postgresql.AccessRequestDenied:
+	default:
+		return nil, apierror.Errors.INVALID_REQUEST.WithMeta(map[string]any{"status": status})
+	}
+
+	res, err := s.store.ListAccessRequests(ctx, userID, status)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	return res, nil
+}
+
+// ApproveAccessRequest grants the requested permission for the requested
+// duration, or until req.ExpiresAt when the approver sets it. Nobody approves
+// their own request.
+func (s *GrantService) ApproveAccessRequest(
+	ctx context.Context,
+	actor Actor,
+	req *DecideAccessRequest,
+) (*postgresql.GrantDTO, error) {
+	request, err := s.store.GetAccessRequest(ctx, req.RequestID)
+	if err != nil {
+		return nil, mapGrantError(err)
+	}
+	if request.UserID == actor.ID {
+		return nil, apierror.Errors.ACCESS_REQUEST_SELF_APPROVAL
+	}
+	if request.Status != postgresql.AccessRequestPending {
+		return nil, apierror.Errors.ACCESS_REQUEST_ALREADY_DECIDED
+	}
+
+	expiresAt := s.now().Add(time.Duration(request.DurationMinutes) * time.Minute)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if err := s.checkExpiry(expiresAt); err != nil {
+		return nil, err
+	}
+
+	grant, err := s.store.ApproveAccessRequest(ctx, request.ID, actor.Username, req.Note, expiresAt)
+	if err != nil {
+		return nil, mapGrantError(err)
+	}
+	s.cache.Invalidate(grant.UserID)
+	return grant, nil
+}
+
+func (s *GrantService) DenyAccessRequest(
+	ctx context.Context,
+	actor Actor,
+	req *DecideAccessRequest,
+) (*postgresql.AccessRequestDTO, error) {
+	res, err := s.store.DenyAccessRequest(ctx, req.RequestID, actor.Username, req.Note)
+	if err != nil {
+		return nil, mapGrantError(err)
+	}
+	return res, nil
+}
+
+// Run removes expired grants every minute until ctx is cancelled.
+func (s *GrantService) Run(ctx context.Context) {
+	ticker := time.NewTicker(grantSweepInterval)
+	defer ticker.Stop()
+	for {
+		s.expireGrants(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *GrantService) expireGrants(ctx context.Context) {
+	expired, err := s.store.DeleteExpiredGrants(ctx)
+	if err != nil {
+		s.logger.Error("Failed to delete expired grants", zap.Error(err))
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	userIDs := make([]int, 0, len(expired))
+	for _, g := range expired {
+		userIDs = append(userIDs, g.UserID)
+		s.audit.Record(
+			audit.Entry{
+				Actor:   AuditActorGrantExpiry,
+				Action:  AuditActionGrantExpire,
+				Target:  strconv.Itoa(g.UserID),
+				Details: grantDetails(&g),
+				Success: true,
+			},
+		)
+	}
+	s.cache.Invalidate(userIDs...)
+	s.logger.Info("Expired grants removed", zap.Int("count", len(expired)))
+}
+
+func (s *GrantService) checkExpiry(expiresAt time.Time) error {
+	now := s.now()
+	if !expiresAt.After(now) || expiresAt.Sub(now) > s.maxDuration {
+		return apierror.Errors.GRANT_EXPIRY_INVALID.WithMeta(map[string]any{"max_duration": s.maxDuration.String()})
+	}
+	return nil
+}
+
+// checkGrantablePermission accepts names and wildcards. A deny entry would
+// take a right away rather than give one, so it cannot be granted.
+func checkGrantablePermission(p string) error {
+	if !permission.Valid(p) || permission.IsDeny(p) {
+		return apierror.Errors.INVALID_REQUEST.WithMeta(map[string]any{"permission": p})
+	}
+	return nil
+}
+
+// grantDetails describes a grant for the audit log.
+func grantDetails(g *postgresql.GrantDTO) string {
+	details := "permission=" + g.Permission
+	if g.Role != "" {
+		details = "role=" + g.Role
+	}
+	details += " expires_at=" + g.ExpiresAt.UTC().Format(time.RFC3339) + " id=" + strconv.Itoa(g.ID)
+	if g.RequestID != nil {
+		details += " request_id=" + strconv.Itoa(*g.RequestID)
+	}
+	return details
+}
+
+func mapGrantError(err error) error {
+	switch {
+	case errors.Is(err, postgresql.ErrGrantNotFound):
+		return apierror.Errors.GRANT_NOT_FOUND
+	case errors.Is(err, postgresql.ErrAccessRequestNotFound):
+		return apierror.Errors.ACCESS_REQUEST_NOT_FOUND
+	case errors.Is(err, postgresql.ErrAccessRequestDecided):
+		return apierror.Errors.ACCESS_REQUEST_ALREADY_DECIDED
+	case errors.Is(err, postgresql.ErrAccessRequestDuplicate):
+		return apierror.Errors.ACCESS_REQUEST_DUPLICATE
+	}
+	return mapRoleError(err)
+}
@@ -0,0 +1,214 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/permission"
+
+	"go.uber.org/zap"
+)
+
+var roleNameRegex = regexp.MustCompile(ReRoleName)
+
+var _ roleManager = (*RoleService)(nil)
+
+// RoleService administers roles, their permission sets and role assignments.
+// Sessions are left alone: the permission store given here is expected to be
+// the auth permission cache, which invalidates the affected users on change,
+// so the new permission set applies from their next request.
+type RoleService struct {
+	perms  postgresql.PermissionStore
+	users  postgresql.UserStore
+	logger *zap.Logger
+}
+
+func NewRoleService(
+	ps postgresql.PermissionStore,
+	us postgresql.UserStore,
+	logger *zap.Logger,
+) *RoleService {
+	return &RoleService{
+		perms:  ps,
+		users:  us,
+		logger: logger.Named("roles"),
+	}
+}
+
+// IsValidRoleName reports whether name is acceptable as a role name.
+func IsValidRoleName(name string) bool {
+	return roleNameRegex.MatchString(name)
+}
+
+func (s *RoleService) ListRoles(ctx context.Context) ([]postgresql.RoleDTO, error) {
+	roles, err := s.perms.GetAllRoles(ctx)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	res := make([]postgresql.RoleDTO, 0, len(roles))
+	for _, role := range roles {
+		role.Permissions, err = s.perms.GetRolePermissions(ctx, role.ID)
+		if err != nil {
+			return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+		}
+		res = append(res, role)
+	}
+	return res, nil
+}
+
+func (s *RoleService) ListPermissions(ctx context.Context) ([]postgresql.PermissionDTO, error) {
+	perms, err := s.perms.GetAllPermissions(ctx)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if perms == nil {
+		perms = []postgresql.PermissionDTO{}
+	}
+	return perms, nil
+}
+
+func (s *RoleService) GetUserPermissions(
+	ctx context.Context,
+	userID int,
+) (*postgresql.UserPermissionsDTO, error) {
+	res, err := s.perms.GetUserFullPermissions(ctx, userID)
+	if err != nil {
+		return nil, mapRoleError(err)
+	}
+	return res, nil
+}
+
+func (s *RoleService) AssignRole(
+	ctx context.Context,
+	userID int,
+	role string,
+) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return mapStoreError(err)
+	}
+	if err := s.perms.AssignRoleToUser(ctx, userID, role); err != nil {
+		return mapRoleError(err)
+	}
+	return nil
+}
+
+func (s *RoleService) RemoveRole(
+	ctx context.Context,
+	userID int,
+	role string,
+) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return mapStoreError(err)
+	}
+	if err := s.perms.RemoveRoleFromUser(ctx, userID, role); err != nil {
+		return mapRoleError(err)
+	}
+	return nil
+}
+
+func (s *RoleService) CreateRole(
+	ctx context.Context,
+	req *CreateRoleRequest,
+) (*postgresql.RoleDTO, error) {
+	if err := checkPermissionEntries(req.Permissions); err != nil {
+		return nil, err
+	}
+	policy := postgresql.RolePolicy{RequireTwoFactor: req.RequireTwoFactor, SingleSession: req.SingleSession}
+	role, err := s.perms.CreateRole(
+		ctx, req.Name, req.Description, policy, normalizePermissions(req.Permissions),
+	)
+	if err != nil {
+		return nil, mapRoleError(err)
+	}
+	return role, nil
+}
+
+// UpdateRole changes the name, description and login policy and, when
+// req.Permissions is set, replaces the permission set of the role.
+func (s *RoleService) UpdateRole(
+	ctx context.Context,
+	req *UpdateRoleRequest,
+) error {
+	if req.Permissions != nil {
+		if err := checkPermissionEntries(*req.Permissions); err != nil {
+			return err
+		}
+	}
+	role, err := s.perms.GetRole(ctx, req.RoleID)
+	if err != nil {
+		return mapRoleError(err)
+	}
+
+	name, description, policy := role.Name, role.Description, role.RolePolicy
+	if req.Name != "" {
+		name = req.Name
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+	if req.RequireTwoFactor != nil {
+		policy.RequireTwoFactor = *req.RequireTwoFactor
+	}
+	if req.SingleSession != nil {
+		policy.SingleSession = *req.SingleSession
+	}
+	if name != role.Name || description != role.Description || policy != role.RolePolicy {
+		if err := s.perms.UpdateRole(ctx, role.ID, name, description, policy); err != nil {
+			return mapRoleError(err)
+		}
+	}
+
+	if req.Permissions == nil {
+		return nil
+	}
+	if err := s.perms.SetRolePermissions(ctx, role.ID, normalizePermissions(*req.Permissions)); err != nil {
+		return mapRoleError(err)
+	}
+	return nil
+}
+
+func (s *RoleService) DeleteRole(
+	ctx context.Context,
+	roleID int,
+) error {
+	if err := s.perms.DeleteRole(ctx, roleID); err != nil {
+		return mapRoleError(err)
+	}
+	return nil
+}
+
+func mapRoleError(err error) error {
+	switch {
+	case errors.Is(err, postgresql.ErrRoleNotFound):
+		return apierror.Errors.ROLE_NOT_FOUND
+	case errors.Is(err, postgresql.ErrRoleAlreadyExists):
+		return apierror.Errors.ROLE_ALREADY_EXISTS
+	case errors.Is(err, postgresql.ErrPermissionNotFound):
+		return apierror.Errors.PERMISSION_NOT_FOUND
+	}
+	return mapStoreError(err)
+}
+
+// checkPermissionEntries rejects malformed names, wildcards and deny entries
+// before they reach the store.
+func checkPermissionEntries(perms []string) error {
+	for _, p := range perms {
+		if !permission.Valid(p) {
+			return apierror.Errors.INVALID_REQUEST.WithMeta(map[string]any{"permission": p})
+		}
+	}
+	return nil
+}
+
+// normalizePermissions sorts and deduplicates permission names so the
+// repository can detect unknown names by comparing row counts.
+func normalizePermissions(perms []string) []string {
+	res := slices.Clone(perms)
+	slices.Sort(res)
+	return slices.Compact(res)
+}
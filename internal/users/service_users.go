@@ -0,0 +1,208 @@
+package users
+
+import (
+	"context"
+	"errors"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/database/postgresql"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+var _ userManager = (*ManagementService)(nil)
+
+// ManagementService administers accounts in the user table. Any change that
+// must lock a user out (deactivation, deletion, password reset) also revokes
+// the user's sessions, since access tokens are otherwise valid until expiry.
+type ManagementService struct {
+	users  postgresql.UserStore
+	tokens sqlite3_local.TokenStore
+	policy passwordPolicy
+	logger *zap.Logger
+}
+
+func NewManagementService(
+	us postgresql.UserStore,
+	ts sqlite3_local.TokenStore,
+	pp passwordPolicy,
+	logger *zap.Logger,
+) *ManagementService {
+	return &ManagementService{
+		users:  us,
+		tokens: ts,
+		policy: pp,
+		logger: logger.Named("users"),
+	}
+}
+
+func (s *ManagementService) List(
+	ctx context.Context,
+	page, pageSize int,
+) (*UserListResponse, error) {
+	page, pageSize = normalizePage(page, pageSize)
+
+	list, total, err := s.users.ListUsers(ctx, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+
+	return &UserListResponse{
+		Users:    list,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) Get(
+	ctx context.Context,
+	userID int,
+) (*postgresql.UserResponseDTO, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+	return user, nil
+}
+
+func (s *ManagementService) Create(
+	ctx context.Context,
+	req *CreateUserRequest,
+) (*postgresql.UserResponseDTO, error) {
+	if err := s.checkPolicy(req.Username, req.Password); err != nil {
+		return nil, err
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	user, err := s.users.CreateUser(ctx, req.Username, req.Password, active)
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+	return user, nil
+}
+
+// SetActive returns the number of sessions revoked by a deactivation.
+func (s *ManagementService) SetActive(
+	ctx context.Context,
+	actor Actor,
+	userID int,
+	active bool,
+) (int64, error) {
+	if !active && actor.ID == userID {
+		return 0, apierror.Errors.USER_SELF_MODIFICATION
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, mapStoreError(err)
+	}
+
+	if err := s.users.SetUserActive(ctx, userID, active); err != nil {
+		return 0, mapStoreError(err)
+	}
+
+	if active {
+		return 0, nil
+	}
+	return s.revokeSessions(actor, user.Username)
+}
+
+func (s *ManagementService) Delete(
+	ctx context.Context,
+	actor Actor,
+	userID int,
+) (int64, error) {
+	if actor.ID == userID {
+		return 0, apierror.Errors.USER_SELF_MODIFICATION
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, mapStoreError(err)
+	}
+
+	if err := s.users.DeleteUser(ctx, userID); err != nil {
+		return 0, mapStoreError(err)
+	}
+
+	return s.revokeSessions(actor, user.Username)
+}
+
+func (s *ManagementService) ResetPassword(
+	ctx context.Context,
+	actor Actor,
+	userID int,
+	password string,
+) (int64, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, mapStoreError(err)
+	}
+	if err := s.checkPolicy(user.Username, password); err != nil {
+		return 0, err
+	}
+
+	if err := s.users.UpdatePassword(ctx, userID, password); err != nil {
+		return 0, mapStoreError(err)
+	}
+
+	return s.revokeSessions(actor, user.Username)
+}
+
+func (s *ManagementService) revokeSessions(
+	actor Actor,
+	username string,
+) (int64, error) {
+	revoked, err := s.tokens.RevokeAllUserTokens(username, actor.ID, actor.Username)
+	if err != nil {
+		s.logger.Error("Failed to revoke user sessions", zap.String("username", username), zap.Error(err))
+		return 0, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if revoked > 0 {
+		s.logger.Info(
+			"User sessions revoked",
+			zap.String("username", username),
+			zap.Int64("revoked", revoked),
+			zap.String("by", actor.Username),
+		)
+	}
+	return revoked, nil
+}
+
+func (s *ManagementService) checkPolicy(
+	username, password string,
+) error {
+	if violations := s.policy.Validate(username, password); len(violations) > 0 {
+		return apierror.Errors.PASSWORD_POLICY_VIOLATION.WithMeta(map[string]any{"violations": violations})
+	}
+	return nil
+}
+
+func mapStoreError(err error) error {
+	switch {
+	case errors.Is(err, postgresql.ErrUserNotFound):
+		return apierror.Errors.USER_NOT_FOUND
+	case errors.Is(err, postgresql.ErrUserAlreadyExists):
+		return apierror.Errors.USER_ALREADY_EXISTS
+	}
+	return apierror.Errors.DATABASE_ERROR.Wrap(err)
+}
+
+func normalizePage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize
+}
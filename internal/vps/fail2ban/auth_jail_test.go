@@ -0,0 +1,134 @@
+package fail2ban
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/auth"
+	"VPS-control/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func testAuthJailConfig(dir string) config.Fail2BanConfig {
+	return config.Fail2BanConfig{
+		ConfigDir: dir,
+		AuthJail: config.AuthJailConfig{
+			Enabled:  true,
+			Name:     "vps-control-auth",
+			LogPath:  filepath.Join(dir, "log", "auth-failures.log"),
+			Port:     "http,https",
+			MaxRetry: 10,
+			FindTime: 10 * time.Minute,
+			BanTime:  time.Hour,
+		},
+	}
+}
+
+func TestInstallAuthJail(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testAuthJailConfig(dir)
+
+	res, err := InstallAuthJail(cfg)
+	if err != nil {
+		t.Fatalf("InstallAuthJail() error: %v", err)
+	}
+
+	filter, err := os.ReadFile(res.FilterPath)
+	if err != nil {
+		t.Fatalf("read filter: %v", err)
+	}
+	if string(filter) != string(AuthFilterContent()) {
+		t.Error("installed filter differs from embedded one")
+	}
+
+	jail, err := os.ReadFile(res.JailPath)
+	if err != nil {
+		t.Fatalf("read jail: %v", err)
+	}
+	for _, want := range []string{
+		"[vps-control-auth]",
+		"enabled  = true",
+		"filter   = vps-control-auth",
+		"logpath  = " + cfg.AuthJail.LogPath,
+		"maxretry = 10",
+		"findtime = 600",
+		"bantime  = 3600",
+	} {
+		if !strings.Contains(string(jail), want) {
+			t.Errorf("jail file missing %q:\n%s", want, jail)
+		}
+	}
+
+	if _, err := os.Stat(cfg.AuthJail.LogPath); err != nil {
+		t.Errorf("log file should exist after install: %v", err)
+	}
+
+	// Повторная установка не должна трогать уже существующий лог
+	if err := os.WriteFile(cfg.AuthJail.LogPath, []byte("keep\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallAuthJail(cfg); err != nil {
+		t.Fatalf("second InstallAuthJail() error: %v", err)
+	}
+	if data, _ := os.ReadFile(cfg.AuthJail.LogPath); string(data) != "keep\n" {
+		t.Errorf("log file was truncated: %q", data)
+	}
+}
+
+func TestInstallAuthJail_InvalidName(t *testing.T) {
+	cfg := testAuthJailConfig(t.TempDir())
+	cfg.AuthJail.Name = "bad name"
+
+	if _, err := InstallAuthJail(cfg); err == nil {
+		t.Error("expected error for invalid jail name")
+	}
+}
+
+func TestAuthFilter_MatchesFailureLog(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testAuthJailConfig(dir)
+	if _, err := InstallAuthJail(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	failureLog := auth.NewFailureLogService(cfg.AuthJail, zap.NewNop())
+	failureLog.LogFailure("203.0.113.7", "admin", auth.FailureReasonBadPassword)
+	failureLog.LogFailure("2001:db8::1", "ghost", auth.FailureReasonUnknownUser)
+	failureLog.LogFailure("198.51.100.1", `x" reason=a from 10.0.0.1`, auth.FailureReasonBadPassword)
+
+	tester := NewRegexTester(
+		config.Fail2BanConfig{
+			ConfigDir: dir,
+			RegexTest: config.RegexTestConfig{
+				LogAllowlist: []string{cfg.AuthJail.LogPath},
+				MaxLines:     100,
+				Timeout:      time.Second,
+			},
+		},
+		zap.NewNop(),
+	)
+	res, err := tester.Test(
+		&RegexTestInput{
+			Filter:  AuthFilterName,
+			LogPath: cfg.AuthJail.LogPath,
+			Engine:  EngineBuiltin,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Test() error: %v", err)
+	}
+
+	wantHosts := []string{"203.0.113.7", "2001:db8::1", "198.51.100.1"}
+	if res.MatchedCount != len(wantHosts) || len(res.Matches) != len(wantHosts) {
+		t.Fatalf("matched %d lines, want %d: %+v", res.MatchedCount, len(wantHosts), res.Matches)
+	}
+	for i, want := range wantHosts {
+		if res.Matches[i].Host != want {
+			t.Errorf("match %d host = %q, want %q", i, res.Matches[i].Host, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package fail2ban
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type fakeBanSource struct {
+	bans     map[string][]string
+	statusEr error
+	jailErr  map[string]error
+}
+
+func (s *fakeBanSource) GetGlobalStatus() (*Fail2BanStatusDTO, error) {
+	if s.statusEr != nil {
+		return nil, s.statusEr
+	}
+	res := &Fail2BanStatusDTO{JailList: []string{}}
+	for jail := range s.bans {
+		res.JailList = append(res.JailList, jail)
+	}
+	res.JailCount = len(res.JailList)
+	return res, nil
+}
+
+func (s *fakeBanSource) GetJailDetails(jail string) (*JailDetailsDTO, error) {
+	if err := s.jailErr[jail]; err != nil {
+		return nil, err
+	}
+	return &JailDetailsDTO{JailName: jail, BannedIPList: s.bans[jail]}, nil
+}
+
+type fakeBanEventStore struct {
+	events []sqlite3_local.BanEventEntity
+	purged int64
+}
+
+func (s *fakeBanEventStore) RecordBanEvents(events []sqlite3_local.BanEventEntity) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *fakeBanEventStore) GetBanEvents(filter sqlite3_local.BanEventFilter) ([]sqlite3_local.BanEventEntity, error) {
+	return s.events, nil
+}
+
+func (s *fakeBanEventStore) DeleteBanEventsBefore(before int64) error {
+	s.purged = before
+	return nil
+}
+
+func newTestBanWatcher(source *fakeBanSource) (*BanWatcher, *fakeBanEventStore, *fakePublisher) {
+	store := &fakeBanEventStore{}
+	publisher := &fakePublisher{}
+	w := NewBanWatcher(
+		config.BanWatcherConfig{Enabled: true, Interval: time.Second, Retention: 24 * time.Hour},
+		source, store, publisher, zap.NewNop(),
+	)
+	w.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	return w, store, publisher
+}
+
+func eventKeys(events []sqlite3_local.BanEventEntity) []string {
+	keys := make([]string, 0, len(events))
+	for _, e := range events {
+		keys = append(keys, e.Event+" "+e.Jail+" "+e.IP)
+	}
+	return keys
+}
+
+func TestBanWatcher_Poll(t *testing.T) {
+	source := &fakeBanSource{
+		bans: map[string][]string{
+			"sshd":  {"1.1.1.1", "2.2.2.2"},
+			"nginx": {"3.3.3.3"},
+		},
+	}
+	w, store, publisher := newTestBanWatcher(source)
+
+	w.poll()
+	if len(store.events) != 0 || len(publisher.subjects) != 0 {
+		t.Fatalf("baseline poll must not emit events, got %v", eventKeys(store.events))
+	}
+
+	source.bans["sshd"] = []string{"2.2.2.2", "4.4.4.4"}
+	delete(source.bans, "nginx")
+	w.poll()
+
+	want := []string{
+		"removed nginx 3.3.3.3",
+		"added sshd 4.4.4.4",
+		"removed sshd 1.1.1.1",
+	}
+	got := eventKeys(store.events)
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	wantSubjects := []string{SubjectBanRemoved, SubjectBanAdded, SubjectBanRemoved}
+	for i, subject := range wantSubjects {
+		if publisher.subjects[i] != subject {
+			t.Errorf("subject[%d] = %q, want %q", i, publisher.subjects[i], subject)
+		}
+	}
+}
+
+func TestBanWatcher_KeepsSnapshotOnErrors(t *testing.T) {
+	source := &fakeBanSource{bans: map[string][]string{"sshd": {"1.1.1.1"}}}
+	w, store, _ := newTestBanWatcher(source)
+	w.poll()
+
+	source.jailErr = map[string]error{"sshd": errors.New("timeout")}
+	w.poll()
+	if len(store.events) != 0 {
+		t.Fatalf("jail error must not look like unbans, got %v", eventKeys(store.events))
+	}
+
+	source.jailErr = nil
+	source.statusEr = errors.New("socket error")
+	w.poll()
+	if len(store.events) != 0 {
+		t.Fatalf("status error must not emit events, got %v", eventKeys(store.events))
+	}
+
+	source.statusEr = nil
+	source.bans["sshd"] = nil
+	w.poll()
+	if got := eventKeys(store.events); len(got) != 1 || got[0] != "removed sshd 1.1.1.1" {
+		t.Errorf("events = %v, want one removal", got)
+	}
+}
+
+func TestBanWatcher_PurgesOldEvents(t *testing.T) {
+	w, store, _ := newTestBanWatcher(&fakeBanSource{bans: map[string][]string{}})
+	w.poll()
+
+	want := w.now().Add(-24 * time.Hour).Unix()
+	if store.purged != want {
+		t.Errorf("purged before %d, want %d", store.purged, want)
+	}
+}
+
+func TestParseBanEventFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    sqlite3_local.BanEventFilter
+		wantErr bool
+	}{
+		{"defaults", "", sqlite3_local.BanEventFilter{Limit: BanEventsDefaultLimit}, false},
+		{
+			"all filters", "?name=sshd&ip=1.2.3.4&since=1700000000&limit=10",
+			sqlite3_local.BanEventFilter{Jail: "sshd", IP: "1.2.3.4", Since: 1700000000, Limit: 10}, false,
+		},
+		{"bad jail", "?name=bad%20jail", sqlite3_local.BanEventFilter{}, true},
+		{"bad ip", "?ip=nope", sqlite3_local.BanEventFilter{}, true},
+		{"bad since", "?since=yesterday", sqlite3_local.BanEventFilter{}, true},
+		{"limit too big", "?limit=5000", sqlite3_local.BanEventFilter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest(http.MethodGet, "/events"+tt.query, nil)
+
+				got, err := parseBanEventFilter(c)
+				if tt.wantErr {
+					if err == nil {
+						t.Errorf("expected error, got %+v", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("filter = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,324 @@
+package fail2ban
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+// fakeBanController bans into the jails of source, so the importer sees its
+// own bans when it reads the jail back.
+type fakeBanController struct {
+	source   *fakeBanSource
+	bans     int
+	unbanned []string
+	failIP   string
+}
+
+func (f *fakeBanController) BanIP(jail, ip string) error {
+	if ip == f.failIP {
+		return apierror.Errors.FAIL2BAN_EXECUTION_ERROR
+	}
+	f.bans++
+	f.jail(jail, ip)
+	return nil
+}
+
+func (f *fakeBanController) UnbanIP(jail, ip string) error {
+	if !f.banned(jail, ip) {
+		return apierror.Errors.FAIL2BAN_IP_NOT_BANNED
+	}
+	f.lift(jail, ip)
+	f.unbanned = append(f.unbanned, ip)
+	return nil
+}
+
+// jail bans ip the way fail2ban does on its own.
+func (f *fakeBanController) jail(jail, ip string) {
+	if !f.banned(jail, ip) {
+		f.source.bans[jail] = append(f.source.bans[jail], ip)
+	}
+}
+
+// lift removes the ban the way the jail's bantime does.
+func (f *fakeBanController) lift(jail, ip string) {
+	f.source.bans[jail] = slices.DeleteFunc(f.source.bans[jail], func(b string) bool { return b == ip })
+}
+
+func (f *fakeBanController) banned(jail, ip string) bool {
+	return slices.Contains(f.source.bans[jail], ip)
+}
+
+type fakeImportedBanStore struct {
+	bans map[string]sqlite3_local.ImportedBanEntity
+}
+
+func (s *fakeImportedBanStore) UpsertImportedBan(ban sqlite3_local.ImportedBanEntity) error {
+	s.bans[ban.IP] = ban
+	return nil
+}
+
+func (s *fakeImportedBanStore) GetImportedBans() ([]sqlite3_local.ImportedBanEntity, error) {
+	var res []sqlite3_local.ImportedBanEntity
+	for _, b := range s.bans {
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func (s *fakeImportedBanStore) GetExpiredImportedBans(now int64) ([]sqlite3_local.ImportedBanEntity, error) {
+	var res []sqlite3_local.ImportedBanEntity
+	for _, b := range s.bans {
+		if b.ExpiresAt <= now {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeImportedBanStore) DeleteImportedBan(ip string) error {
+	delete(s.bans, ip)
+	return nil
+}
+
+func TestParseBlocklist(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        []string
+		wantInvalid int
+	}{
+		{
+			name:  "plain text",
+			input: "# header\n1.2.3.4\n\n5.6.7.8  ; comment\n2001:db8::1 extra column\nnot-an-ip\n",
+			want:  []string{"1.2.3.4", "5.6.7.8", "2001:db8::1"}, wantInvalid: 1,
+		},
+		{
+			name:  "json feed",
+			input: `{"generated_at":"2026-10-18T12:00:00Z","count":2,"entries":[{"ip":"1.2.3.4","jails":["sshd"]},{"ip":"bad","jails":[]}]}`,
+			want:  []string{"1.2.3.4"}, wantInvalid: 1,
+		},
+		{name: "broken json", input: `{"entries":`, want: nil, wantInvalid: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, invalid := parseBlocklist([]byte(tt.input))
+				if strings.Join(got, ",") != strings.Join(tt.want, ",") || invalid != tt.wantInvalid {
+					t.Errorf("parseBlocklist() = %v, %d; want %v, %d", got, invalid, tt.want, tt.wantInvalid)
+				}
+			},
+		)
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	nets, invalid := parseAllowlist([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32", "bogus", "1.2.3.4/99"})
+	if len(nets) != 3 || len(invalid) != 2 {
+		t.Fatalf("parseAllowlist() = %d nets, invalid %v", len(nets), invalid)
+	}
+}
+
+func newTestBlocklistService(
+	t *testing.T,
+	sources []config.BlocklistSource,
+	source *fakeBanSource,
+) (*BlocklistService, *fakeBanController, *fakeImportedBanStore, *time.Time) {
+	t.Helper()
+	if source == nil {
+		source = &fakeBanSource{bans: map[string][]string{}}
+	}
+	banner := &fakeBanController{source: source}
+	store := &fakeImportedBanStore{bans: map[string]sqlite3_local.ImportedBanEntity{}}
+	svc := NewBlocklistService(
+		config.BlocklistImportConfig{
+			Enabled:    true,
+			Jail:       "vps-control-auth",
+			Interval:   time.Hour,
+			Expiry:     time.Hour,
+			Timeout:    time.Second,
+			MaxEntries: 100,
+			Allowlist:  []string{"10.0.0.0/8"},
+			Sources:    sources,
+		},
+		source, banner, store, zap.NewNop(),
+	)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	return svc, banner, store, &now
+}
+
+func TestBlocklistService_Import(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(file, []byte("1.1.1.1\n10.1.2.3\n127.0.0.1\n9.9.9.9\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer peer-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"entries":[{"ip":"2.2.2.2","jails":["sshd"]},{"ip":"1.1.1.1","jails":["sshd"]}]}`))
+			},
+		),
+	)
+	defer feed.Close()
+
+	svc, banner, store, now := newTestBlocklistService(
+		t, []config.BlocklistSource{
+			{Name: "file", Path: file},
+			{Name: "peer", URL: feed.URL, Token: "peer-token"},
+			{Name: "peer-no-token", URL: feed.URL},
+		}, nil,
+	)
+	banner.failIP = "9.9.9.9"
+
+	res, err := svc.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+
+	fileRes, peerRes, badRes := res.Sources[0], res.Sources[1], res.Sources[2]
+	if fileRes.Fetched != 4 || fileRes.Allowlisted != 2 || fileRes.Banned != 1 || fileRes.Failed != 1 {
+		t.Errorf("file result = %+v", fileRes)
+	}
+	if peerRes.Banned != 1 || peerRes.Fetched != 2 {
+		t.Errorf("peer result = %+v (duplicates across sources are imported once)", peerRes)
+	}
+	if badRes.Error == "" {
+		t.Error("feed without token should report an error")
+	}
+	jail := svc.cfg.Jail
+	if !banner.banned(jail, "1.1.1.1") || !banner.banned(jail, "2.2.2.2") || len(store.bans) != 2 {
+		t.Errorf("banned = %v, stored = %v", banner.source.bans, store.bans)
+	}
+
+	// Бан истёк по bantime jail раньше срока импорта: повторный импорт банит заново
+	banner.lift(jail, "1.1.1.1")
+	*now = now.Add(30 * time.Minute)
+	res, err = svc.Import(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sources[0].Refreshed != 1 || res.Sources[0].Banned != 0 {
+		t.Errorf("second run file result = %+v", res.Sources[0])
+	}
+	if !banner.banned(jail, "1.1.1.1") || banner.bans != 3 {
+		t.Errorf("refreshed IP not banned again: banned = %v, ban calls = %d", banner.source.bans, banner.bans)
+	}
+	if want := now.Add(time.Hour).Unix(); store.bans["1.1.1.1"].ExpiresAt != want {
+		t.Errorf("expiry = %d, want refreshed %d", store.bans["1.1.1.1"].ExpiresAt, want)
+	}
+
+	// Источники больше не содержат IP: после истечения срока бан снимается
+	if err := os.WriteFile(file, []byte(""), 0600); err != nil {
+		t.Fatal(err)
+	}
+	svc.cfg.Sources = svc.cfg.Sources[:1]
+	*now = now.Add(2 * time.Hour)
+	res, err = svc.Import(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Expired != 2 || len(store.bans) != 0 || len(banner.source.bans[jail]) != 0 {
+		t.Errorf("expired = %d, stored = %v, banned = %v", res.Expired, store.bans, banner.source.bans)
+	}
+}
+
+func TestBlocklistService_ExpireKeepsFail2banBans(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(file, []byte("3.3.3.3\n4.4.4.4\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	svc, banner, store, now := newTestBlocklistService(t, []config.BlocklistSource{{Name: "file", Path: file}}, nil)
+	jail := svc.cfg.Jail
+
+	// 3.3.3.3 fail2ban забанил сам ещё до импорта
+	banner.jail(jail, "3.3.3.3")
+	res, err := svc.Import(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sources[0].Banned != 1 || res.Sources[0].Refreshed != 1 || store.bans["3.3.3.3"].BannedAt != 0 {
+		t.Errorf("result = %+v, stored = %v", res.Sources[0], store.bans)
+	}
+
+	// Бан импорта 4.4.4.4 снят по bantime, IP больше не в списке, затем fail2ban банит его сам
+	if err := os.WriteFile(file, []byte(""), 0600); err != nil {
+		t.Fatal(err)
+	}
+	banner.lift(jail, "4.4.4.4")
+	*now = now.Add(30 * time.Minute)
+	if _, err := svc.Import(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	banner.jail(jail, "4.4.4.4")
+
+	*now = now.Add(2 * time.Hour)
+	res, err = svc.Import(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Expired != 2 || len(store.bans) != 0 {
+		t.Errorf("expired = %d, stored = %v", res.Expired, store.bans)
+	}
+	if len(banner.unbanned) != 0 || !banner.banned(jail, "3.3.3.3") || !banner.banned(jail, "4.4.4.4") {
+		t.Errorf("bans of fail2ban lifted: unbanned = %v, banned = %v", banner.unbanned, banner.source.bans)
+	}
+}
+
+func TestBlocklistService_ImportNotConfigured(t *testing.T) {
+	svc, _, _, _ := newTestBlocklistService(t, nil, nil)
+
+	_, err := svc.Import(context.Background())
+	if !errors.Is(err, apierror.Errors.FAIL2BAN_IMPORT_NOT_CONFIGURED) {
+		t.Errorf("Import() error = %v, want FAIL2BAN_IMPORT_NOT_CONFIGURED", err)
+	}
+}
+
+func TestBlocklistService_Export(t *testing.T) {
+	source := &fakeBanSource{
+		bans: map[string][]string{
+			"sshd":  {"1.1.1.1", "3.3.3.3"},
+			"nginx": {"1.1.1.1", "2.2.2.2"},
+		},
+	}
+	svc, _, store, _ := newTestBlocklistService(t, nil, source)
+	store.bans["3.3.3.3"] = sqlite3_local.ImportedBanEntity{IP: "3.3.3.3"}
+
+	list, err := svc.Export()
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if list.Count != 2 || list.Entries[0].IP != "1.1.1.1" || list.Entries[1].IP != "2.2.2.2" {
+		t.Fatalf("entries = %+v", list.Entries)
+	}
+	if strings.Join(list.Entries[0].Jails, ",") != "nginx,sshd" {
+		t.Errorf("jails = %v, want nginx,sshd", list.Entries[0].Jails)
+	}
+
+	text := renderBlocklistText(list)
+	if !strings.HasPrefix(text, "# VPS-control blocklist") || !strings.HasSuffix(text, "1.1.1.1\n2.2.2.2\n") {
+		t.Errorf("text feed = %q", text)
+	}
+	if ips, invalid := parseBlocklist([]byte(text)); len(ips) != 2 || invalid != 0 {
+		t.Errorf("text feed is not re-importable: %v, %d", ips, invalid)
+	}
+}
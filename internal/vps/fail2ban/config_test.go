@@ -0,0 +1,293 @@
+package fail2ban
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+// localFiles stands in for sudo in tests.
+type localFiles struct{}
+
+func (localFiles) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (localFiles) WriteFile(
+	path string,
+	data []byte,
+) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (localFiles) CopyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0600)
+}
+
+func (localFiles) Remove(paths ...string) error {
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestConfigWriter(t *testing.T) (*ConfigWriter, string) {
+	dir := t.TempDir()
+	ignores := &fakeTempIgnoreStore{entries: map[string]sqlite3_local.TempIgnoreEntity{}}
+	w := NewConfigWriter(
+		config.Fail2BanConfig{
+			ConfigDir:   dir,
+			ManagedFile: "vps-control.local",
+			BackupsKept: 2,
+		},
+		ignores,
+		zap.NewNop(),
+	)
+	w.files = localFiles{}
+	w.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return w, filepath.Join(dir, ManagedFileSubdir, "vps-control.local")
+}
+
+func TestConfigWriter_PersistJail_NewFile(t *testing.T) {
+	w, path := newTestConfigWriter(t)
+
+	backup, err := w.PersistJail(
+		&JailConfigDTO{
+			JailName: "sshd",
+			BanTime:  3600,
+			FindTime: 600,
+			MaxRetry: 5,
+			IgnoreIP: []string{"127.0.0.1/8", "::1"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("PersistJail() error: %v", err)
+	}
+	if backup != "" {
+		t.Errorf("backup = %q, want empty for a new file", backup)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("managed file not written: %v", err)
+	}
+	content := string(data)
+
+	for _, want := range []string{
+		ManagedFileHeader,
+		"[sshd]",
+		"bantime = 3600",
+		"findtime = 600",
+		"maxretry = 5",
+		"ignoreip = 127.0.0.1/8 ::1",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("managed file missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestConfigWriter_PersistJail_UpdatesAndBacksUp(t *testing.T) {
+	w, path := newTestConfigWriter(t)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	original := "[nginx]\nbantime = 60\nenabled = true\n\n[sshd]\nbantime = 10\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := w.PersistJail(
+		&JailConfigDTO{
+			JailName: "sshd",
+			BanTime:  -1,
+			FindTime: 300,
+			MaxRetry: 3,
+			IgnoreIP: []string{},
+		},
+	)
+	if err != nil {
+		t.Fatalf("PersistJail() error: %v", err)
+	}
+
+	wantBackup := path + ".20260102-030405.bak"
+	if backup != wantBackup {
+		t.Errorf("backup = %q, want %q", backup, wantBackup)
+	}
+	saved, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("backup not written: %v", err)
+	}
+	if string(saved) != original {
+		t.Errorf("backup content = %q, want original", string(saved))
+	}
+
+	data, _ := os.ReadFile(path)
+	parsed := parseJailFile(data)
+
+	nginx := parsed.get("nginx")
+	if nginx.vals["bantime"] != "60" || nginx.vals["enabled"] != "true" {
+		t.Errorf("unrelated jail changed: %v", nginx.vals)
+	}
+
+	sshd := parsed.get("sshd")
+	if sshd.vals[ParamBanTime] != "-1" {
+		t.Errorf("sshd bantime = %q, want -1", sshd.vals[ParamBanTime])
+	}
+	if sshd.vals[ParamMaxRetry] != "3" {
+		t.Errorf("sshd maxretry = %q, want 3", sshd.vals[ParamMaxRetry])
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temporary file left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestConfigWriter_PersistJail_SkipsTemporaryIgnores(t *testing.T) {
+	w, path := newTestConfigWriter(t)
+	_ = w.ignores.(*fakeTempIgnoreStore).SaveTempIgnore(
+		sqlite3_local.TempIgnoreEntity{IP: "203.0.113.7", Jail: "sshd", ExpiresAt: 1},
+	)
+
+	_, err := w.PersistJail(
+		&JailConfigDTO{
+			JailName: "sshd",
+			BanTime:  600,
+			FindTime: 600,
+			MaxRetry: 5,
+			IgnoreIP: []string{"127.0.0.1/8", "203.0.113.7"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("PersistJail() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if got := parseJailFile(data).get("sshd").vals[ParamIgnoreIP]; got != "127.0.0.1/8" {
+		t.Errorf("ignoreip = %q, want the temporary entry left out", got)
+	}
+}
+
+func TestConfigWriter_PersistJail_PrunesBackups(t *testing.T) {
+	w, path := newTestConfigWriter(t)
+
+	for i := range 4 {
+		w.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, i, 0, time.UTC) }
+		if _, err := w.PersistJail(&JailConfigDTO{JailName: "sshd", BanTime: 60, FindTime: 60, MaxRetry: 3}); err != nil {
+			t.Fatalf("PersistJail() error: %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*.bak")
+	want := []string{path + ".20260102-030402.bak", path + ".20260102-030403.bak"}
+	if !slices.Equal(backups, want) {
+		t.Errorf("backups = %v, want %v", backups, want)
+	}
+}
+
+func TestConfigWriter_PersistJail_InvalidName(t *testing.T) {
+	w, path := newTestConfigWriter(t)
+
+	_, err := w.PersistJail(&JailConfigDTO{JailName: "sshd]\n[evil"})
+	if err == nil {
+		t.Fatal("expected error for invalid jail name")
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Error("managed file should not be created for invalid jail name")
+	}
+}
+
+func TestParseIgnoreIPList(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "multiple entries",
+			output: "These IP addresses/networks are ignored:\n|- 127.0.0.0/8\n|- 10.0.0.1\n`- ::1\n",
+			want:   []string{"127.0.0.0/8", "10.0.0.1", "::1"},
+		},
+		{
+			name:   "empty list",
+			output: "No IP address/network is ignored\n",
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := parseIgnoreIPList(tt.output)
+				if len(got) != len(tt.want) {
+					t.Fatalf("parseIgnoreIPList() = %v, want %v", got, tt.want)
+				}
+				for i := range tt.want {
+					if got[i] != tt.want[i] {
+						t.Errorf("entry[%d] = %q, want %q", i, got[i], tt.want[i])
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestIsValidParamValue(t *testing.T) {
+	tests := []struct {
+		param string
+		value int64
+		want  bool
+	}{
+		{ParamBanTime, -1, true},
+		{ParamBanTime, 0, false},
+		{ParamBanTime, 3600, true},
+		{ParamFindTime, 0, false},
+		{ParamFindTime, 600, true},
+		{ParamMaxRetry, -1, false},
+		{ParamMaxRetry, 3, true},
+		{"unknown", 1, false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidParamValue(tt.param, tt.value); got != tt.want {
+			t.Errorf("isValidParamValue(%q, %d) = %v, want %v", tt.param, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidJailName(t *testing.T) {
+	valid := []string{"sshd", "nginx-forbidden", "vps_control.auth"}
+	invalid := []string{"", "ssh d", "sshd]", "../etc", strings.Repeat("a", 65)}
+
+	for _, name := range valid {
+		if !IsValidJailName(name) {
+			t.Errorf("IsValidJailName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range invalid {
+		if IsValidJailName(name) {
+			t.Errorf("IsValidJailName(%q) = true, want false", name)
+		}
+	}
+}
@@ -1,15 +1,121 @@
 package fail2ban
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+
+	"VPS-control/internal/database/sqlite3_local"
+
+	"github.com/gin-gonic/gin"
+)
 
 type Handler interface {
 	GetStatus(c *gin.Context)
 	GetJailDetails(c *gin.Context)
 	Unban(c *gin.Context)
+	GetJailConfig(c *gin.Context)
+	SetBanTime(c *gin.Context)
+	SetFindTime(c *gin.Context)
+	SetMaxRetry(c *gin.Context)
+	UpdateIgnoreIP(c *gin.Context)
+	ReloadAll(c *gin.Context)
+	ReloadJail(c *gin.Context)
+	StartJail(c *gin.Context)
+	StopJail(c *gin.Context)
+	Ping(c *gin.Context)
+	Version(c *gin.Context)
+	TestRegex(c *gin.Context)
+	ListBlocks(c *gin.Context)
+	LiftBlock(c *gin.Context)
+	GetBanEvents(c *gin.Context)
+	ExportBlocklist(c *gin.Context)
+	ImportBlocklist(c *gin.Context)
+	SelfUnban(c *gin.Context)
 }
 
 type fail2banControl interface {
 	GetGlobalStatus() (*Fail2BanStatusDTO, error)
 	GetJailDetails(jailName string) (*JailDetailsDTO, error)
 	UnbanIP(jail, ip string) error
+	BanIP(jail, ip string) error
+	GetJailConfig(jail string) (*JailConfigDTO, error)
+	GetIgnoreIPs(jail string) ([]string, error)
+	SetJailParam(
+		jail, param string,
+		value int64,
+	) error
+	AddIgnoreIP(jail, address string) error
+	RemoveIgnoreIP(jail, address string) error
+	ReloadAll() (*CommandResultDTO, error)
+	ReloadJail(jail string) (*CommandResultDTO, error)
+	StartJail(jail string) (*CommandResultDTO, error)
+	StopJail(jail string) (*CommandResultDTO, error)
+	Ping() (*ServerPingDTO, error)
+	Version() (*ServerVersionDTO, error)
+}
+
+// managedFiles performs the file operations of ConfigWriter. ReadFile
+// returns an fs.ErrNotExist error for a missing file; WriteFile replaces the
+// file atomically and creates missing directories.
+type managedFiles interface {
+	ReadFile(path string) ([]byte, error)
+	WriteFile(
+		path string,
+		data []byte,
+	) error
+	CopyFile(src, dst string) error
+	Remove(paths ...string) error
+}
+
+type tempIgnoreLister interface {
+	GetJailTempIgnores(jail string) ([]sqlite3_local.TempIgnoreEntity, error)
+}
+
+type jailConfigWriter interface {
+	PersistJail(cfg *JailConfigDTO) (string, error)
+}
+
+type regexTester interface {
+	Test(req *RegexTestInput) (*RegexTestResultDTO, error)
+}
+
+type ipBanner interface {
+	BanIP(jail, ip string) error
+}
+
+type eventPublisher interface {
+	Publish(
+		subject string,
+		data any,
+	) error
+}
+
+type blockManager interface {
+	ListBlocks() ([]IPBlockDTO, error)
+	LiftBlock(ip string) error
+}
+
+type banSource interface {
+	GetGlobalStatus() (*Fail2BanStatusDTO, error)
+	GetJailDetails(jailName string) (*JailDetailsDTO, error)
+}
+
+type banEventReader interface {
+	GetEvents(filter sqlite3_local.BanEventFilter) ([]BanEventDTO, error)
+}
+
+type ipBanController interface {
+	BanIP(jail, ip string) error
+	UnbanIP(jail, ip string) error
+}
+
+type blocklistManager interface {
+	Export() (*BlocklistDTO, error)
+	Import(ctx context.Context) (*ImportResultDTO, error)
+}
+
+type selfUnbanner interface {
+	UnbanSelf(
+		ip, username string,
+		ignore bool,
+	) (*SelfUnbanResponse, error)
 }
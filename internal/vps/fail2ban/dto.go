@@ -1,5 +1,7 @@
 package fail2ban
 
+import "time"
+
 // Константы для работы с внешними командами и парсинга
 const (
 	CmdSudo               = "sudo"
@@ -7,6 +9,7 @@ const (
 	ArgStatus             = "status"
 	ArgSet                = "set"
 	ArgUnbanIP            = "unbanip"
+	ArgBanIP              = "banip"
 	ParamJailName         = "name"
 	ReJailList            = `Jail list:\s*(.*)`
 	ReCurrentlyFailed     = `Currently failed:\s*(\d+)`
@@ -20,6 +23,125 @@ const (
 	ErrOutputJailNotFound = "Jail not found"
 )
 
+// Параметры конфигурации jail, доступные через get/set
+const (
+	ArgGet            = "get"
+	ArgReload         = "reload"
+	ArgStart          = "start"
+	ArgStop           = "stop"
+	ArgPing           = "ping"
+	ArgVersion        = "version"
+	ArgAddIgnoreIP    = "addignoreip"
+	ArgDelIgnoreIP    = "delignoreip"
+	ParamBanTime      = "bantime"
+	ParamFindTime     = "findtime"
+	ParamMaxRetry     = "maxretry"
+	ParamIgnoreIP     = "ignoreip"
+	IgnoreIPActionAdd = "add"
+	IgnoreIPActionDel = "remove"
+	ReJailName        = `^[A-Za-z0-9_.-]{1,64}$`
+	ReIgnoreIPEntry   = "^\\s*[|`]-\\s*(\\S+)\\s*$"
+	ManagedFileHeader = "# Managed by VPS-control. Manual changes to managed jails will be overwritten."
+	ManagedFileSubdir = "jail.d"
+)
+
+// Запись управляемого файла через sudo
+const (
+	CmdCat          = "cat"
+	CmdCopy         = "cp"
+	CmdInstall      = "install"
+	CmdMove         = "mv"
+	CmdRemove       = "rm"
+	ArgForce        = "-f"
+	ArgPreserve     = "-p"
+	ArgInstallMode  = "-m"
+	ArgInstallDirs  = "-D"
+	ManagedFileMode = "0640"
+)
+
+// Маркеры вывода fail2ban-client для управления сервером и jail
+const (
+	OutServerPong          = "pong"
+	OutErrorMarker         = "ERROR"
+	ErrOutputSocket        = "Failed to access socket path"
+	ErrOutputNotRunning    = "Is fail2ban running?"
+	ErrOutputAlreadyExists = "already exists"
+	ReVersion              = `v?(\d+\.\d+(?:\.\d+)?)`
+)
+
+// Тестирование failregex через fail2ban-regex или встроенный движок
+const (
+	CmdFail2BanRegex      = "fail2ban-regex"
+	ArgPrintAllMatched    = "--print-all-matched"
+	FilterSubdir          = "filter.d"
+	EngineBuiltin         = "builtin"
+	EngineFail2BanRegex   = "fail2ban-regex"
+	ReRegexSummary        = `Lines:\s*(\d+) lines,\s*(\d+) ignored,\s*(\d+) matched,\s*(\d+) missed`
+	OutMatchedLinesHeader = "|- Matched line(s):"
+	OutMatchedLinePrefix  = "|  "
+	RegexTestMaxLineBytes = 4096
+	RegexTestMaxMatches   = 500
+)
+
+// Собственный jail API для неудачных входов
+const (
+	AuthFilterName = "vps-control-auth"
+	CmdInstallJail = "install-fail2ban"
+)
+
+// Эскалация срабатываний InputSanitizer
+const (
+	SubjectSanitizerBan     = "f2b.sanitizer.ban"
+	BlockMethodFail2Ban     = "fail2ban"
+	BlockMethodInternal     = "internal"
+	AuditActionSanitizerBan = "f2b.sanitizer.ban"
+	AuditActionBlockLift    = "f2b.block.lift"
+	AuditActorSanitizer     = "sanitizer"
+)
+
+// Отслеживание изменений списка банов
+const (
+	SubjectBanAdded        = "f2b.ban.added"
+	SubjectBanRemoved      = "f2b.ban.removed"
+	BanEventAdded          = "added"
+	BanEventRemoved        = "removed"
+	BanEventsDefaultLimit  = 100
+	BanEventsMaxLimit      = 1000
+	QueryParamIP           = "ip"
+	QueryParamSince        = "since"
+	QueryParamLimit        = "limit"
+	banEventsPurgeInterval = time.Hour
+)
+
+// Экспорт и импорт блоклистов
+const (
+	BlocklistFormatJSON        = "json"
+	BlocklistFormatText        = "txt"
+	QueryParamFormat           = "format"
+	BlocklistMaxBodyBytes      = 5 << 20
+	AuditActionBlocklistImport = "f2b.blocklist.import"
+)
+
+// Самостоятельный разбан собственного IP
+const (
+	AuditActionSelfUnban     = "f2b.self.unban"
+	AuditActionIgnoreExpired = "f2b.self.ignore.expired"
+	tempIgnoreSweepInterval  = time.Minute
+)
+
+const (
+	AuditActionReloadAll = "f2b.reload"
+	AuditActionReload    = "f2b.jail.reload"
+	AuditActionStart     = "f2b.jail.start"
+	AuditActionStop      = "f2b.jail.stop"
+)
+
+const (
+	AuditActionConfigSet     = "f2b.config.set"
+	AuditActionConfigIgnore  = "f2b.config.ignoreip"
+	AuditActionConfigPersist = "f2b.config.persist"
+)
+
 type Fail2BanStatusDTO struct {
 	JailCount int      `json:"jail_count" example:"3"`
 	JailList  []string `json:"jail_list" example:"['sshd', 'nginx-forbidden']"`
@@ -43,3 +165,200 @@ type BanActionResponse struct {
 	Success bool   `json:"success" example:"true"`
 	Message string `json:"message" example:"IP unbanned successfully"`
 }
+
+type JailConfigDTO struct {
+	JailName string   `json:"jail_name" example:"sshd"`
+	BanTime  int64    `json:"bantime" example:"600"`
+	FindTime int64    `json:"findtime" example:"600"`
+	MaxRetry int64    `json:"maxretry" example:"5"`
+	IgnoreIP []string `json:"ignoreip" example:"['127.0.0.1/8', '::1']"`
+}
+
+type JailParamRequest struct {
+	Jail    string `json:"jail" binding:"required" example:"sshd"`
+	Value   int64  `json:"value" example:"3600"`
+	Persist bool   `json:"persist" example:"false"`
+}
+
+type IgnoreIPRequest struct {
+	Jail    string `json:"jail" binding:"required" example:"sshd"`
+	Address string `json:"address" binding:"required" example:"10.0.0.0/8"`
+	Action  string `json:"action" binding:"required,oneof=add remove" example:"add"`
+	Persist bool   `json:"persist" example:"false"`
+}
+
+type JailConfigResponse struct {
+	Success   bool           `json:"success" example:"true"`
+	Message   string         `json:"message" example:"jail configuration updated"`
+	Persisted bool           `json:"persisted" example:"true"`
+	Backup    string         `json:"backup,omitempty" example:"/etc/fail2ban/jail.d/vps-control.local.20260101-120000.bak"`
+	Config    *JailConfigDTO `json:"config"`
+}
+
+type JailActionRequest struct {
+	Jail string `json:"jail" binding:"required" example:"sshd"`
+}
+
+// CommandResultDTO is the structured outcome of a fail2ban-client call.
+// It is returned on success and attached as error meta on failure.
+type CommandResultDTO struct {
+	Command    string   `json:"command" example:"reload sshd"`
+	Success    bool     `json:"success" example:"true"`
+	Output     []string `json:"output" example:"['OK']"`
+	Errors     []string `json:"errors,omitempty" example:"['ERROR  Failed during configuration: ...']"`
+	DurationMs int64    `json:"duration_ms" example:"412"`
+}
+
+type ServerPingDTO struct {
+	Alive  bool              `json:"alive" example:"true"`
+	Result *CommandResultDTO `json:"result"`
+}
+
+type ServerVersionDTO struct {
+	Version string            `json:"version" example:"1.0.2"`
+	Result  *CommandResultDTO `json:"result"`
+}
+
+// RegexTestRequest carries regexes and sample lines base64-encoded (standard alphabet)
+// so that they pass the global input sanitizer untouched.
+type RegexTestRequest struct {
+	FailRegexB64   string `json:"failregex_b64" example:"XiVcKF9fcHJlZml4X2xpbmVcKXNGYWlsZWQgcGFzc3dvcmQgZm9yIC4qIGZyb20gPEhPU1Q+"`
+	IgnoreRegexB64 string `json:"ignoreregex_b64" example:""`
+	Filter         string `json:"filter" example:"sshd"`
+	LinesB64       string `json:"lines_b64" example:"SmFuIDEgMDA6MDA6MDAgaG9zdCBzc2hkWzFdOiBGYWlsZWQgcGFzc3dvcmQgZm9yIHJvb3QgZnJvbSAxLjIuMy40"`
+	LogPath        string `json:"log_path" example:"/var/log/auth.log"`
+	MaxLines       int    `json:"max_lines" binding:"omitempty,min=1" example:"1000"`
+	Engine         string `json:"engine" binding:"omitempty,oneof=builtin fail2ban-regex" example:"builtin"`
+}
+
+// RegexTestInput is the decoded form of RegexTestRequest.
+type RegexTestInput struct {
+	FailRegex   string
+	IgnoreRegex string
+	Filter      string
+	Lines       string
+	LogPath     string
+	MaxLines    int
+	Engine      string
+}
+
+type RegexMatchDTO struct {
+	LineNumber int    `json:"line_number" example:"1"`
+	Line       string `json:"line" example:"Jan 1 00:00:00 host sshd[1]: Failed password for root from 1.2.3.4"`
+	Host       string `json:"host" example:"1.2.3.4"`
+	RegexIndex int    `json:"regex_index,omitempty" example:"1"`
+}
+
+type RegexTestResultDTO struct {
+	Engine       string          `json:"engine" example:"builtin"`
+	FailRegex    []string        `json:"failregex"`
+	TotalLines   int             `json:"total_lines" example:"120"`
+	MatchedCount int             `json:"matched_count" example:"4"`
+	IgnoredCount int             `json:"ignored_count" example:"0"`
+	MissedCount  int             `json:"missed_count" example:"116"`
+	Matches      []RegexMatchDTO `json:"matches"`
+	Truncated    bool            `json:"truncated" example:"false"`
+	DurationMs   int64           `json:"duration_ms" example:"3"`
+}
+
+type AuthJailInstallResult struct {
+	Jail       string `json:"jail"`
+	FilterPath string `json:"filter_path"`
+	JailPath   string `json:"jail_path"`
+	LogPath    string `json:"log_path"`
+}
+
+type SanitizerBanEvent struct {
+	IP         string    `json:"ip"`
+	Method     string    `json:"method"`
+	Jail       string    `json:"jail,omitempty"`
+	Detections int       `json:"detections"`
+	Source     string    `json:"source"`
+	Path       string    `json:"path"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+}
+
+type IPBlockDTO struct {
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	Reason     string    `json:"reason" example:"sanitizer: 5 detections, last body on /api/auth/login"`
+	Detections int       `json:"detections" example:"5"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type IPBlockListResponse struct {
+	Blocks []IPBlockDTO `json:"blocks"`
+	Count  int          `json:"count" example:"1"`
+}
+
+type LiftBlockRequest struct {
+	IP string `json:"ip" binding:"required,ip" example:"203.0.113.7"`
+}
+
+type BanChangeEvent struct {
+	Jail       string    `json:"jail"`
+	IP         string    `json:"ip"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+type BanEventDTO struct {
+	ID        int64     `json:"id" example:"42"`
+	Jail      string    `json:"jail" example:"sshd"`
+	IP        string    `json:"ip" example:"203.0.113.7"`
+	Event     string    `json:"event" example:"added"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type BanEventListResponse struct {
+	Events []BanEventDTO `json:"events"`
+	Count  int           `json:"count" example:"1"`
+}
+
+type BlocklistEntryDTO struct {
+	IP    string   `json:"ip" example:"203.0.113.7"`
+	Jails []string `json:"jails" example:"sshd"`
+}
+
+type BlocklistDTO struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Count       int                 `json:"count" example:"1"`
+	Entries     []BlocklistEntryDTO `json:"entries"`
+}
+
+type ImportSourceResultDTO struct {
+	Source      string `json:"source" example:"peer-a"`
+	Fetched     int    `json:"fetched" example:"120"`
+	Invalid     int    `json:"invalid" example:"2"`
+	Allowlisted int    `json:"allowlisted" example:"1"`
+	Banned      int    `json:"banned" example:"10"`
+	Refreshed   int    `json:"refreshed" example:"100"`
+	Failed      int    `json:"failed" example:"0"`
+	Error       string `json:"error,omitempty"`
+}
+
+type ImportResultDTO struct {
+	Jail       string                  `json:"jail" example:"vps-control-auth"`
+	Sources    []ImportSourceResultDTO `json:"sources"`
+	Expired    int                     `json:"expired" example:"3"`
+	Truncated  bool                    `json:"truncated"`
+	DurationMs int64                   `json:"duration_ms" example:"850"`
+}
+
+type SelfUnbanRequest struct {
+	Ignore bool `json:"ignore" example:"true"`
+}
+
+type SelfUnbanJailResult struct {
+	Jail     string `json:"jail" example:"sshd"`
+	Unbanned bool   `json:"unbanned" example:"true"`
+	Ignored  bool   `json:"ignored" example:"true"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SelfUnbanResponse struct {
+	IP           string                `json:"ip" example:"203.0.113.7"`
+	CheckedJails int                   `json:"checked_jails" example:"3"`
+	UnbannedFrom []string              `json:"unbanned_from" example:"sshd"`
+	IgnoreUntil  *time.Time            `json:"ignore_until,omitempty"`
+	Jails        []SelfUnbanJailResult `json:"jails"`
+}
@@ -0,0 +1,281 @@
+package fail2ban
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+type fakeBanner struct {
+	err    error
+	banned []string
+}
+
+func (f *fakeBanner) BanIP(jail, ip string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.banned = append(f.banned, jail+"/"+ip)
+	return nil
+}
+
+type fakeBlockStore struct {
+	mu     sync.Mutex
+	blocks map[string]sqlite3_local.BlockEntity
+}
+
+func newFakeBlockStore() *fakeBlockStore {
+	return &fakeBlockStore{blocks: map[string]sqlite3_local.BlockEntity{}}
+}
+
+func (s *fakeBlockStore) SaveBlock(block sqlite3_local.BlockEntity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks[block.IP] = block
+	return nil
+}
+
+func (s *fakeBlockStore) DeleteBlock(ip string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blocks[ip]; !ok {
+		return 0, nil
+	}
+	delete(s.blocks, ip)
+	return 1, nil
+}
+
+func (s *fakeBlockStore) GetActiveBlocks(now int64) ([]sqlite3_local.BlockEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []sqlite3_local.BlockEntity
+	for _, b := range s.blocks {
+		if b.ExpiresAt > now {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeBlockStore) DeleteExpiredBlocks(int64) error { return nil }
+
+type fakePublisher struct {
+	subjects []string
+	events   []SanitizerBanEvent
+}
+
+func (p *fakePublisher) Publish(subject string, data any) error {
+	p.subjects = append(p.subjects, subject)
+	if ev, ok := data.(SanitizerBanEvent); ok {
+		p.events = append(p.events, ev)
+	}
+	return nil
+}
+
+type fakeRecorder struct {
+	entries []audit.Entry
+}
+
+func (r *fakeRecorder) Record(entry audit.Entry) {
+	r.entries = append(r.entries, entry)
+}
+
+type escalatorFixture struct {
+	esc       *Escalator
+	banner    *fakeBanner
+	store     *fakeBlockStore
+	publisher *fakePublisher
+	recorder  *fakeRecorder
+	now       time.Time
+}
+
+func newEscalatorFixture(t *testing.T, banErr error) *escalatorFixture {
+	t.Helper()
+	f := &escalatorFixture{
+		banner:    &fakeBanner{err: banErr},
+		store:     newFakeBlockStore(),
+		publisher: &fakePublisher{},
+		recorder:  &fakeRecorder{},
+		now:       time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+	f.esc = NewEscalator(
+		config.EscalationConfig{
+			Enabled:   true,
+			Threshold: 3,
+			Window:    time.Minute,
+			Jail:      "vps-control-auth",
+			BlockTime: time.Hour,
+			Allowlist: []string{"192.0.2.0/24"},
+		},
+		f.banner, f.store, f.publisher, f.recorder, zap.NewNop(),
+	)
+	f.esc.now = func() time.Time { return f.now }
+	return f
+}
+
+// detect records n detections and runs the bans they queued, as Run would.
+func (f *escalatorFixture) detect(ip, source, path string, n int) {
+	for i := 0; i < n; i++ {
+		f.esc.RecordDetection(ip, source, path)
+	}
+	for len(f.esc.queue) > 0 {
+		f.esc.escalate(<-f.esc.queue)
+	}
+}
+
+func TestEscalator_BansViaFail2Ban(t *testing.T) {
+	f := newEscalatorFixture(t, nil)
+	ip := "203.0.113.7"
+
+	f.detect(ip, "body", "/api/auth/login", 2)
+	if len(f.banner.banned) != 0 {
+		t.Fatal("should not ban below threshold")
+	}
+
+	f.detect(ip, "query_param", "/api/vps/pm2", 1)
+	if len(f.banner.banned) != 1 || f.banner.banned[0] != "vps-control-auth/"+ip {
+		t.Fatalf("banned = %v, want one ban in vps-control-auth", f.banner.banned)
+	}
+	if f.esc.IsBlocked(ip) {
+		t.Error("fail2ban ban should not create an internal block")
+	}
+	if len(f.publisher.events) != 1 || f.publisher.subjects[0] != SubjectSanitizerBan {
+		t.Fatalf("published %v", f.publisher.subjects)
+	}
+	if ev := f.publisher.events[0]; ev.Method != BlockMethodFail2Ban || ev.Detections != 3 || ev.Path != "/api/vps/pm2" {
+		t.Errorf("event = %+v", ev)
+	}
+	if len(f.recorder.entries) != 1 || f.recorder.entries[0].Action != AuditActionSanitizerBan {
+		t.Errorf("audit entries = %+v", f.recorder.entries)
+	}
+}
+
+func TestEscalator_WindowExpires(t *testing.T) {
+	f := newEscalatorFixture(t, nil)
+	ip := "203.0.113.8"
+
+	f.detect(ip, "body", "/", 2)
+	f.now = f.now.Add(2 * time.Minute)
+	f.detect(ip, "body", "/", 1)
+
+	if len(f.banner.banned) != 0 {
+		t.Errorf("detections outside the window must not count, banned = %v", f.banner.banned)
+	}
+}
+
+func TestEscalator_FallbackToInternalBlock(t *testing.T) {
+	f := newEscalatorFixture(t, apierror.Errors.FAIL2BAN_SERVER_UNAVAILABLE)
+	ip := "203.0.113.9"
+
+	f.detect(ip, "header", "/api/auth/login", 3)
+
+	if !f.esc.IsBlocked(ip) {
+		t.Fatal("IP should be blocked internally when fail2ban is unavailable")
+	}
+	if _, ok := f.store.blocks[ip]; !ok {
+		t.Error("internal block should be persisted")
+	}
+	if ev := f.publisher.events[0]; ev.Method != BlockMethodInternal || !ev.ExpiresAt.Equal(f.now.Add(time.Hour)) {
+		t.Errorf("event = %+v", ev)
+	}
+
+	blocks, err := f.esc.ListBlocks()
+	if err != nil || len(blocks) != 1 || blocks[0].IP != ip {
+		t.Fatalf("ListBlocks() = %+v, %v", blocks, err)
+	}
+
+	f.now = f.now.Add(2 * time.Hour)
+	if f.esc.IsBlocked(ip) {
+		t.Error("block should expire after block_time")
+	}
+}
+
+func TestEscalator_LiftBlock(t *testing.T) {
+	f := newEscalatorFixture(t, errors.New("jail not found"))
+	ip := "198.51.100.4"
+
+	f.detect(ip, "body", "/", 3)
+	if err := f.esc.LiftBlock(ip); err != nil {
+		t.Fatalf("LiftBlock() error: %v", err)
+	}
+	if f.esc.IsBlocked(ip) {
+		t.Error("IP should not be blocked after lift")
+	}
+	if _, ok := f.store.blocks[ip]; ok {
+		t.Error("lifted block should be removed from store")
+	}
+
+	var appErr *apierror.AppError
+	if err := f.esc.LiftBlock(ip); !errors.As(err, &appErr) || appErr.Code != "FAIL2BAN_BLOCK_NOT_FOUND" {
+		t.Errorf("second LiftBlock() = %v, want FAIL2BAN_BLOCK_NOT_FOUND", err)
+	}
+}
+
+func TestEscalator_LoadsPersistedBlocks(t *testing.T) {
+	store := newFakeBlockStore()
+	_ = store.SaveBlock(
+		sqlite3_local.BlockEntity{
+			IP:        "192.0.2.1",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			CreatedAt: time.Now().Unix(),
+		},
+	)
+
+	esc := NewEscalator(
+		config.EscalationConfig{Threshold: 3, Window: time.Minute, BlockTime: time.Hour},
+		&fakeBanner{}, store, &fakePublisher{}, &fakeRecorder{}, zap.NewNop(),
+	)
+	if !esc.IsBlocked("192.0.2.1") {
+		t.Error("persisted block should be enforced after restart")
+	}
+}
+
+func TestEscalator_Disabled(t *testing.T) {
+	f := newEscalatorFixture(t, nil)
+	f.esc.cfg.Enabled = false
+
+	f.detect("203.0.113.10", "body", "/", 5)
+	if len(f.banner.banned) != 0 || len(f.publisher.events) != 0 {
+		t.Error("disabled escalator must not ban")
+	}
+}
+
+func TestEscalator_Allowlist(t *testing.T) {
+	f := newEscalatorFixture(t, nil)
+
+	for _, ip := range []string{"127.0.0.1", "::1", "192.0.2.50"} {
+		f.detect(ip, "body", "/api/vps/fail2ban/regex/test", 3)
+		if f.esc.IsBlocked(ip) {
+			t.Errorf("%s blocked internally", ip)
+		}
+	}
+	if len(f.banner.banned) != 0 || len(f.publisher.events) != 0 {
+		t.Errorf("allowlisted IPs banned: %v", f.banner.banned)
+	}
+}
+
+func TestEscalator_QueueFull(t *testing.T) {
+	f := newEscalatorFixture(t, nil)
+	for i := 0; i < escalationQueueSize; i++ {
+		f.esc.queue <- escalation{ip: "203.0.113.1"}
+	}
+
+	ip := "203.0.113.11"
+	for i := 0; i < 3; i++ {
+		f.esc.RecordDetection(ip, "body", "/")
+	}
+	if !f.esc.IsBlocked(ip) || len(f.banner.banned) != 0 {
+		t.Errorf("with a full queue the IP should be blocked internally, banned = %v", f.banner.banned)
+	}
+	if len(f.publisher.events) != 1 || f.publisher.events[0].Method != BlockMethodInternal {
+		t.Errorf("events = %+v", f.publisher.events)
+	}
+}
@@ -2,24 +2,55 @@ package fail2ban
 
 import (
 	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+	"VPS-control/internal/auth"
+	"VPS-control/internal/database/sqlite3_local"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 type handler struct {
-	controlSvc fail2banControl
-	logger     *zap.Logger
+	controlSvc   fail2banControl
+	configWriter jailConfigWriter
+	regexTester  regexTester
+	blocks       blockManager
+	banEvents    banEventReader
+	blocklist    blocklistManager
+	selfUnban    selfUnbanner
+	audit        audit.Recorder
+	logger       *zap.Logger
 }
 
 func NewHandler(
 	cs fail2banControl,
+	cw jailConfigWriter,
+	rt regexTester,
+	bm blockManager,
+	be banEventReader,
+	bl blocklistManager,
+	su selfUnbanner,
+	ar audit.Recorder,
 	l *zap.Logger,
 ) Handler {
 	return &handler{
-		controlSvc: cs,
-		logger:     l,
+		controlSvc:   cs,
+		configWriter: cw,
+		regexTester:  rt,
+		blocks:       bm,
+		banEvents:    be,
+		blocklist:    bl,
+		selfUnban:    su,
+		audit:        ar,
+		logger:       l,
 	}
 }
 
@@ -91,3 +122,641 @@ func (h *handler) Unban(c *gin.Context) {
 		},
 	)
 }
+
+// GetJailConfig godoc
+// @Summary      Get jail configuration
+// @Description  Returns runtime bantime, findtime, maxretry and ignoreip of a jail
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Param        name  query  string  true  "Jail Name"
+// @Produce      json
+// @Success      200  {object}  JailConfigDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/config [get]
+func (h *handler) GetJailConfig(c *gin.Context) {
+	jailName := c.Query(ParamJailName)
+	if !IsValidJailName(jailName) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("query parameter 'name' must be a valid jail name"))
+		return
+	}
+
+	data, err := h.controlSvc.GetJailConfig(jailName)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// SetBanTime godoc
+// @Summary      Set jail bantime
+// @Description  Sets bantime in seconds at runtime (-1 bans permanently). Optionally persists to the managed jail.d file.
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  JailParamRequest  true  "Jail and value"
+// @Success      200  {object}  JailConfigResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/config/bantime [post]
+func (h *handler) SetBanTime(c *gin.Context) {
+	h.setJailParam(c, ParamBanTime)
+}
+
+// SetFindTime godoc
+// @Summary      Set jail findtime
+// @Description  Sets findtime in seconds at runtime. Optionally persists to the managed jail.d file.
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  JailParamRequest  true  "Jail and value"
+// @Success      200  {object}  JailConfigResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/config/findtime [post]
+func (h *handler) SetFindTime(c *gin.Context) {
+	h.setJailParam(c, ParamFindTime)
+}
+
+// SetMaxRetry godoc
+// @Summary      Set jail maxretry
+// @Description  Sets maxretry at runtime. Optionally persists to the managed jail.d file.
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  JailParamRequest  true  "Jail and value"
+// @Success      200  {object}  JailConfigResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/config/maxretry [post]
+func (h *handler) SetMaxRetry(c *gin.Context) {
+	h.setJailParam(c, ParamMaxRetry)
+}
+
+// UpdateIgnoreIP godoc
+// @Summary      Add or remove ignoreip entry
+// @Description  Adds or removes an IP or CIDR in the jail ignore list. Optionally persists to the managed jail.d file.
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  IgnoreIPRequest  true  "Jail, address and action"
+// @Success      200  {object}  JailConfigResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/config/ignoreip [post]
+func (h *handler) UpdateIgnoreIP(c *gin.Context) {
+	var req IgnoreIPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	if !IsValidJailName(req.Jail) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("invalid jail name"))
+		return
+	}
+	if !isValidAddress(req.Address) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("address must be an IP or CIDR"))
+		return
+	}
+
+	var err error
+	if req.Action == IgnoreIPActionAdd {
+		err = h.controlSvc.AddIgnoreIP(req.Jail, req.Address)
+	} else {
+		err = h.controlSvc.RemoveIgnoreIP(req.Jail, req.Address)
+	}
+	h.recordAudit(c, AuditActionConfigIgnore, req.Jail, fmt.Sprintf("%s %s", req.Action, req.Address), err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	h.respondWithConfig(c, req.Jail, req.Persist)
+}
+
+// ReloadAll godoc
+// @Summary      Reload Fail2Ban
+// @Description  Reloads the Fail2Ban server configuration and all jails
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {object}  CommandResultDTO
+// @Failure      500  {object}  apierror.AppError
+// @Failure      503  {object}  apierror.AppError
+// @Router       /vps/fail2ban/reload [post]
+func (h *handler) ReloadAll(c *gin.Context) {
+	result, err := h.controlSvc.ReloadAll()
+	h.recordAudit(c, AuditActionReloadAll, "", "", err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ReloadJail godoc
+// @Summary      Reload a jail
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  JailActionRequest  true  "Jail"
+// @Success      200  {object}  CommandResultDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /vps/fail2ban/jail/reload [post]
+func (h *handler) ReloadJail(c *gin.Context) {
+	h.jailAction(c, AuditActionReload, h.controlSvc.ReloadJail)
+}
+
+// StartJail godoc
+// @Summary      Start a jail
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  JailActionRequest  true  "Jail"
+// @Success      200  {object}  CommandResultDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      409  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /vps/fail2ban/jail/start [post]
+func (h *handler) StartJail(c *gin.Context) {
+	h.jailAction(c, AuditActionStart, h.controlSvc.StartJail)
+}
+
+// StopJail godoc
+// @Summary      Stop a jail
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  JailActionRequest  true  "Jail"
+// @Success      200  {object}  CommandResultDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /vps/fail2ban/jail/stop [post]
+func (h *handler) StopJail(c *gin.Context) {
+	h.jailAction(c, AuditActionStop, h.controlSvc.StopJail)
+}
+
+// Ping godoc
+// @Summary      Ping Fail2Ban server
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {object}  ServerPingDTO
+// @Failure      503  {object}  apierror.AppError
+// @Router       /vps/fail2ban/server/ping [get]
+func (h *handler) Ping(c *gin.Context) {
+	data, err := h.controlSvc.Ping()
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// Version godoc
+// @Summary      Get Fail2Ban server version
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {object}  ServerVersionDTO
+// @Failure      503  {object}  apierror.AppError
+// @Router       /vps/fail2ban/server/version [get]
+func (h *handler) Version(c *gin.Context) {
+	data, err := h.controlSvc.Version()
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// TestRegex godoc
+// @Summary      Test failregex against log lines
+// @Description  Runs failregex/ignoreregex (or an existing filter) against base64-encoded sample lines
+// @Description  or the tail of an allowlisted log file. Regexes and lines must be base64 (standard alphabet).
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  RegexTestRequest  true  "Regex test input"
+// @Success      200  {object}  RegexTestResultDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      403  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/regex/test [post]
+func (h *handler) TestRegex(c *gin.Context) {
+	var req RegexTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	input, err := decodeRegexTestRequest(&req)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta(err.Error()))
+		return
+	}
+
+	result, err := h.regexTester.Test(input)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListBlocks godoc
+// @Summary      List internal IP blocks
+// @Description  Returns IPs blocked by the sanitizer escalation when fail2ban could not ban them
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {object}  IPBlockListResponse
+// @Router       /vps/fail2ban/blocks [get]
+func (h *handler) ListBlocks(c *gin.Context) {
+	blocks, err := h.blocks.ListBlocks()
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, IPBlockListResponse{Blocks: blocks, Count: len(blocks)})
+}
+
+// LiftBlock godoc
+// @Summary      Lift an internal IP block
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  LiftBlockRequest  true  "IP to unblock"
+// @Success      200  {object}  BanActionResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      404  {object}  apierror.AppError
+// @Router       /vps/fail2ban/blocks/lift [post]
+func (h *handler) LiftBlock(c *gin.Context) {
+	var req LiftBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	err := h.blocks.LiftBlock(req.IP)
+	h.recordAudit(c, AuditActionBlockLift, req.IP, "", err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK, BanActionResponse{
+			Success: true,
+			Message: "IP block lifted successfully",
+		},
+	)
+}
+
+// GetBanEvents godoc
+// @Summary      Ban timeline
+// @Description  Returns ban added/removed events detected by the background ban watcher, newest first
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Param        name   query  string  false  "Jail Name"
+// @Param        ip     query  string  false  "IP address"
+// @Param        since  query  int     false  "Unix timestamp, only events at or after it"
+// @Param        limit  query  int     false  "Max events (default 100, max 1000)"
+// @Produce      json
+// @Success      200  {object}  BanEventListResponse
+// @Failure      400  {object}  apierror.AppError
+// @Router       /vps/fail2ban/events [get]
+func (h *handler) GetBanEvents(c *gin.Context) {
+	filter, err := parseBanEventFilter(c)
+	if err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta(err.Error()))
+		return
+	}
+
+	events, err := h.banEvents.GetEvents(filter)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, BanEventListResponse{Events: events, Count: len(events)})
+}
+
+// ExportBlocklist godoc
+// @Summary      Blocklist feed
+// @Description  Currently banned IPs aggregated across all jails, excluding imported entries. Use format=txt for one IP per line
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Param        format  query  string  false  "Output format"  Enums(json, txt)
+// @Produce      json
+// @Produce      plain
+// @Success      200  {object}  BlocklistDTO
+// @Failure      400  {object}  apierror.AppError
+// @Failure      500  {object}  apierror.AppError
+// @Router       /vps/fail2ban/blocklist [get]
+func (h *handler) ExportBlocklist(c *gin.Context) {
+	format := c.DefaultQuery(QueryParamFormat, BlocklistFormatJSON)
+	if format != BlocklistFormatJSON && format != BlocklistFormatText {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("query parameter 'format' must be json or txt"))
+		return
+	}
+
+	list, err := h.blocklist.Export()
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	if format == BlocklistFormatText {
+		c.String(http.StatusOK, renderBlocklistText(list))
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ImportBlocklist godoc
+// @Summary      Import blocklists now
+// @Description  Runs the configured blocklist import immediately instead of waiting for the next scheduled run
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Produce      json
+// @Success      200  {object}  ImportResultDTO
+// @Failure      409  {object}  apierror.AppError
+// @Router       /vps/fail2ban/blocklist/import [post]
+func (h *handler) ImportBlocklist(c *gin.Context) {
+	res, err := h.blocklist.Import(c.Request.Context())
+	details := ""
+	if res != nil {
+		details = fmt.Sprintf("jail=%s sources=%d expired=%d", res.Jail, len(res.Sources), res.Expired)
+	}
+	h.recordAudit(c, AuditActionBlocklistImport, "", details, err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// SelfUnban godoc
+// @Summary      Unban my current IP
+// @Description  Checks every jail for the caller's IP and unbans it where found. With ignore=true the IP is also added to ignoreip of every jail for the configured time
+// @Tags         fail2ban
+// @Security     CookieAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  SelfUnbanRequest  false  "Options"
+// @Success      200  {object}  SelfUnbanResponse
+// @Failure      400  {object}  apierror.AppError
+// @Failure      503  {object}  apierror.AppError
+// @Router       /vps/fail2ban/self/unban [post]
+func (h *handler) SelfUnban(c *gin.Context) {
+	var req SelfUnbanRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+
+	ip := c.ClientIP()
+	username, _ := auth.GetUsername(c)
+	res, err := h.selfUnban.UnbanSelf(ip, username, req.Ignore)
+
+	details := fmt.Sprintf("ignore=%t", req.Ignore)
+	if res != nil {
+		details = fmt.Sprintf("ignore=%t unbanned_from=%v", req.Ignore, res.UnbannedFrom)
+	}
+	h.recordAudit(c, AuditActionSelfUnban, ip, details, err)
+	if err != nil {
+		var appErr *apierror.AppError
+		if !errors.As(err, &appErr) {
+			err = apierror.Errors.INVALID_REQUEST.WithMeta(err.Error())
+		}
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *handler) jailAction(
+	c *gin.Context,
+	action string,
+	run func(jail string) (*CommandResultDTO, error),
+) {
+	var req JailActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	if !IsValidJailName(req.Jail) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("invalid jail name"))
+		return
+	}
+
+	result, err := run(req.Jail)
+	h.recordAudit(c, action, req.Jail, "", err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *handler) setJailParam(
+	c *gin.Context,
+	param string,
+) {
+	var req JailParamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST)
+		return
+	}
+	if !IsValidJailName(req.Jail) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta("invalid jail name"))
+		return
+	}
+	if !isValidParamValue(param, req.Value) {
+		apierror.Abort(c, apierror.Errors.INVALID_REQUEST.WithMeta(fmt.Sprintf("invalid value for %s", param)))
+		return
+	}
+
+	err := h.controlSvc.SetJailParam(req.Jail, param, req.Value)
+	h.recordAudit(c, AuditActionConfigSet, req.Jail, fmt.Sprintf("%s=%d", param, req.Value), err)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	h.respondWithConfig(c, req.Jail, req.Persist)
+}
+
+// respondWithConfig re-reads the runtime configuration, optionally writes it
+// to the managed file and reloads the jail so the file becomes authoritative.
+func (h *handler) respondWithConfig(
+	c *gin.Context,
+	jail string,
+	persist bool,
+) {
+	cfg, err := h.controlSvc.GetJailConfig(jail)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	resp := JailConfigResponse{
+		Success: true,
+		Message: "jail configuration updated",
+		Config:  cfg,
+	}
+
+	if persist {
+		backup, err := h.configWriter.PersistJail(cfg)
+		if err == nil {
+			_, err = h.controlSvc.ReloadJail(jail)
+		}
+		h.recordAudit(c, AuditActionConfigPersist, jail, "backup="+backup, err)
+		if err != nil {
+			if _, ok := err.(*apierror.AppError); !ok {
+				err = apierror.Errors.FAIL2BAN_CONFIG_WRITE_ERROR.Wrap(err)
+			}
+			apierror.Abort(c, err)
+			return
+		}
+		resp.Persisted = true
+		resp.Backup = backup
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) recordAudit(
+	c *gin.Context,
+	action, target, details string,
+	err error,
+) {
+	actorID, actor := auth.GetActor(c)
+	h.audit.Record(
+		audit.Entry{
+			ActorID: actorID,
+			Actor:   actor,
+			Action:  action,
+			Target:  target,
+			Details: details,
+			IP:      c.ClientIP(),
+			Success: err == nil,
+		},
+	)
+}
+
+func isValidParamValue(
+	param string,
+	value int64,
+) bool {
+	switch param {
+	case ParamBanTime:
+		return value == -1 || value > 0
+	case ParamFindTime, ParamMaxRetry:
+		return value > 0
+	}
+	return false
+}
+
+func isValidAddress(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(address)
+	return err == nil
+}
+
+func decodeRegexTestRequest(req *RegexTestRequest) (*RegexTestInput, error) {
+	if (req.FailRegexB64 == "") == (req.Filter == "") {
+		return nil, errors.New("exactly one of failregex_b64 or filter is required")
+	}
+	if (req.LinesB64 == "") == (req.LogPath == "") {
+		return nil, errors.New("exactly one of lines_b64 or log_path is required")
+	}
+	if req.Filter != "" && !IsValidJailName(req.Filter) {
+		return nil, errors.New("invalid filter name")
+	}
+
+	input := &RegexTestInput{
+		Filter:   req.Filter,
+		LogPath:  req.LogPath,
+		MaxLines: req.MaxLines,
+		Engine:   req.Engine,
+	}
+	if input.Engine == "" {
+		input.Engine = EngineBuiltin
+	}
+
+	fields := []struct {
+		name string
+		src  string
+		dst  *string
+	}{
+		{"failregex_b64", req.FailRegexB64, &input.FailRegex},
+		{"ignoreregex_b64", req.IgnoreRegexB64, &input.IgnoreRegex},
+		{"lines_b64", req.LinesB64, &input.Lines},
+	}
+	for _, f := range fields {
+		if f.src == "" {
+			continue
+		}
+		decoded, err := decodeBase64(f.src)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not valid base64", f.name)
+		}
+		if !utf8.Valid(decoded) {
+			return nil, fmt.Errorf("%s must be UTF-8 text", f.name)
+		}
+		*f.dst = string(decoded)
+	}
+	return input, nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return decoded, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func parseBanEventFilter(c *gin.Context) (sqlite3_local.BanEventFilter, error) {
+	filter := sqlite3_local.BanEventFilter{
+		Jail:  c.Query(ParamJailName),
+		IP:    c.Query(QueryParamIP),
+		Limit: BanEventsDefaultLimit,
+	}
+	if filter.Jail != "" && !IsValidJailName(filter.Jail) {
+		return filter, errors.New("query parameter 'name' must be a valid jail name")
+	}
+	if filter.IP != "" && net.ParseIP(filter.IP) == nil {
+		return filter, errors.New("query parameter 'ip' must be a valid IP address")
+	}
+	if raw := c.Query(QueryParamSince); raw != "" {
+		since, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || since < 0 {
+			return filter, errors.New("query parameter 'since' must be a unix timestamp")
+		}
+		filter.Since = since
+	}
+	if raw := c.Query(QueryParamLimit); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > BanEventsMaxLimit {
+			return filter, fmt.Errorf("query parameter 'limit' must be between 1 and %d", BanEventsMaxLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
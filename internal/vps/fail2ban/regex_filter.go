@@ -0,0 +1,267 @@
+package fail2ban
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Шаблоны тегов fail2ban, переведённые в синтаксис RE2
+const (
+	reIP4  = `(?:\d{1,3}\.){3}\d{1,3}`
+	reIP6  = `[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}`
+	reDNS  = `[\w\-.^_]*\w`
+	reHost = `(?:::f{4,6}:)?(?P<host>` + reIP4 + `|` + reIP6 + `|` + reDNS + `)`
+	reAddr = `(?:::f{4,6}:)?(?P<addr>` + reIP4 + `|` + reIP6 + `)`
+
+	// Упрощённая замена %(__prefix_line)s из common.conf: hostname и daemon[pid]:
+	defaultPrefixLine = `\s*(?:\S+\s+)?(?:[\w\-./]+(?:\[\d+\])?:\s+)?`
+
+	filterSectionIncludes   = "INCLUDES"
+	filterSectionDefinition = "Definition"
+	filterSectionDefault    = "DEFAULT"
+	filterKeyFailRegex      = "failregex"
+	filterKeyIgnoreRegex    = "ignoreregex"
+	filterKeyBefore         = "before"
+	filterKeyAfter          = "after"
+	filterMaxIncludeDepth   = 5
+	filterMaxInterpolations = 20
+)
+
+var (
+	fTagOpenRegex   = regexp.MustCompile(`<F-([A-Z0-9_]+)>`)
+	fTagCloseRegex  = regexp.MustCompile(`</F-[A-Z0-9_]+>`)
+	interpolateExpr = regexp.MustCompile(`%\(([^)]+)\)s`)
+
+	tagReplacer = strings.NewReplacer(
+		"<HOST>", reHost,
+		"<ADDR>", reAddr,
+		"<IP4>", `(?P<ip4>`+reIP4+`)`,
+		"<IP6>", `\[?(?P<ip6>`+reIP6+`)\]?`,
+		"<DNS>", `(?P<dns>`+reDNS+`)`,
+	)
+
+	// Даты, которые fail2ban вырезает из строки перед применением failregex
+	datePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?\s*`),
+		regexp.MustCompile(`^[A-Z][a-z]{2}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}\s*`),
+		regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?:\s+[+-]\d{4})?`),
+	}
+
+	hostGroups = []string{"host", "addr", "ip4", "ip6", "dns", "f_id"}
+
+	builtinFilterVars = map[string]string{
+		"__prefix_line": defaultPrefixLine,
+	}
+
+	ErrFilterNotFound = errors.New("filter not found")
+)
+
+type compiledPattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// compilePatterns translates fail2ban failregex/ignoreregex lines into Go regexps.
+// Each non-empty line is a separate pattern, as in filter files.
+func compilePatterns(raw string) ([]compiledPattern, error) {
+	var patterns []compiledPattern
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.Contains(line, "<SKIPLINES>") {
+			return nil, fmt.Errorf("pattern %q: multi-line <SKIPLINES> is not supported by the builtin engine", line)
+		}
+
+		translated := tagReplacer.Replace(line)
+		translated = fTagOpenRegex.ReplaceAllStringFunc(
+			translated, func(tag string) string {
+				name := fTagOpenRegex.FindStringSubmatch(tag)[1]
+				return "(?P<f_" + strings.ToLower(name) + ">"
+			},
+		)
+		translated = fTagCloseRegex.ReplaceAllString(translated, ")")
+
+		re, err := regexp.Compile(translated)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", line, err)
+		}
+		patterns = append(patterns, compiledPattern{source: line, re: re})
+	}
+	return patterns, nil
+}
+
+// stripDate removes the first recognised timestamp the way fail2ban does
+// before matching, leaving surrounding brackets in place.
+func stripDate(line string) string {
+	for _, re := range datePatterns {
+		if loc := re.FindStringIndex(line); loc != nil {
+			return line[:loc[0]] + line[loc[1]:]
+		}
+	}
+	return line
+}
+
+// extractHost returns the first non-empty address-like group of a match.
+func extractHost(
+	re *regexp.Regexp,
+	matches []string,
+) string {
+	for _, group := range hostGroups {
+		if idx := re.SubexpIndex(group); idx >= 0 && idx < len(matches) && matches[idx] != "" {
+			return matches[idx]
+		}
+	}
+	return ""
+}
+
+type filterDefinition struct {
+	FailRegex   string
+	IgnoreRegex string
+}
+
+// loadFilter resolves <dir>/<name>.conf together with its .local override and
+// [INCLUDES] before/after files, then interpolates %(var)s references.
+func loadFilter(
+	dir, name string,
+) (*filterDefinition, error) {
+	vars := map[string]string{}
+	for k, v := range builtinFilterVars {
+		vars[k] = v
+	}
+
+	found, err := loadFilterFile(dir, name, vars, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrFilterNotFound
+	}
+
+	def := &filterDefinition{}
+	if def.FailRegex, err = interpolate(vars[filterKeyFailRegex], vars); err != nil {
+		return nil, err
+	}
+	if def.IgnoreRegex, err = interpolate(vars[filterKeyIgnoreRegex], vars); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+func loadFilterFile(
+	dir, name string,
+	vars map[string]string,
+	depth int,
+) (bool, error) {
+	if depth > filterMaxIncludeDepth {
+		return false, fmt.Errorf("filter %q: include depth exceeded", name)
+	}
+
+	base := strings.TrimSuffix(strings.TrimSuffix(name, ".conf"), ".local")
+	found := false
+	for _, ext := range []string{".conf", ".local"} {
+		data, err := os.ReadFile(filepath.Join(dir, base+ext)) //nolint:gosec // name validated by caller, dir from config
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return false, err
+		}
+		found = true
+
+		sections := parseFilterConf(data)
+		includes := sections[filterSectionIncludes]
+		for _, inc := range strings.Fields(includes[filterKeyBefore]) {
+			if _, err := loadFilterFile(dir, inc, vars, depth+1); err != nil {
+				return false, err
+			}
+		}
+		for _, section := range []string{filterSectionDefault, filterSectionDefinition} {
+			for k, v := range sections[section] {
+				vars[k] = v
+			}
+		}
+		for _, inc := range strings.Fields(includes[filterKeyAfter]) {
+			if _, err := loadFilterFile(dir, inc, vars, depth+1); err != nil {
+				return false, err
+			}
+		}
+	}
+	return found, nil
+}
+
+// parseFilterConf parses fail2ban INI files, including multi-line values
+// whose continuation lines start with whitespace.
+func parseFilterConf(data []byte) map[string]map[string]string {
+	sections := map[string]map[string]string{}
+	var current map[string]string
+	lastKey := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			current = sections[name]
+			lastKey = ""
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if (raw[0] == ' ' || raw[0] == '\t') && lastKey != "" {
+			current[lastKey] += "\n" + line
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			continue
+		}
+		lastKey = strings.TrimSpace(line[:sep])
+		current[lastKey] = strings.TrimSpace(line[sep+1:])
+	}
+	return sections
+}
+
+func interpolate(
+	value string,
+	vars map[string]string,
+) (string, error) {
+	for i := 0; i < filterMaxInterpolations; i++ {
+		if !interpolateExpr.MatchString(value) {
+			return value, nil
+		}
+		var missing string
+		value = interpolateExpr.ReplaceAllStringFunc(
+			value, func(expr string) string {
+				key := interpolateExpr.FindStringSubmatch(expr)[1]
+				v, ok := vars[key]
+				if !ok {
+					missing = key
+					return expr
+				}
+				return v
+			},
+		)
+		if missing != "" {
+			return "", fmt.Errorf("undefined filter variable %q", missing)
+		}
+	}
+	return "", errors.New("filter variable interpolation too deep")
+}
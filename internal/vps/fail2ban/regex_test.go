@@ -0,0 +1,275 @@
+package fail2ban
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func newTestRegexTester(t *testing.T, allowlist ...string) (*RegexTester, string) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, FilterSubdir), 0750); err != nil {
+		t.Fatal(err)
+	}
+	tester := NewRegexTester(
+		config.Fail2BanConfig{
+			ConfigDir: dir,
+			RegexTest: config.RegexTestConfig{
+				LogAllowlist: allowlist,
+				MaxLines:     100,
+				Timeout:      time.Second,
+			},
+		},
+		zap.NewNop(),
+	)
+	return tester, dir
+}
+
+func TestCompilePatterns_Tags(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		line     string
+		wantHost string
+	}{
+		{"host ipv4", `Failed password for .* from <HOST>`, "Failed password for root from 1.2.3.4 port 22", "1.2.3.4"},
+		{"host ipv6", `from <HOST> port`, "from 2001:db8::1 port 22", "2001:db8::1"},
+		{"addr", `client <ADDR>$`, "client 10.0.0.7", "10.0.0.7"},
+		{"f-id", `user=<F-ID>\S+</F-ID> denied`, "user=bob denied", "bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				patterns, err := compilePatterns(tt.pattern)
+				if err != nil {
+					t.Fatalf("compilePatterns() error: %v", err)
+				}
+				m := patterns[0].re.FindStringSubmatch(tt.line)
+				if m == nil {
+					t.Fatalf("pattern %q did not match %q", tt.pattern, tt.line)
+				}
+				if got := extractHost(patterns[0].re, m); got != tt.wantHost {
+					t.Errorf("host = %q, want %q", got, tt.wantHost)
+				}
+			},
+		)
+	}
+}
+
+func TestCompilePatterns_Errors(t *testing.T) {
+	for _, pattern := range []string{`(?<!foo)<HOST>`, "<SKIPLINES>foo"} {
+		if _, err := compilePatterns(pattern); err == nil {
+			t.Errorf("compilePatterns(%q) expected error", pattern)
+		}
+	}
+}
+
+func TestStripDate(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"2026-10-18T12:00:00Z auth failure", "auth failure"},
+		{"Oct 18 12:00:00 host sshd[1]: msg", "host sshd[1]: msg"},
+		{`1.2.3.4 - - [18/Oct/2026:12:00:00 +0000] "GET /"`, `1.2.3.4 - - [] "GET /"`},
+		{"no date here", "no date here"},
+	}
+
+	for _, tt := range tests {
+		if got := stripDate(tt.line); got != tt.want {
+			t.Errorf("stripDate(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFilter_IncludesAndInterpolation(t *testing.T) {
+	_, dir := newTestRegexTester(t)
+	filterDir := filepath.Join(dir, FilterSubdir)
+
+	common := "[DEFAULT]\n_daemon = \\S*\n__prefix_line = \\s*\\S+ %(_daemon)s(?:\\[\\d+\\])?:\\s+\n"
+	filter := "[INCLUDES]\nbefore = common.conf\n\n[Definition]\n_daemon = sshd\n" +
+		"failregex = ^%(__prefix_line)sFailed password for .* from <HOST>\n" +
+		"            ^%(__prefix_line)sInvalid user .* from <HOST>\n" +
+		"ignoreregex =\n"
+	local := "[Definition]\nignoreregex = for admin from\n"
+
+	for name, content := range map[string]string{
+		"common.conf":     common,
+		"test-sshd.conf":  filter,
+		"test-sshd.local": local,
+	} {
+		if err := os.WriteFile(filepath.Join(filterDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	def, err := loadFilter(filterDir, "test-sshd")
+	if err != nil {
+		t.Fatalf("loadFilter() error: %v", err)
+	}
+
+	lines := strings.Split(def.FailRegex, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("failregex lines = %d, want 2: %q", len(lines), def.FailRegex)
+	}
+	if !strings.Contains(lines[0], `\s*\S+ sshd(?:\[\d+\])?:\s+`) {
+		t.Errorf("prefix not interpolated with filter _daemon: %q", lines[0])
+	}
+	if def.IgnoreRegex != "for admin from" {
+		t.Errorf("ignoreregex = %q, want .local override", def.IgnoreRegex)
+	}
+
+	if _, err := loadFilter(filterDir, "missing"); !errors.Is(err, ErrFilterNotFound) {
+		t.Errorf("loadFilter(missing) error = %v, want ErrFilterNotFound", err)
+	}
+}
+
+func TestRegexTester_BuiltinSampleLines(t *testing.T) {
+	tester, _ := newTestRegexTester(t)
+
+	res, err := tester.Test(
+		&RegexTestInput{
+			FailRegex:   `^%(__prefix_line)sFailed password for \S+ from <HOST>`,
+			IgnoreRegex: `from 10\.`,
+			Lines: "Oct 18 12:00:00 host sshd[1]: Failed password for root from 1.2.3.4 port 22\n" +
+				"Oct 18 12:00:01 host sshd[1]: Accepted password for root from 5.6.7.8 port 22\n" +
+				"Oct 18 12:00:02 host sshd[1]: Failed password for root from 10.0.0.1 port 22\n",
+			Engine: EngineBuiltin,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Test() error: %v", err)
+	}
+
+	if res.TotalLines != 3 || res.MatchedCount != 1 || res.IgnoredCount != 1 || res.MissedCount != 1 {
+		t.Errorf("counts = %d/%d/%d/%d, want 3/1/1/1", res.TotalLines, res.MatchedCount, res.IgnoredCount, res.MissedCount)
+	}
+	if len(res.Matches) != 1 || res.Matches[0].Host != "1.2.3.4" || res.Matches[0].LineNumber != 1 {
+		t.Errorf("matches = %+v", res.Matches)
+	}
+}
+
+func TestRegexTester_UndefinedVariable(t *testing.T) {
+	tester, _ := newTestRegexTester(t)
+
+	_, err := tester.Test(&RegexTestInput{FailRegex: `^%(__unknown)s<HOST>`, Lines: "1.2.3.4"})
+	var appErr *apierror.AppError
+	if !errors.As(err, &appErr) || appErr.Code != "FAIL2BAN_INVALID_REGEX" {
+		t.Errorf("error = %v, want FAIL2BAN_INVALID_REGEX", err)
+	}
+}
+
+func TestRegexTester_LogAllowlist(t *testing.T) {
+	logDir := t.TempDir()
+	allowed := filepath.Join(logDir, "auth.log")
+	other := filepath.Join(logDir, "other.log")
+	content := "2026-10-18T12:00:00Z login failed from 1.2.3.4\n2026-10-18T12:00:01Z login failed from 5.6.7.8\n"
+	for _, p := range []string{allowed, other} {
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tester, _ := newTestRegexTester(t, allowed)
+
+	res, err := tester.Test(&RegexTestInput{FailRegex: `^login failed from <HOST>$`, LogPath: allowed, MaxLines: 1})
+	if err != nil {
+		t.Fatalf("Test() error: %v", err)
+	}
+	if res.TotalLines != 1 || len(res.Matches) != 1 || res.Matches[0].Host != "5.6.7.8" {
+		t.Errorf("expected only the last line to be tested, got %+v", res)
+	}
+
+	_, err = tester.Test(&RegexTestInput{FailRegex: `<HOST>`, LogPath: other})
+	var appErr *apierror.AppError
+	if !errors.As(err, &appErr) || appErr.Code != "FAIL2BAN_LOG_NOT_ALLOWED" {
+		t.Errorf("non-allowlisted log error = %v, want FAIL2BAN_LOG_NOT_ALLOWED", err)
+	}
+}
+
+func TestParseNativeOutput(t *testing.T) {
+	lines := []string{"line one 1.2.3.4", "line two", "line three 5.6.7.8"}
+	output := "Results\n=======\n\n" +
+		"Lines: 3 lines, 0 ignored, 2 matched, 1 missed\n" +
+		"[processed in 0.00 sec]\n\n" +
+		"|- Matched line(s):\n" +
+		"|  line one 1.2.3.4\n" +
+		"|  line three 5.6.7.8\n" +
+		"`-\n"
+
+	res := parseNativeOutput(output, lines)
+	if res.TotalLines != 3 || res.MatchedCount != 2 || res.MissedCount != 1 {
+		t.Errorf("summary = %+v", res)
+	}
+	if len(res.Matches) != 2 || res.Matches[1].LineNumber != 3 {
+		t.Errorf("matches = %+v", res.Matches)
+	}
+}
+
+func TestWriteTempFilter(t *testing.T) {
+	path, err := writeTempFilter("", "/etc/shadow\n  -h\n\nrate 100%(x)s <HOST>", "")
+	if err != nil {
+		t.Fatalf("writeTempFilter() error: %v", err)
+	}
+	defer func() { _ = os.Remove(path) }()
+
+	data, _ := os.ReadFile(path)
+	want := "[Definition]\nfailregex = /etc/shadow\n    -h\n    rate 100%%(x)s <HOST>\nignoreregex =\n"
+	if string(data) != want {
+		t.Errorf("filter = %q, want %q", string(data), want)
+	}
+
+	path, err = writeTempFilter("/etc/fail2ban/filter.d/sshd.conf", "", "^\\[x\\]\n[Definition]")
+	if err != nil {
+		t.Fatalf("writeTempFilter() error: %v", err)
+	}
+	defer func() { _ = os.Remove(path) }()
+
+	data, _ = os.ReadFile(path)
+	want = "[INCLUDES]\nbefore = /etc/fail2ban/filter.d/sshd.conf\n\n[Definition]\nignoreregex = ^\\[x\\]\n    [Definition]\n"
+	if string(data) != want {
+		t.Errorf("filter = %q, want %q", string(data), want)
+	}
+}
+
+func TestDecodeRegexTestRequest(t *testing.T) {
+	t.Run(
+		"valid", func(t *testing.T) {
+			input, err := decodeRegexTestRequest(
+				&RegexTestRequest{
+					FailRegexB64: "ZnJvbSA8SE9TVD4=",
+					LinesB64:     "ZnJvbSAxLjIuMy40",
+				},
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if input.FailRegex != "from <HOST>" || input.Lines != "from 1.2.3.4" || input.Engine != EngineBuiltin {
+				t.Errorf("decoded = %+v", input)
+			}
+		},
+	)
+
+	invalid := []RegexTestRequest{
+		{LinesB64: "eA=="},
+		{FailRegexB64: "eA==", Filter: "sshd", LinesB64: "eA=="},
+		{FailRegexB64: "eA=="},
+		{FailRegexB64: "eA==", LinesB64: "eA==", LogPath: "/var/log/auth.log"},
+		{Filter: "../sshd", LinesB64: "eA=="},
+		{FailRegexB64: "not base64!", LinesB64: "eA=="},
+	}
+	for i, req := range invalid {
+		if _, err := decodeRegexTestRequest(&req); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, req)
+		}
+	}
+}
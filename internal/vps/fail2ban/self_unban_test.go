@@ -0,0 +1,196 @@
+package fail2ban
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+
+	"go.uber.org/zap"
+)
+
+// fakeControl implements only the calls the self-unban flow needs.
+type fakeControl struct {
+	fail2banControl
+	bans      map[string][]string
+	ignores   map[string][]string
+	detailErr map[string]error
+	removed   []string
+}
+
+func (f *fakeControl) GetGlobalStatus() (*Fail2BanStatusDTO, error) {
+	res := &Fail2BanStatusDTO{}
+	for jail := range f.bans {
+		res.JailList = append(res.JailList, jail)
+	}
+	slices.Sort(res.JailList)
+	res.JailCount = len(res.JailList)
+	return res, nil
+}
+
+func (f *fakeControl) GetJailDetails(jail string) (*JailDetailsDTO, error) {
+	if err := f.detailErr[jail]; err != nil {
+		return nil, err
+	}
+	return &JailDetailsDTO{JailName: jail, BannedIPList: f.bans[jail]}, nil
+}
+
+func (f *fakeControl) UnbanIP(jail, ip string) error {
+	f.bans[jail] = slices.DeleteFunc(f.bans[jail], func(s string) bool { return s == ip })
+	return nil
+}
+
+func (f *fakeControl) GetIgnoreIPs(jail string) ([]string, error) {
+	return f.ignores[jail], nil
+}
+
+func (f *fakeControl) AddIgnoreIP(jail, address string) error {
+	f.ignores[jail] = append(f.ignores[jail], address)
+	return nil
+}
+
+func (f *fakeControl) RemoveIgnoreIP(jail, address string) error {
+	f.ignores[jail] = slices.DeleteFunc(f.ignores[jail], func(s string) bool { return s == address })
+	f.removed = append(f.removed, jail+"/"+address)
+	return nil
+}
+
+type fakeTempIgnoreStore struct {
+	entries map[string]sqlite3_local.TempIgnoreEntity
+}
+
+func (s *fakeTempIgnoreStore) SaveTempIgnore(entry sqlite3_local.TempIgnoreEntity) error {
+	s.entries[entry.IP+"/"+entry.Jail] = entry
+	return nil
+}
+
+func (s *fakeTempIgnoreStore) GetExpiredTempIgnores(now int64) ([]sqlite3_local.TempIgnoreEntity, error) {
+	var res []sqlite3_local.TempIgnoreEntity
+	for _, e := range s.entries {
+		if e.ExpiresAt <= now {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeTempIgnoreStore) GetJailTempIgnores(jail string) ([]sqlite3_local.TempIgnoreEntity, error) {
+	var res []sqlite3_local.TempIgnoreEntity
+	for _, e := range s.entries {
+		if e.Jail == jail {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeTempIgnoreStore) DeleteTempIgnore(ip, jail string) error {
+	delete(s.entries, ip+"/"+jail)
+	return nil
+}
+
+func newTestSelfUnban() (*SelfUnbanService, *fakeControl, *fakeTempIgnoreStore, *fakeRecorder, *time.Time) {
+	control := &fakeControl{
+		bans: map[string][]string{
+			"sshd":   {"203.0.113.7", "1.1.1.1"},
+			"nginx":  {"1.1.1.1"},
+			"broken": nil,
+		},
+		ignores: map[string][]string{
+			"nginx": {"203.0.113.7"},
+		},
+		detailErr: map[string]error{"broken": apierror.Errors.FAIL2BAN_JAIL_NOT_FOUND},
+	}
+	store := &fakeTempIgnoreStore{entries: map[string]sqlite3_local.TempIgnoreEntity{}}
+	recorder := &fakeRecorder{}
+	svc := NewSelfUnbanService(
+		config.SelfUnbanConfig{IgnoreDuration: time.Hour},
+		control, store, recorder, zap.NewNop(),
+	)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	return svc, control, store, recorder, &now
+}
+
+func TestSelfUnbanService_Unban(t *testing.T) {
+	svc, control, store, _, _ := newTestSelfUnban()
+
+	res, err := svc.UnbanSelf("203.0.113.7", "admin", false)
+	if err != nil {
+		t.Fatalf("UnbanSelf() error: %v", err)
+	}
+	if res.CheckedJails != 3 || !slices.Equal(res.UnbannedFrom, []string{"sshd"}) {
+		t.Errorf("response = %+v", res)
+	}
+	if slices.Contains(control.bans["sshd"], "203.0.113.7") || !slices.Contains(control.bans["sshd"], "1.1.1.1") {
+		t.Errorf("sshd bans = %v, only the caller's IP should be removed", control.bans["sshd"])
+	}
+	if res.IgnoreUntil != nil || len(store.entries) != 0 {
+		t.Error("no ignoreip expected without ignore flag")
+	}
+
+	var brokenErr string
+	for _, jr := range res.Jails {
+		if jr.Jail == "broken" {
+			brokenErr = jr.Error
+		}
+	}
+	if brokenErr == "" {
+		t.Error("per-jail errors should be reported")
+	}
+}
+
+func TestSelfUnbanService_TemporaryIgnore(t *testing.T) {
+	svc, control, store, recorder, now := newTestSelfUnban()
+
+	res, err := svc.UnbanSelf("203.0.113.7", "admin", true)
+	if err != nil {
+		t.Fatalf("UnbanSelf() error: %v", err)
+	}
+	if res.IgnoreUntil == nil || !res.IgnoreUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("IgnoreUntil = %v", res.IgnoreUntil)
+	}
+	if !slices.Contains(control.ignores["sshd"], "203.0.113.7") {
+		t.Error("IP should be added to sshd ignoreip")
+	}
+	if _, ok := store.entries["203.0.113.7/nginx"]; ok {
+		t.Error("pre-existing ignoreip entry must not be tracked for expiry")
+	}
+	if _, ok := store.entries["203.0.113.7/sshd"]; !ok {
+		t.Error("temporary ignore should be stored")
+	}
+
+	svc.expireIgnores()
+	if len(control.removed) != 0 {
+		t.Fatalf("nothing should expire yet, removed %v", control.removed)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	svc.expireIgnores()
+	if !slices.Equal(control.removed, []string{"sshd/203.0.113.7"}) {
+		t.Errorf("removed = %v, want only sshd", control.removed)
+	}
+	if !slices.Contains(control.ignores["nginx"], "203.0.113.7") {
+		t.Error("pre-existing nginx ignoreip entry must survive expiry")
+	}
+	if len(store.entries) != 0 {
+		t.Errorf("store entries = %v, want empty", store.entries)
+	}
+	if len(recorder.entries) != 1 || recorder.entries[0].Action != AuditActionIgnoreExpired {
+		t.Errorf("audit entries = %+v", recorder.entries)
+	}
+}
+
+func TestSelfUnbanService_InvalidIP(t *testing.T) {
+	svc, _, _, _, _ := newTestSelfUnban()
+
+	if _, err := svc.UnbanSelf("", "admin", false); err == nil {
+		t.Error("expected error for empty client IP")
+	} else if errors.As(err, new(*apierror.AppError)) {
+		t.Errorf("invalid IP should be a plain error, got %v", err)
+	}
+}
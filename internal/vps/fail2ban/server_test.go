@@ -0,0 +1,67 @@
+package fail2ban
+
+import (
+	"errors"
+	"testing"
+
+	"VPS-control/internal/apierror"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		wantCode string
+	}{
+		{
+			name:     "socket unavailable",
+			output:   "ERROR  Failed to access socket path: /var/run/fail2ban/fail2ban.sock. Is fail2ban running?",
+			wantCode: "FAIL2BAN_SERVER_UNAVAILABLE",
+		},
+		{
+			name:     "jail missing",
+			output:   "Sorry but the jail 'nope' Does not exist",
+			wantCode: "FAIL2BAN_JAIL_NOT_FOUND",
+		},
+		{
+			name:     "generic failure",
+			output:   "ERROR  Failed during configuration: bad value",
+			wantCode: "FAIL2BAN_EXECUTION_ERROR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				result := &CommandResultDTO{Command: "reload"}
+				err := classifyFailure(result, tt.output, errors.New("exit status 255"))
+
+				var appErr *apierror.AppError
+				if !errors.As(err, &appErr) {
+					t.Fatalf("expected *apierror.AppError, got %T", err)
+				}
+				if appErr.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", appErr.Code, tt.wantCode)
+				}
+			},
+		)
+	}
+}
+
+func TestVersionRegex(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"1.0.2\n", "1.0.2"},
+		{"Fail2Ban v0.11.2\n", "0.11.2"},
+		{"1.1\n", "1.1"},
+	}
+
+	for _, tt := range tests {
+		matches := versionRegex.FindStringSubmatch(tt.output)
+		if len(matches) < 2 || matches[1] != tt.want {
+			t.Errorf("version from %q = %v, want %q", tt.output, matches, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/config"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//go:embed assets/vps-control-auth.conf
+var authFilterConf []byte
+
+// AuthFilterContent returns the shipped filter for the API's own failure log.
+func AuthFilterContent() []byte {
+	return authFilterConf
+}
+
+// InstallAuthJail writes the auth filter to filter.d and a jail.d file for the
+// configured auth jail, and makes sure the watched log exists so fail2ban can
+// start the jail before the first failed login.
+func InstallAuthJail(cfg config.Fail2BanConfig) (*AuthJailInstallResult, error) {
+	jail := cfg.AuthJail
+	if !IsValidJailName(jail.Name) {
+		return nil, fmt.Errorf("invalid jail name %q", jail.Name)
+	}
+
+	res := &AuthJailInstallResult{
+		Jail:       jail.Name,
+		FilterPath: filepath.Join(cfg.ConfigDir, FilterSubdir, AuthFilterName+".conf"),
+		JailPath:   filepath.Join(cfg.ConfigDir, ManagedFileSubdir, jail.Name+".local"),
+		LogPath:    jail.LogPath,
+	}
+
+	for _, file := range []struct {
+		path string
+		data []byte
+	}{
+		{res.FilterPath, authFilterConf},
+		{res.JailPath, renderAuthJail(jail)},
+	} {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0750); err != nil {
+			return nil, fmt.Errorf("create %s: %w", filepath.Dir(file.path), err)
+		}
+		if err := writeFileAtomic(file.path, file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := touchLogFile(jail.LogPath); err != nil {
+		return nil, fmt.Errorf("prepare auth log: %w", err)
+	}
+	return res, nil
+}
+
+func renderAuthJail(jail config.AuthJailConfig) []byte {
+	var b strings.Builder
+	b.WriteString(ManagedFileHeader + "\n\n")
+	fmt.Fprintf(&b, "[%s]\n", jail.Name)
+	fmt.Fprintf(&b, "enabled  = %t\n", jail.Enabled)
+	fmt.Fprintf(&b, "filter   = %s\n", AuthFilterName)
+	fmt.Fprintf(&b, "port     = %s\n", jail.Port)
+	fmt.Fprintf(&b, "logpath  = %s\n", jail.LogPath)
+	b.WriteString("backend  = auto\n")
+	fmt.Fprintf(&b, "%s = %d\n", ParamMaxRetry, jail.MaxRetry)
+	fmt.Fprintf(&b, "%s = %d\n", ParamFindTime, int64(jail.FindTime/time.Second))
+	fmt.Fprintf(&b, "%s  = %d\n", ParamBanTime, int64(jail.BanTime/time.Second))
+	return []byte(b.String())
+}
+
+func touchLogFile(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0640) //nolint:gosec // path from config
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
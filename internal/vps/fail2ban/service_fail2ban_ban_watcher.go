@@ -0,0 +1,197 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ banEventReader = (*BanWatcher)(nil)
+
+// BanWatcher periodically snapshots the banned IPs of every jail and turns the
+// difference to the previous snapshot into ban added/removed events. The first
+// poll only records a baseline, so a restart does not replay existing bans.
+type BanWatcher struct {
+	source    banSource
+	store     sqlite3_local.BanEventStore
+	publisher eventPublisher
+	interval  time.Duration
+	retention time.Duration
+	snapshot  map[string]map[string]struct{}
+	baseline  bool
+	lastPurge time.Time
+	now       func() time.Time
+	logger    *zap.Logger
+}
+
+func NewBanWatcher(
+	cfg config.BanWatcherConfig,
+	source banSource,
+	store sqlite3_local.BanEventStore,
+	publisher eventPublisher,
+	logger *zap.Logger,
+) *BanWatcher {
+	return &BanWatcher{
+		source:    source,
+		store:     store,
+		publisher: publisher,
+		interval:  cfg.Interval,
+		retention: cfg.Retention,
+		snapshot:  make(map[string]map[string]struct{}),
+		now:       time.Now,
+		logger:    logger.Named("fail2ban_ban_watcher"),
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (w *BanWatcher) Run(ctx context.Context) {
+	w.logger.Info("Ban watcher started", zap.Duration("interval", w.interval))
+	w.poll()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Ban watcher stopped")
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *BanWatcher) GetEvents(filter sqlite3_local.BanEventFilter) ([]BanEventDTO, error) {
+	entities, err := w.store.GetBanEvents(filter)
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	events := make([]BanEventDTO, 0, len(entities))
+	for _, e := range entities {
+		events = append(
+			events, BanEventDTO{
+				ID:        e.ID,
+				Jail:      e.Jail,
+				IP:        e.IP,
+				Event:     e.Event,
+				Timestamp: time.Unix(e.CreatedAt, 0).UTC(),
+			},
+		)
+	}
+	return events, nil
+}
+
+func (w *BanWatcher) poll() {
+	status, err := w.source.GetGlobalStatus()
+	if err != nil {
+		w.logger.Warn("Failed to get fail2ban status, skipping poll", zap.Error(err))
+		return
+	}
+
+	now := w.now()
+	current := make(map[string]map[string]struct{}, len(status.JailList))
+	for _, jail := range status.JailList {
+		details, err := w.source.GetJailDetails(jail)
+		if err != nil {
+			// Оставляем прошлый снимок, чтобы временная ошибка не выглядела как снятие всех банов
+			w.logger.Warn("Failed to get jail details", zap.String("jail", jail), zap.Error(err))
+			if prev, ok := w.snapshot[jail]; ok {
+				current[jail] = prev
+			}
+			continue
+		}
+		ips := make(map[string]struct{}, len(details.BannedIPList))
+		for _, ip := range details.BannedIPList {
+			ips[ip] = struct{}{}
+		}
+		current[jail] = ips
+	}
+
+	if w.baseline {
+		w.emit(diffSnapshots(w.snapshot, current, now))
+	}
+	w.snapshot = current
+	w.baseline = true
+
+	w.purge(now)
+}
+
+func (w *BanWatcher) emit(events []sqlite3_local.BanEventEntity) {
+	if len(events) == 0 {
+		return
+	}
+	if err := w.store.RecordBanEvents(events); err != nil {
+		w.logger.Error("Failed to store ban events", zap.Int("count", len(events)), zap.Error(err))
+	}
+	for _, e := range events {
+		subject := SubjectBanAdded
+		if e.Event == BanEventRemoved {
+			subject = SubjectBanRemoved
+		}
+		payload := BanChangeEvent{Jail: e.Jail, IP: e.IP, DetectedAt: time.Unix(e.CreatedAt, 0).UTC()}
+		if err := w.publisher.Publish(subject, payload); err != nil {
+			w.logger.Warn("Failed to publish ban event", zap.String("subject", subject), zap.Error(err))
+		}
+	}
+	w.logger.Info("Ban set changed", zap.Int("events", len(events)))
+}
+
+func (w *BanWatcher) purge(now time.Time) {
+	if now.Sub(w.lastPurge) < banEventsPurgeInterval {
+		return
+	}
+	w.lastPurge = now
+	if err := w.store.DeleteBanEventsBefore(now.Add(-w.retention).Unix()); err != nil {
+		w.logger.Warn("Failed to purge old ban events", zap.Error(err))
+	}
+}
+
+// diffSnapshots returns added/removed events in a stable jail/ip order.
+// A jail that disappeared (stopped) reports all of its bans as removed.
+func diffSnapshots(
+	prev, current map[string]map[string]struct{},
+	now time.Time,
+) []sqlite3_local.BanEventEntity {
+	jails := make(map[string]struct{}, len(prev)+len(current))
+	for jail := range prev {
+		jails[jail] = struct{}{}
+	}
+	for jail := range current {
+		jails[jail] = struct{}{}
+	}
+	names := make([]string, 0, len(jails))
+	for jail := range jails {
+		names = append(names, jail)
+	}
+	sort.Strings(names)
+
+	var events []sqlite3_local.BanEventEntity
+	for _, jail := range names {
+		for _, ip := range missingIn(current[jail], prev[jail]) {
+			events = append(events, sqlite3_local.BanEventEntity{Jail: jail, IP: ip, Event: BanEventAdded, CreatedAt: now.Unix()})
+		}
+		for _, ip := range missingIn(prev[jail], current[jail]) {
+			events = append(events, sqlite3_local.BanEventEntity{Jail: jail, IP: ip, Event: BanEventRemoved, CreatedAt: now.Unix()})
+		}
+	}
+	return events
+}
+
+// missingIn returns sorted keys of a that are not present in b.
+func missingIn(
+	a, b map[string]struct{},
+) []string {
+	var res []string
+	for ip := range a {
+		if _, ok := b[ip]; !ok {
+			res = append(res, ip)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
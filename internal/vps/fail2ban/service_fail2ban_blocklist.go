@@ -0,0 +1,462 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ blocklistManager = (*BlocklistService)(nil)
+
+// BlocklistService exports the IPs banned on this host and imports blocklists
+// from files or other instances into a designated jail. Imported entries are
+// tracked in SQLite with an expiry and are never re-exported, so two instances
+// feeding each other cannot keep a ban alive forever.
+type BlocklistService struct {
+	cfg       config.BlocklistImportConfig
+	source    banSource
+	banner    ipBanController
+	store     sqlite3_local.ImportedBanStore
+	allowlist []*net.IPNet
+	client    *http.Client
+	running   sync.Mutex
+	now       func() time.Time
+	logger    *zap.Logger
+}
+
+func NewBlocklistService(
+	cfg config.BlocklistImportConfig,
+	source banSource,
+	banner ipBanController,
+	store sqlite3_local.ImportedBanStore,
+	logger *zap.Logger,
+) *BlocklistService {
+	l := logger.Named("fail2ban_blocklist")
+	allowlist, invalid := parseAllowlist(cfg.Allowlist)
+	for _, entry := range invalid {
+		l.Warn("Ignoring invalid blocklist allowlist entry", zap.String("entry", entry))
+	}
+	return &BlocklistService{
+		cfg:       cfg,
+		source:    source,
+		banner:    banner,
+		store:     store,
+		allowlist: allowlist,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		now:       time.Now,
+		logger:    l,
+	}
+}
+
+// Export aggregates banned IPs across all jails, skipping IPs that were imported.
+func (s *BlocklistService) Export() (*BlocklistDTO, error) {
+	status, err := s.source.GetGlobalStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	imported := map[string]struct{}{}
+	bans, err := s.store.GetImportedBans()
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	for _, b := range bans {
+		imported[b.IP] = struct{}{}
+	}
+
+	jailsByIP := map[string][]string{}
+	for _, jail := range status.JailList {
+		details, err := s.source.GetJailDetails(jail)
+		if err != nil {
+			s.logger.Warn("Skipping jail in blocklist export", zap.String("jail", jail), zap.Error(err))
+			continue
+		}
+		for _, ip := range details.BannedIPList {
+			if _, ok := imported[ip]; ok {
+				continue
+			}
+			jailsByIP[ip] = append(jailsByIP[ip], jail)
+		}
+	}
+
+	res := &BlocklistDTO{
+		GeneratedAt: s.now().UTC(),
+		Entries:     make([]BlocklistEntryDTO, 0, len(jailsByIP)),
+	}
+	for ip, jails := range jailsByIP {
+		sort.Strings(jails)
+		res.Entries = append(res.Entries, BlocklistEntryDTO{IP: ip, Jails: jails})
+	}
+	sort.Slice(res.Entries, func(i, j int) bool { return res.Entries[i].IP < res.Entries[j].IP })
+	res.Count = len(res.Entries)
+	return res, nil
+}
+
+// Run imports on the configured interval until ctx is cancelled.
+func (s *BlocklistService) Run(ctx context.Context) {
+	s.logger.Info("Blocklist import started", zap.Duration("interval", s.cfg.Interval))
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		s.runScheduled(ctx)
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Blocklist import stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *BlocklistService) runScheduled(ctx context.Context) {
+	res, err := s.Import(ctx)
+	if err != nil {
+		s.logger.Warn("Scheduled blocklist import failed", zap.Error(err))
+		return
+	}
+	s.logger.Info(
+		"Blocklist import finished",
+		zap.Int("sources", len(res.Sources)),
+		zap.Int("expired", res.Expired),
+		zap.Int64("duration_ms", res.DurationMs),
+	)
+}
+
+// Import pulls every configured source, bans new entries, refreshes the expiry
+// of entries seen again and unbans entries whose expiry has passed.
+func (s *BlocklistService) Import(ctx context.Context) (*ImportResultDTO, error) {
+	if !s.cfg.Enabled || len(s.cfg.Sources) == 0 {
+		return nil, apierror.Errors.FAIL2BAN_IMPORT_NOT_CONFIGURED
+	}
+	if !s.running.TryLock() {
+		return nil, apierror.Errors.FAIL2BAN_IMPORT_IN_PROGRESS
+	}
+	defer s.running.Unlock()
+
+	started := s.now()
+	known := map[string]sqlite3_local.ImportedBanEntity{}
+	bans, err := s.store.GetImportedBans()
+	if err != nil {
+		return nil, apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	for _, b := range bans {
+		known[b.IP] = b
+	}
+
+	jailed := s.jailBans()
+	res := &ImportResultDTO{Jail: s.cfg.Jail, Sources: []ImportSourceResultDTO{}}
+	seen := map[string]struct{}{}
+	for _, src := range s.cfg.Sources {
+		sr := ImportSourceResultDTO{Source: sourceName(src)}
+		data, err := s.fetch(ctx, src)
+		if err != nil {
+			sr.Error = err.Error()
+			res.Sources = append(res.Sources, sr)
+			continue
+		}
+
+		ips, invalid := parseBlocklist(data)
+		sr.Fetched, sr.Invalid = len(ips), invalid
+		for _, ip := range ips {
+			if _, dup := seen[ip]; dup {
+				continue
+			}
+			if s.isAllowlisted(ip) {
+				sr.Allowlisted++
+				continue
+			}
+			if len(seen) >= s.cfg.MaxEntries {
+				res.Truncated = true
+				break
+			}
+			seen[ip] = struct{}{}
+			s.importIP(ip, sr.Source, known, jailed, &sr)
+		}
+		res.Sources = append(res.Sources, sr)
+	}
+
+	if jailed != nil {
+		s.releaseLapsed(known, seen, jailed)
+	}
+	res.Expired = s.expire()
+	res.DurationMs = s.now().Sub(started).Milliseconds()
+	return res, nil
+}
+
+// importIP makes sure a listed IP is banned in the import jail. The jail's own
+// bantime may be shorter than the import expiry, so an IP missing from the jail
+// is banned again on every run, not only the first time it is listed. An IP
+// fail2ban already banned on its own is left to fail2ban. With jailed nil the
+// jail could not be read: every IP is banned, but a known IP does not become
+// the importer's, so a ban of fail2ban is never lifted by mistake.
+func (s *BlocklistService) importIP(
+	ip, source string,
+	known map[string]sqlite3_local.ImportedBanEntity,
+	jailed map[string]struct{},
+	sr *ImportSourceResultDTO,
+) {
+	prev, refreshed := known[ip]
+	bannedAt := prev.BannedAt
+	_, inJail := jailed[ip]
+	if !inJail {
+		if err := s.banner.BanIP(s.cfg.Jail, ip); err != nil {
+			sr.Failed++
+			s.logger.Warn("Failed to ban imported IP", zap.String("ip", ip), zap.Error(err))
+			return
+		}
+		if jailed != nil || !refreshed {
+			bannedAt = s.now().Unix()
+		}
+	}
+
+	err := s.store.UpsertImportedBan(
+		sqlite3_local.ImportedBanEntity{
+			IP:        ip,
+			Jail:      s.cfg.Jail,
+			Source:    source,
+			ExpiresAt: s.now().Add(s.cfg.Expiry).Unix(),
+			BannedAt:  bannedAt,
+		},
+	)
+	if err != nil {
+		s.logger.Error("Failed to store imported ban", zap.String("ip", ip), zap.Error(err))
+	}
+	if refreshed || inJail {
+		sr.Refreshed++
+	} else {
+		sr.Banned++
+	}
+}
+
+// releaseLapsed forgets the import ban of IPs that are no longer listed and
+// already gone from the jail. Whatever bans them there later is fail2ban's
+// own ban, which expire must not lift.
+func (s *BlocklistService) releaseLapsed(
+	known map[string]sqlite3_local.ImportedBanEntity,
+	seen, jailed map[string]struct{},
+) {
+	for ip, b := range known {
+		if _, listed := seen[ip]; listed || b.BannedAt == 0 {
+			continue
+		}
+		if _, ok := jailed[ip]; ok {
+			continue
+		}
+		b.BannedAt = 0
+		if err := s.store.UpsertImportedBan(b); err != nil {
+			s.logger.Error("Failed to store imported ban", zap.String("ip", ip), zap.Error(err))
+		}
+	}
+}
+
+// jailBans returns the IPs currently banned in the import jail, or nil when
+// the jail cannot be read.
+func (s *BlocklistService) jailBans() map[string]struct{} {
+	details, err := s.source.GetJailDetails(s.cfg.Jail)
+	if err != nil {
+		s.logger.Warn("Failed to read import jail", zap.String("jail", s.cfg.Jail), zap.Error(err))
+		return nil
+	}
+	jailed := make(map[string]struct{}, len(details.BannedIPList))
+	for _, ip := range details.BannedIPList {
+		jailed[ip] = struct{}{}
+	}
+	return jailed
+}
+
+// expire drops imports that have not been listed for Expiry and lifts only the
+// bans the importer still holds itself.
+func (s *BlocklistService) expire() int {
+	expired, err := s.store.GetExpiredImportedBans(s.now().Unix())
+	if err != nil {
+		s.logger.Error("Failed to load expired imported bans", zap.Error(err))
+		return 0
+	}
+
+	count := 0
+	for _, b := range expired {
+		if b.BannedAt != 0 {
+			err := s.banner.UnbanIP(b.Jail, b.IP)
+			if err != nil && !errors.Is(err, apierror.Errors.FAIL2BAN_IP_NOT_BANNED) {
+				s.logger.Warn("Failed to unban expired imported IP", zap.String("ip", b.IP), zap.Error(err))
+				continue
+			}
+		}
+		if err := s.store.DeleteImportedBan(b.IP); err != nil {
+			s.logger.Error("Failed to delete imported ban", zap.String("ip", b.IP), zap.Error(err))
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+func (s *BlocklistService) fetch(
+	ctx context.Context,
+	src config.BlocklistSource,
+) ([]byte, error) {
+	if src.Path != "" {
+		f, err := os.Open(src.Path) //nolint:gosec // path from config
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		return readLimited(f)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if src.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+src.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return readLimited(resp.Body)
+}
+
+func (s *BlocklistService) isAllowlisted(ip string) bool {
+	return ipAllowlisted(s.allowlist, ip)
+}
+
+// ipAllowlisted reports whether ip must never be banned: loopback and
+// unspecified addresses always, anything else when a network contains it.
+func ipAllowlisted(
+	allowlist []*net.IPNet,
+	ip string,
+) bool {
+	parsed := net.ParseIP(ip)
+	if parsed.IsLoopback() || parsed.IsUnspecified() {
+		return true
+	}
+	for _, n := range allowlist {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, BlocklistMaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > BlocklistMaxBodyBytes {
+		return nil, fmt.Errorf("blocklist exceeds %d bytes", BlocklistMaxBodyBytes)
+	}
+	return data, nil
+}
+
+// parseBlocklist accepts the JSON feed of another instance or a plain list
+// with one IP per line; '#' and ';' start comments, extra columns are ignored.
+func parseBlocklist(data []byte) ([]string, int) {
+	var ips []string
+	invalid := 0
+	add := func(raw string) {
+		if ip := net.ParseIP(raw); ip != nil {
+			ips = append(ips, ip.String())
+		} else {
+			invalid++
+		}
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var feed BlocklistDTO
+		if err := json.Unmarshal(trimmed, &feed); err != nil {
+			return nil, 1
+		}
+		for _, e := range feed.Entries {
+			add(e.IP)
+		}
+		return ips, invalid
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexAny(line, "#;"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		add(fields[0])
+	}
+	return ips, invalid
+}
+
+func parseAllowlist(entries []string) ([]*net.IPNet, []string) {
+	var nets []*net.IPNet
+	var invalid []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				invalid = append(invalid, entry)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets, invalid
+}
+
+func sourceName(src config.BlocklistSource) string {
+	switch {
+	case src.Name != "":
+		return src.Name
+	case src.Path != "":
+		return src.Path
+	}
+	return src.URL
+}
+
+// renderBlocklistText formats the feed as one IP per line with a comment header.
+func renderBlocklistText(list *BlocklistDTO) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# VPS-control blocklist generated %s, %d entries\n", list.GeneratedAt.Format(time.RFC3339), list.Count)
+	for _, e := range list.Entries {
+		b.WriteString(e.IP)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
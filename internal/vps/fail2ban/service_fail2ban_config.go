@@ -0,0 +1,108 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/apierror"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	jailNameRegex      = regexp.MustCompile(ReJailName)
+	ignoreIPEntryRegex = regexp.MustCompile(ReIgnoreIPEntry)
+)
+
+// IsValidJailName reports whether name is safe to pass to fail2ban-client
+// and to use as a section header in the managed jail file.
+func IsValidJailName(name string) bool {
+	return jailNameRegex.MatchString(name)
+}
+
+func (s *ControlService) GetJailConfig(jail string) (*JailConfigDTO, error) {
+	res := &JailConfigDTO{
+		JailName: jail,
+		IgnoreIP: []string{},
+	}
+
+	for param, target := range map[string]*int64{
+		ParamBanTime:  &res.BanTime,
+		ParamFindTime: &res.FindTime,
+		ParamMaxRetry: &res.MaxRetry,
+	} {
+		out, err := s.runClient(ArgGet, jail, param)
+		if err != nil {
+			return nil, err
+		}
+		val, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+		if err != nil {
+			return nil, apierror.Errors.FAIL2BAN_EXECUTION_ERROR.Wrap(
+				fmt.Errorf("unexpected %s value %q: %w", param, strings.TrimSpace(out), err),
+			)
+		}
+		*target = val
+	}
+
+	ignoreIP, err := s.GetIgnoreIPs(jail)
+	if err != nil {
+		return nil, err
+	}
+	res.IgnoreIP = ignoreIP
+
+	return res, nil
+}
+
+func (s *ControlService) GetIgnoreIPs(jail string) ([]string, error) {
+	out, err := s.runClient(ArgGet, jail, ParamIgnoreIP)
+	if err != nil {
+		return nil, err
+	}
+	return parseIgnoreIPList(out), nil
+}
+
+func (s *ControlService) SetJailParam(
+	jail, param string,
+	value int64,
+) error {
+	if _, err := s.runClient(ArgSet, jail, param, strconv.FormatInt(value, 10)); err != nil {
+		return err
+	}
+	s.logger.Info(
+		"Jail parameter updated",
+		zap.String("jail", jail),
+		zap.String("param", param),
+		zap.Int64("value", value),
+	)
+	return nil
+}
+
+func (s *ControlService) AddIgnoreIP(jail, address string) error {
+	if _, err := s.runClient(ArgSet, jail, ArgAddIgnoreIP, address); err != nil {
+		return err
+	}
+	s.logger.Info("Address added to ignoreip", zap.String("jail", jail), zap.String("address", address))
+	return nil
+}
+
+func (s *ControlService) RemoveIgnoreIP(jail, address string) error {
+	if _, err := s.runClient(ArgSet, jail, ArgDelIgnoreIP, address); err != nil {
+		return err
+	}
+	s.logger.Info("Address removed from ignoreip", zap.String("jail", jail), zap.String("address", address))
+	return nil
+}
+
+// parseIgnoreIPList parses the tree-formatted output of
+// `fail2ban-client get <jail> ignoreip`.
+func parseIgnoreIPList(output string) []string {
+	list := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		matches := ignoreIPEntryRegex.FindStringSubmatch(line)
+		if len(matches) > 1 {
+			list = append(list, matches[1])
+		}
+	}
+	return list
+}
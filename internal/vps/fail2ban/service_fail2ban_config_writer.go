@@ -0,0 +1,297 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ jailConfigWriter = (*ConfigWriter)(nil)
+
+const backupTimeLayout = "20060102-150405"
+
+// ConfigWriter maintains a single jail.d file owned by the API.
+// Every write backs up the previous file next to it, keeping the newest
+// backupsKept backups, and replaces the target atomically, so fail2ban never
+// reads a half-written config. The config dir belongs to root, so files are
+// handled through sudo like every other fail2ban operation.
+type ConfigWriter struct {
+	path        string
+	backupsKept int
+	files       managedFiles
+	ignores     tempIgnoreLister
+	mu          sync.Mutex
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+func NewConfigWriter(
+	cfg config.Fail2BanConfig,
+	ignores tempIgnoreLister,
+	logger *zap.Logger,
+) *ConfigWriter {
+	return &ConfigWriter{
+		path:        filepath.Join(cfg.ConfigDir, ManagedFileSubdir, cfg.ManagedFile),
+		backupsKept: cfg.BackupsKept,
+		files:       sudoFiles{},
+		ignores:     ignores,
+		now:         time.Now,
+		logger:      logger.Named("fail2ban_config_writer"),
+	}
+}
+
+func (w *ConfigWriter) Path() string {
+	return w.path
+}
+
+// PersistJail stores the given jail values in the managed file and returns
+// the path of the backup made from the previous version (empty if none existed).
+// Temporary ignoreip entries added by self-unban are left out: they expire and
+// must not become permanent.
+func (w *ConfigWriter) PersistJail(cfg *JailConfigDTO) (string, error) {
+	if !IsValidJailName(cfg.JailName) {
+		return "", fmt.Errorf("invalid jail name %q", cfg.JailName)
+	}
+	ignoreIP, err := w.permanentIgnoreIPs(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.files.ReadFile(w.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("read managed file: %w", err)
+	}
+
+	sections := parseJailFile(current)
+	section := sections.get(cfg.JailName)
+	section.set(ParamBanTime, strconv.FormatInt(cfg.BanTime, 10))
+	section.set(ParamFindTime, strconv.FormatInt(cfg.FindTime, 10))
+	section.set(ParamMaxRetry, strconv.FormatInt(cfg.MaxRetry, 10))
+	section.set(ParamIgnoreIP, strings.Join(ignoreIP, " "))
+
+	backup := ""
+	if current != nil {
+		backup = fmt.Sprintf("%s.%s.bak", w.path, w.now().Format(backupTimeLayout))
+		if err := w.files.CopyFile(w.path, backup); err != nil {
+			return "", fmt.Errorf("write backup: %w", err)
+		}
+	}
+
+	if err := w.files.WriteFile(w.path, sections.render()); err != nil {
+		return backup, err
+	}
+	w.pruneBackups()
+
+	w.logger.Info(
+		"Jail configuration persisted",
+		zap.String("jail", cfg.JailName),
+		zap.String("path", w.path),
+		zap.String("backup", backup),
+	)
+	return backup, nil
+}
+
+func (w *ConfigWriter) permanentIgnoreIPs(cfg *JailConfigDTO) ([]string, error) {
+	temporary, err := w.ignores.GetJailTempIgnores(cfg.JailName)
+	if err != nil {
+		return nil, fmt.Errorf("load temporary ignores: %w", err)
+	}
+	return slices.DeleteFunc(
+		slices.Clone(cfg.IgnoreIP), func(ip string) bool {
+			return slices.ContainsFunc(
+				temporary, func(e sqlite3_local.TempIgnoreEntity) bool { return e.IP == ip },
+			)
+		},
+	), nil
+}
+
+// pruneBackups removes all but the newest backupsKept backups. The timestamp
+// layout sorts chronologically. Failures only leave extra backups behind.
+func (w *ConfigWriter) pruneBackups() {
+	backups, err := filepath.Glob(w.path + ".*.bak")
+	if err != nil || len(backups) <= w.backupsKept {
+		return
+	}
+	slices.Sort(backups)
+	stale := backups[:len(backups)-w.backupsKept]
+	if err := w.files.Remove(stale...); err != nil {
+		w.logger.Warn("Failed to remove old config backups", zap.Strings("backups", stale), zap.Error(err))
+	}
+}
+
+// sudoFiles reads and writes files under the fail2ban config dir as root.
+// Writes are staged in a private temp file, installed next to the target and
+// renamed over it.
+type sudoFiles struct{}
+
+func (sudoFiles) ReadFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return sudoRun(CmdCat, "--", path)
+}
+
+func (sudoFiles) WriteFile(
+	path string,
+	data []byte,
+) error {
+	tmp, err := os.CreateTemp("", "vps-control-*.local")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	staged := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if _, err := sudoRun(CmdInstall, ArgInstallMode, ManagedFileMode, ArgInstallDirs, "--", tmp.Name(), staged); err != nil {
+		return fmt.Errorf("install managed file: %w", err)
+	}
+	if _, err := sudoRun(CmdMove, ArgForce, "--", staged, path); err != nil {
+		_, _ = sudoRun(CmdRemove, ArgForce, "--", staged)
+		return fmt.Errorf("replace managed file: %w", err)
+	}
+	return nil
+}
+
+func (sudoFiles) CopyFile(src, dst string) error {
+	_, err := sudoRun(CmdCopy, ArgPreserve, "--", src, dst)
+	return err
+}
+
+func (sudoFiles) Remove(paths ...string) error {
+	_, err := sudoRun(CmdRemove, append([]string{ArgForce, "--"}, paths...)...)
+	return err
+}
+
+func sudoRun(
+	name string,
+	args ...string,
+) ([]byte, error) {
+	cmd := exec.Command(CmdSudo, append([]string{name}, args...)...) //nolint:gosec // fixed commands, paths built from config
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// writeFileAtomic replaces path directly. Only InstallAuthJail uses it, which
+// runs as an install step with write access to the config dir.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Chmod(0640); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace managed file: %w", err)
+	}
+	return nil
+}
+
+type jailSection struct {
+	name string
+	keys []string
+	vals map[string]string
+}
+
+func (s *jailSection) set(key, value string) {
+	if _, ok := s.vals[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.vals[key] = value
+}
+
+type jailFile struct {
+	sections []*jailSection
+}
+
+func (f *jailFile) get(name string) *jailSection {
+	for _, s := range f.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	s := &jailSection{name: name, vals: map[string]string{}}
+	f.sections = append(f.sections, s)
+	return s
+}
+
+// parseJailFile reads the subset of INI syntax the managed file uses:
+// section headers and single-line "key = value" pairs. Comments are dropped
+// because the file is fully regenerated on every write.
+func parseJailFile(data []byte) *jailFile {
+	f := &jailFile{}
+	var current *jailSection
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = f.get(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		current.set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	return f
+}
+
+func (f *jailFile) render() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(ManagedFileHeader + "\n")
+	for _, s := range f.sections {
+		fmt.Fprintf(&buf, "\n[%s]\n", s.name)
+		for _, k := range s.keys {
+			fmt.Fprintf(&buf, "%s = %s\n", k, s.vals[k])
+		}
+	}
+	return buf.Bytes()
+}
@@ -105,6 +105,15 @@ func (s *ControlService) UnbanIP(jail, ip string) error {
 	return nil
 }
 
+func (s *ControlService) BanIP(jail, ip string) error {
+	if _, err := s.runClient(ArgSet, jail, ArgBanIP, ip); err != nil {
+		return err
+	}
+
+	s.logger.Info("IP banned successfully", zap.String("jail", jail), zap.String("ip", ip))
+	return nil
+}
+
 func (s *ControlService) parseIntField(input, pattern string) int {
 	re := regexp.MustCompile(pattern)
 	matches := re.FindStringSubmatch(input)
@@ -0,0 +1,329 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/audit"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ blockManager = (*Escalator)(nil)
+
+// escalationQueueSize bounds the bans waiting for Run. When it is full the IP
+// goes straight to the internal block list, which needs no fail2ban call.
+const escalationQueueSize = 64
+
+var (
+	errNoEscalationJail    = errors.New("no escalation jail configured")
+	errEscalationQueueFull = errors.New("escalation queue full")
+)
+
+// Escalator counts InputSanitizer detections per IP and, once the configured
+// threshold is reached within the window, bans the IP in a fail2ban jail.
+// If fail2ban cannot ban (not running, jail missing, no jail configured),
+// the IP goes to the internal block list that the sanitizer enforces itself.
+// Bans run in Run, off the request path; allowlisted IPs are never banned.
+type Escalator struct {
+	cfg        config.EscalationConfig
+	allowlist  []*net.IPNet
+	queue      chan escalation
+	banner     ipBanner
+	store      sqlite3_local.BlockStore
+	publisher  eventPublisher
+	audit      audit.Recorder
+	mu         sync.Mutex
+	detections map[string][]time.Time
+	blocks     map[string]IPBlockDTO
+	lastSweep  time.Time
+	now        func() time.Time
+	logger     *zap.Logger
+}
+
+func NewEscalator(
+	cfg config.EscalationConfig,
+	banner ipBanner,
+	store sqlite3_local.BlockStore,
+	publisher eventPublisher,
+	ar audit.Recorder,
+	logger *zap.Logger,
+) *Escalator {
+	l := logger.Named("sanitizer_escalation")
+	allowlist, invalid := parseAllowlist(cfg.Allowlist)
+	for _, entry := range invalid {
+		l.Warn("Ignoring invalid escalation allowlist entry", zap.String("entry", entry))
+	}
+	e := &Escalator{
+		cfg:        cfg,
+		allowlist:  allowlist,
+		queue:      make(chan escalation, escalationQueueSize),
+		banner:     banner,
+		store:      store,
+		publisher:  publisher,
+		audit:      ar,
+		detections: make(map[string][]time.Time),
+		blocks:     make(map[string]IPBlockDTO),
+		now:        time.Now,
+		logger:     l,
+	}
+	e.loadBlocks()
+	return e
+}
+
+func (e *Escalator) loadBlocks() {
+	now := e.now()
+	if err := e.store.DeleteExpiredBlocks(now.Unix()); err != nil {
+		e.logger.Warn("Failed to purge expired blocks", zap.Error(err))
+	}
+	entities, err := e.store.GetActiveBlocks(now.Unix())
+	if err != nil {
+		e.logger.Error("Failed to load internal block list", zap.Error(err))
+		return
+	}
+	for _, b := range entities {
+		e.blocks[b.IP] = blockFromEntity(b)
+	}
+}
+
+// RecordDetection registers one sanitizer hit and queues a ban for Run when the
+// threshold is reached.
+func (e *Escalator) RecordDetection(
+	ip, source, path string,
+) {
+	if !e.cfg.Enabled || ip == "" {
+		return
+	}
+
+	now := e.now()
+	e.mu.Lock()
+	e.sweep(now)
+	hits := append(pruneBefore(e.detections[ip], now.Add(-e.cfg.Window)), now)
+	if len(hits) < e.cfg.Threshold {
+		e.detections[ip] = hits
+		e.mu.Unlock()
+		return
+	}
+	delete(e.detections, ip)
+	e.mu.Unlock()
+
+	esc := escalation{ip: ip, detections: len(hits), source: source, path: path}
+	select {
+	case e.queue <- esc:
+	default:
+		if !ipAllowlisted(e.allowlist, ip) {
+			e.block(esc, errEscalationQueueFull)
+		}
+	}
+}
+
+// Run bans the IPs queued by RecordDetection until ctx is cancelled.
+func (e *Escalator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case esc := <-e.queue:
+			e.escalate(esc)
+		}
+	}
+}
+
+// IsBlocked reports whether the IP is in the internal block list.
+func (e *Escalator) IsBlocked(ip string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	block, ok := e.blocks[ip]
+	if !ok {
+		return false
+	}
+	if !e.now().Before(block.ExpiresAt) {
+		delete(e.blocks, ip)
+		return false
+	}
+	return true
+}
+
+func (e *Escalator) ListBlocks() ([]IPBlockDTO, error) {
+	now := e.now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	blocks := make([]IPBlockDTO, 0, len(e.blocks))
+	for ip, b := range e.blocks {
+		if !now.Before(b.ExpiresAt) {
+			delete(e.blocks, ip)
+			continue
+		}
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].CreatedAt.After(blocks[j].CreatedAt) })
+	return blocks, nil
+}
+
+func (e *Escalator) LiftBlock(ip string) error {
+	e.mu.Lock()
+	_, cached := e.blocks[ip]
+	delete(e.blocks, ip)
+	delete(e.detections, ip)
+	e.mu.Unlock()
+
+	deleted, err := e.store.DeleteBlock(ip)
+	if err != nil {
+		return apierror.Errors.DATABASE_ERROR.Wrap(err)
+	}
+	if !cached && deleted == 0 {
+		return apierror.Errors.FAIL2BAN_BLOCK_NOT_FOUND
+	}
+
+	e.logger.Info("Internal block lifted", zap.String("ip", ip))
+	return nil
+}
+
+func (e *Escalator) escalate(esc escalation) {
+	ip := esc.ip
+	if ipAllowlisted(e.allowlist, ip) {
+		e.logger.Info("Not banning allowlisted IP", zap.String("ip", ip), zap.Int("detections", esc.detections))
+		return
+	}
+
+	banErr := errNoEscalationJail
+	if e.cfg.Jail != "" {
+		banErr = e.banner.BanIP(e.cfg.Jail, ip)
+	}
+	e.block(esc, banErr)
+}
+
+// block reports a ban; when fail2ban did not ban (banErr set) the IP goes to
+// the internal block list instead.
+func (e *Escalator) block(
+	esc escalation,
+	banErr error,
+) {
+	ip := esc.ip
+	event := SanitizerBanEvent{
+		IP:         ip,
+		Detections: esc.detections,
+		Source:     esc.source,
+		Path:       esc.path,
+	}
+	reason := esc.reason()
+
+	if banErr == nil {
+		event.Method = BlockMethodFail2Ban
+		event.Jail = e.cfg.Jail
+	} else {
+		e.logger.Warn("fail2ban ban failed, using internal block list", zap.String("ip", ip), zap.Error(banErr))
+		block := e.addBlock(ip, reason, esc.detections)
+		event.Method = BlockMethodInternal
+		event.ExpiresAt = block.ExpiresAt
+	}
+
+	e.logger.Warn(
+		"IP banned after repeated malicious input",
+		zap.String("ip", ip),
+		zap.String("method", event.Method),
+		zap.Int("detections", esc.detections),
+	)
+	e.audit.Record(
+		audit.Entry{
+			Actor:   AuditActorSanitizer,
+			Action:  AuditActionSanitizerBan,
+			Target:  ip,
+			Details: fmt.Sprintf("method=%s jail=%s %s", event.Method, event.Jail, reason),
+			IP:      ip,
+			Success: true,
+		},
+	)
+	if err := e.publisher.Publish(SubjectSanitizerBan, event); err != nil {
+		e.logger.Warn("Failed to publish sanitizer ban event", zap.Error(err))
+	}
+}
+
+func (e *Escalator) addBlock(
+	ip, reason string,
+	detections int,
+) IPBlockDTO {
+	now := e.now()
+	block := IPBlockDTO{
+		IP:         ip,
+		Reason:     reason,
+		Detections: detections,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(e.cfg.BlockTime),
+	}
+
+	e.mu.Lock()
+	e.blocks[ip] = block
+	e.mu.Unlock()
+
+	err := e.store.SaveBlock(
+		sqlite3_local.BlockEntity{
+			IP:         ip,
+			Reason:     reason,
+			Detections: detections,
+			ExpiresAt:  block.ExpiresAt.Unix(),
+			CreatedAt:  now.Unix(),
+		},
+	)
+	if err != nil {
+		e.logger.Error("Failed to persist internal block", zap.String("ip", ip), zap.Error(err))
+	}
+	return block
+}
+
+// escalation is an IP that reached the threshold and waits to be banned.
+type escalation struct {
+	ip           string
+	detections   int
+	source, path string
+}
+
+func (esc escalation) reason() string {
+	return fmt.Sprintf("sanitizer: %d detections, last %s on %s", esc.detections, esc.source, esc.path)
+}
+
+// sweep drops detection counters that fell out of the window; runs at most once per window.
+func (e *Escalator) sweep(now time.Time) {
+	if now.Sub(e.lastSweep) < e.cfg.Window {
+		return
+	}
+	e.lastSweep = now
+	cutoff := now.Add(-e.cfg.Window)
+	for ip, hits := range e.detections {
+		if hits = pruneBefore(hits, cutoff); len(hits) == 0 {
+			delete(e.detections, ip)
+		} else {
+			e.detections[ip] = hits
+		}
+	}
+}
+
+func pruneBefore(
+	hits []time.Time,
+	cutoff time.Time,
+) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
+
+func blockFromEntity(b sqlite3_local.BlockEntity) IPBlockDTO {
+	return IPBlockDTO{
+		IP:         b.IP,
+		Reason:     b.Reason,
+		Detections: b.Detections,
+		CreatedAt:  time.Unix(b.CreatedAt, 0),
+		ExpiresAt:  time.Unix(b.ExpiresAt, 0),
+	}
+}
@@ -0,0 +1,439 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/apierror"
+	"VPS-control/internal/config"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ regexTester = (*RegexTester)(nil)
+
+var regexSummaryRegex = regexp.MustCompile(ReRegexSummary)
+
+// RegexTester runs failregex/ignoreregex against sample lines or the tail of an
+// allowlisted log file, either with a builtin RE2 translation of fail2ban tags
+// or by invoking fail2ban-regex itself.
+type RegexTester struct {
+	filterDir string
+	allowlist []string
+	maxLines  int
+	timeout   time.Duration
+	logger    *zap.Logger
+}
+
+func NewRegexTester(
+	cfg config.Fail2BanConfig,
+	logger *zap.Logger,
+) *RegexTester {
+	return &RegexTester{
+		filterDir: filepath.Join(cfg.ConfigDir, FilterSubdir),
+		allowlist: cfg.RegexTest.LogAllowlist,
+		maxLines:  cfg.RegexTest.MaxLines,
+		timeout:   cfg.RegexTest.Timeout,
+		logger:    logger.Named("fail2ban_regex"),
+	}
+}
+
+func (t *RegexTester) Test(req *RegexTestInput) (*RegexTestResultDTO, error) {
+	maxLines := t.maxLines
+	if req.MaxLines > 0 && req.MaxLines < maxLines {
+		maxLines = req.MaxLines
+	}
+
+	lines, err := t.collectLines(req, maxLines)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Engine == EngineFail2BanRegex {
+		return t.runNative(req, lines)
+	}
+	return t.runBuiltin(req, lines)
+}
+
+func (t *RegexTester) runBuiltin(
+	req *RegexTestInput,
+	lines []string,
+) (*RegexTestResultDTO, error) {
+	failRaw, ignoreRaw := req.FailRegex, req.IgnoreRegex
+	if req.Filter != "" {
+		def, err := loadFilter(t.filterDir, req.Filter)
+		if err != nil {
+			if errors.Is(err, ErrFilterNotFound) {
+				return nil, apierror.Errors.FAIL2BAN_FILTER_NOT_FOUND
+			}
+			return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta(err.Error())
+		}
+		failRaw = def.FailRegex
+		if ignoreRaw == "" {
+			ignoreRaw = def.IgnoreRegex
+		}
+	} else {
+		var err error
+		if failRaw, err = interpolate(failRaw, builtinFilterVars); err != nil {
+			return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta(err.Error())
+		}
+		if ignoreRaw, err = interpolate(ignoreRaw, builtinFilterVars); err != nil {
+			return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta(err.Error())
+		}
+	}
+
+	failPatterns, err := compilePatterns(failRaw)
+	if err != nil {
+		return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta(err.Error())
+	}
+	if len(failPatterns) == 0 {
+		return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta("no failregex provided")
+	}
+	ignorePatterns, err := compilePatterns(ignoreRaw)
+	if err != nil {
+		return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta(err.Error())
+	}
+
+	started := time.Now()
+	res := newRegexTestResult(EngineBuiltin, len(lines))
+	for _, p := range failPatterns {
+		res.FailRegex = append(res.FailRegex, p.source)
+	}
+
+	for i, line := range lines {
+		stripped := stripDate(line)
+		if matchesAny(ignorePatterns, stripped) {
+			res.IgnoredCount++
+			continue
+		}
+		for idx, p := range failPatterns {
+			matches := p.re.FindStringSubmatch(stripped)
+			if matches == nil {
+				continue
+			}
+			res.MatchedCount++
+			res.addMatch(
+				RegexMatchDTO{
+					LineNumber: i + 1,
+					Line:       line,
+					Host:       extractHost(p.re, matches),
+					RegexIndex: idx + 1,
+				},
+			)
+			break
+		}
+	}
+	res.MissedCount = res.TotalLines - res.MatchedCount - res.IgnoredCount
+	res.DurationMs = time.Since(started).Milliseconds()
+	return res, nil
+}
+
+// runNative feeds the collected lines to fail2ban-regex through a temporary file.
+// Hosts are extracted with the builtin translation when the pattern allows it.
+// User patterns never reach the argv of the root process: they are written to a
+// generated filter file, so they cannot name a file for fail2ban-regex to read
+// or be parsed as options.
+func (t *RegexTester) runNative(
+	req *RegexTestInput,
+	lines []string,
+) (*RegexTestResultDTO, error) {
+	logFile, err := writeTempLines(lines)
+	if err != nil {
+		return nil, apierror.Errors.INTERNAL_ERROR.Wrap(err)
+	}
+	defer func() { _ = os.Remove(logFile) }()
+
+	filterFile := ""
+	if req.Filter != "" {
+		filterFile = filepath.Join(t.filterDir, req.Filter+".conf")
+		if _, err := os.Stat(filterFile); err != nil {
+			return nil, apierror.Errors.FAIL2BAN_FILTER_NOT_FOUND
+		}
+	}
+	// A named filter keeps its own ignoreregex unless the request overrides it,
+	// which needs a second, generated filter layered on top of it.
+	if req.Filter == "" || req.IgnoreRegex != "" {
+		generated, err := writeTempFilter(filterFile, req.FailRegex, req.IgnoreRegex)
+		if err != nil {
+			return nil, apierror.Errors.INTERNAL_ERROR.Wrap(err)
+		}
+		defer func() { _ = os.Remove(generated) }()
+		filterFile = generated
+	}
+	args := []string{CmdFail2BanRegex, ArgPrintAllMatched, "--", logFile, filterFile}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	started := time.Now()
+	out, err := exec.CommandContext(ctx, CmdSudo, args...).CombinedOutput() //nolint:gosec // all paths are generated or allowlisted
+	duration := time.Since(started)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, apierror.Errors.FAIL2BAN_EXECUTION_ERROR.Wrap(fmt.Errorf("fail2ban-regex timed out after %v", t.timeout))
+		}
+		t.logger.Warn("fail2ban-regex failed", zap.String("output", strings.TrimSpace(string(out))), zap.Error(err))
+		return nil, apierror.Errors.FAIL2BAN_INVALID_REGEX.WithMeta("fail2ban-regex rejected the patterns").Wrap(err)
+	}
+
+	res := parseNativeOutput(string(out), lines)
+	res.DurationMs = duration.Milliseconds()
+	res.FailRegex = []string{req.FailRegex}
+	if req.Filter != "" {
+		res.FailRegex = []string{filepath.Join(t.filterDir, req.Filter+".conf")}
+	}
+
+	if raw, err := interpolate(req.FailRegex, builtinFilterVars); err == nil && req.Filter == "" {
+		if patterns, err := compilePatterns(raw); err == nil {
+			for i := range res.Matches {
+				stripped := stripDate(res.Matches[i].Line)
+				for idx, p := range patterns {
+					if m := p.re.FindStringSubmatch(stripped); m != nil {
+						res.Matches[i].Host = extractHost(p.re, m)
+						res.Matches[i].RegexIndex = idx + 1
+						break
+					}
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// parseNativeOutput extracts the summary and matched lines printed by
+// `fail2ban-regex --print-all-matched`.
+func parseNativeOutput(
+	output string,
+	lines []string,
+) *RegexTestResultDTO {
+	res := newRegexTestResult(EngineFail2BanRegex, len(lines))
+
+	if m := regexSummaryRegex.FindStringSubmatch(output); len(m) == 5 {
+		res.TotalLines, _ = strconv.Atoi(m[1])
+		res.IgnoredCount, _ = strconv.Atoi(m[2])
+		res.MatchedCount, _ = strconv.Atoi(m[3])
+		res.MissedCount, _ = strconv.Atoi(m[4])
+	}
+
+	lineNumbers := make(map[string]int, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		lineNumbers[lines[i]] = i + 1
+	}
+
+	inMatched := false
+	for _, raw := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(raw, OutMatchedLinesHeader):
+			inMatched = true
+		case inMatched && strings.HasPrefix(raw, OutMatchedLinePrefix):
+			line := strings.TrimPrefix(raw, OutMatchedLinePrefix)
+			res.addMatch(RegexMatchDTO{LineNumber: lineNumbers[line], Line: line})
+		case inMatched:
+			inMatched = false
+		}
+	}
+	return res
+}
+
+func (t *RegexTester) collectLines(
+	req *RegexTestInput,
+	maxLines int,
+) ([]string, error) {
+	if req.LogPath != "" {
+		path, ok := t.resolveAllowedLog(req.LogPath)
+		if !ok {
+			return nil, apierror.Errors.FAIL2BAN_LOG_NOT_ALLOWED
+		}
+		lines, err := readTailLines(path, maxLines, int64(maxLines)*RegexTestMaxLineBytes)
+		if err != nil {
+			return nil, apierror.Errors.FAIL2BAN_EXECUTION_ERROR.Wrap(err)
+		}
+		return lines, nil
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(req.Lines, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return lines, nil
+}
+
+// resolveAllowedLog accepts a path only if it resolves to the same file
+// as one of the configured allowlist entries.
+func (t *RegexTester) resolveAllowedLog(path string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", false
+	}
+	for _, allowed := range t.allowlist {
+		allowedResolved, err := filepath.EvalSymlinks(filepath.Clean(allowed))
+		if err != nil {
+			continue
+		}
+		if resolved == allowedResolved {
+			return resolved, true
+		}
+	}
+	return "", false
+}
+
+// readTailLines returns up to maxLines last lines of a file, reading at most maxBytes.
+func readTailLines(
+	path string,
+	maxLines int,
+	maxBytes int64,
+) ([]string, error) {
+	f, err := os.Open(path) //nolint:gosec // path checked against allowlist
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	if info.Size() > maxBytes {
+		offset = info.Size() - maxBytes
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			data = data[idx+1:]
+		}
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return lines, nil
+}
+
+func writeTempLines(lines []string) (string, error) {
+	f, err := os.CreateTemp("", "vps-control-regex-*.log")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// writeTempFilter writes a filter definition for fail2ban-regex. With base
+// set it extends that filter and only overrides ignoreregex. Patterns are
+// taken literally: '%' is escaped so they are not interpolated.
+func writeTempFilter(base, failRegex, ignoreRegex string) (string, error) {
+	var b strings.Builder
+	if base != "" {
+		fmt.Fprintf(&b, "[INCLUDES]\nbefore = %s\n\n", base)
+	}
+	b.WriteString("[Definition]\n")
+	if base == "" {
+		writeFilterOption(&b, "failregex", failRegex)
+	}
+	writeFilterOption(&b, "ignoreregex", ignoreRegex)
+
+	f, err := os.CreateTemp("", "vps-control-filter-*.conf")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// writeFilterOption writes one pattern per line; continuation lines are
+// indented so no pattern can start a new option or section.
+func writeFilterOption(
+	b *strings.Builder,
+	key, raw string,
+) {
+	b.WriteString(key + " =")
+	first := true
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !first {
+			b.WriteString("\n   ")
+		}
+		b.WriteString(" " + strings.ReplaceAll(line, "%", "%%"))
+		first = false
+	}
+	b.WriteString("\n")
+}
+
+func matchesAny(
+	patterns []compiledPattern,
+	line string,
+) bool {
+	for _, p := range patterns {
+		if p.re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func newRegexTestResult(
+	engine string,
+	total int,
+) *RegexTestResultDTO {
+	return &RegexTestResultDTO{
+		Engine:     engine,
+		TotalLines: total,
+		FailRegex:  []string{},
+		Matches:    []RegexMatchDTO{},
+	}
+}
+
+func (r *RegexTestResultDTO) addMatch(m RegexMatchDTO) {
+	if len(r.Matches) >= RegexTestMaxMatches {
+		r.Truncated = true
+		return
+	}
+	r.Matches = append(r.Matches, m)
+}
@@ -0,0 +1,195 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/audit"
+	"VPS-control/internal/config"
+	"VPS-control/internal/database/sqlite3_local"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ selfUnbanner = (*SelfUnbanService)(nil)
+
+// SelfUnbanService lets an operator lift bans on their own address in every
+// jail and optionally keeps the address in ignoreip for a limited time.
+// Temporary ignores are stored in SQLite and removed by Run after they expire.
+type SelfUnbanService struct {
+	control        fail2banControl
+	store          sqlite3_local.TempIgnoreStore
+	audit          audit.Recorder
+	ignoreDuration time.Duration
+	now            func() time.Time
+	logger         *zap.Logger
+}
+
+func NewSelfUnbanService(
+	cfg config.SelfUnbanConfig,
+	control fail2banControl,
+	store sqlite3_local.TempIgnoreStore,
+	ar audit.Recorder,
+	logger *zap.Logger,
+) *SelfUnbanService {
+	return &SelfUnbanService{
+		control:        control,
+		store:          store,
+		audit:          ar,
+		ignoreDuration: cfg.IgnoreDuration,
+		now:            time.Now,
+		logger:         logger.Named("fail2ban_self_unban"),
+	}
+}
+
+func (s *SelfUnbanService) UnbanSelf(
+	ip, username string,
+	ignore bool,
+) (*SelfUnbanResponse, error) {
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("client address %q is not an IP", ip)
+	}
+
+	status, err := s.control.GetGlobalStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SelfUnbanResponse{
+		IP:           ip,
+		CheckedJails: len(status.JailList),
+		UnbannedFrom: []string{},
+		Jails:        []SelfUnbanJailResult{},
+	}
+	var expiresAt time.Time
+	if ignore {
+		expiresAt = s.now().Add(s.ignoreDuration)
+	}
+
+	for _, jail := range status.JailList {
+		jr := SelfUnbanJailResult{Jail: jail}
+		if err := s.unbanInJail(ip, jail, &jr); err != nil {
+			jr.Error = err.Error()
+		} else if ignore {
+			if err := s.ignoreInJail(ip, jail, username, expiresAt, &jr); err != nil {
+				jr.Error = err.Error()
+			}
+		}
+		if jr.Unbanned {
+			res.UnbannedFrom = append(res.UnbannedFrom, jail)
+		}
+		if jr.Unbanned || jr.Ignored || jr.Error != "" {
+			res.Jails = append(res.Jails, jr)
+		}
+	}
+	if ignore {
+		res.IgnoreUntil = &expiresAt
+	}
+
+	s.logger.Info(
+		"Self unban processed",
+		zap.String("ip", ip),
+		zap.String("username", username),
+		zap.Strings("unbanned_from", res.UnbannedFrom),
+		zap.Bool("ignore", ignore),
+	)
+	return res, nil
+}
+
+func (s *SelfUnbanService) unbanInJail(
+	ip, jail string,
+	jr *SelfUnbanJailResult,
+) error {
+	details, err := s.control.GetJailDetails(jail)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(details.BannedIPList, ip) {
+		return nil
+	}
+	if err := s.control.UnbanIP(jail, ip); err != nil {
+		return err
+	}
+	jr.Unbanned = true
+	return nil
+}
+
+// ignoreInJail adds the IP to ignoreip unless it is already there; addresses
+// that were ignored before are left alone so expiry never removes them.
+func (s *SelfUnbanService) ignoreInJail(
+	ip, jail, username string,
+	expiresAt time.Time,
+	jr *SelfUnbanJailResult,
+) error {
+	current, err := s.control.GetIgnoreIPs(jail)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(current, ip) {
+		return nil
+	}
+	if err := s.control.AddIgnoreIP(jail, ip); err != nil {
+		return err
+	}
+	jr.Ignored = true
+
+	return s.store.SaveTempIgnore(
+		sqlite3_local.TempIgnoreEntity{
+			IP:        ip,
+			Jail:      jail,
+			Username:  username,
+			ExpiresAt: expiresAt.Unix(),
+		},
+	)
+}
+
+// Run removes expired temporary ignores until ctx is cancelled.
+func (s *SelfUnbanService) Run(ctx context.Context) {
+	ticker := time.NewTicker(tempIgnoreSweepInterval)
+	defer ticker.Stop()
+	for {
+		s.expireIgnores()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SelfUnbanService) expireIgnores() {
+	expired, err := s.store.GetExpiredTempIgnores(s.now().Unix())
+	if err != nil {
+		s.logger.Error("Failed to load expired temporary ignores", zap.Error(err))
+		return
+	}
+
+	for _, e := range expired {
+		// Адрес мог пропасть из ignoreip после reload fail2ban, это не ошибка
+		removeErr := s.control.RemoveIgnoreIP(e.Jail, e.IP)
+		if removeErr != nil {
+			s.logger.Warn(
+				"Failed to remove temporary ignoreip",
+				zap.String("jail", e.Jail),
+				zap.String("ip", e.IP),
+				zap.Error(removeErr),
+			)
+		}
+		if err := s.store.DeleteTempIgnore(e.IP, e.Jail); err != nil {
+			s.logger.Error("Failed to delete temporary ignore", zap.String("ip", e.IP), zap.Error(err))
+			continue
+		}
+		s.audit.Record(
+			audit.Entry{
+				Actor:   e.Username,
+				Action:  AuditActionIgnoreExpired,
+				Target:  e.Jail,
+				Details: "ip=" + e.IP,
+				IP:      e.IP,
+				Success: removeErr == nil,
+			},
+		)
+	}
+}
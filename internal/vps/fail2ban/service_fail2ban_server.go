@@ -0,0 +1,151 @@
+package fail2ban
+
+import (
+	"VPS-control/internal/apierror"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var versionRegex = regexp.MustCompile(ReVersion)
+
+func (s *ControlService) ReloadAll() (*CommandResultDTO, error) {
+	return s.reload(ArgReload)
+}
+
+func (s *ControlService) ReloadJail(jail string) (*CommandResultDTO, error) {
+	return s.reload(ArgReload, jail)
+}
+
+func (s *ControlService) StartJail(jail string) (*CommandResultDTO, error) {
+	result, output, err := s.execute(ArgStart, jail)
+	if err != nil {
+		if strings.Contains(output, ErrOutputAlreadyExists) {
+			return result, apierror.Errors.FAIL2BAN_JAIL_ALREADY_RUNNING.WithMeta(result)
+		}
+		return result, classifyFailure(result, output, err)
+	}
+	s.logger.Info("Jail started", zap.String("jail", jail), zap.Int64("duration_ms", result.DurationMs))
+	return result, nil
+}
+
+func (s *ControlService) StopJail(jail string) (*CommandResultDTO, error) {
+	result, output, err := s.execute(ArgStop, jail)
+	if err != nil {
+		return result, classifyFailure(result, output, err)
+	}
+	s.logger.Info("Jail stopped", zap.String("jail", jail), zap.Int64("duration_ms", result.DurationMs))
+	return result, nil
+}
+
+func (s *ControlService) Ping() (*ServerPingDTO, error) {
+	result, output, err := s.execute(ArgPing)
+	if err != nil {
+		return nil, classifyFailure(result, output, err)
+	}
+	return &ServerPingDTO{
+		Alive:  strings.Contains(output, OutServerPong),
+		Result: result,
+	}, nil
+}
+
+func (s *ControlService) Version() (*ServerVersionDTO, error) {
+	result, output, err := s.execute(ArgVersion)
+	if err != nil {
+		return nil, classifyFailure(result, output, err)
+	}
+	res := &ServerVersionDTO{Result: result}
+	if matches := versionRegex.FindStringSubmatch(output); len(matches) > 1 {
+		res.Version = matches[1]
+	}
+	return res, nil
+}
+
+func (s *ControlService) reload(args ...string) (*CommandResultDTO, error) {
+	result, output, err := s.execute(args...)
+	if err != nil {
+		var appErr *apierror.AppError
+		if errors.As(classifyFailure(result, output, err), &appErr) &&
+			appErr.Code != apierror.Errors.FAIL2BAN_EXECUTION_ERROR.Code {
+			return result, appErr
+		}
+		return result, apierror.Errors.FAIL2BAN_RELOAD_FAILED.WithMeta(result).Wrap(err)
+	}
+	s.logger.Info(
+		"Fail2Ban reloaded",
+		zap.String("command", result.Command),
+		zap.Int64("duration_ms", result.DurationMs),
+	)
+	return result, nil
+}
+
+// runClient executes fail2ban-client and maps well-known failure outputs to API errors.
+func (s *ControlService) runClient(args ...string) (string, error) {
+	result, output, err := s.execute(args...)
+	if err != nil {
+		return output, classifyFailure(result, output, err)
+	}
+	return output, nil
+}
+
+// execute runs fail2ban-client with the given arguments and captures its output
+// as a CommandResultDTO. A run that exits successfully but prints ERROR lines
+// is reported as failed.
+func (s *ControlService) execute(args ...string) (*CommandResultDTO, string, error) {
+	started := time.Now()
+	cmdArgs := append([]string{CmdFail2Ban}, args...)
+	out, err := exec.Command(CmdSudo, cmdArgs...).CombinedOutput() //nolint:gosec // arguments are validated by handlers
+	output := string(out)
+
+	result := &CommandResultDTO{
+		Command:    strings.Join(args, " "),
+		Output:     []string{},
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		result.Output = append(result.Output, line)
+		if strings.Contains(line, OutErrorMarker) {
+			result.Errors = append(result.Errors, line)
+		}
+	}
+
+	if err == nil && len(result.Errors) > 0 {
+		err = errors.New(result.Errors[0])
+	}
+	result.Success = err == nil
+
+	if err != nil {
+		s.logger.Warn(
+			"fail2ban-client command failed",
+			zap.String("command", result.Command),
+			zap.Strings("errors", result.Errors),
+			zap.Error(err),
+		)
+	}
+	return result, output, err
+}
+
+func classifyFailure(
+	result *CommandResultDTO,
+	output string,
+	err error,
+) error {
+	switch {
+	case strings.Contains(output, ErrOutputSocket) || strings.Contains(output, ErrOutputNotRunning):
+		return apierror.Errors.FAIL2BAN_SERVER_UNAVAILABLE.WithMeta(result).Wrap(err)
+	case strings.Contains(output, ErrOutputJailNotFound) ||
+		strings.Contains(output, ErrOutputDoesNotExist) ||
+		strings.Contains(output, ErrOutputNotFound):
+		return apierror.Errors.FAIL2BAN_JAIL_NOT_FOUND
+	}
+	return apierror.Errors.FAIL2BAN_EXECUTION_ERROR.WithMeta(result).Wrap(fmt.Errorf("%w: %s", err, output))
+}
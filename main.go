@@ -1,9 +1,13 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 
 	"VPS-control/internal/config"
+	"VPS-control/internal/vps"
+	"VPS-control/internal/vps/fail2ban"
 
 	_ "VPS-control/docs"
 
@@ -20,6 +24,8 @@ import (
 // @in cookie
 // @name discord_bot_auth
 // @description HTTP-only cookie with JWT token (set automatically after login)
+// @securityDefinitions.basic IntrospectionClient
+// @description Service client ID and secret from auth.introspection.clients
 
 func main() {
 	_ = godotenv.Load(".env.dev")
@@ -29,8 +35,47 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 	logger := InitLogger(cfg.Server.Debug)
+
+	if len(os.Args) > 1 && os.Args[1] == fail2ban.CmdInstallJail {
+		runInstallFail2Ban(cfg, logger, os.Args[2:])
+		return
+	}
+
 	app := initApp(cfg, logger)
 	if err := app.Run(); err != nil {
 		logger.Fatal("Application terminated with error", zap.Error(err))
 	}
 }
+
+// runInstallFail2Ban installs the filter and jail for the API's failed-login log.
+// Usage: VPS-control install-fail2ban [-reload]
+func runInstallFail2Ban(
+	cfg *config.Config,
+	logger *zap.Logger,
+	args []string,
+) {
+	fs := flag.NewFlagSet(fail2ban.CmdInstallJail, flag.ExitOnError)
+	reload := fs.Bool("reload", false, "reload fail2ban after installing the jail")
+	_ = fs.Parse(args)
+
+	res, err := fail2ban.InstallAuthJail(cfg.Fail2Ban)
+	if err != nil {
+		logger.Fatal("Failed to install fail2ban auth jail", zap.Error(err))
+	}
+	logger.Info(
+		"Fail2ban auth jail installed",
+		zap.String("jail", res.Jail),
+		zap.String("filter", res.FilterPath),
+		zap.String("jail_file", res.JailPath),
+		zap.String("log", res.LogPath),
+	)
+
+	if !*reload {
+		return
+	}
+	result, err := fail2ban.NewControlService(vps.NewBaseVpsService(), logger).ReloadAll()
+	if err != nil {
+		logger.Fatal("Failed to reload fail2ban", zap.Error(err))
+	}
+	logger.Info("Fail2ban reloaded", zap.Strings("output", result.Output))
+}
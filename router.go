@@ -2,6 +2,7 @@ package main
 
 import (
 	"VPS-control/internal"
+	"VPS-control/internal/config"
 	"VPS-control/internal/middleware"
 	"time"
 
@@ -12,10 +13,18 @@ import (
 	"go.uber.org/zap"
 )
 
-func setupRouter(logger *zap.Logger) *gin.Engine {
+func setupRouter(
+	cfg config.ServerConfig,
+	logger *zap.Logger,
+) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 
+	// ClientIP() берёт X-Forwarded-For только от перечисленных прокси
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	r.Use(ginzap.Ginzap(logger.Named("http"), time.RFC3339, true))
 	r.Use(ginzap.RecoveryWithZap(logger, true))
 	r.Use(middleware.SecurityHeadersMiddleware())
@@ -36,8 +45,12 @@ func (app *application) registerRoutes(r *gin.Engine) {
 		),
 	)
 
-	authMW := middleware.AuthMiddleware(app.authJwt, app.authCookie, app.tokenRepo, app.logger)
-	internal.RegisterAuthRoutes(authGroup, app.authHdl, authMW)
+	authMW := middleware.AuthMiddleware(
+		app.authJwt, app.authCookie, app.tokenRepo, app.apiKeys, app.permCache, app.logger,
+	)
+	// Опасные операции дополнительно требуют недавнего повторного входа
+	stepUp := middleware.RequireRecentAuth(app.tokenRepo, app.cfg.Auth.StepUp.Window)
+	internal.RegisterAuthRoutes(authGroup, app.authHdl, authMW, stepUp)
 
 	vpsGroup := api.Group("/vps")
 	vpsGroup.Use(
@@ -48,8 +61,20 @@ func (app *application) registerRoutes(r *gin.Engine) {
 	)
 	vpsGroup.Use(authMW)
 
-	internal.RegisterPM2Routes(vpsGroup, app.pm2Hdl)
-	internal.RegisterFail2BanRoutes(vpsGroup, app.f2bHdl)
+	internal.RegisterPM2Routes(vpsGroup, app.pm2Hdl, stepUp)
+	internal.RegisterFail2BanRoutes(vpsGroup, app.f2bHdl, stepUp)
+
+	usersGroup := api.Group("")
+	usersGroup.Use(
+		middleware.RateLimitMiddleware(
+			app.cfg.RateLimit.API.Limit,
+			app.cfg.RateLimit.API.Window,
+		),
+	)
+	usersGroup.Use(authMW)
+
+	internal.RegisterUserRoutes(usersGroup, app.usersHdl, stepUp)
 
+	r.GET("/.well-known/jwks.json", app.authHdl.JWKS)
 	r.GET("/api/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }